@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"gmail-tui/internal/app"
+)
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), `Usage:
+  gmail-tui [--no-browser] [--inline] [--reduced-motion] [--accessible]
+            [--demo] [--debug]         start the interactive client
+  gmail-tui list [--query Q] [--max N] [--output text|json]
+                                       list messages
+  gmail-tui search [--max N] [--output text|json] QUERY
+                                       list messages matching QUERY
+  gmail-tui send --to ADDR [--cc ADDR] [--bcc ADDR] [--from ADDR]
+                 [--subject S] [--body-file FILE|-] [--attach FILE]...
+                 [--markdown] [--sign] [--encrypt]
+                                       send a message
+  gmail-tui labels [--output text|json]
+                                       list labels
+  gmail-tui save-attachments [--dir DIR] ID
+                                       save a message's attachments and
+                                       print their paths
+  gmail-tui export [--format eml|mbox|maildir|txt|pdf]
+                   [--out PATH] [--query Q [--max N] | ID...]
+                                       export messages for backup, other
+                                       mail clients or printing
+  gmail-tui sync [--maildir PATH] [--max N] [--notmuch]
+                                       sync all mail into a Maildir and
+                                       tag it with notmuch
+  gmail-tui status [--format F] [--max-age D] [--output text|json]
+                                       print the unread count and newest
+                                       unread subject for status lines
+  gmail-tui daemon                     serve mail on a Unix socket for the
+                                       app and commands to share
+  gmail-tui mailto:ADDR?subject=...    start by composing to ADDR
+  gmail-tui install-mailto-handler     make gmail-tui the mailto: handler
+                                       (Linux)
+
+--json is short for --output json, which prints one JSON object per line.
+
+Flags:
+`)
+	flag.PrintDefaults()
+}
+
+func main() {
+	var opts app.Options
+	flag.BoolVar(&opts.NoBrowser, "no-browser", false, "sign in by pasting a code instead of opening a local browser, e.g. over SSH")
+	flag.BoolVar(&opts.Inline, "inline", false, "draw in the normal screen instead of the alternate one, so the session stays in the scrollback")
+	flag.BoolVar(&opts.ReducedMotion, "reduced-motion", false, "show a static marker instead of the animated spinner, e.g. for screen readers or slow terminals")
+	flag.BoolVar(&opts.Accessible, "accessible", false, "announce changes as plain lines for screen readers; implies --inline and --reduced-motion")
+	flag.BoolVar(&opts.Demo, "demo", false, "use a mailbox of sample messages kept in memory instead of a mail account")
+	flag.BoolVar(&opts.Debug, "debug", false, "log API calls, their latency, UI messages and errors to debug.log in $XDG_STATE_HOME/gmail-tui")
+	flag.Usage = usage
+	flag.Parse()
+	log.SetOutput(os.Stderr)
+
+	if err := app.Run(opts, flag.Args()); err != nil {
+		log.Fatal(err)
+	}
+}
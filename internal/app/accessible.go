@@ -0,0 +1,200 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/paginator"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"gmail-tui/internal/ui/view"
+)
+
+// plainPagers shows the lists' pages as "1/3" rather than as dots that
+// differ only in color.
+func (m Model) plainPagers() Model {
+	for _, l := range []*list.Model{&m.list, &m.drafts, &m.filters, &m.signatures, &m.scheduled, &m.contactList} {
+		l.Paginator.Type = paginator.Arabic
+	}
+	return m
+}
+
+// Update runs update and, in accessible mode, prints what changed as plain
+// lines above the screen, where a screen reader follows them in order.
+func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	start := time.Now()
+	next, cmd := m.update(msg)
+	cmd = retryable(cmd)
+	if after, ok := next.(Model); ok {
+		logMsg(msg, time.Since(start), m, after)
+	}
+	if !view.Accessible {
+		return next, cmd
+	}
+	if after, ok := next.(Model); ok {
+		if lines := announce(m, after); len(lines) > 0 {
+			cmd = tea.Batch(cmd, tea.Println(strings.Join(lines, "\n")))
+		}
+	}
+	return next, cmd
+}
+
+// announce describes the change from before to after: a new screen or
+// prompt, a finished load, the row the cursor moved to, and the status.
+func announce(before, after Model) []string {
+	var lines []string
+	screen := screenName(after)
+	if screen != screenName(before) {
+		lines = append(lines, screen)
+	}
+
+	if kind, text := promptOf(after); kind != "" {
+		if was, _ := promptOf(before); was != kind {
+			lines = append(lines, text)
+		}
+	}
+
+	l, lb := activeList(after), activeList(before)
+	switch {
+	case l == nil || after.loading:
+	case before.loading || screen != screenName(before):
+		lines = append(lines, fmt.Sprintf("%d items", len(l.VisibleItems())))
+		if row := describeRow(l); row != "" {
+			lines = append(lines, row)
+		}
+	case lb != nil && (l.Index() != lb.Index() || describeRow(l) != describeRow(lb)):
+		if row := describeRow(l); row != "" {
+			lines = append(lines, row)
+		}
+	}
+
+	if after.status != "" && after.status != before.status {
+		lines = append(lines, after.status)
+	}
+	return lines
+}
+
+// screenName names the screen m is showing.
+func screenName(m Model) string {
+	switch m.state {
+	case messageView:
+		if m.selectedMail == nil {
+			return "Message"
+		}
+		return fmt.Sprintf("Message: %s, from %s", m.selectedMail.Subject, m.selectedMail.From)
+	case draftsView:
+		return "Drafts"
+	case composeView:
+		return "Compose"
+	case filtersView:
+		return "Filters"
+	case vacationView:
+		return "Vacation responder"
+	case signaturesView:
+		return "Signatures"
+	case scheduledView:
+		return "Outbox"
+	case contactsView:
+		if m.contact != nil {
+			return "Contact: " + m.contact.card.Title()
+		}
+		return "Contacts"
+	}
+	return "List: " + m.list.Title
+}
+
+// promptOf names the prompt or popup open in m, with the text to announce
+// when it opens.
+func promptOf(m Model) (kind, text string) {
+	switch {
+	case m.reauth != nil:
+		return "reauth", "Sign in again"
+	case m.failure != nil && m.failure.details != nil:
+		return "failure details", "Error details. esc to go back"
+	case m.failure != nil:
+		return "failure: " + m.failure.err.Error(), m.failurePrompt()
+	case m.helpScreen != nil:
+		return "help", "Key bindings. Type to filter, esc to close"
+	case m.palette != nil:
+		return "palette", "Command prompt"
+	case m.rsvp != nil:
+		return "rsvp", m.rsvpPrompt()
+	case m.unsubscribe != nil:
+		return "unsubscribe", m.unsubscribePrompt()
+	case m.confirmation != nil:
+		return "confirmation: " + m.confirmation.prompt, m.confirmation.prompt
+	case m.quitting:
+		return "quit", m.quitPrompt()
+	case m.snooze != nil:
+		return "snooze", m.snoozePrompt()
+	case m.followUp != nil:
+		return "followUp", m.followUpPrompt()
+	case m.purge != nil:
+		return "purge", m.purgePrompt()
+	case m.profile != nil:
+		return "profile", "Sender profile: " + m.profile.address
+	}
+	return "", ""
+}
+
+// activeList is the list on m's screen, if it shows one.
+func activeList(m Model) *list.Model {
+	switch {
+	case m.state == listView:
+		return &m.list
+	case m.state == draftsView:
+		return &m.drafts
+	case m.state == filtersView && m.filterForm == nil:
+		return &m.filters
+	case m.state == signaturesView:
+		return &m.signatures
+	case m.state == scheduledView:
+		return &m.scheduled
+	case m.state == contactsView && m.contact == nil:
+		return &m.contactList
+	}
+	return nil
+}
+
+// describeRow reads out the selected row with its position.
+func describeRow(l *list.Model) string {
+	var text string
+	switch item := l.SelectedItem().(type) {
+	case Email:
+		text = describeEmail(item)
+	case list.DefaultItem:
+		text = item.Title() + ", " + item.Description()
+	default:
+		return ""
+	}
+	return fmt.Sprintf("%d of %d: %s", l.Index()+1, len(l.VisibleItems()), text)
+}
+
+// describeEmail spells out a message's subject, sender (or recipients, for
+// mail you sent), date and flags.
+func describeEmail(e Email) string {
+	subject := e.Subject
+	if subject == "" {
+		subject = "(no subject)"
+	}
+	role, who := e.correspondent()
+	parts := []string{subject, strings.ToLower(role) + " " + who, view.ListDate(e.Date)}
+	for _, f := range []struct{ label, word string }{
+		{"UNREAD", "unread"},
+		{"STARRED", "starred"},
+		{"IMPORTANT", "important"},
+	} {
+		if e.hasLabel(f.label) {
+			parts = append(parts, f.word)
+		}
+	}
+	if e.hasFiles() {
+		parts = append(parts, "attachment")
+	}
+	if e.muted {
+		parts = append(parts, "muted")
+	}
+	return strings.Join(parts, ", ")
+}
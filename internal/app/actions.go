@@ -0,0 +1,174 @@
+package app
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"google.golang.org/api/gmail/v1"
+)
+
+// confirmation is a yes/no prompt guarding an action.
+type confirmation struct {
+	prompt string
+	cmd    tea.Cmd
+}
+
+// labelsChangedMsg reports a successful label change on a message. When
+// removed is set the message no longer belongs in the current list. undo
+// reverses the change.
+type labelsChangedMsg struct {
+	email   Email
+	removed bool
+	status  string
+	undo    *undoStep
+}
+
+type filterCreatedMsg string
+
+func (m Model) confirm(prompt string, cmd tea.Cmd) Model {
+	m.confirmation = &confirmation{prompt: prompt, cmd: cmd}
+	return m
+}
+
+func (m Model) updateConfirmation(msg tea.KeyMsg) (Model, tea.Cmd) {
+	c := m.confirmation
+	m.confirmation = nil
+	if key.Matches(msg, m.keys.Confirm) {
+		return m, c.cmd
+	}
+	m.status = "Cancelled"
+	return m, nil
+}
+
+// modifyLabels adds and removes labels on e.
+func (m Model) modifyLabels(e Email, add, remove []string, removed bool, status string) tea.Cmd {
+	return func() tea.Msg {
+		msg, err := m.mail.Modify(m.ctx, e.ID, add, remove)
+		if err != nil {
+			return errMsg(fmt.Errorf("unable to update message: %w", err))
+		}
+		undo := reverseOf(e, msg.LabelIds, status)
+		undo.id = msg.Id
+		e.Labels = msg.LabelIds
+		return labelsChangedMsg{email: e, removed: removed, status: status, undo: undo}
+	}
+}
+
+// archive removes e from the inbox, running the on_archive hook once it
+// has been.
+func (m Model) archive(e Email) tea.Cmd {
+	return withArchiveHook(m.config, e, m.modifyLabels(e, nil, []string{"INBOX"}, true, "Archived"))
+}
+
+// withArchiveHook runs the on_archive hook for e after cmd, a label change
+// taking it out of the inbox, succeeds.
+func withArchiveHook(c Config, e Email, cmd tea.Cmd) tea.Cmd {
+	return func() tea.Msg {
+		msg := cmd()
+		if changed, ok := msg.(labelsChangedMsg); ok && e.hasLabel("INBOX") {
+			runHook("archive", c.Hooks.OnArchive, emailEvent(changed.email))
+		}
+		return msg
+	}
+}
+
+// toggleSpam reports e as spam, or moves it back to the inbox if it is
+// already in Spam.
+func (m Model) toggleSpam(e Email) tea.Cmd {
+	if e.hasLabel("SPAM") {
+		return m.modifyLabels(e, []string{"INBOX"}, []string{"SPAM"}, true, "Moved to inbox")
+	}
+	return m.modifyLabels(e, []string{"SPAM"}, []string{"INBOX"}, true, "Reported as spam")
+}
+
+// blockSender creates a filter that sends future mail from the sender of e
+// straight to Trash.
+func (m Model) blockSender(e Email) Model {
+	addr := senderAddress(e.From)
+	return m.confirm(
+		fmt.Sprintf("Block %s? Future mail from this address will be deleted. y: block • n: cancel", addr),
+		func() tea.Msg {
+			_, err := m.gmailSvc.Users.Settings.Filters.Create("me", &gmail.Filter{
+				Criteria: &gmail.FilterCriteria{From: addr},
+				Action: &gmail.FilterAction{
+					AddLabelIds:    []string{"TRASH"},
+					RemoveLabelIds: []string{"INBOX"},
+				},
+			}).Context(m.ctx).Do()
+			if err != nil {
+				return errMsg(fmt.Errorf("unable to create filter: %w", err))
+			}
+			return filterCreatedMsg(fmt.Sprintf("Blocked %s", addr))
+		},
+	)
+}
+
+func (e Email) hasLabel(id string) bool {
+	return slices.Contains(e.Labels, id)
+}
+
+// paperclip marks messages with attachments in the list.
+const paperclip = "📎"
+
+// hasFiles reports whether e has attachments, or looks like it does if its
+// body isn't loaded yet.
+func (e Email) hasFiles() bool {
+	if e.loaded {
+		return len(e.files) > 0
+	}
+	return e.attached
+}
+
+// emailIndex returns the position of the message with the given ID among
+// all list items, or -1.
+func (m Model) emailIndex(id string) int {
+	for i, item := range m.list.Items() {
+		if e, ok := item.(Email); ok && e.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// applyLabelsChanged updates or removes the changed message in the list and,
+// if it was open, moves on to the next message or back to the list.
+func (m Model) applyLabelsChanged(msg labelsChangedMsg) (Model, tea.Cmd) {
+	m.status = msg.status
+	if m.reading != nil && m.reading.id == msg.email.ID {
+		// A change made by hand wins over marking the message read later.
+		m.reading = nil
+	}
+	if msg.undo.changes() {
+		m = m.pushUndo(*msg.undo)
+		m.status += " • u: undo"
+	}
+
+	i := m.emailIndex(msg.email.ID)
+	if i < 0 {
+		return m, nil
+	}
+	if !msg.removed {
+		// The change may have been made on a copy taken before the body
+		// was loaded.
+		if old := m.list.Items()[i].(Email); old.loaded && !msg.email.loaded {
+			msg.email.Body, msg.email.cut, msg.email.loaded = old.Body, old.cut, true
+		}
+		m.list.SetItem(i, msg.email)
+		if m.selectedMail != nil && m.selectedMail.ID == msg.email.ID {
+			m.selectedMail = &msg.email
+		}
+		return m, nil
+	}
+
+	m.list.RemoveItem(i)
+	if m.state == messageView && m.selectedMail != nil && m.selectedMail.ID == msg.email.ID {
+		if m.config.AutoAdvance && len(m.list.VisibleItems()) > 0 {
+			return m.openSelected()
+		}
+		m.selectedMail = nil
+		m.state = listView
+	}
+	return m, nil
+}
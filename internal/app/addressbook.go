@@ -0,0 +1,272 @@
+package app
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"google.golang.org/api/people/v1"
+
+	"gmail-tui/internal/ui/compose"
+	"gmail-tui/internal/ui/view"
+)
+
+// maxContactMail caps the recent messages shown for a contact.
+const maxContactMail = 10
+
+// contactCard is a person in the contacts screen: a Google contact with all
+// of their addresses, or someone you recently wrote to who is not in
+// Google Contacts.
+type contactCard struct {
+	name   string
+	emails []string
+}
+
+func (c contactCard) Title() string {
+	if c.name == "" {
+		return c.emails[0]
+	}
+	return c.name
+}
+func (c contactCard) Description() string { return strings.Join(c.emails, ", ") }
+func (c contactCard) FilterValue() string { return c.name + " " + strings.Join(c.emails, " ") }
+
+// contact is the card's first address, to compose to.
+func (c contactCard) contact() Contact {
+	return Contact{Name: c.name, Email: c.emails[0]}
+}
+
+// query is a Gmail query for mail from the contact, or also to them when
+// both is set.
+func (c contactCard) query(both bool) string {
+	var terms []string
+	for _, e := range c.emails {
+		terms = append(terms, "from:"+e)
+		if both {
+			terms = append(terms, "to:"+e)
+		}
+	}
+	if len(terms) == 1 {
+		return terms[0]
+	}
+	return "{" + strings.Join(terms, " ") + "}"
+}
+
+// contactDetail is the open card and its recent correspondence.
+type contactDetail struct {
+	card   contactCard
+	mail   []Email
+	loaded bool
+	err    error
+}
+
+type contactCardsMsg struct {
+	cards []contactCard
+	err   error
+}
+
+type contactMailMsg struct {
+	card contactCard
+	mail []Email
+	err  error
+}
+
+func newContactList(delegate list.ItemDelegate) list.Model {
+	l := list.New([]list.Item{}, delegate, 40, 20)
+	l.SetShowTitle(true)
+	l.SetFilteringEnabled(true)
+	l.SetShowHelp(true)
+	l.DisableQuitKeybindings()
+	l.Title = "Contacts"
+	l.Styles.Title = view.TitleStyle
+	return l
+}
+
+// fetchContactCards lists Google Contacts, followed by recent recipients
+// who are not among them. If the People API fails, the recent recipients
+// are still listed and the error is reported alongside.
+func (m Model) fetchContactCards() tea.Msg {
+	var cards []contactCard
+	seen := map[string]bool{}
+	var err error
+	if m.peopleSvc == nil {
+		err = fmt.Errorf("the People API is not available")
+	} else {
+		err = m.peopleSvc.People.Connections.List("people/me").
+			PersonFields("names,emailAddresses").
+			PageSize(1000).
+			Pages(m.ctx, func(r *people.ListConnectionsResponse) error {
+				for _, p := range r.Connections {
+					var card contactCard
+					if len(p.Names) > 0 {
+						card.name = p.Names[0].DisplayName
+					}
+					for _, e := range p.EmailAddresses {
+						if e.Value != "" && !seen[strings.ToLower(e.Value)] {
+							seen[strings.ToLower(e.Value)] = true
+							card.emails = append(card.emails, e.Value)
+						}
+					}
+					if len(card.emails) > 0 {
+						cards = append(cards, card)
+					}
+				}
+				return nil
+			})
+		if err != nil {
+			err = fmt.Errorf("unable to list Google Contacts: %v", err)
+		}
+	}
+
+	m.contacts.mu.Lock()
+	for _, c := range m.contacts.contacts {
+		if !seen[strings.ToLower(c.Email)] {
+			seen[strings.ToLower(c.Email)] = true
+			cards = append(cards, contactCard{name: c.Name, emails: []string{c.Email}})
+		}
+	}
+	m.contacts.mu.Unlock()
+
+	sort.SliceStable(cards, func(i, j int) bool {
+		return strings.ToLower(cards[i].Title()) < strings.ToLower(cards[j].Title())
+	})
+	return contactCardsMsg{cards: cards, err: err}
+}
+
+// fetchContactMail loads the newest messages from or to a contact.
+func (m Model) fetchContactMail(c contactCard) tea.Cmd {
+	return func() tea.Msg {
+		mail, _, err := m.listEmails(m.ctx, c.query(true), maxContactMail)
+		return contactMailMsg{card: c, mail: mail, err: err}
+	}
+}
+
+func (m Model) openContacts() (Model, tea.Cmd) {
+	m.state = contactsView
+	m.contact = nil
+	m.loading = true
+	return m, m.fetchContactCards
+}
+
+func (m Model) applyContactCards(msg contactCardsMsg) Model {
+	m.loading = false
+	items := make([]list.Item, len(msg.cards))
+	for i, c := range msg.cards {
+		items[i] = c
+	}
+	m.contactList.SetItems(items)
+	if msg.err != nil {
+		m.status = msg.err.Error()
+	}
+	return m
+}
+
+func (m Model) applyContactMail(msg contactMailMsg) Model {
+	if m.contact == nil || m.contact.card.emails[0] != msg.card.emails[0] {
+		return m
+	}
+	m.contact.mail, m.contact.err, m.contact.loaded = msg.mail, msg.err, true
+	return m
+}
+
+// mailFrom lists all mail from a contact.
+func (m Model) mailFrom(c contactCard) (Model, tea.Cmd) {
+	m.contact = nil
+	m.localSearch = m.index != nil
+	return m.showQuery(c.query(false), "From: "+c.Title())
+}
+
+func (m Model) updateContacts(msg tea.KeyMsg) (Model, tea.Cmd) {
+	if m.contact != nil {
+		switch {
+		case key.Matches(msg, m.keys.Back):
+			m.contact = nil
+		case key.Matches(msg, m.keys.Quit):
+			return m.quit()
+		case key.Matches(msg, m.keys.Compose):
+			return m.openCompose(compose.Draft{To: m.contact.card.contact().String()})
+		case key.Matches(msg, m.keys.MailFrom):
+			return m.mailFrom(m.contact.card)
+		}
+		return m, nil
+	}
+
+	if m.contactList.FilterState() != list.Filtering {
+		switch {
+		case key.Matches(msg, m.keys.Back) && m.contactList.FilterState() == list.Unfiltered:
+			m.state = listView
+			return m, nil
+		case key.Matches(msg, m.keys.Quit):
+			return m.quit()
+		case key.Matches(msg, m.keys.Fetch):
+			m.loading = true
+			return m, m.fetchContactCards
+		case key.Matches(msg, m.keys.Select):
+			if c, ok := m.contactList.SelectedItem().(contactCard); ok {
+				m.contact = &contactDetail{card: c}
+				return m, m.fetchContactMail(c)
+			}
+			return m, nil
+		case key.Matches(msg, m.keys.Compose):
+			if c, ok := m.contactList.SelectedItem().(contactCard); ok {
+				return m.openCompose(compose.Draft{To: c.contact().String()})
+			}
+			return m, nil
+		case key.Matches(msg, m.keys.MailFrom):
+			if c, ok := m.contactList.SelectedItem().(contactCard); ok {
+				return m.mailFrom(c)
+			}
+			return m, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	m.contactList, cmd = m.contactList.Update(msg)
+	return m, cmd
+}
+
+func (m Model) contactsView() string {
+	if m.contact != nil {
+		return m.contactDetailView()
+	}
+	return fmt.Sprintf(
+		"%s\n\n%s",
+		m.contactList.View(),
+		view.HelpStyle.Render(m.statusLine()+"enter: details • c: compose • s: mail from • r: refresh • esc: back"),
+	)
+}
+
+func (m Model) contactDetailView() string {
+	d := m.contact
+	lines := []string{view.TitleStyle.Render(d.card.Title())}
+	if d.card.name != "" {
+		lines = append(lines, view.InfoStyle.Render("Name: "+d.card.name))
+	}
+	for _, e := range d.card.emails {
+		lines = append(lines, view.InfoStyle.Render("Email: "+e))
+	}
+	lines = append(lines, "", view.InfoStyle.Render("Recent mail:"))
+	switch {
+	case !d.loaded:
+		lines = append(lines, view.InfoStyle.Render(m.spinner.View()+" Loading..."))
+	case d.err != nil:
+		lines = append(lines, view.InfoStyle.Render(fmt.Sprintf("Unable to load recent mail: %v", d.err)))
+	case len(d.mail) == 0:
+		lines = append(lines, view.InfoStyle.Render("None"))
+	}
+	for _, e := range d.mail {
+		dir := "←"
+		if e.hasLabel("SENT") {
+			dir = "→"
+		}
+		lines = append(lines, view.InfoStyle.Render(fmt.Sprintf("%s %-12s %s", dir, view.ListDate(e.Date), e.Subject)))
+	}
+	return fmt.Sprintf(
+		"\n%s\n\n%s",
+		strings.Join(lines, "\n"),
+		view.HelpStyle.Render(m.statusLine()+"c: compose • s: mail from • esc: back"),
+	)
+}
@@ -0,0 +1,257 @@
+// Package app is the interactive client and the commands of gmail-tui:
+// the Model, its views and key handling, and the features built on them.
+package app
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/charmbracelet/bubbles/help"
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/people/v1"
+
+	"gmail-tui/internal/auth"
+	"gmail-tui/internal/backend"
+	"gmail-tui/internal/ui/compose"
+	"gmail-tui/internal/ui/keys"
+	"gmail-tui/internal/ui/palette"
+	"gmail-tui/internal/ui/view"
+)
+
+type viewState int
+
+const (
+	listView viewState = iota
+	messageView
+	draftsView
+	composeView
+	filtersView
+	vacationView
+	signaturesView
+	scheduledView
+	contactsView
+)
+
+type Model struct {
+	list          list.Model
+	drafts        list.Model
+	filters       list.Model
+	filterForm    *filterForm
+	labels        labelIndex
+	vacation      *vacationForm
+	aliases       []Alias
+	pickingAlias  bool
+	aliasCursor   int
+	signatures    list.Model
+	outbox        *outbox
+	scheduling    bool
+	scheduleInput textinput.Model
+	scheduled     list.Model
+	contactList   list.Model
+	contact       *contactDetail
+	help          help.Model
+	helpScreen    *helpScreen
+	keys          keys.Map
+	spinner       spinner.Model
+	reducedMotion bool
+	viewport      viewport.Model
+	state         viewState
+	loading       bool
+	load          *listLoad
+	selectedMail  *Email
+	reading       *reading
+	compose       *compose.Session
+	composeReturn viewState
+	editor        *composeEditor
+	confirming    bool
+	previewing    bool
+	expandQuotes  bool
+	glamourStyle  string
+	pending       *pendingSend
+	quitting      bool
+	undo          []undoStep
+	attaching     bool
+	attachInput   textinput.Model
+	candidates    []string
+	addressField  string
+	addressInput  textinput.Model
+	suggestions   []Contact
+	suggestion    int
+	searching     bool
+	searchInput   textinput.Model
+	searchQuery   string
+	matches       []searchMatch
+	match         int
+	pickingLink   bool
+	pickingAttach bool
+	attachCursor  int
+	saving        *attachmentSave
+	export        *exportJob
+	syncing       bool
+	daemon        bool
+	links         []string
+	linkCursor    int
+	yankPending   bool
+	motion        motion
+	source        string
+	unsubscribe   *unsubscribeRequest
+	confirmation  *confirmation
+	snooze        *Email
+	followUp      *followUpPrompt
+	profile       *senderProfile
+	purge         *purgePrompt
+	snoozeInput   textinput.Model
+	contacts      *contactIndex
+	senders       *senderStats
+	lists         *listCache
+	offline       *offlineState
+	resume        *session
+	resumeScroll  int
+	index         *mailIndex
+	reauth        *reauthPrompt
+	mailto        *compose.Draft
+	plugins       *plugins
+	palette       *palette.Prompt
+	rsvp          *invite
+	history       []string
+	searches      searchHistory
+	query         string
+	title         string
+	quick         []string
+	place         string
+	tabUnread     map[string]int64
+	localSearch   bool
+	mutedLabel    string
+	newestMail    time.Time
+	watches       []*mailWatch
+	hud           bool
+	ctx           context.Context
+	cancelFetch   context.CancelFunc
+	gmailSvc      *gmail.Service
+	mail          backend.Provider
+	httpClient    *http.Client
+	auth          *auth.Source
+	prefetch      *prefetcher
+	bodyLRU       []string
+	peopleSvc     *people.Service
+	config        Config
+	status        string
+	failure       *failure
+	width         int
+	height        int
+}
+
+func initialModel(ctx context.Context, svc *gmail.Service, mail backend.Provider, psvc *people.Service, client *http.Client, source *auth.Source, cfg Config, ob *outbox) Model {
+	km := keys.New()
+
+	s := spinner.New()
+	s.Spinner = spinner.Dot
+	s.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("205"))
+
+	delegate := view.ItemDelegate()
+	if view.Accessible {
+		delegate = view.PlainDelegate(delegate)
+	}
+	l := list.New([]list.Item{}, emailDelegate{DefaultDelegate: delegate}, 40, 20)
+	l.SetShowTitle(true)
+	l.SetFilteringEnabled(true)
+	l.Filter = filterEmails
+	l.SetShowHelp(true)
+	l.Title = "Gmail Inbox"
+	l.Styles.Title = view.TitleStyle
+	// u undoes the last change instead of paging up, here and in the
+	// viewport below.
+	l.KeyMap.PrevPage.SetKeys("left", "h", "pgup", "b")
+
+	vp := viewport.New(80, 20)
+	vp.Style = lipgloss.NewStyle().Padding(1, 2)
+	vp.KeyMap.HalfPageUp.SetKeys("ctrl+u")
+
+	m := Model{
+		list:         l,
+		drafts:       newDraftsList(delegate),
+		filters:      newFiltersList(delegate),
+		signatures:   newSignaturesList(delegate),
+		scheduled:    newScheduledList(delegate),
+		contactList:  newContactList(delegate),
+		help:         help.New(),
+		keys:         km,
+		spinner:      s,
+		viewport:     vp,
+		attachInput:  newAttachInput(),
+		addressInput: newAddressInput(),
+		searchInput:  newSearchInput(),
+		snoozeInput:  newSnoozeInput(),
+		outbox:       ob,
+		contacts:     loadContactIndex(cfg.vault),
+		senders:      loadSenderStats(cfg.vault),
+		lists:        loadListCache(cfg.vault),
+		ctx:          ctx,
+		gmailSvc:     svc,
+		mail:         mail,
+		httpClient:   client,
+		auth:         source,
+		prefetch:     newPrefetcher(),
+		peopleSvc:    psvc,
+		config:       cfg,
+		newestMail:   time.Now(),
+		watches:      cfg.mailWatches(),
+		history:      palette.LoadHistory(cfg.vault),
+		searches:     loadSearchHistory(cfg.vault),
+		loading:      true,
+		place:        "all",
+		title:        l.Title,
+	}
+	if tabs := cfg.tabs(); len(tabs) > 0 {
+		m.query = "in:inbox category:" + tabs[0].name
+		m.list.Title = tabs[0].title
+		m.title = tabs[0].title
+		m.place = tabs[0].name
+	}
+	m.list.SetDelegate(m.emailDelegate())
+	if view.Accessible {
+		m = m.plainPagers()
+	}
+	return m.startLoad()
+}
+
+// withoutAnimation swaps the spinner for a static marker that is never
+// ticked, for screen readers and slow terminals.
+func (m Model) withoutAnimation() Model {
+	m.reducedMotion = true
+	m.spinner.Spinner = spinner.Spinner{Frames: []string{"*"}}
+	return m
+}
+
+func (m Model) Init() tea.Cmd {
+	cmds := []tea.Cmd{m.fetchEmails(m.ctx), loadTick(m.load), m.dispatchOutbox, outboxTick()}
+	if !m.reducedMotion {
+		cmds = append(cmds, m.spinner.Tick)
+	}
+	// Aliases, snoozing, follow-ups, muting, contacts and tabs all need
+	// the Gmail API.
+	if m.config.GmailAPI() {
+		cmds = append(cmds, m.fetchAliases, m.wakeSnoozed, m.checkFollowUps, m.archiveMuted, snoozeTick(), contactsTick(m.config.ContactsRefreshInterval()))
+		if m.contacts.stale(m.config.ContactsRefreshInterval()) {
+			cmds = append(cmds, m.refreshContacts)
+		}
+		if len(m.config.tabs()) > 0 {
+			cmds = append(cmds, m.fetchTabCounts)
+		}
+	}
+	if m.config.autoRefreshes() {
+		cmds = append(cmds, m.nextRefresh())
+	}
+	for _, w := range m.watches {
+		cmds = append(cmds, m.checkWatch(w))
+	}
+	cmds = append(cmds, m.writeStatus)
+	return retryable(tea.Batch(cmds...))
+}
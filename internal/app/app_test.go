@@ -0,0 +1,124 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/exp/teatest"
+
+	"gmail-tui/internal/backend"
+)
+
+// startDemo runs gmail-tui on the demo mailbox and waits for the inbox to
+// be listed.
+func startDemo(t *testing.T) (*teatest.TestModel, backend.Provider) {
+	t.Helper()
+	m, mail := newTestModel(t)
+	tm := teatest.NewTestModel(t, m, teatest.WithInitialTermSize(100, 30))
+	t.Cleanup(func() { tm.Quit() })
+	waitFor(t, tm, "Welcome to the demo mailbox")
+	return tm, mail
+}
+
+func waitFor(t *testing.T, tm *teatest.TestModel, s string) {
+	t.Helper()
+	teatest.WaitFor(t, tm.Output(), func(out []byte) bool {
+		return bytes.Contains(out, []byte(s))
+	}, teatest.WithDuration(5*time.Second))
+}
+
+// command runs a command from the palette.
+func command(tm *teatest.TestModel, line string) {
+	tm.Type(":" + line)
+	tm.Send(tea.KeyMsg{Type: tea.KeyEnter})
+}
+
+// finalModel quits and returns the model as it was left.
+func finalModel(t *testing.T, tm *teatest.TestModel) Model {
+	t.Helper()
+	command(tm, "quit")
+	return tm.FinalModel(t, teatest.WithFinalTimeout(5*time.Second)).(Model)
+}
+
+// subjects lists the subjects shown in the message list.
+func subjects(m Model) []string {
+	var s []string
+	for _, item := range m.list.Items() {
+		if e, ok := item.(Email); ok {
+			s = append(s, e.Subject)
+		}
+	}
+	return s
+}
+
+// inboxIDs lists the messages in the demo inbox.
+func inboxIDs(t *testing.T, mail backend.Provider) []string {
+	t.Helper()
+	msgs, _, err := mail.List(context.Background(), "in:inbox", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, msg := range msgs {
+		ids = append(ids, msg.Id)
+	}
+	return ids
+}
+
+func TestListShowsInbox(t *testing.T) {
+	tm, _ := startDemo(t)
+
+	m := finalModel(t, tm)
+	if got := subjects(m); !slices.Contains(got, "Welcome to the demo mailbox") || !slices.Contains(got, "Quarterly numbers") {
+		t.Errorf("inbox lists %q", got)
+	}
+}
+
+func TestOpenMessage(t *testing.T) {
+	tm, _ := startDemo(t)
+	tm.Send(tea.KeyMsg{Type: tea.KeyEnter})
+	waitFor(t, tm, "This mailbox lives in memory")
+
+	m := finalModel(t, tm)
+	if m.state != messageView || m.selectedMail == nil || m.selectedMail.Subject != "Welcome to the demo mailbox" {
+		t.Errorf("not reading the welcome message: state %v, message %v", m.state, m.selectedMail)
+	}
+}
+
+func TestArchiveAndUndo(t *testing.T) {
+	tm, mail := startDemo(t)
+	before := inboxIDs(t, mail)
+
+	command(tm, "archive")
+	waitFor(t, tm, "Archived")
+	if got := inboxIDs(t, mail); len(got) != len(before)-1 {
+		t.Fatalf("inbox has %d messages after archiving, want %d", len(got), len(before)-1)
+	}
+
+	tm.Type("u")
+	waitFor(t, tm, "Undone: Archived")
+	if got := inboxIDs(t, mail); !slices.Equal(got, before) {
+		t.Fatalf("inbox is %v after undoing, want %v", got, before)
+	}
+
+	m := finalModel(t, tm)
+	if got := subjects(m); !slices.Contains(got, "Welcome to the demo mailbox") {
+		t.Errorf("the unarchived message is not listed: %q", got)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	tm, _ := startDemo(t)
+
+	command(tm, "search subject:quarterly")
+	waitFor(t, tm, "Search: subject:quarterly")
+
+	m := finalModel(t, tm)
+	if got := subjects(m); !slices.Equal(got, []string{"Quarterly numbers"}) {
+		t.Errorf("search lists %q, want the quarterly numbers only", got)
+	}
+}
@@ -0,0 +1,62 @@
+package app
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+
+	"gmail-tui/internal/ui/compose"
+)
+
+// writtenText is the part of a body its sender wrote, without the quoted
+// message it answers or the signature.
+func writtenText(body string) string {
+	if i := quoteStart(body); i >= 0 {
+		body = body[:i]
+	}
+	if i := strings.Index(body, "\n-- \n"); i >= 0 {
+		body = body[:i]
+	}
+	return body
+}
+
+// missingAttachment returns the word d's subject or text mentions an
+// attachment with, when d has no attachments.
+func (c Config) missingAttachment(d compose.Draft) (string, bool) {
+	if len(d.Attachments) > 0 {
+		return "", false
+	}
+	text := foldRunes(d.Subject + "\n" + writtenText(d.Body))
+	for _, k := range c.AttachmentKeywords {
+		word := foldRunes(strings.TrimSpace(k))
+		if i := wordStart(text, word); i >= 0 {
+			end := i + len(word)
+			for end < len(text) && spacedLetter(text[end]) {
+				end++
+			}
+			return string(text[i:end]), true
+		}
+	}
+	return "", false
+}
+
+// wordStart returns where word first occurs in text at the start of a
+// word, or -1. Scripts written without spaces, such as Japanese, match
+// anywhere.
+func wordStart(text, word []rune) int {
+	if len(word) == 0 {
+		return -1
+	}
+	for i := 0; i+len(word) <= len(text); i++ {
+		if slices.Equal(text[i:i+len(word)], word) && (i == 0 || !spacedLetter(text[i-1])) {
+			return i
+		}
+	}
+	return -1
+}
+
+// spacedLetter reports whether r is a letter of a script that puts spaces
+// between words.
+func spacedLetter(r rune) bool {
+	return unicode.IsLetter(r) && unicode.In(r, unicode.Latin, unicode.Cyrillic, unicode.Greek)
+}
@@ -0,0 +1,169 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"google.golang.org/api/gmail/v1"
+
+	"gmail-tui/internal/ui/compose"
+	"gmail-tui/internal/ui/view"
+)
+
+// messageAttachments collects the attachment parts of a message payload.
+func messageAttachments(messageID string, payload *gmail.MessagePart) []compose.Attachment {
+	var atts []compose.Attachment
+	if payload.Filename != "" && payload.Body != nil && payload.Body.AttachmentId != "" {
+		atts = append(atts, compose.Attachment{
+			Name:         payload.Filename,
+			MimeType:     payload.MimeType,
+			Size:         payload.Body.Size,
+			MessageID:    messageID,
+			AttachmentID: payload.Body.AttachmentId,
+		})
+	}
+	for _, part := range payload.Parts {
+		atts = append(atts, messageAttachments(messageID, part)...)
+	}
+	return atts
+}
+
+func localAttachment(path string) (compose.Attachment, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return compose.Attachment{}, fmt.Errorf("unable to attach %s: %v", path, err)
+	}
+	if info.IsDir() {
+		return compose.Attachment{}, fmt.Errorf("unable to attach %s: is a directory", path)
+	}
+	return compose.Attachment{
+		Name: filepath.Base(path),
+		Size: info.Size(),
+		Path: path,
+	}, nil
+}
+
+func expandHome(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, path[1:])
+		}
+	}
+	return path
+}
+
+// completePath extends input as far as the file names in its directory
+// allow and returns the names that still match. A unique directory match
+// gets a trailing separator so completion can continue into it.
+func completePath(input string) (string, []string) {
+	dir, base := filepath.Split(input)
+	entries, err := os.ReadDir(expandHome(orDot(dir)))
+	if err != nil {
+		return input, nil
+	}
+
+	var matches []string
+	for _, e := range entries {
+		name := e.Name()
+		if !strings.HasPrefix(name, base) {
+			continue
+		}
+		if strings.HasPrefix(name, ".") && !strings.HasPrefix(base, ".") {
+			continue
+		}
+		if e.IsDir() {
+			name += string(filepath.Separator)
+		}
+		matches = append(matches, name)
+	}
+	sort.Strings(matches)
+
+	if len(matches) == 0 {
+		return input, nil
+	}
+	if len(matches) == 1 {
+		return dir + matches[0], nil
+	}
+	return dir + commonPrefix(matches), matches
+}
+
+func orDot(dir string) string {
+	if dir == "" {
+		return "."
+	}
+	return dir
+}
+
+func commonPrefix(names []string) string {
+	prefix := names[0]
+	for _, n := range names[1:] {
+		for !strings.HasPrefix(n, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
+
+func newAttachInput() textinput.Model {
+	ti := textinput.New()
+	ti.Prompt = "Attach: "
+	ti.Placeholder = "path to file (tab to complete)"
+	return ti
+}
+func (m Model) updateAttach(msg tea.KeyMsg) (Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, m.keys.Complete):
+		value, candidates := completePath(m.attachInput.Value())
+		m.attachInput.SetValue(value)
+		m.attachInput.CursorEnd()
+		m.candidates = candidates
+		return m, nil
+	case key.Matches(msg, m.keys.Select):
+		a, err := localAttachment(expandHome(m.attachInput.Value()))
+		if err != nil {
+			m.status = err.Error()
+			return m, nil
+		}
+		m.attaching = false
+		m.candidates = nil
+		m.compose.AddAttachment(a)
+		m.loading = true
+		return m, m.saveCompose(m.compose, "Attachments updated")
+	case key.Matches(msg, m.keys.Back):
+		m.attaching = false
+		m.candidates = nil
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.attachInput, cmd = m.attachInput.Update(msg)
+	m.candidates = nil
+	return m, cmd
+}
+
+func (m Model) attachView() string {
+	var b strings.Builder
+	b.WriteString(m.attachInput.View())
+	for i, c := range m.candidates {
+		if i == 10 {
+			fmt.Fprintf(&b, "\n  … %d more", len(m.candidates)-i)
+			break
+		}
+		b.WriteString("\n  " + c)
+	}
+	return b.String()
+}
+
+func attachmentLines(atts []compose.Attachment) []string {
+	var lines []string
+	for _, a := range atts {
+		lines = append(lines, view.InfoStyle.Render(fmt.Sprintf("📎 %s (%s)", a.Name, view.Size(a.Size))))
+	}
+	return lines
+}
@@ -0,0 +1,140 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"gmail-tui/internal/mimepart"
+	"gmail-tui/internal/ui/compose"
+	"gmail-tui/internal/ui/view"
+)
+
+// bodyMsg carries the body of a message fetched on demand. When copy is set
+// the body was requested to be copied to the clipboard.
+type bodyMsg struct {
+	id     string
+	body   string
+	invite *invite
+	pgp    string
+	smime  string
+	files  []compose.Attachment
+	remote remoteContent
+	cut    int64
+	copy   bool
+}
+
+// fetchBody loads the full message for a list row, which only carries
+// headers, with its text cut at max_body_kb.
+func (m Model) fetchBody(id string, copy bool) tea.Cmd {
+	return m.loadBody(id, m.config.MaxBodyBytes(), copy)
+}
+
+// loadBody is fetchBody keeping no more than limit bytes of the text, or
+// all of it if limit is 0.
+func (m Model) loadBody(id string, limit int, copy bool) tea.Cmd {
+	return func() tea.Msg {
+		msg, err := m.mail.Get(m.ctx, id, "full")
+		if err != nil {
+			return errMsg(fmt.Errorf("unable to fetch message: %w", err))
+		}
+		body, cut := mimepart.TextLimit(msg.Payload, limit)
+		var pgp string
+		if isPGP(msg.Payload, body) {
+			// An inline signature covers, and an encrypted block holds,
+			// all of the text.
+			if cut > 0 {
+				body, cut = mimepart.TextLimit(msg.Payload, 0)
+			}
+			if body, pgp, err = m.openPGP(id, msg.Payload, body); err != nil {
+				return errMsg(err)
+			}
+		}
+		// Decrypted text is never written to disk.
+		if !strings.HasPrefix(pgp, "encrypted") {
+			m.index.add(messageEmail(msg), body)
+		}
+		var smime string
+		if isSMIME(msg.Payload) {
+			if smime, err = m.verifySMIME(id, messageEmail(msg).From); err != nil {
+				return errMsg(err)
+			}
+		}
+		return bodyMsg{
+			id:     id,
+			body:   m.plugins.body(body, messageEmail(msg)),
+			invite: parseInvite(msg.Payload),
+			pgp:    pgp,
+			smime:  smime,
+			files:  messageAttachments(id, msg.Payload),
+			remote: parseRemote(msg.Payload),
+			cut:    cut,
+			copy:   copy,
+		}
+	}
+}
+
+// loadWholeBody fetches the open message again with all of its text, when
+// it was cut at max_body_kb, keeping the scroll position.
+func (m Model) loadWholeBody() (Model, tea.Cmd) {
+	m.selectedMail.loaded = false
+	m.resumeScroll = m.viewport.YOffset
+	m.status = "Loading all of the message..."
+	return m, m.loadBody(m.selectedMail.ID, 0, false)
+}
+
+// cutNote says in the message header that the body was cut and how to get
+// the rest.
+func (m Model) cutNote(cut int64) string {
+	if cut == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" • Showing %s of %s • X: load all", view.Size(int64(m.config.MaxBodyBytes())), view.Size(cut))
+}
+
+// cutNotice ends a body that was cut short.
+func (m Model) cutNotice(cut int64) string {
+	return fmt.Sprintf("[Message cut at %s of %s • X: load all • ctrl+s: save it all to a text file]",
+		view.Size(int64(m.config.MaxBodyBytes())), view.Size(cut))
+}
+
+// applyBody stores a fetched body on its list row and, if the message is
+// open, shows it.
+func (m Model) applyBody(msg bodyMsg) Model {
+	if i := m.emailIndex(msg.id); i >= 0 {
+		e := m.list.Items()[i].(Email)
+		e.Body = msg.body
+		e.invite = msg.invite
+		e.pgp = msg.pgp
+		e.smime = msg.smime
+		e.files = msg.files
+		e.remote = msg.remote
+		e.cut = msg.cut
+		e.loaded = true
+		m.list.SetItem(i, e)
+		m = m.cacheBody(msg.id)
+	}
+
+	if m.selectedMail != nil && m.selectedMail.ID == msg.id && !m.selectedMail.loaded {
+		m.selectedMail.Body = msg.body
+		m.selectedMail.invite = msg.invite
+		m.selectedMail.pgp = msg.pgp
+		m.selectedMail.smime = msg.smime
+		m.selectedMail.files = msg.files
+		m.selectedMail.remote = msg.remote
+		m.selectedMail.cut = msg.cut
+		m.selectedMail.loaded = true
+		if m.state == messageView && m.source == "" {
+			m.viewport.SetContent(m.messageContent())
+			m.viewport.GotoTop()
+			m.viewport.SetYOffset(m.resumeScroll)
+			m.resumeScroll = 0
+		}
+	}
+
+	if msg.copy {
+		m = m.copyText("body", msg.body)
+	}
+	return m
+}
@@ -0,0 +1,491 @@
+package app
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"google.golang.org/api/gmail/v1"
+
+	"gmail-tui/internal/browser"
+	"gmail-tui/internal/mimepart"
+	"gmail-tui/internal/ui/view"
+)
+
+// icsProperty is a content line of an iCalendar object, unfolded.
+type icsProperty struct {
+	name   string
+	params map[string]string
+	value  string
+	line   string
+}
+
+// invite is the first event of a text/calendar part, as sent with meeting
+// invitations.
+type invite struct {
+	method     string
+	uid        string
+	sequence   string
+	summary    string
+	location   string
+	organizer  string
+	recurrence string
+	start, end time.Time
+	allDay     bool
+
+	// props keeps the event's content lines, which replies repeat, and
+	// raw the whole calendar for exporting.
+	props []icsProperty
+	raw   string
+}
+
+type rsvpSentMsg string
+
+// parseInvite returns the invitation in a message, or nil if it has none.
+func parseInvite(payload *gmail.MessagePart) *invite {
+	part := mimepart.Find(payload, "text/calendar")
+	if part == nil {
+		return nil
+	}
+	data, ok := mimepart.Data(part)
+	if !ok {
+		return nil
+	}
+	return parseICS(mimepart.ToUTF8(data, mimepart.Charset(part)))
+}
+
+func parseICS(text string) *invite {
+	inv := &invite{raw: text}
+	inEvent, seen := false, false
+	depth := 0 // inside components nested in the event, such as VALARM
+	for _, p := range icsProperties(text) {
+		switch {
+		case !inEvent:
+			if p.name == "METHOD" {
+				inv.method = strings.ToUpper(p.value)
+			}
+			if p.name == "BEGIN" && strings.EqualFold(p.value, "VEVENT") && !seen {
+				inEvent, seen = true, true
+			}
+		case p.name == "BEGIN":
+			depth++
+		case p.name == "END" && depth > 0:
+			depth--
+		case p.name == "END":
+			inEvent = false
+		case depth == 0:
+			inv.props = append(inv.props, p)
+			switch p.name {
+			case "UID":
+				inv.uid = p.value
+			case "SEQUENCE":
+				inv.sequence = p.value
+			case "SUMMARY":
+				inv.summary = icsText(p.value)
+			case "LOCATION":
+				inv.location = icsText(p.value)
+			case "ORGANIZER":
+				inv.organizer = icsPerson(p)
+			case "RRULE":
+				inv.recurrence = describeRRule(p.value)
+			case "DTSTART":
+				inv.start, inv.allDay = icsTime(p)
+			case "DTEND":
+				inv.end, _ = icsTime(p)
+			}
+		}
+	}
+	if !seen {
+		return nil
+	}
+	return inv
+}
+
+// icsProperties splits iCalendar text into unfolded content lines.
+func icsProperties(text string) []icsProperty {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.NewReplacer("\n ", "", "\n\t", "").Replace(text)
+
+	var props []icsProperty
+	for _, line := range strings.Split(text, "\n") {
+		head, value, ok := cutUnquoted(line, ':')
+		if !ok {
+			continue
+		}
+		parts := strings.Split(head, ";")
+		p := icsProperty{name: strings.ToUpper(parts[0]), params: map[string]string{}, value: value, line: line}
+		for _, param := range parts[1:] {
+			if k, v, ok := strings.Cut(param, "="); ok {
+				p.params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+			}
+		}
+		props = append(props, p)
+	}
+	return props
+}
+
+// cutUnquoted is strings.Cut ignoring separators inside double quotes,
+// which parameter values such as CN="Doe: Jane" may contain.
+func cutUnquoted(s string, sep byte) (string, string, bool) {
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			quoted = !quoted
+		case sep:
+			if !quoted {
+				return s[:i], s[i+1:], true
+			}
+		}
+	}
+	return s, "", false
+}
+
+func icsText(s string) string {
+	return strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`).Replace(s)
+}
+
+// icsPerson formats an ORGANIZER or ATTENDEE as "Name <address>".
+func icsPerson(p icsProperty) string {
+	addr := icsAddress(p)
+	if cn := p.params["CN"]; cn != "" && cn != addr {
+		return fmt.Sprintf("%s <%s>", cn, addr)
+	}
+	return addr
+}
+
+func icsAddress(p icsProperty) string {
+	v := p.value
+	if len(v) >= 7 && strings.EqualFold(v[:7], "mailto:") {
+		v = v[7:]
+	}
+	return v
+}
+
+// icsTime parses a DTSTART or DTEND value, reporting whether it is a date
+// without a time.
+func icsTime(p icsProperty) (time.Time, bool) {
+	if p.params["VALUE"] == "DATE" || len(p.value) == 8 {
+		t, _ := time.ParseInLocation("20060102", p.value, time.Local)
+		return t, true
+	}
+	if strings.HasSuffix(p.value, "Z") {
+		t, _ := time.Parse("20060102T150405Z", p.value)
+		return t.Local(), false
+	}
+	loc := time.Local
+	if tz := p.params["TZID"]; tz != "" {
+		if l, err := time.LoadLocation(tz); err == nil {
+			loc = l
+		}
+	}
+	t, _ := time.ParseInLocation("20060102T150405", p.value, loc)
+	return t.Local(), false
+}
+
+var weekdays = map[string]string{
+	"MO": "Monday", "TU": "Tuesday", "WE": "Wednesday", "TH": "Thursday",
+	"FR": "Friday", "SA": "Saturday", "SU": "Sunday",
+}
+
+// describeRRule summarises common recurrence rules, falling back to the
+// rule itself.
+func describeRRule(rule string) string {
+	fields := map[string]string{}
+	for _, f := range strings.Split(rule, ";") {
+		if k, v, ok := strings.Cut(f, "="); ok {
+			fields[strings.ToUpper(k)] = v
+		}
+	}
+
+	freq := map[string]string{"DAILY": "day", "WEEKLY": "week", "MONTHLY": "month", "YEARLY": "year"}[fields["FREQ"]]
+	if freq == "" {
+		return rule
+	}
+	desc := "Every " + freq
+	if n := fields["INTERVAL"]; n != "" && n != "1" {
+		desc = fmt.Sprintf("Every %s %ss", n, freq)
+	}
+	if days := fields["BYDAY"]; days != "" && fields["FREQ"] == "WEEKLY" {
+		var names []string
+		for _, d := range strings.Split(days, ",") {
+			if name, ok := weekdays[d]; ok {
+				names = append(names, name)
+			}
+		}
+		desc += " on " + strings.Join(names, ", ")
+	}
+	if until := fields["UNTIL"]; len(until) >= 8 {
+		if t, err := time.Parse("20060102", until[:8]); err == nil {
+			desc += " until " + t.Format("Jan 2, 2006")
+		}
+	}
+	if count := fields["COUNT"]; count != "" {
+		desc += ", " + count + " times"
+	}
+	return desc
+}
+
+// when formats the event's time span.
+func (inv *invite) when() string {
+	if inv.start.IsZero() {
+		return ""
+	}
+	if inv.allDay {
+		last := inv.end.AddDate(0, 0, -1)
+		if inv.end.IsZero() || !last.After(inv.start) {
+			return inv.start.Format("Mon Jan 2, 2006") + " (all day)"
+		}
+		return inv.start.Format("Mon Jan 2") + " – " + last.Format("Mon Jan 2, 2006")
+	}
+	s := inv.start.Format("Mon Jan 2, 2006 15:04")
+	switch {
+	case inv.end.IsZero():
+	case inv.end.YearDay() == inv.start.YearDay() && inv.end.Year() == inv.start.Year():
+		s += "–" + inv.end.Format("15:04")
+	default:
+		s += " – " + inv.end.Format("Mon Jan 2, 15:04")
+	}
+	return s + " " + inv.start.Format("MST")
+}
+
+// inviteLines describes the event at the top of the reading view.
+func inviteLines(inv *invite) []string {
+	title := inv.summary
+	if title == "" {
+		title = "(untitled event)"
+	}
+	kind := "Invitation"
+	switch inv.method {
+	case "CANCEL":
+		kind = "Cancelled"
+	case "REPLY":
+		kind = "Reply"
+	}
+	lines := []string{fmt.Sprintf("[%s] %s", kind, title)}
+	for _, f := range []struct{ name, value string }{
+		{"When", inv.when()},
+		{"Repeats", inv.recurrence},
+		{"Where", inv.location},
+		{"Organizer", inv.organizer},
+	} {
+		if f.value != "" {
+			lines = append(lines, fmt.Sprintf("%-10s %s", f.name+":", strings.ReplaceAll(f.value, "\n", ", ")))
+		}
+	}
+	if inv.method == "REQUEST" {
+		lines = append(lines, "R: accept, maybe or decline • export to calendar")
+	} else {
+		lines = append(lines, "R: export to calendar")
+	}
+	return append(lines, view.Rule(20))
+}
+
+func (m Model) openRSVP() Model {
+	if m.selectedMail.invite == nil {
+		m.status = "No calendar invitation in this message"
+		return m
+	}
+	m.rsvp = m.selectedMail.invite
+	return m
+}
+
+func (m Model) updateRSVP(msg tea.KeyMsg) (Model, tea.Cmd) {
+	inv := m.rsvp
+	m.rsvp = nil
+
+	request := inv.method == "REQUEST"
+	switch {
+	case key.Matches(msg, m.keys.Accept) && request:
+		return m, m.sendRSVP(inv, "ACCEPTED")
+	case key.Matches(msg, m.keys.Tentative) && request:
+		return m, m.sendRSVP(inv, "TENTATIVE")
+	case key.Matches(msg, m.keys.Decline) && request:
+		return m, m.sendRSVP(inv, "DECLINED")
+	case key.Matches(msg, m.keys.ExportICS):
+		return m.exportInvite(inv), nil
+	}
+	return m, nil
+}
+
+func (m Model) rsvpPrompt() string {
+	if m.rsvp.method != "REQUEST" {
+		return fmt.Sprintf("%s\nx: export .ics • esc: cancel", m.rsvp.summary)
+	}
+	return fmt.Sprintf("Reply to %s?\na: accept • t: maybe • d: decline • x: export .ics • esc: cancel", m.rsvp.summary)
+}
+
+// rsvpVerbs are the reply subjects used by Google Calendar.
+var rsvpVerbs = map[string]string{
+	"ACCEPTED":  "Accepted",
+	"TENTATIVE": "Tentatively accepted",
+	"DECLINED":  "Declined",
+}
+
+// sendRSVP sends an iTIP reply (RFC 5546) to the organizer, which calendar
+// servers apply to the event.
+func (m Model) sendRSVP(inv *invite, status string) tea.Cmd {
+	me, _ := m.defaultAlias()
+	return func() tea.Msg {
+		var organizer icsProperty
+		for _, p := range inv.props {
+			if p.name == "ORGANIZER" {
+				organizer = p
+			}
+		}
+		if organizer.name == "" {
+			return errMsg(fmt.Errorf("the invitation has no organizer to reply to"))
+		}
+
+		attendee := m.attendee(inv, me)
+		raw, err := rsvpMessage(inv, organizer, attendee, status, me)
+		if err != nil {
+			return errMsg(err)
+		}
+		if _, err := m.gmailSvc.Users.Messages.Send("me", &gmail.Message{Raw: raw}).Context(m.ctx).Do(); err != nil {
+			return errMsg(fmt.Errorf("unable to send reply: %w", err))
+		}
+		return rsvpSentMsg(fmt.Sprintf("%s %s", rsvpVerbs[status], inv.summary))
+	}
+}
+
+// attendee finds the invitation's ATTENDEE line for the user, matching any
+// of their send-as addresses, so the reply names the invited address.
+func (m Model) attendee(inv *invite, me Alias) icsProperty {
+	for _, p := range inv.props {
+		if p.name != "ATTENDEE" {
+			continue
+		}
+		for _, a := range m.aliases {
+			if strings.EqualFold(icsAddress(p), a.SendAsEmail) {
+				return p
+			}
+		}
+	}
+	addr := ""
+	if me.SendAs != nil {
+		addr = me.SendAsEmail
+	}
+	return icsProperty{name: "ATTENDEE", params: map[string]string{}, value: "mailto:" + addr}
+}
+
+// rsvpMessage builds the reply email: a short text part and the
+// text/calendar METHOD:REPLY part calendar servers look for.
+func rsvpMessage(inv *invite, organizer, attendee icsProperty, status string, me Alias) (string, error) {
+	now := time.Now().UTC().Format("20060102T150405Z")
+	cal := []string{
+		"BEGIN:VCALENDAR",
+		"PRODID:-//gmail-tui//EN",
+		"VERSION:2.0",
+		"CALSCALE:GREGORIAN",
+		"METHOD:REPLY",
+		"BEGIN:VEVENT",
+		"DTSTAMP:" + now,
+	}
+	for _, p := range inv.props {
+		switch p.name {
+		case "UID", "SEQUENCE", "DTSTART", "DTEND", "DURATION", "RECURRENCE-ID", "SUMMARY", "ORGANIZER":
+			cal = append(cal, p.line)
+		}
+	}
+	params := fmt.Sprintf("PARTSTAT=%s", status)
+	if cn := attendee.params["CN"]; cn != "" {
+		params += fmt.Sprintf(";CN=%q", cn)
+	}
+	cal = append(cal, fmt.Sprintf("ATTENDEE;%s:%s", params, attendee.value), "END:VEVENT", "END:VCALENDAR")
+
+	var ics strings.Builder
+	for _, line := range cal {
+		ics.WriteString(foldICS(line))
+	}
+
+	from := icsAddress(attendee)
+	if me.SendAs != nil && strings.EqualFold(me.SendAsEmail, from) {
+		from = me.address()
+	}
+	subject := fmt.Sprintf("%s: %s", rsvpVerbs[status], inv.summary)
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", icsAddress(organizer))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	b.WriteString("MIME-Version: 1.0\r\n")
+
+	w := multipart.NewWriter(&b)
+	fmt.Fprintf(&b, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", w.Boundary())
+	text, err := w.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {`text/plain; charset="UTF-8"`},
+		"Content-Transfer-Encoding": {"8bit"},
+	})
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintf(text, "%s has %s this invitation.\r\n", icsAddress(attendee), strings.ToLower(rsvpVerbs[status]))
+
+	part, err := w.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {`text/calendar; charset="UTF-8"; method=REPLY`},
+		"Content-Transfer-Encoding": {"8bit"},
+	})
+	if err != nil {
+		return "", err
+	}
+	part.Write([]byte(ics.String()))
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	return base64.URLEncoding.EncodeToString(b.Bytes()), nil
+}
+
+// foldICS ends an iCalendar line, folding it into 75 octet pieces without
+// splitting UTF-8 sequences.
+func foldICS(line string) string {
+	var b strings.Builder
+	for len(line) > 75 {
+		n := 75
+		for n > 0 && line[n]&0xC0 == 0x80 {
+			n--
+		}
+		b.WriteString(line[:n] + "\r\n ")
+		line = line[n:]
+	}
+	b.WriteString(line + "\r\n")
+	return b.String()
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^\w.-]+`)
+
+// exportInvite saves the calendar to the downloads folder and opens it with
+// the system calendar app.
+func (m Model) exportInvite(inv *invite) Model {
+	dir := m.config.downloadDir()
+	name := strings.Trim(unsafeFileChars.ReplaceAllString(inv.summary, "-"), "-")
+	if name == "" {
+		name = "invite"
+	}
+	path := filepath.Join(dir, name+".ics")
+	if err := os.WriteFile(path, []byte(inv.raw), 0644); err != nil {
+		m.status = fmt.Sprintf("Unable to save invitation: %v", err)
+		return m
+	}
+	if err := browser.Open(path); err != nil {
+		m.status = "Saved " + path
+		return m
+	}
+	m.status = "Saved and opened " + path
+	return m
+}
+
+func dirExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
@@ -0,0 +1,94 @@
+package app
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"google.golang.org/api/gmail/v1"
+
+	"gmail-tui/internal/ui/view"
+)
+
+// chipStyle is a label without a color of its own set in Gmail.
+var chipStyle = lipgloss.NewStyle().
+	Padding(0, 1).
+	Background(lipgloss.Color("#444444")).
+	Foreground(lipgloss.Color("#FFFFFF"))
+
+// labelChip draws a label's name in the colors it has in Gmail. In
+// accessible mode it is bracketed instead, as the colors add nothing to a
+// screen reader.
+func labelChip(l *gmail.Label) string {
+	if view.Accessible {
+		return "[" + l.Name + "]"
+	}
+	style := chipStyle
+	if c := l.Color; c != nil && c.BackgroundColor != "" {
+		style = style.Background(lipgloss.Color(c.BackgroundColor)).Foreground(lipgloss.Color(c.TextColor))
+	}
+	return style.Render(l.Name)
+}
+
+// chipped reports whether l is shown as a chip: your own labels, but not
+// the ones gmail-tui keeps for snoozing, follow-ups and muting.
+func chipped(l *gmail.Label) bool {
+	return l.Type == "user" && !strings.HasPrefix(l.Name, "gmail-tui/")
+}
+
+// chips draws e's own labels, or "" if it has none or the labels aren't
+// loaded yet.
+func (idx labelIndex) chips(e Email) string {
+	var chips []string
+	for _, id := range e.Labels {
+		if l, ok := idx[id]; ok && chipped(l) {
+			chips = append(chips, labelChip(l))
+		}
+	}
+	return strings.Join(chips, " ")
+}
+
+// names returns the names of e's own labels.
+func (idx labelIndex) names(e Email) []string {
+	var names []string
+	for _, id := range e.Labels {
+		if l, ok := idx[id]; ok && chipped(l) {
+			names = append(names, l.Name)
+		}
+	}
+	return names
+}
+
+// headerChips are the chips shown after the subject of an open message.
+func (idx labelIndex) headerChips(e Email) string {
+	if chips := idx.chips(e); chips != "" {
+		return " " + chips
+	}
+	return ""
+}
+
+// missing reports whether any of emails has a label that isn't in idx, so
+// the labels need loading again to show its chip.
+func (idx labelIndex) missing(emails []Email) bool {
+	for _, e := range emails {
+		for _, id := range e.Labels {
+			if _, ok := idx[id]; !ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// chippedEmail is a list row for the default layout, with label chips
+// after the subject.
+type chippedEmail struct {
+	Email
+	chips string
+}
+
+func (e chippedEmail) Title() string {
+	if e.chips == "" {
+		return e.Email.Title()
+	}
+	return e.Email.Title() + " " + e.chips
+}
@@ -0,0 +1,80 @@
+package app
+
+import (
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/sahilm/fuzzy"
+)
+
+// gotoChords maps the key pressed after g to a goto place, like the
+// shortcuts of Gmail on the web. g l opens the label picker instead, and
+// g u moves to the first unread message.
+var gotoChords = map[string]string{
+	"i": "inbox",
+	"s": "starred",
+	"t": "sent",
+	"d": "drafts",
+}
+
+// labelsMsg carries the labels for the label picker.
+type labelsMsg struct {
+	labels labelIndex
+}
+
+// chord runs the g chord ending in msg. Any other key after g just cancels
+// it, so a mistyped chord never acts on the selected message.
+func (m Model) chord(msg tea.KeyMsg) (Model, tea.Cmd, bool) {
+	s := msg.String()
+	if s == "l" {
+		m, cmd := m.openLabelPicker()
+		return m, cmd, true
+	}
+	if s == "u" {
+		m, cmd := m.firstUnread()
+		return m, cmd, true
+	}
+	if place, ok := gotoChords[s]; ok {
+		m, cmd := m.gotoPlace(place)
+		return m, cmd, true
+	}
+	return m, nil, true
+}
+
+// openLabelPicker opens the command prompt at goto, suggesting label names
+// as they are typed.
+func (m Model) openLabelPicker() (Model, tea.Cmd) {
+	m, blink := m.openPalette()
+	m.palette.Input.SetValue("goto ")
+	m.palette.Input.CursorEnd()
+	return m, tea.Batch(blink, func() tea.Msg {
+		labels, err := fetchLabels(m.ctx, m.gmailSvc)
+		if err != nil {
+			return errMsg(err)
+		}
+		return labelsMsg{labels: labels}
+	})
+}
+
+// matchLabels returns the names of your own labels that fuzzily match
+// name, best first, or all of them in order if name is empty.
+func (m Model) matchLabels(name string) []string {
+	var names []string
+	for _, l := range m.labels {
+		if l.Type == "user" {
+			names = append(names, l.Name)
+		}
+	}
+	slices.SortFunc(names, func(a, b string) int {
+		return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+	})
+	if name == "" {
+		return names
+	}
+	var out []string
+	for _, match := range fuzzy.Find(name, names) {
+		out = append(out, names[match.Index])
+	}
+	return out
+}
@@ -0,0 +1,230 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"google.golang.org/api/gmail/v1"
+
+	"gmail-tui/internal/ui/compose"
+)
+
+// runCommand runs a non-interactive subcommand, writing results to stdout
+// so they can be used in shell pipelines.
+func (m Model) runCommand(args []string) error {
+	switch args[0] {
+	case "list", "search":
+		return m.listCommand(args[0], args[1:])
+	case "send":
+		return m.sendCommand(args[1:])
+	case "labels":
+		return m.labelsCommand(args[1:])
+	case "save-attachments":
+		return m.saveAttachmentsCommand(args[1:])
+	case "export":
+		return m.exportCommand(args[1:])
+	case "sync":
+		return m.syncCommand(args[1:])
+	case "status":
+		return m.statusCommand(args[1:])
+	case "daemon":
+		return m.daemonCommand(args[1:])
+	}
+	return fmt.Errorf("unknown command %q; run gmail-tui -h for usage", args[0])
+}
+
+// outputFlags adds --output and its --json shorthand to fs. The returned
+// function reports whether JSON output was asked for.
+func outputFlags(fs *flag.FlagSet) func() (bool, error) {
+	output := fs.String("output", "text", "output format: text or json (one object per line)")
+	asJSON := fs.Bool("json", false, "short for --output json")
+	return func() (bool, error) {
+		switch {
+		case *asJSON || *output == "json":
+			return true, nil
+		case *output == "text":
+			return false, nil
+		}
+		return false, fmt.Errorf("unknown output format %q; want text or json", *output)
+	}
+}
+
+// printJSONLines writes each item as one line of JSON.
+func printJSONLines[T any](items []T) error {
+	enc := json.NewEncoder(os.Stdout)
+	for _, item := range items {
+		if err := enc.Encode(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// emailJSON is how a message is printed with --output json.
+type emailJSON struct {
+	ID       string    `json:"id"`
+	ThreadID string    `json:"threadId"`
+	From     string    `json:"from"`
+	Subject  string    `json:"subject"`
+	Date     time.Time `json:"date"`
+	Labels   []string  `json:"labels"`
+	Snippet  string    `json:"snippet"`
+}
+
+// listCommand implements list, which takes the query as --query, and
+// search, which takes it as arguments.
+func (m Model) listCommand(name string, args []string) error {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	query := fs.String("query", "", "Gmail search query, e.g. \"is:unread\"")
+	max := fs.Int64("max", 20, "maximum number of messages")
+	asJSON := outputFlags(fs)
+	fs.Parse(args)
+
+	if name == "search" {
+		if fs.NArg() == 0 {
+			return errors.New("search: a query is required")
+		}
+		*query = strings.Join(fs.Args(), " ")
+	}
+	jsonOut, err := asJSON()
+	if err != nil {
+		return err
+	}
+
+	emails, failed, err := m.listEmails(m.ctx, *query, *max)
+	if err != nil {
+		return fmt.Errorf("unable to list messages: %v", err)
+	}
+	if failed > 0 {
+		fmt.Fprintf(os.Stderr, "%d messages could not be loaded\n", failed)
+	}
+
+	if jsonOut {
+		items := make([]emailJSON, 0, len(emails))
+		for _, e := range emails {
+			items = append(items, emailJSON{
+				ID:       e.ID,
+				ThreadID: e.ThreadID,
+				From:     e.From,
+				Subject:  e.Subject,
+				Date:     e.Date,
+				Labels:   e.Labels,
+				Snippet:  e.Snippet,
+			})
+		}
+		return printJSONLines(items)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, e := range emails {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", e.ID, e.Date.Local().Format("2006-01-02 15:04"), e.From, e.Subject)
+	}
+	return w.Flush()
+}
+
+func (m Model) sendCommand(args []string) error {
+	fs := flag.NewFlagSet("send", flag.ExitOnError)
+	var d compose.Draft
+	fs.StringVar(&d.To, "to", "", "recipients, comma separated")
+	fs.StringVar(&d.Cc, "cc", "", "Cc recipients")
+	fs.StringVar(&d.Bcc, "bcc", "", "Bcc recipients")
+	fs.StringVar(&d.From, "from", "", "send-as address (default: your primary address)")
+	fs.StringVar(&d.Subject, "subject", "", "subject")
+	fs.BoolVar(&d.Markdown, "markdown", m.config.ComposeMarkdown, "send the body as Markdown with an HTML rendering")
+	fs.BoolVar(&d.Sign, "sign", m.config.PGPSign, "sign the message with gpg")
+	fs.BoolVar(&d.Encrypt, "encrypt", false, "encrypt the message with gpg to every recipient")
+	bodyFile := fs.String("body-file", "", "file to read the body from, or - for stdin")
+	fs.Func("attach", "file to attach (repeatable)", func(path string) error {
+		a, err := localAttachment(path)
+		if err != nil {
+			return err
+		}
+		d.Attachments = append(d.Attachments, a)
+		return nil
+	})
+	fs.Parse(args)
+
+	if d.To == "" && d.Cc == "" && d.Bcc == "" {
+		return errors.New("send: at least one of --to, --cc or --bcc is required")
+	}
+
+	if *bodyFile != "" {
+		var r io.Reader = os.Stdin
+		if *bodyFile != "-" {
+			f, err := os.Open(*bodyFile)
+			if err != nil {
+				return fmt.Errorf("unable to read body: %v", err)
+			}
+			defer f.Close()
+			r = f
+		}
+		body, err := io.ReadAll(r)
+		if err != nil {
+			return fmt.Errorf("unable to read body: %v", err)
+		}
+		d.Body = string(body)
+	}
+
+	raw, err := rawMessage(m.ctx, m.mail, d)
+	if err != nil {
+		return err
+	}
+	msg, err := m.mail.Send(m.ctx, raw)
+	if err != nil {
+		return fmt.Errorf("unable to send message: %v", err)
+	}
+	runHook("send", m.config.Hooks.OnSend, sentEvent(msg, d))
+	fmt.Println(msg.Id)
+	return nil
+}
+
+// labelJSON is how a label is printed with --output json.
+type labelJSON struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+func (m Model) labelsCommand(args []string) error {
+	fs := flag.NewFlagSet("labels", flag.ExitOnError)
+	asJSON := outputFlags(fs)
+	fs.Parse(args)
+	jsonOut, err := asJSON()
+	if err != nil {
+		return err
+	}
+
+	idx, err := fetchLabels(m.ctx, m.gmailSvc)
+	if err != nil {
+		return err
+	}
+	labels := make([]*gmail.Label, 0, len(idx))
+	for _, l := range idx {
+		labels = append(labels, l)
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		return strings.ToLower(labels[i].Name) < strings.ToLower(labels[j].Name)
+	})
+
+	if jsonOut {
+		items := make([]labelJSON, 0, len(labels))
+		for _, l := range labels {
+			items = append(items, labelJSON{ID: l.Id, Name: l.Name, Type: strings.ToLower(l.Type)})
+		}
+		return printJSONLines(items)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, l := range labels {
+		fmt.Fprintf(w, "%s\t%s\n", l.Name, l.Id)
+	}
+	return w.Flush()
+}
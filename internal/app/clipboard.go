@@ -0,0 +1,77 @@
+package app
+
+import (
+	"fmt"
+	"net/mail"
+	"os"
+
+	"github.com/atotto/clipboard"
+	"github.com/aymanbagabas/go-osc52/v2"
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"gmail-tui/internal/mimepart"
+)
+
+// copyToClipboard writes text to the system clipboard and also emits an
+// OSC 52 sequence, which reaches the local clipboard over SSH in terminals
+// that support it.
+func copyToClipboard(text string) error {
+	seq := osc52.New(text)
+	if os.Getenv("TMUX") != "" {
+		seq = seq.Tmux()
+	}
+	seq.WriteTo(os.Stderr)
+
+	if clipboard.Unsupported {
+		return nil
+	}
+	return clipboard.WriteAll(text)
+}
+
+// senderAddress returns the bare address from a From header.
+func senderAddress(from string) string {
+	if a, err := mimepart.AddressParser.Parse(from); err == nil {
+		return a.Address
+	}
+	if a, err := mail.ParseAddress(from); err == nil {
+		return a.Address
+	}
+	return from
+}
+
+// updateYank handles the key after the copy prefix.
+func (m Model) updateYank(msg tea.KeyMsg, e Email) (Model, tea.Cmd) {
+	m.yankPending = false
+
+	var what, text string
+	switch {
+	case key.Matches(msg, m.keys.YankBody) && !e.loaded:
+		m.status = "Loading message..."
+		return m, m.fetchBody(e.ID, true)
+	case key.Matches(msg, m.keys.YankBody) && e.cut > 0:
+		m.status = "Loading all of the message..."
+		return m, m.loadBody(e.ID, 0, true)
+	case key.Matches(msg, m.keys.YankBody):
+		what, text = "body", e.Body
+	case key.Matches(msg, m.keys.YankSender):
+		what, text = "sender address", senderAddress(e.From)
+	case key.Matches(msg, m.keys.YankSubject):
+		what, text = "subject", e.Subject
+	case key.Matches(msg, m.keys.YankLink):
+		what, text = "Gmail link", gmailWebURL(m.config.AccountIndex, e.ID)
+	default:
+		return m, nil
+	}
+
+	return m.copyText(what, text), nil
+}
+
+func (m Model) copyText(what, text string) Model {
+	if err := copyToClipboard(text); err != nil {
+		m.status = fmt.Sprintf("Unable to copy %s: %v", what, err)
+		return m
+	}
+	m.status = fmt.Sprintf("Copied %s", what)
+	return m
+}
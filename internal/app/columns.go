@@ -0,0 +1,200 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"net/mail"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+
+	"gmail-tui/internal/config"
+	"gmail-tui/internal/ui/maillist"
+	"gmail-tui/internal/ui/view"
+)
+
+// columns returns the configured list columns, dropping unknown ones. It
+// is nil when the rows keep their default layout.
+func (c Config) columns() []config.ListColumn {
+	var cols []config.ListColumn
+	for _, col := range c.ListColumns {
+		if _, ok := maillist.Columns[col.Name]; ok {
+			cols = append(cols, col)
+		}
+	}
+	if len(cols) == 0 && c.compact() {
+		return maillist.CompactColumns
+	}
+	return cols
+}
+
+func (c Config) compact() bool {
+	return c.ListDensity == "compact"
+}
+
+// emailDelegate builds the delegate that draws the message list with the
+// configured columns, density and grouping.
+func (m Model) emailDelegate() list.ItemDelegate {
+	d := view.ItemDelegate()
+	if view.Accessible {
+		d = view.PlainDelegate(d)
+	}
+	if m.config.compact() {
+		d.ShowDescription = false
+		d.SetSpacing(0)
+	}
+	rows := emailDelegate{DefaultDelegate: d, columns: m.config.columns(), labels: m.labels}
+	if header := listHeader(m.listView()); header != nil {
+		return maillist.Grouped{ItemDelegate: rows, Header: header}
+	}
+	return rows
+}
+
+// setDensity switches the list between one and two lines per message and
+// saves the choice.
+func (m Model) setDensity(density string) (Model, tea.Cmd) {
+	switch density {
+	case "":
+		density = "compact"
+		if m.config.compact() {
+			density = "comfortable"
+		}
+	case "compact", "comfortable":
+	default:
+		m.status = fmt.Sprintf("Unknown density %q; want compact or comfortable", density)
+		return m, nil
+	}
+	m.config.ListDensity = density
+	m.list.SetDelegate(m.emailDelegate())
+	m.status = "List density: " + density
+	if err := config.SaveValue("list_density", density); err != nil {
+		m.status = err.Error()
+	}
+	return m, nil
+}
+
+// fetchListLabels loads the labels for the label chips.
+func (m Model) fetchListLabels() tea.Msg {
+	labels, err := fetchLabels(m.ctx, m.gmailSvc)
+	if err != nil {
+		return nil
+	}
+	return labelsMsg{labels: labels}
+}
+
+// renderColumns draws e as a row of the configured columns and, unless the
+// list is compact, a second line with its snippet.
+func (d emailDelegate) renderColumns(w io.Writer, m list.Model, index int, e Email) {
+	title, desc := d.Styles.NormalTitle, d.Styles.NormalDesc
+	if index == m.Index() && m.FilterState() != list.Filtering {
+		title, desc = d.Styles.SelectedTitle, d.Styles.SelectedDesc
+	}
+	width := max(m.Width()-title.GetHorizontalFrameSize(), 1)
+	words := filterWords(m)
+	fmt.Fprint(w, title.Render(d.row(e, width, func(cell string) string { return d.highlight(cell, words, title) })))
+	if !d.ShowDescription {
+		return
+	}
+
+	var more []string
+	if e.hit != "" {
+		more = append(more, e.hit)
+	}
+	if e.muted {
+		more = append(more, "muted")
+	}
+	if snippet, _, _ := strings.Cut(e.Snippet, "\n"); snippet != "" && !slices.ContainsFunc(d.columns, func(c config.ListColumn) bool { return c.Name == "snippet" }) {
+		more = append(more, snippet)
+	}
+	if e.Extra != "" {
+		more = append(more, e.Extra)
+	}
+	fmt.Fprint(w, "\n"+desc.Render(d.highlight(ansi.Truncate(strings.Join(more, " | "), width, "…"), words, desc)))
+}
+
+// row lays out e's columns in width cells. Columns without a width share
+// what the others leave. mark styles the text of each cell but the label
+// chips.
+func (d emailDelegate) row(e Email, width int, mark func(string) string) string {
+	widths := make([]int, len(d.columns))
+	used, flex := len(d.columns)-1, 0
+	for i, c := range d.columns {
+		widths[i] = c.Width
+		if widths[i] <= 0 {
+			widths[i] = maillist.Columns[c.Name]
+		}
+		if widths[i] == 0 {
+			flex++
+		}
+		used += widths[i]
+	}
+	for i := range widths {
+		if widths[i] == 0 {
+			widths[i] = max((width-used)/flex, 1)
+			used += widths[i]
+			flex--
+		}
+	}
+
+	cells := make([]string, len(d.columns))
+	for i, c := range d.columns {
+		cell := ansi.Truncate(d.cell(e, c.Name), widths[i], "…")
+		pad := strings.Repeat(" ", max(widths[i]-ansi.StringWidth(cell), 0))
+		if c.Name != "labels" {
+			cell = mark(cell)
+		}
+		if i < len(d.columns)-1 {
+			cell += pad
+		}
+		cells[i] = cell
+	}
+	return ansi.Truncate(strings.Join(cells, " "), width, "")
+}
+
+func (d emailDelegate) cell(e Email, column string) string {
+	switch column {
+	case "star":
+		if e.hasLabel("STARRED") {
+			return "★"
+		}
+	case "unread":
+		if e.hasLabel("UNREAD") {
+			return "●"
+		}
+	case "files":
+		if e.hasFiles() {
+			return paperclip
+		}
+	case "sender":
+		if role, who := e.correspondent(); role == "To" {
+			return "To: " + who
+		}
+		if a, err := mail.ParseAddress(e.From); err == nil {
+			if a.Name != "" {
+				return a.Name
+			}
+			return a.Address
+		}
+		return e.From
+	case "subject":
+		if slices.ContainsFunc(d.columns, func(c config.ListColumn) bool { return c.Name == "files" }) {
+			return e.markedSubject()
+		}
+		return e.Title()
+	case "snippet":
+		snippet, _, _ := strings.Cut(e.Snippet, "\n")
+		return snippet
+	case "labels":
+		return d.labels.chips(e)
+	case "size":
+		if e.Size > 0 {
+			return view.Size(e.Size)
+		}
+	case "date":
+		return view.ListDate(e.Date)
+	}
+	return ""
+}
@@ -0,0 +1,280 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"gmail-tui/internal/backend"
+	"gmail-tui/internal/ui/compose"
+	"gmail-tui/internal/ui/view"
+)
+
+type editorFinishedMsg struct {
+	session *compose.Session
+	err     error
+}
+
+type composeSavedMsg struct {
+	session *compose.Session
+	status  string
+}
+
+// composeChangedMsg reports that a change made from the compose view, rather
+// than in the editor, has been saved to the draft.
+type composeChangedMsg struct {
+	session *compose.Session
+	status  string
+}
+
+// rawMessage builds the message for d as the Gmail API's Raw field takes
+// it, wrapped in PGP/MIME if d is marked for signing or encryption.
+func rawMessage(ctx context.Context, mp backend.Provider, d compose.Draft) (string, error) {
+	return compose.Raw(ctx, mp, d, protectEntity)
+}
+
+// editCompose suspends the TUI and opens the compose file in the user's
+// editor, or in the built-in one, autosaving in the background.
+func (m Model) editCompose(c *compose.Session) (Model, tea.Cmd) {
+	if m.config.builtinEditor() {
+		return m.openEditor(c)
+	}
+	c.StartAutosave(m.ctx, m.mail, m.config.DraftAutosaveInterval())
+	return m, tea.ExecProcess(compose.EditorCommand(c.Path()), func(err error) tea.Msg {
+		return editorFinishedMsg{session: c, err: err}
+	})
+}
+
+// finishCompose stops autosaving and makes a final save of the draft.
+// Cancelling the upload of a large draft leaves it as last saved.
+func (m Model) finishCompose(c *compose.Session) tea.Cmd {
+	return tea.Batch(func() tea.Msg {
+		c.StopAutosave()
+		err := c.Save(m.ctx, m.mail)
+		switch {
+		case errors.Is(err, compose.ErrUploadCancelled):
+			return composeSavedMsg{session: c, status: uploadCancelledStatus}
+		case err != nil:
+			return errMsg(err)
+		}
+		return composeSavedMsg{session: c}
+	}, uploadTick(c))
+}
+
+// saveCompose stores the draft after it was changed from the compose view.
+func (m Model) saveCompose(c *compose.Session, status string) tea.Cmd {
+	return tea.Batch(func() tea.Msg {
+		err := c.Save(m.ctx, m.mail)
+		switch {
+		case errors.Is(err, compose.ErrUploadCancelled):
+			return composeChangedMsg{session: c, status: uploadCancelledStatus}
+		case err != nil:
+			return errMsg(err)
+		}
+		return composeChangedMsg{session: c, status: status}
+	}, uploadTick(c))
+}
+
+// openCompose starts a compose session for d and opens it in the editor.
+// New messages are sent from the default alias, with its signature, or
+// just get the default signature when there are no send-as addresses.
+func (m Model) openCompose(d compose.Draft) (Model, tea.Cmd) {
+	if d.ID == "" && d.From == "" {
+		if a, ok := m.defaultAlias(); ok {
+			d = m.withAlias(d, a)
+		} else {
+			d.Body = m.config.addSignature(d.Body, signatureBlock(m.config.Signatures[defaultSignatureKey]))
+		}
+	}
+	d.Markdown = m.config.ComposeMarkdown
+	d.Sign = m.config.PGPSign
+	c, err := compose.NewSession(d, protectEntity)
+	if err != nil {
+		m = m.fail(err)
+		return m, nil
+	}
+	m.composeReturn = m.state
+	m.compose = c
+	m.previewing = false
+	return m.editCompose(c)
+}
+
+func (m Model) updateCompose(msg tea.KeyMsg) (Model, tea.Cmd) {
+	if m.editor != nil {
+		return m.updateEditor(msg)
+	}
+	if m.attaching {
+		return m.updateAttach(msg)
+	}
+	if m.addressField != "" {
+		return m.updateAddress(msg)
+	}
+	if m.pickingAlias {
+		return m.updateAliasPicker(msg)
+	}
+	if m.scheduling {
+		return m.updateSchedule(msg)
+	}
+
+	switch {
+	case key.Matches(msg, m.keys.Edit):
+		return m.editCompose(m.compose)
+	case key.Matches(msg, m.keys.Preview) && m.compose.Snapshot().Markdown:
+		m.previewing = !m.previewing
+		m.viewport.SetContent(m.composeContent())
+		m.viewport.GotoTop()
+	case key.Matches(msg, m.keys.PGP):
+		return m.cyclePGP()
+	case key.Matches(msg, m.keys.FollowUp):
+		return m.startFollowUp(nil)
+	case key.Matches(msg, m.keys.EditTo):
+		return m.editAddress("To")
+	case key.Matches(msg, m.keys.EditCc):
+		return m.editAddress("Cc")
+	case key.Matches(msg, m.keys.EditBcc):
+		return m.editAddress("Bcc")
+	case key.Matches(msg, m.keys.From):
+		return m.openAliasPicker(), nil
+	case key.Matches(msg, m.keys.Attach):
+		m.attaching = true
+		m.attachInput.Reset()
+		return m, m.attachInput.Focus()
+	case key.Matches(msg, m.keys.Detach):
+		if m.compose.RemoveLastAttachment() {
+			m.loading = true
+			return m, m.saveCompose(m.compose, "Attachments updated")
+		}
+	case key.Matches(msg, m.keys.Send):
+		if m.status = unsendable(m.compose); m.status != "" {
+			return m, nil
+		}
+		m.confirming = true
+	case key.Matches(msg, m.keys.SendLater):
+		if m.status = unsendable(m.compose); m.status != "" {
+			return m, nil
+		}
+		if m.compose.Unsaved() {
+			m.status = "The draft isn't saved yet, so it can't be scheduled"
+			return m, nil
+		}
+		if d := m.compose.Snapshot(); d.Sign || d.Encrypt {
+			m.status = "Signed or encrypted messages can't be scheduled"
+			return m, nil
+		}
+		m.scheduling = true
+		m.scheduleInput = newScheduleInput()
+		return m, m.scheduleInput.Focus()
+	case key.Matches(msg, m.keys.Discard):
+		id := m.compose.Snapshot().ID
+		m = m.closeCompose()
+		m.status = "Draft discarded"
+		if id == "" {
+			return m, nil
+		}
+		return m, m.deleteDraft(id)
+	case key.Matches(msg, m.keys.Back):
+		saved := m.compose.Snapshot().ID != ""
+		m = m.closeCompose()
+		if saved {
+			m.status = "Draft saved"
+		}
+		if m.state == draftsView {
+			return m, m.fetchDrafts
+		}
+	case key.Matches(msg, m.keys.PageDown):
+		m.viewport.HalfViewDown()
+	case key.Matches(msg, m.keys.PageUp):
+		m.viewport.HalfViewUp()
+	case key.Matches(msg, m.keys.Down):
+		m.viewport.LineDown(1)
+	case key.Matches(msg, m.keys.Up):
+		m.viewport.LineUp(1)
+	}
+	return m, nil
+}
+
+// unsendable explains why the message in c cannot be sent yet, or returns
+// "". A message that could not be saved, say while offline, can still be
+// sent: it goes to the outbox until Gmail can be reached.
+func unsendable(c *compose.Session) string {
+	d := c.Snapshot()
+	if d.ID == "" && !c.Unsaved() {
+		return "Nothing to send"
+	}
+	if d.To == "" && d.Cc == "" && d.Bcc == "" {
+		return "Add a recipient before sending"
+	}
+	if _, bad := recipientsByHeader(d); bad != "" {
+		return "Invalid address in " + bad
+	}
+	return ""
+}
+
+func (m Model) closeCompose() Model {
+	m.compose.Remove()
+	m.compose = nil
+	m.state = m.composeReturn
+	return m
+}
+
+func (m Model) composeView() string {
+	d := m.compose.Snapshot()
+
+	subject := d.Subject
+	if subject == "" {
+		subject = "(no subject)"
+	}
+
+	var lines []string
+	lines = append(lines, view.TitleStyle.Render(subject))
+	if d.From != "" {
+		lines = append(lines, view.InfoStyle.Render(fmt.Sprintf("From: %s", d.From)))
+	}
+	lines = append(lines, view.InfoStyle.Render(fmt.Sprintf("To: %s", d.To)))
+	if d.Cc != "" {
+		lines = append(lines, view.InfoStyle.Render(fmt.Sprintf("Cc: %s", d.Cc)))
+	}
+	if d.Bcc != "" {
+		lines = append(lines, view.InfoStyle.Render(fmt.Sprintf("Bcc: %s", d.Bcc)))
+	}
+	if mode := pgpMode(d); mode != "" {
+		lines = append(lines, view.InfoStyle.Render("PGP: "+mode))
+	}
+	if !d.FollowUp.IsZero() {
+		lines = append(lines, view.InfoStyle.Render("Follow up: if no reply by "+d.FollowUp.Local().Format("Mon Jan 2 15:04")))
+	}
+	lines = append(lines, attachmentLines(d.Attachments)...)
+	lines = append(lines, view.Rule(m.viewport.Width))
+
+	help := "e: edit • f: from • t/c/b: to/cc/bcc • a: attach • A: remove attachment • P: pgp • W: follow up • s: send • L: send later • x: discard • esc: save draft & close"
+	if d.Markdown {
+		help = "p: preview • " + help
+	}
+	footer := view.HelpStyle.Render(m.statusLine() + help)
+	switch {
+	case m.attaching:
+		footer = view.HelpStyle.Render(m.statusLine() + m.attachView())
+	case m.addressField != "":
+		footer = view.HelpStyle.Render(m.statusLine() + m.addressView())
+	case m.pickingAlias:
+		footer = view.HelpStyle.Render(m.statusLine() + m.aliasPickerView())
+	case m.scheduling:
+		footer = view.HelpStyle.Render(m.statusLine() + m.scheduleInput.View() + "\nenter: schedule • esc: cancel")
+		if warnings := m.config.sendWarnings(d); len(warnings) > 0 {
+			footer = warningLines(warnings) + "\n" + footer
+		}
+	case m.followUp != nil:
+		footer = view.HelpStyle.Render(m.followUpPrompt())
+	}
+
+	return fmt.Sprintf(
+		"%s\n%s\n\n%s",
+		strings.Join(lines, "\n"),
+		m.viewport.View(),
+		footer,
+	)
+}
@@ -0,0 +1,35 @@
+package app
+
+import (
+	"gmail-tui/internal/config"
+	"gmail-tui/internal/vault"
+)
+
+// Config is the user's settings, with the helpers the interface needs to
+// read them.
+type Config struct {
+	config.Config
+
+	// vault encrypts the caches when encrypt_cache is set; it is opened
+	// by openVault.
+	vault *vault.Vault
+}
+
+func loadConfig() (Config, error) {
+	cfg, err := config.Load()
+	return Config{Config: cfg}, err
+}
+
+// openVault gets the key the caches are encrypted with, asking for the
+// passphrase if need be. The demo mailbox caches nothing.
+func (c *Config) openVault() error {
+	if c.Backend == "demo" {
+		return nil
+	}
+	v, err := vault.Open(c.EncryptCache, c.PassphraseCommand)
+	if err != nil {
+		return err
+	}
+	c.vault = v
+	return nil
+}
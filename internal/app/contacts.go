@@ -0,0 +1,223 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/sahilm/fuzzy"
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/people/v1"
+
+	"gmail-tui/internal/mimepart"
+	"gmail-tui/internal/vault"
+)
+
+// Contact is an address offered for autocompletion in recipient fields.
+type Contact struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+func (c Contact) String() string {
+	if c.Name == "" {
+		return c.Email
+	}
+	return (&mail.Address{Name: c.Name, Address: c.Email}).String()
+}
+
+// contactIndex holds Google Contacts plus recent sent-mail recipients. It is
+// cached on disk so completion works straight away on the next start, and
+// refreshed in the background.
+type contactIndex struct {
+	vault    *vault.Vault
+	mu       sync.Mutex
+	contacts []Contact
+	updated  time.Time
+}
+
+type contactsCache struct {
+	Updated  time.Time `json:"updated"`
+	Contacts []Contact `json:"contacts"`
+}
+
+type contactsRefreshedMsg struct{}
+type contactsTickMsg struct{}
+
+func contactsCachePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gmail-tui", "contacts.json"), nil
+}
+
+// loadContactIndex reads the cached index. A missing or unreadable cache
+// just yields an empty index.
+func loadContactIndex(v *vault.Vault) *contactIndex {
+	idx := &contactIndex{vault: v}
+
+	path, err := contactsCachePath()
+	if err != nil {
+		return idx
+	}
+	data, err := v.ReadFile(path)
+	if err != nil {
+		return idx
+	}
+
+	var cache contactsCache
+	if err := json.Unmarshal(data, &cache); err == nil {
+		idx.contacts = cache.Contacts
+		idx.updated = cache.Updated
+	}
+	return idx
+}
+
+func (idx *contactIndex) save() error {
+	path, err := contactsCachePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	idx.mu.Lock()
+	data, err := json.Marshal(contactsCache{Updated: idx.updated, Contacts: idx.contacts})
+	idx.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := idx.vault.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("unable to write contacts cache: %v", err)
+	}
+	return nil
+}
+
+func (idx *contactIndex) stale(maxAge time.Duration) bool {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	return time.Since(idx.updated) > maxAge
+}
+
+func (idx *contactIndex) set(contacts []Contact) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.contacts = contacts
+	idx.updated = time.Now()
+}
+
+type contactSource []Contact
+
+func (s contactSource) String(i int) string { return s[i].String() }
+func (s contactSource) Len() int            { return len(s) }
+
+// search returns up to n contacts fuzzy-matching query against name and
+// address.
+func (idx *contactIndex) search(query string, n int) []Contact {
+	idx.mu.Lock()
+	contacts := idx.contacts
+	idx.mu.Unlock()
+
+	if query == "" {
+		return nil
+	}
+
+	var results []Contact
+	for _, match := range fuzzy.FindFrom(query, contactSource(contacts)) {
+		results = append(results, contacts[match.Index])
+		if len(results) == n {
+			break
+		}
+	}
+	return results
+}
+
+// refresh rebuilds the index from Google Contacts and recent sent mail.
+// Either source may fail (e.g. the People API is not enabled for the
+// project); whatever could be fetched is kept.
+func (idx *contactIndex) refresh(ctx context.Context, gsvc *gmail.Service, psvc *people.Service) {
+	seen := map[string]bool{}
+	var contacts []Contact
+	add := func(c Contact) {
+		k := strings.ToLower(c.Email)
+		if c.Email == "" || seen[k] {
+			return
+		}
+		seen[k] = true
+		contacts = append(contacts, c)
+	}
+
+	if psvc != nil {
+		psvc.People.Connections.List("people/me").
+			PersonFields("names,emailAddresses").
+			PageSize(1000).
+			Pages(ctx, func(r *people.ListConnectionsResponse) error {
+				for _, p := range r.Connections {
+					var name string
+					if len(p.Names) > 0 {
+						name = p.Names[0].DisplayName
+					}
+					for _, e := range p.EmailAddresses {
+						add(Contact{Name: name, Email: e.Value})
+					}
+				}
+				return nil
+			})
+	}
+
+	for _, c := range recentRecipients(ctx, gsvc) {
+		add(c)
+	}
+
+	if len(contacts) == 0 {
+		return
+	}
+	idx.set(contacts)
+	idx.save()
+}
+
+// recentRecipients collects the addresses of recently sent messages.
+func recentRecipients(ctx context.Context, svc *gmail.Service) []Contact {
+	r, err := svc.Users.Messages.List("me").Q("in:sent").MaxResults(50).Context(ctx).Do()
+	if err != nil {
+		return nil
+	}
+
+	var contacts []Contact
+	for _, msg := range r.Messages {
+		m, err := svc.Users.Messages.Get("me", msg.Id).Format("metadata").MetadataHeaders("To", "Cc", "Bcc").Context(ctx).Do()
+		if err != nil || m.Payload == nil {
+			continue
+		}
+		for _, header := range m.Payload.Headers {
+			addrs, err := mimepart.AddressParser.ParseList(header.Value)
+			if err != nil {
+				continue
+			}
+			for _, a := range addrs {
+				contacts = append(contacts, Contact{Name: a.Name, Email: a.Address})
+			}
+		}
+	}
+	return contacts
+}
+
+func (m Model) refreshContacts() tea.Msg {
+	m.contacts.refresh(m.ctx, m.gmailSvc, m.peopleSvc)
+	return contactsRefreshedMsg{}
+}
+
+func contactsTick(interval time.Duration) tea.Cmd {
+	return tea.Tick(interval, func(time.Time) tea.Msg {
+		return contactsTickMsg{}
+	})
+}
@@ -0,0 +1,88 @@
+package app
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"gmail-tui/internal/backend"
+)
+
+// daemonInterval is how often the daemon refreshes when refresh_seconds
+// turns auto-refresh off.
+const daemonInterval = time.Minute
+
+// daemonProvider returns the daemon's provider in place of mail when the
+// config allows it and a daemon is running.
+func (c Config) daemonProvider(mail backend.Provider) (backend.Provider, bool) {
+	if !c.UseDaemon || c.Backend == "demo" {
+		return mail, false
+	}
+	socket, err := backend.DaemonSocket()
+	if err != nil {
+		return mail, false
+	}
+	if p, ok := backend.DialDaemon(socket); ok {
+		return p, true
+	}
+	return mail, false
+}
+
+// daemonCommand serves mail on the daemon's socket, for the app and the
+// other commands to share, until interrupted. In the background it keeps
+// the lists they ask for fresh, status.json up to date and the Maildir
+// synced.
+func (m Model) daemonCommand(args []string) error {
+	fs := flag.NewFlagSet("daemon", flag.ExitOnError)
+	fs.Parse(args)
+
+	socket, err := backend.DaemonSocket()
+	if err != nil {
+		return fmt.Errorf("unable to find the daemon socket: %v", err)
+	}
+	l, err := backend.ListenDaemon(socket)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(socket)
+
+	interval := m.config.refreshInterval()
+	if interval == 0 {
+		interval = daemonInterval
+	}
+	cache := backend.NewCache(m.mail, interval)
+	m.mail = cache
+
+	ctx, stop := signal.NotifyContext(m.ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	m.ctx = ctx
+	go m.maintain(ctx, cache, interval)
+
+	fmt.Fprintf(os.Stderr, "Listening on %s\n", socket)
+	return backend.Serve(ctx, l, cache)
+}
+
+// maintain refreshes the cache, status.json and the Maildir every interval
+// until ctx is done.
+func (m Model) maintain(ctx context.Context, cache *backend.Cache, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		m.writeStatus()
+		if m.config.Maildir != "" {
+			if _, err := m.syncMaildir(ctx, expandHome(m.config.Maildir), 0, m.config.Notmuch, nil); err != nil && ctx.Err() == nil {
+				fmt.Fprintf(os.Stderr, "Maildir sync failed: %v\n", err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		cache.Refresh(ctx)
+	}
+}
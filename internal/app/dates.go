@@ -0,0 +1,108 @@
+package app
+
+import (
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dateLayouts are tried after mail.ParseDate for Date headers written by
+// non-conforming mailers.
+var dateLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC850,
+	time.ANSIC,
+	time.RFC3339,
+	"Mon, 2 Jan 2006 15:04:05 -0700 (MST)",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04 -0700",
+}
+
+// parseDate parses a Date header, returning the zero time if no known
+// layout matches.
+func parseDate(value string) time.Time {
+	value = strings.TrimSpace(value)
+	if d, err := mail.ParseDate(value); err == nil {
+		return d
+	}
+	for _, layout := range dateLayouts {
+		if d, err := time.Parse(layout, value); err == nil {
+			return d
+		}
+	}
+	return time.Time{}
+}
+
+// dayStartHour is the hour used for a future day given without a time.
+const dayStartHour = 8
+
+// futureTimeHint lists examples of what parseFutureTime accepts.
+const futureTimeHint = "tomorrow, weekend, monday, 3h, 2d, 18:00, 2006-01-02 09:00"
+
+var relativeDuration = regexp.MustCompile(`^(\d+)\s*([mhdw])$`)
+
+// parseFutureTime parses a time entered for snoozing or sending later,
+// such as "tomorrow", "3h" or "2006-01-02 15:04". Days without a time of day
+// mean dayStartHour on that day.
+func parseFutureTime(s string, now time.Time) (time.Time, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	now = now.Local()
+	morning := func(days int) time.Time {
+		d := now.AddDate(0, 0, days)
+		return time.Date(d.Year(), d.Month(), d.Day(), dayStartHour, 0, 0, 0, now.Location())
+	}
+	nextWeekday := func(wd time.Weekday) time.Time {
+		days := (int(wd) - int(now.Weekday()) + 7) % 7
+		if days == 0 {
+			days = 7
+		}
+		return morning(days)
+	}
+
+	var t time.Time
+	switch s {
+	case "":
+		return t, fmt.Errorf("enter a time")
+	case "tomorrow":
+		t = morning(1)
+	case "tonight":
+		t = time.Date(now.Year(), now.Month(), now.Day(), 18, 0, 0, 0, now.Location())
+	case "weekend":
+		t = nextWeekday(time.Saturday)
+	case "monday", "next week":
+		t = nextWeekday(time.Monday)
+	default:
+		if m := relativeDuration.FindStringSubmatch(s); m != nil {
+			n, _ := strconv.Atoi(m[1])
+			unit := map[string]time.Duration{"m": time.Minute, "h": time.Hour, "d": 24 * time.Hour, "w": 7 * 24 * time.Hour}[m[2]]
+			t = now.Add(time.Duration(n) * unit)
+			break
+		}
+		if d, err := time.ParseInLocation("2006-01-02 15:04", s, time.Local); err == nil {
+			t = d
+			break
+		}
+		if d, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+			t = d.Add(dayStartHour * time.Hour)
+			break
+		}
+		if d, err := time.ParseInLocation("15:04", s, time.Local); err == nil {
+			t = time.Date(now.Year(), now.Month(), now.Day(), d.Hour(), d.Minute(), 0, 0, now.Location())
+			if !t.After(now) {
+				t = t.AddDate(0, 0, 1)
+			}
+			break
+		}
+		return t, fmt.Errorf("unrecognized time %q", s)
+	}
+
+	if !t.After(now) {
+		return t, fmt.Errorf("%s is in the past", t.Format("Mon Jan 2 15:04"))
+	}
+	return t, nil
+}
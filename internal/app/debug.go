@@ -0,0 +1,190 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+	"google.golang.org/api/gmail/v1"
+
+	"gmail-tui/internal/backend"
+)
+
+// debugLog receives the --debug log. Without --debug nothing is enabled, so
+// logging costs no more than the level check.
+var debugLog = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError + 1}))
+
+// stateDir is where gmail-tui keeps what it writes for itself between
+// runs: gmail-tui in $XDG_STATE_HOME, or in ~/.local/state when it is
+// unset.
+func stateDir() (string, error) {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, "gmail-tui"), nil
+}
+
+// debugLogPath is where --debug writes.
+func debugLogPath() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "debug.log"), nil
+}
+
+// openDebugLog starts logging to the debug log, appending to what earlier
+// runs wrote.
+func openDebugLog() (*os.File, error) {
+	path, err := debugLogPath()
+	if err != nil {
+		return nil, fmt.Errorf("unable to find the debug log: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("unable to create %s: %v", filepath.Dir(path), err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open the debug log: %v", err)
+	}
+	debugLog = slog.New(slog.NewTextHandler(f, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	debugLog.Info("start", "pid", os.Getpid(), "args", os.Args[1:])
+	return f, nil
+}
+
+// quietLog stops the standard logger from writing over the UI while it is
+// running, sending its output to the debug log instead. The returned
+// function puts it back on stderr.
+func quietLog(debugFile *os.File) func() {
+	var w io.Writer = io.Discard
+	if debugFile != nil {
+		w = debugFile
+	}
+	log.SetOutput(w)
+	return func() { log.SetOutput(os.Stderr) }
+}
+
+// logMsg records a message handled by Update and how long handling it took.
+// Spinner frames are left out, since they arrive ten times a second.
+func logMsg(msg tea.Msg, took time.Duration, before, after Model) {
+	if _, ok := msg.(spinner.TickMsg); ok || !debugLog.Enabled(context.Background(), slog.LevelDebug) {
+		return
+	}
+	debugLog.Debug("message", "type", fmt.Sprintf("%T", msg), "took", took)
+	if after.failure != nil && (before.failure == nil || before.failure.err != after.failure.err) {
+		debugLog.Error("operation failed", "err", after.failure.err, "retryable", after.failure.retry != nil)
+	}
+}
+
+// debugTransport logs each Gmail and People API request with its status and
+// how long the response took to start arriving.
+type debugTransport struct {
+	base http.RoundTripper
+}
+
+func (t debugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !debugLog.Enabled(req.Context(), slog.LevelDebug) {
+		return t.base.RoundTrip(req)
+	}
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		debugLog.Error("api request", "method", req.Method, "url", req.URL.Redacted(), "took", time.Since(start), "err", err)
+		return resp, err
+	}
+	debugLog.Debug("api request", "method", req.Method, "url", req.URL.Redacted(), "status", resp.StatusCode, "took", time.Since(start))
+	return resp, nil
+}
+
+// debugProvider logs and counts each mail operation, whichever backend it
+// goes to.
+type debugProvider struct {
+	backend.Provider
+}
+
+func logCall(op string, start time.Time, err error, args ...any) {
+	took := time.Since(start)
+	sessionMetrics.op(op, took, err)
+	args = append(args, "took", took)
+	if err != nil {
+		debugLog.Error(op, append(args, "err", err)...)
+		return
+	}
+	debugLog.Debug(op, args...)
+}
+
+func (p debugProvider) List(ctx context.Context, query string, max int64, headers ...string) ([]*gmail.Message, int, error) {
+	start := time.Now()
+	msgs, failed, err := p.Provider.List(ctx, query, max, headers...)
+	logCall("list", start, err, "query", query, "max", max, "got", len(msgs), "failed", failed)
+	return msgs, failed, err
+}
+
+func (p debugProvider) Get(ctx context.Context, id, format string) (*gmail.Message, error) {
+	start := time.Now()
+	msg, err := p.Provider.Get(ctx, id, format)
+	logCall("get", start, err, "id", id, "format", format)
+	return msg, err
+}
+
+func (p debugProvider) Attachment(ctx context.Context, messageID, attachmentID string) ([]byte, error) {
+	start := time.Now()
+	data, err := p.Provider.Attachment(ctx, messageID, attachmentID)
+	logCall("attachment", start, err, "id", messageID, "bytes", len(data))
+	return data, err
+}
+
+func (p debugProvider) Modify(ctx context.Context, id string, add, remove []string) (*gmail.Message, error) {
+	start := time.Now()
+	msg, err := p.Provider.Modify(ctx, id, add, remove)
+	logCall("modify", start, err, "id", id, "add", add, "remove", remove)
+	return msg, err
+}
+
+func (p debugProvider) Trash(ctx context.Context, id string) (*gmail.Message, error) {
+	start := time.Now()
+	msg, err := p.Provider.Trash(ctx, id)
+	logCall("trash", start, err, "id", id)
+	return msg, err
+}
+
+func (p debugProvider) Untrash(ctx context.Context, id string) (*gmail.Message, error) {
+	start := time.Now()
+	msg, err := p.Provider.Untrash(ctx, id)
+	logCall("untrash", start, err, "id", id)
+	return msg, err
+}
+
+func (p debugProvider) SaveDraft(ctx context.Context, id, raw string) (string, error) {
+	start := time.Now()
+	newID, err := p.Provider.SaveDraft(ctx, id, raw)
+	logCall("save draft", start, err, "id", id, "saved_as", newID)
+	return newID, err
+}
+
+func (p debugProvider) SendDraft(ctx context.Context, id string) (*gmail.Message, error) {
+	start := time.Now()
+	msg, err := p.Provider.SendDraft(ctx, id)
+	logCall("send draft", start, err, "id", id)
+	return msg, err
+}
+
+func (p debugProvider) Send(ctx context.Context, raw string) (*gmail.Message, error) {
+	start := time.Now()
+	msg, err := p.Provider.Send(ctx, raw)
+	logCall("send", start, err)
+	return msg, err
+}
@@ -0,0 +1,204 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/progress"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"gmail-tui/internal/backend"
+	"gmail-tui/internal/ui/compose"
+)
+
+// attachmentSave tracks "save all" downloading a message's attachments one
+// after another.
+type attachmentSave struct {
+	id    string
+	files []compose.Attachment
+	dir   string
+	next  int
+	paths []string
+	bar   progress.Model
+}
+
+// attachmentSavedMsg reports that the next attachment of a save was
+// written to path.
+type attachmentSavedMsg struct {
+	save *attachmentSave
+	path string
+	err  error
+}
+
+// downloadDir returns the directory attachments are saved to: the
+// download_dir setting, else ~/Downloads if there is one, else the
+// temporary directory.
+func (c Config) downloadDir() string {
+	if c.DownloadDir != "" {
+		return expandHome(c.DownloadDir)
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		if d := filepath.Join(home, "Downloads"); dirExists(d) {
+			return d
+		}
+	}
+	return os.TempDir()
+}
+
+// saveAllAttachments starts downloading every attachment of the open
+// message to the download directory.
+func (m Model) saveAllAttachments() (Model, tea.Cmd) {
+	if !m.selectedMail.loaded {
+		m.status = "Message is still loading"
+		return m, nil
+	}
+	if len(m.selectedMail.files) == 0 {
+		m.status = "No attachments in this message"
+		return m, nil
+	}
+	if m.saving != nil {
+		m.status = "Already saving attachments"
+		return m, nil
+	}
+	dir := m.config.downloadDir()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		m.status = fmt.Sprintf("Unable to create %s: %v", dir, err)
+		return m, nil
+	}
+
+	m.pickingAttach = false
+	m.saving = &attachmentSave{
+		id:    m.selectedMail.ID,
+		files: m.selectedMail.files,
+		dir:   dir,
+		bar:   progress.New(progress.WithDefaultGradient(), progress.WithWidth(30)),
+	}
+	return m, m.saveNext(m.saving)
+}
+
+// saveNext downloads the next attachment of s.
+func (m Model) saveNext(s *attachmentSave) tea.Cmd {
+	a := s.files[s.next]
+	return func() tea.Msg {
+		path, err := saveAttachment(m.ctx, m.mail, a, s.dir)
+		return attachmentSavedMsg{save: s, path: path, err: err}
+	}
+}
+
+func (m Model) updateAttachmentSaved(msg attachmentSavedMsg) (Model, tea.Cmd) {
+	s := msg.save
+	if s != m.saving {
+		return m, nil
+	}
+	if msg.err != nil {
+		m.saving = nil
+		m.status = msg.err.Error()
+		return m, nil
+	}
+	s.paths = append(s.paths, msg.path)
+	s.next++
+	if s.next < len(s.files) {
+		return m, m.saveNext(s)
+	}
+
+	m.saving = nil
+	paths := strings.Join(s.paths, "\n")
+	m.status = fmt.Sprintf("Saved %d attachment(s) to %s", len(s.paths), s.dir)
+	if err := copyToClipboard(paths); err == nil {
+		m.status += " (paths copied)"
+	}
+	if m.state == messageView && m.selectedMail != nil && m.selectedMail.ID == s.id {
+		m.source = "Saved attachments:\n\n" + paths
+		m = m.clearSearch()
+		m.viewport.SetContent(m.messageContent())
+		m.viewport.GotoTop()
+	}
+	return m, nil
+}
+
+// saveProgress is the progress bar shown while attachments are saved.
+func (m Model) saveProgress() string {
+	s := m.saving
+	var total, done int64
+	for i, a := range s.files {
+		total += a.Size
+		if i < s.next {
+			done += a.Size
+		}
+	}
+	pct := 0.0
+	if total > 0 {
+		pct = float64(done) / float64(total)
+	}
+	return fmt.Sprintf("Saving %s (%d/%d) %s", s.files[s.next].Name, s.next+1, len(s.files), s.bar.ViewAs(pct))
+}
+
+// saveAttachment downloads a into dir, never overwriting an existing file,
+// and returns the path it was written to.
+func saveAttachment(ctx context.Context, mp backend.Provider, a compose.Attachment, dir string) (string, error) {
+	data, err := a.Load(ctx, mp)
+	if err != nil {
+		return "", err
+	}
+	name := filepath.Base(strings.ReplaceAll(a.Name, "\\", "/"))
+	if name == "." || name == "/" || name == "" {
+		name = "attachment"
+	}
+
+	ext := filepath.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	for n := 1; ; n++ {
+		path := filepath.Join(dir, name)
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if errors.Is(err, os.ErrExist) {
+			name = stem + " (" + strconv.Itoa(n) + ")" + ext
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("unable to save %s: %v", a.Name, err)
+		}
+		_, err = f.Write(data)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(path)
+			return "", fmt.Errorf("unable to save %s: %v", a.Name, err)
+		}
+		return path, nil
+	}
+}
+
+// saveAttachmentsCommand saves every attachment of a message and prints
+// the paths written.
+func (m Model) saveAttachmentsCommand(args []string) error {
+	fs := flag.NewFlagSet("save-attachments", flag.ExitOnError)
+	dir := fs.String("dir", m.config.downloadDir(), "directory to save to")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New("save-attachments: a message ID is required")
+	}
+	id := fs.Arg(0)
+
+	msg, err := m.mail.Get(m.ctx, id, "full")
+	if err != nil {
+		return fmt.Errorf("unable to fetch message: %v", err)
+	}
+	if err := os.MkdirAll(*dir, 0o755); err != nil {
+		return fmt.Errorf("unable to create %s: %v", *dir, err)
+	}
+	for _, a := range messageAttachments(id, msg.Payload) {
+		path, err := saveAttachment(m.ctx, m.mail, a, *dir)
+		if err != nil {
+			return err
+		}
+		fmt.Println(path)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package app
+
+import (
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"gmail-tui/internal/mimepart"
+	"gmail-tui/internal/ui/compose"
+	"gmail-tui/internal/ui/view"
+)
+
+type DraftsMsg []compose.Draft
+type draftDeletedMsg string
+
+func newDraftsList(delegate list.ItemDelegate) list.Model {
+	l := list.New([]list.Item{}, delegate, 40, 20)
+	l.SetShowTitle(true)
+	l.SetFilteringEnabled(true)
+	l.SetShowHelp(true)
+	l.DisableQuitKeybindings()
+	l.Title = "Drafts"
+	l.Styles.Title = view.TitleStyle
+	return l
+}
+
+func (m Model) fetchDrafts() tea.Msg {
+	r, err := m.gmailSvc.Users.Drafts.List("me").MaxResults(20).Context(m.ctx).Do()
+	if err != nil {
+		return errMsg(err)
+	}
+
+	var drafts []compose.Draft
+	for _, d := range r.Drafts {
+		draft, err := m.gmailSvc.Users.Drafts.Get("me", d.Id).Format("full").Context(m.ctx).Do()
+		if err != nil || draft.Message == nil || draft.Message.Payload == nil {
+			continue
+		}
+
+		item := compose.Draft{
+			ID:   d.Id,
+			Date: time.UnixMilli(draft.Message.InternalDate),
+			Body: mimepart.Text(draft.Message.Payload),
+
+			Attachments: messageAttachments(draft.Message.Id, draft.Message.Payload),
+		}
+		for _, header := range draft.Message.Payload.Headers {
+			switch header.Name {
+			case "From":
+				item.From = mimepart.DecodeHeader(header.Value)
+			case "To":
+				item.To = mimepart.DecodeHeader(header.Value)
+			case "Cc":
+				item.Cc = mimepart.DecodeHeader(header.Value)
+			case "Bcc":
+				item.Bcc = mimepart.DecodeHeader(header.Value)
+			case "Subject":
+				item.Subject = mimepart.DecodeHeader(header.Value)
+			}
+		}
+		if pgpEncrypted(draft.Message.Payload) {
+			if err := m.openEncryptedDraft(&item, draft.Message.Id); err != nil {
+				return errMsg(err)
+			}
+		}
+		drafts = append(drafts, item)
+	}
+
+	return DraftsMsg(drafts)
+}
+
+func (m Model) deleteDraft(id string) tea.Cmd {
+	return func() tea.Msg {
+		if err := m.gmailSvc.Users.Drafts.Delete("me", id).Context(m.ctx).Do(); err != nil {
+			return errMsg(err)
+		}
+		return draftDeletedMsg(id)
+	}
+}
+
+func (m Model) updateDrafts(msg tea.KeyMsg) (Model, tea.Cmd) {
+	if m.drafts.FilterState() != list.Filtering {
+		switch {
+		case key.Matches(msg, m.keys.Back) && m.drafts.FilterState() == list.Unfiltered:
+			m.state = listView
+			return m, nil
+		case key.Matches(msg, m.keys.Quit):
+			return m.quit()
+		case key.Matches(msg, m.keys.Fetch):
+			m.loading = true
+			return m, m.fetchDrafts
+		case key.Matches(msg, m.keys.Discard):
+			if d, ok := m.drafts.SelectedItem().(compose.Draft); ok {
+				return m, m.deleteDraft(d.ID)
+			}
+			return m, nil
+		case key.Matches(msg, m.keys.Select):
+			if d, ok := m.drafts.SelectedItem().(compose.Draft); ok {
+				return m.openCompose(d)
+			}
+			return m, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	m.drafts, cmd = m.drafts.Update(msg)
+	return m, cmd
+}
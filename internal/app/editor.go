@@ -0,0 +1,184 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textarea"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"gmail-tui/internal/ui/compose"
+	"gmail-tui/internal/ui/view"
+)
+
+const (
+	editorTo = iota
+	editorCc
+	editorBcc
+	editorSubject
+	editorBody
+	editorFields
+)
+
+// composeEditor writes a message inside gmail-tui, for when no $VISUAL or
+// $EDITOR is set up. Each change is written to the compose file, so the
+// draft is autosaved just as it is from an external editor. The editor
+// keeps what it shows of the draft itself, as the draft is locked while a
+// save uploads it.
+type composeEditor struct {
+	title   string
+	from    string
+	headers [editorBody]textinput.Model
+	body    textarea.Model
+	focus   int
+	written string
+}
+
+// builtinEditor reports whether messages are written in the built-in
+// editor rather than in $VISUAL or $EDITOR.
+func (c Config) builtinEditor() bool {
+	switch c.ComposeEditor {
+	case "builtin":
+		return true
+	case "external":
+		return false
+	}
+	return os.Getenv("VISUAL") == "" && os.Getenv("EDITOR") == ""
+}
+
+func newComposeEditor(d compose.Draft, width, height int) *composeEditor {
+	e := &composeEditor{title: "New message", from: d.From, written: compose.Text(d)}
+	if d.ID != "" {
+		e.title = "Draft"
+	}
+	for i, h := range []struct{ prompt, value string }{
+		{"To:      ", d.To},
+		{"Cc:      ", d.Cc},
+		{"Bcc:     ", d.Bcc},
+		{"Subject: ", d.Subject},
+	} {
+		e.headers[i] = textinput.New()
+		e.headers[i].Prompt = h.prompt
+		e.headers[i].SetValue(h.value)
+	}
+
+	e.body = textarea.New()
+	e.body.ShowLineNumbers = false
+	e.body.Placeholder = "Message"
+	e.body.CharLimit = 0
+	e.body.SetValue(d.Body)
+	e.body.CursorStart()
+	e.resize(width, height)
+	return e
+}
+
+// resize fits the body to the window, below the header fields.
+func (e *composeEditor) resize(width, height int) {
+	e.body.SetWidth(max(width-4, 20))
+	e.body.SetHeight(max(height-12, 3))
+}
+
+func (e *composeEditor) setFocus(i int) tea.Cmd {
+	e.focus = (i + editorFields) % editorFields
+	for j := range e.headers {
+		e.headers[j].Blur()
+	}
+	e.body.Blur()
+	if e.focus == editorBody {
+		return e.body.Focus()
+	}
+	return e.headers[e.focus].Focus()
+}
+
+// draft is d with the headers and body as edited.
+func (e *composeEditor) draft(d compose.Draft) compose.Draft {
+	d.To = strings.TrimSpace(e.headers[editorTo].Value())
+	d.Cc = strings.TrimSpace(e.headers[editorCc].Value())
+	d.Bcc = strings.TrimSpace(e.headers[editorBcc].Value())
+	d.Subject = strings.TrimSpace(e.headers[editorSubject].Value())
+	d.Body = e.body.Value()
+	return d
+}
+
+// openEditor starts writing c in the built-in editor.
+func (m Model) openEditor(c *compose.Session) (Model, tea.Cmd) {
+	c.StartAutosave(m.ctx, m.mail, m.config.DraftAutosaveInterval())
+	m.editor = newComposeEditor(c.Snapshot(), m.width, m.height)
+	m.state = composeView
+	focus := editorTo
+	if d := c.Snapshot(); d.To != "" || d.Cc != "" || d.Bcc != "" {
+		focus = editorBody
+	}
+	return m, m.editor.setFocus(focus)
+}
+
+// writeEditor puts what is in the editor in the compose file for the next
+// save.
+func (m Model) writeEditor() error {
+	text := compose.Text(m.editor.draft(compose.Draft{From: m.editor.from}))
+	if text == m.editor.written {
+		return nil
+	}
+	if err := m.compose.Write(text); err != nil {
+		return err
+	}
+	m.editor.written = text
+	return nil
+}
+
+func (m Model) updateEditor(msg tea.KeyMsg) (Model, tea.Cmd) {
+	e := m.editor
+	switch {
+	case key.Matches(msg, m.keys.Back):
+		err := m.writeEditor()
+		m.editor = nil
+		c := m.compose
+		return m, func() tea.Msg { return editorFinishedMsg{session: c, err: err} }
+	case key.Matches(msg, m.keys.Save):
+		if err := m.writeEditor(); err != nil {
+			m = m.fail(err)
+			return m, nil
+		}
+		return m, m.saveCompose(m.compose, "Draft saved")
+	case msg.Type == tea.KeyTab:
+		return m, e.setFocus(e.focus + 1)
+	case msg.Type == tea.KeyShiftTab:
+		return m, e.setFocus(e.focus - 1)
+	case msg.Type == tea.KeyEnter && e.focus < editorBody:
+		return m, e.setFocus(e.focus + 1)
+	}
+
+	var cmd tea.Cmd
+	if e.focus == editorBody {
+		e.body, cmd = e.body.Update(msg)
+	} else {
+		e.headers[e.focus], cmd = e.headers[e.focus].Update(msg)
+	}
+	if err := m.writeEditor(); err != nil {
+		m.status = err.Error()
+	}
+	return m, cmd
+}
+
+func (m Model) editorView() string {
+	e := m.editor
+	lines := []string{view.TitleStyle.Render(e.title)}
+	if e.from != "" {
+		lines = append(lines, view.InfoStyle.Render("  From:    "+e.from))
+	}
+	for _, h := range e.headers {
+		lines = append(lines, "  "+h.View())
+	}
+	lines = append(lines, "", e.body.View())
+
+	body := e.body.Value()
+	count := fmt.Sprintf("%d characters • %d lines", len([]rune(body)), e.body.LineCount())
+	return fmt.Sprintf(
+		"\n%s\n\n%s",
+		strings.Join(lines, "\n"),
+		view.HelpStyle.Render(m.statusLine()+count+"\ntab/shift+tab: move • ctrl+s: save draft • esc: done"),
+	)
+}
@@ -0,0 +1,260 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"google.golang.org/api/gmail/v1"
+
+	"gmail-tui/internal/backend"
+	"gmail-tui/internal/mimepart"
+	"gmail-tui/internal/ui/compose"
+	"gmail-tui/internal/ui/view"
+)
+
+type Email struct {
+	ID      string
+	From    string
+	Subject string
+	Date    time.Time
+	Snippet string
+	Body    string
+	Labels  []string
+
+	// To and Cc are shown in the list instead of From for mail you sent.
+	To string
+	Cc string
+
+	ThreadID string
+	// Size is Gmail's estimate of the message size in bytes.
+	Size int64
+
+	// Extra is text plugins add to the list row.
+	Extra string
+
+	ListUnsubscribe     string
+	ListUnsubscribePost string
+
+	// loaded is set once Body has been fetched; the list is fetched with
+	// headers only.
+	loaded bool
+
+	// invite is the calendar invitation in the body, if any.
+	invite *invite
+
+	// pgp summarises how the body was decrypted and verified, if it was.
+	pgp string
+	// smime is the result of checking an S/MIME signature.
+	smime string
+
+	// files are the message's attachments.
+	files []compose.Attachment
+	// remote is what the body would load from the web, which is blocked.
+	remote remoteContent
+	// cut is the size of the message's text when Body holds only the
+	// first max_body_kb of it, and 0 when Body is all of it.
+	cut int64
+	// attached is set if the message looks like it has attachments before
+	// its body is loaded.
+	attached bool
+
+	// hit says whether a search result came from the local index, Gmail
+	// or both. It is empty outside searches.
+	hit string
+	// muted is set if the message's thread is muted.
+	muted bool
+	// labelNames are the names of the message's own labels, for the list
+	// filter to search.
+	labelNames []string
+}
+
+func (e Email) Title() string {
+	if e.hasFiles() {
+		return e.markedSubject() + " " + paperclip
+	}
+	return e.markedSubject()
+}
+
+// markedSubject is the subject, after » if the message is important.
+func (e Email) markedSubject() string {
+	if e.hasLabel("IMPORTANT") {
+		return "» " + e.Subject
+	}
+	return e.Subject
+}
+
+func (e Email) Description() string {
+	role, who := e.correspondent()
+	desc := fmt.Sprintf("%s: %s | %s", role, who, view.ListDate(e.Date))
+	if e.Size > 0 {
+		desc += " | " + view.Size(e.Size)
+	}
+	if e.hit != "" {
+		desc += " | " + e.hit
+	}
+	if e.muted {
+		desc += " | muted"
+	}
+	if snippet, _, _ := strings.Cut(e.Snippet, "\n"); snippet != "" {
+		desc += " | " + snippet
+	}
+	if e.Extra != "" {
+		desc += " | " + e.Extra
+	}
+	return desc
+}
+
+// setEmails fills the list, keeping bodies already loaded for messages
+// still in it and the cursor on the same message.
+func (m Model) setEmails(emails []Email) Model {
+	loaded := map[string]Email{}
+	for _, item := range m.list.Items() {
+		if e, ok := item.(Email); ok && e.loaded {
+			loaded[e.ID] = e
+		}
+	}
+	var items []list.Item
+	for _, email := range emails {
+		if old, ok := loaded[email.ID]; ok {
+			email.Body, email.files, email.remote, email.cut, email.loaded = old.Body, old.files, old.remote, old.cut, true
+		}
+		email.labelNames = m.labels.names(email)
+		items = append(items, email)
+	}
+	old := m.list.Items()
+	selected, _ := m.list.SelectedItem().(Email)
+	m.list.SetItems(items)
+	return m.markMuted().arrangeList().restoreCursor(old, selected.ID)
+}
+
+// EmailsMsg carries the fetched list. failed counts messages whose details
+// could not be fetched even after retrying, so they are not silently
+// missing.
+type EmailsMsg struct {
+	emails []Email
+	failed int
+	status string
+}
+
+type errMsg error
+
+// refreshEmails fetches the list again, cancelling a refresh still in
+// flight.
+func (m Model) refreshEmails() (Model, tea.Cmd) {
+	if m.cancelFetch != nil {
+		m.cancelFetch()
+	}
+	ctx, cancel := context.WithCancel(m.ctx)
+	m.cancelFetch = cancel
+	m = m.startLoad()
+	if len(m.config.tabs()) > 0 {
+		return m, tea.Batch(m.fetchEmails(ctx), loadTick(m.load), m.fetchTabCounts)
+	}
+	return m, tea.Batch(m.fetchEmails(ctx), loadTick(m.load))
+}
+
+// fetchEmails loads the list, reporting its progress to m.load. A
+// cancelled fetch produces no message.
+func (m Model) fetchEmails(ctx context.Context) tea.Cmd {
+	return func() tea.Msg {
+		msg := m.loadEmails(backend.WithListProgress(ctx, m.load.progress()))
+		if ctx.Err() != nil {
+			return nil
+		}
+		return msg
+	}
+}
+
+// listSize is how many messages a list loads.
+const listSize = 20
+
+func (m Model) loadEmails(ctx context.Context) tea.Msg {
+	emails, failed, err := m.listEmails(ctx, m.listQuery(), listSize)
+	var status string
+	if m.localSearch {
+		emails, status, err = m.index.merge(m.listQuery(), emails, err)
+	}
+	if err != nil {
+		if isTransient(err) {
+			return m.offlineList(m.listQuery(), err)
+		}
+		return errMsg(err)
+	}
+	m.lists.put(m.listQuery(), emails)
+	m.recordEmails(emails)
+	for i := range emails {
+		emails[i].Extra = m.plugins.columnText(emails[i])
+	}
+	return EmailsMsg{emails: emails, failed: failed, status: status}
+}
+
+// listEmails fetches the headers of the newest max messages matching query.
+// failed counts messages whose details could not be fetched.
+func (m Model) listEmails(ctx context.Context, query string, max int64) (emails []Email, failed int, err error) {
+	msgs, failed, err := m.mail.List(ctx, query, max, "From", "To", "Cc", "Subject", "Date", "List-Unsubscribe", "List-Unsubscribe-Post")
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, email := range msgs {
+		emails = append(emails, headerEmail(email))
+	}
+
+	return emails, failed, nil
+}
+
+// headerEmail is the list row for a message fetched with its headers only.
+func headerEmail(email *gmail.Message) Email {
+	var from, to, cc, subject, unsub, unsubPost string
+	var date time.Time
+
+	for _, header := range email.Payload.Headers {
+		switch header.Name {
+		case "From":
+			from = mimepart.DecodeHeader(header.Value)
+		case "To":
+			to = mimepart.DecodeHeader(header.Value)
+		case "Cc":
+			cc = mimepart.DecodeHeader(header.Value)
+		case "Subject":
+			subject = mimepart.DecodeHeader(header.Value)
+		case "Date":
+			date = parseDate(header.Value)
+		case "List-Unsubscribe":
+			unsub = header.Value
+		case "List-Unsubscribe-Post":
+			unsubPost = header.Value
+		}
+	}
+
+	if date.IsZero() && email.InternalDate != 0 {
+		date = time.UnixMilli(email.InternalDate)
+	}
+
+	if subject == "" {
+		subject = "(no subject)"
+	}
+
+	return Email{
+		ID:      email.Id,
+		From:    from,
+		To:      to,
+		Cc:      cc,
+		Subject: subject,
+		Date:    date,
+		Snippet: html.UnescapeString(email.Snippet),
+		Labels:  email.LabelIds,
+
+		ThreadID: email.ThreadId,
+		Size:     email.SizeEstimate,
+
+		ListUnsubscribe:     unsub,
+		ListUnsubscribePost: unsubPost,
+
+		attached: email.Payload != nil && email.Payload.MimeType == "multipart/mixed",
+	}
+}
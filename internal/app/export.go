@@ -0,0 +1,367 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"google.golang.org/api/gmail/v1"
+)
+
+// Export formats. eml writes one file per message into a directory, mbox
+// appends to a single mboxrd file, and maildir fills a Maildir's cur
+// directory with flags from the message's labels. txt and pdf print one
+// file per message, named after its date and subject, for archiving
+// receipts and the like.
+const (
+	exportEML     = "eml"
+	exportMbox    = "mbox"
+	exportMaildir = "maildir"
+	exportText    = "txt"
+	exportPDF     = "pdf"
+)
+
+var mboxFromLine = regexp.MustCompile(`(?m)^(>*From )`)
+
+// exporter writes raw messages to path in one of the export formats.
+type exporter struct {
+	format string
+	path   string
+	mbox   *os.File
+
+	// pdf is the program PDFs are rendered with, and last the file the
+	// last message was printed to.
+	pdf  string
+	last string
+}
+
+func newExporter(format, path string) (*exporter, error) {
+	x := &exporter{format: format, path: path}
+	var err error
+	switch format {
+	case exportEML, exportText:
+		err = os.MkdirAll(path, 0o755)
+	case exportPDF:
+		if x.pdf, err = pdfConverter(); err != nil {
+			return nil, err
+		}
+		err = os.MkdirAll(path, 0o755)
+	case exportMbox:
+		if err = os.MkdirAll(filepath.Dir(path), 0o755); err == nil {
+			x.mbox, err = os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+		}
+	case exportMaildir:
+		for _, sub := range []string{"cur", "new", "tmp"} {
+			if err = os.MkdirAll(filepath.Join(path, sub), 0o700); err != nil {
+				break
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown export format %q; want eml, mbox, maildir, txt or pdf", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to create %s: %v", path, err)
+	}
+	return x, nil
+}
+
+// add writes a message fetched in the exporter's fetchFormat. Exporting
+// the same message again to an eml, txt or pdf directory or a Maildir
+// replaces it rather than making a copy.
+func (x *exporter) add(msg *gmail.Message) error {
+	if printFormat(x.format) {
+		if err := x.addPrinted(msg); err != nil {
+			return fmt.Errorf("unable to export message %s: %v", msg.Id, err)
+		}
+		return nil
+	}
+	raw, err := base64.URLEncoding.DecodeString(msg.Raw)
+	if err != nil {
+		return fmt.Errorf("unable to decode message %s: %v", msg.Id, err)
+	}
+	date := time.UnixMilli(msg.InternalDate)
+
+	switch x.format {
+	case exportEML:
+		err = os.WriteFile(filepath.Join(x.path, msg.Id+".eml"), raw, 0o600)
+	case exportMbox:
+		err = x.addMbox(raw, date)
+	case exportMaildir:
+		err = x.addMaildir(raw, msg)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to export message %s: %v", msg.Id, err)
+	}
+	return nil
+}
+
+// addMbox appends a message in mboxrd form: a From_ line, the message with
+// LF line endings and any line starting with >*From quoted once more, and a
+// blank line.
+func (x *exporter) addMbox(raw []byte, date time.Time) error {
+	sender := "MAILER-DAEMON"
+	if m, err := mail.ReadMessage(bytes.NewReader(raw)); err == nil {
+		if a, err := mail.ParseAddress(m.Header.Get("From")); err == nil {
+			sender = a.Address
+		}
+	}
+	body := bytes.ReplaceAll(raw, []byte("\r\n"), []byte("\n"))
+	body = mboxFromLine.ReplaceAll(body, []byte(">$1"))
+	if !bytes.HasSuffix(body, []byte("\n")) {
+		body = append(body, '\n')
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From %s %s\n", sender, date.UTC().Format(time.ANSIC))
+	b.Write(body)
+	b.WriteString("\n")
+	_, err := x.mbox.Write(b.Bytes())
+	return err
+}
+
+// addMaildir delivers a message through tmp into cur, named after its
+// Gmail ID, with the seen, flagged and draft flags taken from its labels.
+func (x *exporter) addMaildir(raw []byte, msg *gmail.Message) error {
+	name := maildirName(msg)
+
+	tmp := filepath.Join(x.path, "tmp", name)
+	if err := os.WriteFile(tmp, raw, 0o600); err != nil {
+		return err
+	}
+	// A message exported before may have had different flags.
+	old, _ := filepath.Glob(filepath.Join(x.path, "cur", name+":2,*"))
+	for _, o := range old {
+		os.Remove(o)
+	}
+	return os.Rename(tmp, filepath.Join(x.path, "cur", name+":2,"+maildirFlags(msg.LabelIds)))
+}
+
+// fetchFormat is the format messages are fetched in for the exporter:
+// printing needs them parsed, the other formats want them as they are.
+func (x *exporter) fetchFormat() string {
+	if printFormat(x.format) {
+		return "full"
+	}
+	return "raw"
+}
+
+func (x *exporter) close() error {
+	if x.mbox != nil {
+		return x.mbox.Close()
+	}
+	return nil
+}
+
+// defaultExportPath names an export in the download directory. Printed
+// messages go in the download directory itself, as their names are dated.
+func (c Config) defaultExportPath(format string) string {
+	if printFormat(format) {
+		return c.downloadDir()
+	}
+	name := "gmail-export-" + time.Now().Format("20060102-150405")
+	if format == exportMbox {
+		name += ".mbox"
+	}
+	return filepath.Join(c.downloadDir(), name)
+}
+
+// queryIDs returns the IDs of the messages matching query, following
+// pages until max IDs are found, or all of them when max is 0.
+func (m Model) queryIDs(ctx context.Context, query string, max int64) ([]string, error) {
+	var ids []string
+	call := m.gmailSvc.Users.Messages.List("me").Q(query).MaxResults(500)
+	err := call.Pages(ctx, func(r *gmail.ListMessagesResponse) error {
+		for _, msg := range r.Messages {
+			ids = append(ids, msg.Id)
+			if max > 0 && int64(len(ids)) >= max {
+				return errStopPaging
+			}
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errStopPaging) {
+		return nil, fmt.Errorf("unable to list messages: %v", err)
+	}
+	return ids, nil
+}
+
+var errStopPaging = errors.New("enough messages")
+
+// exportJob is an export running in the background, one message at a
+// time so the status line can show progress.
+type exportJob struct {
+	x    *exporter
+	ids  []string
+	next int
+}
+
+// exportStartedMsg carries the messages an export will write.
+type exportStartedMsg struct {
+	job *exportJob
+	err error
+}
+
+// exportedMsg reports that the next message of an export was written.
+type exportedMsg struct {
+	job *exportJob
+	err error
+}
+
+// startExport exports the open message or, from the list, every message
+// matching the list's query and quick filters, to path in format.
+func (m Model) startExport(format, path string) (Model, tea.Cmd) {
+	if m.export != nil {
+		m.status = "An export is already running"
+		return m, nil
+	}
+	if path == "" {
+		path = m.config.defaultExportPath(format)
+	}
+	x, err := newExporter(format, expandHome(path))
+	if err != nil {
+		m.status = err.Error()
+		return m, nil
+	}
+
+	var single string
+	switch {
+	case m.state == messageView && m.selectedMail != nil:
+		single = m.selectedMail.ID
+	case m.state != listView:
+		x.close()
+		m.status = "Export works from the message list or an open message"
+		return m, nil
+	}
+
+	query := m.listQuery()
+	m.status = "Finding messages to export..."
+	return m, func() tea.Msg {
+		job := &exportJob{x: x, ids: []string{single}}
+		if single == "" {
+			ids, err := m.queryIDs(m.ctx, query, 0)
+			if err != nil {
+				x.close()
+				return exportStartedMsg{err: err}
+			}
+			job.ids = ids
+		}
+		return exportStartedMsg{job: job}
+	}
+}
+
+func (m Model) exportNext(job *exportJob) tea.Cmd {
+	id := job.ids[job.next]
+	return func() tea.Msg {
+		msg, err := m.mail.Get(m.ctx, id, job.x.fetchFormat())
+		if err != nil {
+			return exportedMsg{job: job, err: fmt.Errorf("unable to fetch message %s: %v", id, err)}
+		}
+		return exportedMsg{job: job, err: job.x.add(msg)}
+	}
+}
+
+func (m Model) updateExport(msg tea.Msg) (Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case exportStartedMsg:
+		if msg.err != nil {
+			m.status = msg.err.Error()
+			return m, nil
+		}
+		if len(msg.job.ids) == 0 {
+			msg.job.x.close()
+			m.status = "Nothing to export"
+			return m, nil
+		}
+		m.export = msg.job
+		m.status = fmt.Sprintf("Exporting 0/%d to %s", len(msg.job.ids), msg.job.x.path)
+		return m, m.exportNext(msg.job)
+
+	case exportedMsg:
+		job := msg.job
+		if job != m.export {
+			return m, nil
+		}
+		if msg.err == nil {
+			job.next++
+		}
+		if msg.err != nil || job.next == len(job.ids) {
+			m.export = nil
+			err := job.x.close()
+			if msg.err != nil {
+				err = msg.err
+			}
+			if err != nil {
+				m.status = fmt.Sprintf("Export stopped after %d message(s): %v", job.next, err)
+				return m, nil
+			}
+			if len(job.ids) == 1 && job.x.last != "" {
+				m.status = "Saved to " + job.x.last
+				return m, nil
+			}
+			m.status = fmt.Sprintf("Exported %d message(s) to %s", job.next, job.x.path)
+			return m, nil
+		}
+		m.status = fmt.Sprintf("Exporting %d/%d to %s", job.next, len(job.ids), job.x.path)
+		return m, m.exportNext(job)
+	}
+	return m, nil
+}
+
+// exportCommand writes the given messages, or those matching --query, to
+// --out.
+func (m Model) exportCommand(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	format := fs.String("format", exportMbox, "eml, mbox, maildir, txt or pdf")
+	query := fs.String("query", "", "export the messages matching this Gmail query, e.g. \"label:receipts\"")
+	max := fs.Int64("max", 0, "maximum number of messages to export with --query (0 for all)")
+	out := fs.String("out", "", "file (mbox) or directory (eml, maildir, txt, pdf) to write to")
+	fs.Parse(args)
+
+	ids := fs.Args()
+	if len(ids) == 0 {
+		if *query == "" {
+			return errors.New("export: message IDs or --query are required")
+		}
+		var err error
+		if ids, err = m.queryIDs(m.ctx, *query, *max); err != nil {
+			return err
+		}
+	}
+	if *out == "" {
+		*out = m.config.defaultExportPath(*format)
+	}
+
+	x, err := newExporter(*format, *out)
+	if err != nil {
+		return err
+	}
+	for i, id := range ids {
+		msg, err := m.mail.Get(m.ctx, id, x.fetchFormat())
+		if err == nil {
+			err = x.add(msg)
+		}
+		if err != nil {
+			x.close()
+			return fmt.Errorf("export stopped after %d message(s): %v", i, err)
+		}
+		fmt.Fprintf(os.Stderr, "\r%d/%d", i+1, len(ids))
+	}
+	if len(ids) > 0 {
+		fmt.Fprintln(os.Stderr)
+	}
+	if err := x.close(); err != nil {
+		return fmt.Errorf("unable to write %s: %v", *out, err)
+	}
+	fmt.Printf("Exported %d message(s) to %s\n", len(ids), strings.TrimSuffix(*out, "/"))
+	return nil
+}
@@ -0,0 +1,223 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"golang.org/x/oauth2"
+	"google.golang.org/api/googleapi"
+
+	"gmail-tui/internal/ui/view"
+)
+
+var failureStyle = lipgloss.NewStyle().
+	Foreground(lipgloss.Color("#FFFFFF")).
+	Background(lipgloss.Color("#C0392B")).
+	Padding(0, 1)
+
+// failure is an operation that failed. It is shown in the status line over
+// the screen it failed on, which stays usable once the error is dismissed.
+// details is set while the full error is shown.
+type failure struct {
+	err     error
+	retry   tea.Cmd
+	details *viewport.Model
+}
+
+// retryError is an error from a command that can be run again to retry.
+type retryError struct {
+	err   error
+	retry tea.Cmd
+}
+
+func (e *retryError) Error() string { return e.err.Error() }
+func (e *retryError) Unwrap() error { return e.err }
+
+// retryable makes errors from cmd, and from the commands it batches,
+// remember the command that failed so it can be retried.
+func retryable(cmd tea.Cmd) tea.Cmd {
+	if cmd == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		switch msg := cmd().(type) {
+		case errMsg:
+			var re *retryError
+			if errors.As(msg, &re) {
+				return msg
+			}
+			return errMsg(&retryError{err: msg, retry: cmd})
+		case tea.BatchMsg:
+			for i, c := range msg {
+				msg[i] = retryable(c)
+			}
+			return msg
+		default:
+			return msg
+		}
+	}
+}
+
+// fail shows err as the failure of the last operation.
+func (m Model) fail(err error) Model {
+	m = m.clearPending()
+	m.loading = false
+	f := &failure{err: err}
+	var re *retryError
+	if errors.As(err, &re) {
+		f.err, f.retry = re.err, re.retry
+	}
+	m.failure = f
+	return m
+}
+
+func (m Model) updateFailure(msg tea.KeyMsg) (Model, tea.Cmd) {
+	f := m.failure
+	if f.details != nil {
+		switch msg.String() {
+		case "esc", "d", "q":
+			g := *f
+			g.details = nil
+			m.failure = &g
+			return m, nil
+		case "ctrl+c":
+			m.failure = nil
+			return m.quit()
+		}
+		var cmd tea.Cmd
+		*f.details, cmd = f.details.Update(msg)
+		return m, cmd
+	}
+
+	switch msg.String() {
+	case "r":
+		if f.retry == nil {
+			return m, nil
+		}
+		m.failure = nil
+		m.status = "Retrying..."
+		return m, retryable(f.retry)
+	case "d":
+		vp := viewport.New(m.width, max(m.height-4, 1))
+		vp.SetContent(lipgloss.NewStyle().Width(m.width).Render(errorDetails(f.err)))
+		g := *f
+		g.details = &vp
+		m.failure = &g
+		return m, nil
+	case "c", "esc", "enter":
+		m.failure = nil
+		if len(m.list.Items()) == 0 && m.state == listView {
+			if off := m.offlineList(m.listQuery(), f.err); off.emails != nil {
+				return m.updateOffline(off)
+			}
+		}
+		return m, nil
+	case "q", "ctrl+c":
+		m.failure = nil
+		return m.quit()
+	}
+	return m, nil
+}
+
+// canContinueWithCache reports whether dismissing the failure shows the
+// cached copy of an empty list.
+func (m Model) canContinueWithCache() bool {
+	if len(m.list.Items()) > 0 || m.state != listView {
+		return false
+	}
+	_, _, ok := m.lists.get(m.listQuery())
+	return ok
+}
+
+// failurePrompt is the failure as shown in the status line.
+func (m Model) failurePrompt() string {
+	keys := []string{"d: details"}
+	if m.failure.retry != nil {
+		keys = append([]string{"r: retry"}, keys...)
+	}
+	if m.canContinueWithCache() {
+		keys = append(keys, "c: continue with cached mail")
+	} else {
+		keys = append(keys, "esc: continue")
+	}
+	return failureStyle.Render("Error: "+m.failure.err.Error()) + "\n" + strings.Join(keys, " • ")
+}
+
+func (m Model) failureView() string {
+	return fmt.Sprintf(
+		"%s\n%s\n\n%s",
+		view.TitleStyle.Render("Error details"),
+		m.failure.details.View(),
+		view.HelpStyle.Render("↑/↓: scroll • esc: back"),
+	)
+}
+
+// errorDetails describes err for the details screen: the HTTP status and
+// response body of API errors, the request that failed, and the chain of
+// errors it was wrapped in.
+func errorDetails(err error) string {
+	lines := []string{err.Error(), ""}
+
+	var gerr *googleapi.Error
+	if errors.As(err, &gerr) {
+		lines = append(lines, fmt.Sprintf("HTTP status: %d %s", gerr.Code, http.StatusText(gerr.Code)))
+		for _, item := range gerr.Errors {
+			lines = append(lines, fmt.Sprintf("Reason: %s (%s)", item.Reason, item.Message))
+		}
+		if gerr.Body != "" {
+			lines = append(lines, "", "Response body:", prettyJSON(gerr.Body))
+		}
+	}
+	var rerr *oauth2.RetrieveError
+	if errors.As(err, &rerr) && rerr.Response != nil {
+		lines = append(lines, "Sign-in failed with HTTP status: "+rerr.Response.Status, "", "Response body:", prettyJSON(string(rerr.Body)))
+	}
+	var uerr *url.Error
+	if errors.As(err, &uerr) {
+		lines = append(lines, fmt.Sprintf("Request: %s to %s", uerr.Op, hostOf(uerr.URL)))
+	}
+	var nerr net.Error
+	if errors.As(err, &nerr) {
+		kind := "Network error"
+		if nerr.Timeout() {
+			kind = "Network timeout"
+		}
+		lines = append(lines, kind+": check the connection, or a proxy or firewall in the way")
+	}
+
+	var chain []string
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		if _, ok := e.(*retryError); !ok {
+			chain = append(chain, fmt.Sprintf("%T", e))
+		}
+	}
+	lines = append(lines, "", "Error types: "+strings.Join(chain, " → "))
+	return strings.Join(lines, "\n")
+}
+
+// hostOf returns the host of a request URL, leaving out the path and query,
+// which can hold search terms.
+func hostOf(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+func prettyJSON(body string) string {
+	var buf bytes.Buffer
+	if json.Indent(&buf, []byte(body), "", "  ") != nil {
+		return body
+	}
+	return buf.String()
+}
@@ -0,0 +1,394 @@
+package app
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"google.golang.org/api/gmail/v1"
+
+	"gmail-tui/internal/ui/view"
+)
+
+// Filter is a Gmail filter as shown in the filters screen. Label is the
+// name of the first user label the filter applies.
+type Filter struct {
+	filter    *gmail.Filter
+	Label     string
+	SkipInbox bool
+	Trash     bool
+}
+
+func newFilter(f *gmail.Filter, labels labelIndex) Filter {
+	item := Filter{filter: f}
+	if f.Action == nil {
+		return item
+	}
+	item.SkipInbox = slices.Contains(f.Action.RemoveLabelIds, "INBOX")
+	item.Trash = slices.Contains(f.Action.AddLabelIds, "TRASH")
+	for _, id := range f.Action.AddLabelIds {
+		if l, ok := labels[id]; ok && l.Type == "user" {
+			item.Label = l.Name
+			break
+		}
+	}
+	return item
+}
+
+func (f Filter) criteria() *gmail.FilterCriteria {
+	if f.filter.Criteria == nil {
+		return &gmail.FilterCriteria{}
+	}
+	return f.filter.Criteria
+}
+
+func (f Filter) Title() string {
+	c := f.criteria()
+	var parts []string
+	for _, p := range []struct{ name, value string }{
+		{"from", c.From},
+		{"to", c.To},
+		{"subject", c.Subject},
+		{"matching", c.Query},
+	} {
+		if p.value != "" {
+			parts = append(parts, fmt.Sprintf("%s: %s", p.name, p.value))
+		}
+	}
+	if len(parts) == 0 {
+		return "(any message)"
+	}
+	return strings.Join(parts, " • ")
+}
+
+func (f Filter) Description() string {
+	var actions []string
+	if f.SkipInbox {
+		actions = append(actions, "skip inbox")
+	}
+	if f.Label != "" {
+		actions = append(actions, "apply label "+f.Label)
+	}
+	if f.Trash {
+		actions = append(actions, "delete")
+	}
+	if f.filter.Action != nil && f.filter.Action.Forward != "" {
+		actions = append(actions, "forward to "+f.filter.Action.Forward)
+	}
+	if len(actions) == 0 {
+		return "no actions"
+	}
+	return strings.Join(actions, ", ")
+}
+
+func (f Filter) FilterValue() string { return f.Title() }
+
+type FiltersMsg struct {
+	filters []Filter
+	labels  labelIndex
+}
+
+type filterSavedMsg string
+
+func newFiltersList(delegate list.ItemDelegate) list.Model {
+	l := list.New([]list.Item{}, delegate, 40, 20)
+	l.SetShowTitle(true)
+	l.SetFilteringEnabled(true)
+	l.SetShowHelp(true)
+	l.DisableQuitKeybindings()
+	l.Title = "Filters"
+	l.Styles.Title = view.TitleStyle
+	return l
+}
+
+func (m Model) fetchFilters() tea.Msg {
+	labels, err := fetchLabels(m.ctx, m.gmailSvc)
+	if err != nil {
+		return errMsg(err)
+	}
+
+	r, err := m.gmailSvc.Users.Settings.Filters.List("me").Context(m.ctx).Do()
+	if err != nil {
+		return errMsg(fmt.Errorf("unable to list filters: %w", err))
+	}
+
+	var filters []Filter
+	for _, f := range r.Filter {
+		filters = append(filters, newFilter(f, labels))
+	}
+	return FiltersMsg{filters: filters, labels: labels}
+}
+
+func (m Model) deleteFilter(f Filter) Model {
+	return m.confirm(
+		fmt.Sprintf("Delete filter %s? y: delete • n: cancel", f.Title()),
+		func() tea.Msg {
+			if err := m.gmailSvc.Users.Settings.Filters.Delete("me", f.filter.Id).Context(m.ctx).Do(); err != nil {
+				return errMsg(fmt.Errorf("unable to delete filter: %w", err))
+			}
+			return filterSavedMsg("Filter deleted")
+		},
+	)
+}
+
+const (
+	formFrom = iota
+	formTo
+	formSubject
+	formQuery
+	formLabel
+	formSkipInbox
+	formTrash
+	formFields
+)
+
+// filterForm edits the criteria and actions of a filter. Gmail filters
+// cannot be updated in place, so saving an edited filter creates a new one
+// and deletes the original.
+type filterForm struct {
+	original  *gmail.Filter
+	inputs    []textinput.Model
+	skipInbox bool
+	trash     bool
+	focus     int
+}
+
+func newFilterForm(f Filter) *filterForm {
+	form := &filterForm{original: f.filter, skipInbox: f.SkipInbox, trash: f.Trash}
+	c := f.criteria()
+	for i, v := range []struct{ prompt, value string }{
+		{"From:      ", c.From},
+		{"To:        ", c.To},
+		{"Subject:   ", c.Subject},
+		{"Has words: ", c.Query},
+		{"Label:     ", f.Label},
+	} {
+		ti := textinput.New()
+		ti.Prompt = v.prompt
+		ti.SetValue(v.value)
+		if i == 0 {
+			ti.Focus()
+		}
+		form.inputs = append(form.inputs, ti)
+	}
+	return form
+}
+
+// filterFromEmail pre-fills a new filter from a message's sender and
+// subject.
+func filterFromEmail(e Email) Filter {
+	return Filter{filter: &gmail.Filter{Criteria: &gmail.FilterCriteria{
+		From:    senderAddress(e.From),
+		Subject: e.Subject,
+	}}}
+}
+
+func (f *filterForm) setFocus(i int) tea.Cmd {
+	f.focus = (i + formFields) % formFields
+	var cmd tea.Cmd
+	for j := range f.inputs {
+		if j == f.focus {
+			cmd = f.inputs[j].Focus()
+		} else {
+			f.inputs[j].Blur()
+		}
+	}
+	return cmd
+}
+
+func (f *filterForm) value(i int) string {
+	return strings.TrimSpace(f.inputs[i].Value())
+}
+
+func (m Model) openFilterForm(f Filter) (Model, tea.Cmd) {
+	m.filterForm = newFilterForm(f)
+	return m, textinput.Blink
+}
+
+func (m Model) saveFilter(form *filterForm) tea.Cmd {
+	labels := m.labels
+	return func() tea.Msg {
+		action := &gmail.FilterAction{}
+		if form.original != nil && form.original.Action != nil {
+			// Keep actions the form does not edit, such as forwarding
+			// or other system labels.
+			a := *form.original.Action
+			action = &a
+			action.AddLabelIds = slices.DeleteFunc(slices.Clone(a.AddLabelIds), func(id string) bool {
+				l, ok := labels[id]
+				return id == "TRASH" || (ok && l.Type == "user")
+			})
+			action.RemoveLabelIds = slices.DeleteFunc(slices.Clone(a.RemoveLabelIds), func(id string) bool {
+				return id == "INBOX"
+			})
+		}
+
+		if form.skipInbox {
+			action.RemoveLabelIds = append(action.RemoveLabelIds, "INBOX")
+		}
+		if form.trash {
+			action.AddLabelIds = append(action.AddLabelIds, "TRASH")
+		}
+		if name := form.value(formLabel); name != "" {
+			id, err := ensureLabel(m.ctx, m.gmailSvc, labels, name)
+			if err != nil {
+				return errMsg(err)
+			}
+			action.AddLabelIds = append(action.AddLabelIds, id)
+		}
+
+		filter := &gmail.Filter{
+			Criteria: &gmail.FilterCriteria{
+				From:    form.value(formFrom),
+				To:      form.value(formTo),
+				Subject: form.value(formSubject),
+				Query:   form.value(formQuery),
+			},
+			Action: action,
+		}
+		if form.original != nil && form.original.Criteria != nil {
+			c := *form.original.Criteria
+			c.From, c.To, c.Subject, c.Query = filter.Criteria.From, filter.Criteria.To, filter.Criteria.Subject, filter.Criteria.Query
+			filter.Criteria = &c
+		}
+
+		if _, err := m.gmailSvc.Users.Settings.Filters.Create("me", filter).Context(m.ctx).Do(); err != nil {
+			return errMsg(fmt.Errorf("unable to create filter: %w", err))
+		}
+		if form.original != nil && form.original.Id != "" {
+			if err := m.gmailSvc.Users.Settings.Filters.Delete("me", form.original.Id).Context(m.ctx).Do(); err != nil {
+				return errMsg(fmt.Errorf("unable to replace filter: %w", err))
+			}
+			return filterSavedMsg("Filter updated")
+		}
+		return filterSavedMsg("Filter created")
+	}
+}
+
+func (m Model) updateFilterForm(msg tea.KeyMsg) (Model, tea.Cmd) {
+	form := m.filterForm
+	switch {
+	case key.Matches(msg, m.keys.Back):
+		m.filterForm = nil
+		return m, nil
+	case key.Matches(msg, m.keys.SuggestNext):
+		return m, form.setFocus(form.focus + 1)
+	case key.Matches(msg, m.keys.SuggestPrev):
+		return m, form.setFocus(form.focus - 1)
+	case key.Matches(msg, m.keys.Select):
+		m.filterForm = nil
+		m.loading = true
+		return m, m.saveFilter(form)
+	case key.Matches(msg, m.keys.Toggle) && form.focus == formSkipInbox:
+		form.skipInbox = !form.skipInbox
+		return m, nil
+	case key.Matches(msg, m.keys.Toggle) && form.focus == formTrash:
+		form.trash = !form.trash
+		return m, nil
+	}
+
+	if form.focus < len(form.inputs) {
+		var cmd tea.Cmd
+		form.inputs[form.focus], cmd = form.inputs[form.focus].Update(msg)
+		return m, cmd
+	}
+	return m, nil
+}
+
+func (m Model) filterFormView() string {
+	form := m.filterForm
+	title := "New filter"
+	if form.original != nil && form.original.Id != "" {
+		title = "Edit filter"
+	}
+
+	lines := []string{view.TitleStyle.Render(title), ""}
+	for _, in := range form.inputs {
+		lines = append(lines, "  "+in.View())
+	}
+	for i, t := range []struct {
+		label string
+		on    bool
+	}{
+		{"Skip inbox", form.skipInbox},
+		{"Delete it", form.trash},
+	} {
+		box := "[ ]"
+		if t.on {
+			box = "[x]"
+		}
+		marker := "  "
+		if form.focus == formSkipInbox+i {
+			marker = "> "
+		}
+		lines = append(lines, fmt.Sprintf("%s%s %s", marker, box, t.label))
+	}
+
+	return fmt.Sprintf(
+		"\n%s\n\n%s",
+		strings.Join(lines, "\n"),
+		view.HelpStyle.Render(m.statusLine()+"tab/shift+tab: move • space: toggle • enter: save • esc: cancel"),
+	)
+}
+
+func (m Model) updateFilters(msg tea.KeyMsg) (Model, tea.Cmd) {
+	if m.filterForm != nil {
+		return m.updateFilterForm(msg)
+	}
+
+	if m.filters.FilterState() != list.Filtering {
+		switch {
+		case key.Matches(msg, m.keys.Back) && m.filters.FilterState() == list.Unfiltered:
+			m.state = listView
+			return m, nil
+		case key.Matches(msg, m.keys.Quit):
+			return m.quit()
+		case key.Matches(msg, m.keys.Fetch):
+			m.loading = true
+			return m, m.fetchFilters
+		case key.Matches(msg, m.keys.NewFilter):
+			return m.openFilterForm(Filter{filter: &gmail.Filter{}})
+		case key.Matches(msg, m.keys.FilterFromMsg):
+			if e, ok := m.list.SelectedItem().(Email); ok {
+				return m.openFilterForm(filterFromEmail(e))
+			}
+			return m, nil
+		case key.Matches(msg, m.keys.Discard):
+			if f, ok := m.filters.SelectedItem().(Filter); ok {
+				m = m.deleteFilter(f)
+			}
+			return m, nil
+		case key.Matches(msg, m.keys.Select):
+			if f, ok := m.filters.SelectedItem().(Filter); ok {
+				return m.openFilterForm(f)
+			}
+			return m, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	m.filters, cmd = m.filters.Update(msg)
+	return m, cmd
+}
+
+func (m Model) filtersView() string {
+	if m.filterForm != nil {
+		return m.filterFormView()
+	}
+	return fmt.Sprintf(
+		"%s\n\n%s",
+		m.filters.View(),
+		view.HelpStyle.Render(m.statusLine()+"enter: edit • n: new • c: from selected message • x: delete • r: refresh • esc: back"),
+	)
+}
+
+func (m Model) openFilters() (Model, tea.Cmd) {
+	m.state = filtersView
+	m.filterForm = nil
+	m.loading = true
+	return m, m.fetchFilters
+}
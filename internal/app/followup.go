@@ -0,0 +1,251 @@
+package app
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"google.golang.org/api/gmail/v1"
+
+	"gmail-tui/internal/mimepart"
+)
+
+// Like snoozes, follow-up reminders are labels: a message waiting for a
+// reply gets a label named after its deadline. Once the deadline passes
+// without a reply the message moves to the Follow-ups label, which the
+// follow-ups view lists; if a reply came the reminder is just dropped.
+const (
+	followUpLabelPrefix = "gmail-tui/awaiting-reply/"
+	followUpDueLabel    = "gmail-tui/follow-ups"
+)
+
+// followUpPrompt asks when to be reminded about a message. email is nil
+// for the message being composed, which is labelled once it is sent.
+type followUpPrompt struct {
+	email *Email
+	input textinput.Model
+}
+
+// followUpsDueMsg reports messages that got no reply by their deadline.
+type followUpsDueMsg []string
+
+func followUpLabelName(t time.Time) string {
+	return followUpLabelPrefix + t.UTC().Format(snoozeLabelLayout)
+}
+
+func followUpLabelTime(name string) (time.Time, bool) {
+	s, ok := strings.CutPrefix(name, followUpLabelPrefix)
+	if !ok {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(snoozeLabelLayout, s)
+	return t, err == nil
+}
+
+// startFollowUp asks when to be reminded if e, or the message being
+// composed when e is nil, gets no reply.
+func (m Model) startFollowUp(e *Email) (Model, tea.Cmd) {
+	ti := textinput.New()
+	ti.Prompt = "Remind me if no reply by: "
+	ti.Placeholder = futureTimeHint
+	m.followUp = &followUpPrompt{email: e, input: ti}
+	return m, m.followUp.input.Focus()
+}
+
+func (m Model) updateFollowUp(msg tea.KeyMsg) (Model, tea.Cmd) {
+	p := m.followUp
+	switch {
+	case key.Matches(msg, m.keys.Back):
+		m.followUp = nil
+		m.status = "Cancelled"
+		return m, nil
+	case key.Matches(msg, m.keys.Select):
+		by, err := parseFutureTime(p.input.Value(), time.Now())
+		if err != nil {
+			m.status = err.Error()
+			return m, nil
+		}
+		m.followUp = nil
+		if p.email == nil {
+			m.compose.SetFollowUp(by)
+			m.status = "Will remind you if there is no reply by " + by.Local().Format("Mon Jan 2 15:04")
+			return m, nil
+		}
+		return m, m.setFollowUp(*p.email, by)
+	}
+
+	var cmd tea.Cmd
+	m.followUp.input, cmd = p.input.Update(msg)
+	return m, cmd
+}
+
+// setFollowUp labels e to be checked for a reply at the given time.
+func (m Model) setFollowUp(e Email, by time.Time) tea.Cmd {
+	return func() tea.Msg {
+		labels, err := fetchLabels(m.ctx, m.gmailSvc)
+		if err != nil {
+			return errMsg(err)
+		}
+		id, err := ensureLabel(m.ctx, m.gmailSvc, labels, followUpLabelName(by))
+		if err != nil {
+			return errMsg(err)
+		}
+		status := "Will remind you if there is no reply by " + by.Local().Format("Mon Jan 2 15:04")
+		return m.modifyLabels(e, []string{id}, nil, false, status)()
+	}
+}
+
+// labelSentFollowUp sets the reminder chosen while composing on the message
+// that was sent.
+func (m Model) labelSentFollowUp(id string, by time.Time) error {
+	labels, err := fetchLabels(m.ctx, m.gmailSvc)
+	if err != nil {
+		return err
+	}
+	label, err := ensureLabel(m.ctx, m.gmailSvc, labels, followUpLabelName(by))
+	if err != nil {
+		return err
+	}
+	_, err = m.gmailSvc.Users.Messages.Modify("me", id, &gmail.ModifyMessageRequest{
+		AddLabelIds: []string{label},
+	}).Context(m.ctx).Do()
+	return err
+}
+
+// checkFollowUps looks at the messages whose reminder is due. Those that
+// got no reply move to the Follow-ups label; the others just lose the
+// reminder. Messages in Follow-ups that have since been replied to leave
+// it. Like wakeSnoozed it runs in the background and leaves failures for
+// the next run.
+func (m Model) checkFollowUps() tea.Msg {
+	labels, err := fetchLabels(m.ctx, m.gmailSvc)
+	if err != nil {
+		return followUpsDueMsg(nil)
+	}
+
+	if l := labels.byName(followUpDueLabel); l != nil {
+		r, err := m.gmailSvc.Users.Messages.List("me").LabelIds(l.Id).Context(m.ctx).Do()
+		if err == nil {
+			for _, ref := range r.Messages {
+				if _, replied, err := m.replied(ref.Id, ref.ThreadId); err == nil && replied {
+					m.gmailSvc.Users.Messages.Modify("me", ref.Id, &gmail.ModifyMessageRequest{
+						RemoveLabelIds: []string{l.Id},
+					}).Context(m.ctx).Do()
+				}
+			}
+		}
+	}
+
+	var due []string
+	for _, l := range labels {
+		t, ok := followUpLabelTime(l.Name)
+		if !ok || t.After(time.Now()) {
+			continue
+		}
+		r, err := m.gmailSvc.Users.Messages.List("me").LabelIds(l.Id).Context(m.ctx).Do()
+		if err != nil {
+			continue
+		}
+		dueID := ""
+		left := len(r.Messages)
+		for _, ref := range r.Messages {
+			subject, replied, err := m.replied(ref.Id, ref.ThreadId)
+			if err != nil {
+				continue
+			}
+			add := []string{}
+			if !replied {
+				if dueID == "" {
+					if dueID, err = ensureLabel(m.ctx, m.gmailSvc, labels, followUpDueLabel); err != nil {
+						continue
+					}
+				}
+				add = []string{dueID}
+			}
+			_, err = m.gmailSvc.Users.Messages.Modify("me", ref.Id, &gmail.ModifyMessageRequest{
+				AddLabelIds:    add,
+				RemoveLabelIds: []string{l.Id},
+			}).Context(m.ctx).Do()
+			if err != nil {
+				continue
+			}
+			left--
+			if !replied {
+				due = append(due, subject)
+			}
+		}
+		if left == 0 && r.NextPageToken == "" {
+			m.gmailSvc.Users.Labels.Delete("me", l.Id).Context(m.ctx).Do()
+		}
+	}
+	return followUpsDueMsg(due)
+}
+
+// replied reports whether the thread of message id has a later message
+// from the other side: from someone else if id was sent, or from you if
+// it was received. It also returns the message's subject.
+func (m Model) replied(id, threadID string) (string, bool, error) {
+	th, err := m.gmailSvc.Users.Threads.Get("me", threadID).Format("metadata").
+		MetadataHeaders("Subject").Context(m.ctx).Do()
+	if err != nil {
+		return "", false, err
+	}
+	var subject string
+	var sent bool
+	var at int64 = -1
+	for _, msg := range th.Messages {
+		if msg.Id == id {
+			subject = mimepart.DecodeHeader(mimepart.Header(msg.Payload, "Subject"))
+			sent = slices.Contains(msg.LabelIds, "SENT")
+			at = msg.InternalDate
+		}
+	}
+	if at < 0 {
+		return "", false, fmt.Errorf("message %s is not in its thread", id)
+	}
+	for _, msg := range th.Messages {
+		if msg.InternalDate > at && slices.Contains(msg.LabelIds, "SENT") != sent && !slices.Contains(msg.LabelIds, "DRAFT") {
+			return subject, true, nil
+		}
+	}
+	return subject, false, nil
+}
+
+func (m Model) updateFollowUpsDue(msg followUpsDueMsg) Model {
+	if len(msg) == 0 {
+		return m
+	}
+	m.status = fmt.Sprintf("%d message(s) got no reply • :goto follow-ups", len(msg))
+	if m.config.FollowUpNotify {
+		notify("No reply yet", strings.Join(msg, "\n"))
+	}
+	return m
+}
+
+// notify shows a desktop notification, if the system has a way to. Errors
+// are ignored.
+func notify(title, body string) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		script := fmt.Sprintf("display notification %q with title %q", body, title)
+		cmd = exec.Command("osascript", "-e", script)
+	case "windows":
+		return
+	default:
+		cmd = exec.Command("notify-send", "--app-name=gmail-tui", title, body)
+	}
+	if cmd.Start() == nil {
+		go cmd.Wait()
+	}
+}
+
+func (m Model) followUpPrompt() string {
+	return m.followUp.input.View() + "\nenter: set reminder • esc: cancel"
+}
@@ -0,0 +1,97 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"gmail-tui/internal/ui/view"
+)
+
+// helpScreen is the full-screen list of key bindings, narrowed by what is
+// typed into filter.
+type helpScreen struct {
+	filter   textinput.Model
+	viewport viewport.Model
+}
+
+func (m Model) openHelp() (Model, tea.Cmd) {
+	ti := textinput.New()
+	ti.Prompt = "Filter: "
+	m.helpScreen = &helpScreen{filter: ti, viewport: viewport.New(m.width, max(m.height-4, 1))}
+	m.helpScreen.viewport.SetContent(m.helpContent())
+	return m, m.helpScreen.filter.Focus()
+}
+
+func (m Model) updateHelp(msg tea.KeyMsg) (Model, tea.Cmd) {
+	h := m.helpScreen
+	switch msg.Type {
+	case tea.KeyEsc:
+		if h.filter.Value() != "" {
+			h.filter.SetValue("")
+			h.viewport.SetContent(m.helpContent())
+			h.viewport.GotoTop()
+			return m, nil
+		}
+		m.helpScreen = nil
+		return m, nil
+	case tea.KeyCtrlC:
+		m.helpScreen = nil
+		return m.quit()
+	case tea.KeyUp, tea.KeyDown, tea.KeyPgUp, tea.KeyPgDown:
+		var cmd tea.Cmd
+		h.viewport, cmd = h.viewport.Update(msg)
+		return m, cmd
+	}
+
+	var cmd tea.Cmd
+	h.filter, cmd = h.filter.Update(msg)
+	h.viewport.SetContent(m.helpContent())
+	h.viewport.GotoTop()
+	return m, cmd
+}
+
+// helpContent lists the bindings matching the filter, by section. A section
+// whose title matches is listed whole.
+func (m Model) helpContent() string {
+	filter := ""
+	if m.helpScreen != nil {
+		filter = strings.ToLower(strings.TrimSpace(m.helpScreen.filter.Value()))
+	}
+	var lines []string
+	for _, s := range m.keys.Sections() {
+		whole := strings.Contains(strings.ToLower(s.Title), filter)
+		var rows []string
+		for _, b := range s.Bindings {
+			h := b.Help()
+			if h.Key == "" {
+				continue
+			}
+			if whole || strings.Contains(strings.ToLower(h.Key+" "+h.Desc), filter) {
+				rows = append(rows, fmt.Sprintf("  %-14s %s", h.Key, h.Desc))
+			}
+		}
+		if len(rows) > 0 {
+			lines = append(lines, view.TitleStyle.Render(s.Title))
+			lines = append(lines, rows...)
+			lines = append(lines, "")
+		}
+	}
+	if len(lines) == 0 {
+		return "  No key bindings match"
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (m Model) helpView() string {
+	h := m.helpScreen
+	return fmt.Sprintf(
+		"%s\n%s\n\n%s",
+		h.filter.View(),
+		h.viewport.View(),
+		view.HelpStyle.Render(m.statusLine()+"type to filter • ↑/↓: scroll • esc: clear / close"),
+	)
+}
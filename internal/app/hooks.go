@@ -0,0 +1,99 @@
+package app
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"google.golang.org/api/gmail/v1"
+
+	"gmail-tui/internal/ui/compose"
+)
+
+// hookEvent is the message a hook is run for.
+type hookEvent struct {
+	ID       string
+	ThreadID string
+	From     string
+	To       string
+	Subject  string
+	Snippet  string
+	Date     time.Time
+	Labels   []string
+}
+
+func emailEvent(e Email) hookEvent {
+	return hookEvent{
+		ID:       e.ID,
+		ThreadID: e.ThreadID,
+		From:     e.From,
+		Subject:  e.Subject,
+		Snippet:  e.Snippet,
+		Date:     e.Date,
+		Labels:   e.Labels,
+	}
+}
+
+// sentEvent describes a message sent from a draft.
+func sentEvent(msg *gmail.Message, d compose.Draft) hookEvent {
+	ev := hookEvent{From: d.From, To: d.To, Subject: d.Subject, Date: time.Now()}
+	if msg != nil {
+		ev.ID, ev.ThreadID, ev.Labels = msg.Id, msg.ThreadId, msg.LabelIds
+	}
+	return ev
+}
+
+// runHook starts command for event, if one is configured, without waiting
+// for it to finish.
+func runHook(event, command string, ev hookEvent) {
+	if command == "" {
+		return
+	}
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+
+	date := ""
+	if !ev.Date.IsZero() {
+		date = ev.Date.Format(time.RFC3339)
+	}
+	cmd.Env = append(os.Environ(),
+		"GMAIL_TUI_EVENT="+event,
+		"GMAIL_TUI_ID="+ev.ID,
+		"GMAIL_TUI_THREAD_ID="+ev.ThreadID,
+		"GMAIL_TUI_FROM="+ev.From,
+		"GMAIL_TUI_TO="+ev.To,
+		"GMAIL_TUI_SUBJECT="+ev.Subject,
+		"GMAIL_TUI_SNIPPET="+ev.Snippet,
+		"GMAIL_TUI_DATE="+date,
+		"GMAIL_TUI_LABELS="+strings.Join(ev.Labels, ","),
+	)
+	if err := cmd.Start(); err != nil {
+		return
+	}
+	go cmd.Wait()
+}
+
+// newMail returns the inbox messages in emails newer than newest, the date
+// of the newest message seen so far (or the start time, before any were),
+// and the updated newest date.
+func newMail(emails []Email, newest time.Time) ([]Email, time.Time) {
+	var fresh []Email
+	latest := newest
+	for _, e := range emails {
+		if e.Date.After(newest) && slices.Contains(e.Labels, "INBOX") {
+			fresh = append(fresh, e)
+		}
+		if e.Date.After(latest) {
+			latest = e.Date
+		}
+	}
+	return fresh, latest
+}
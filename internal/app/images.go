@@ -0,0 +1,330 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"google.golang.org/api/gmail/v1"
+
+	"gmail-tui/internal/mimepart"
+	"gmail-tui/internal/ui/compose"
+	"gmail-tui/internal/ui/view"
+)
+
+// Terminal graphics protocols images can be shown with. imageNone shows a
+// text placeholder instead.
+const (
+	imageKitty = "kitty"
+	imageITerm = "iterm"
+	imageSixel = "sixel"
+	imageNone  = "none"
+)
+
+// maxSixelWidth bounds the pixel width of sixel images, which are drawn at
+// their actual size.
+const maxSixelWidth = 800
+
+// messageImage is an image attached to or embedded in a message.
+type messageImage struct {
+	name string
+	data []byte
+}
+
+type imagesMsg []messageImage
+
+type imagesClosedMsg struct {
+	err error
+}
+
+// imageProtocol resolves the image_protocol setting. "auto" picks a
+// protocol from what the terminal announces about itself.
+func imageProtocol(setting string) string {
+	if setting != "" && setting != "auto" {
+		return setting
+	}
+	term, program := os.Getenv("TERM"), os.Getenv("TERM_PROGRAM")
+	switch {
+	case os.Getenv("KITTY_WINDOW_ID") != "" || term == "xterm-kitty" || term == "xterm-ghostty" || program == "ghostty":
+		return imageKitty
+	case program == "iTerm.app" || program == "WezTerm" || os.Getenv("LC_TERMINAL") == "iTerm2":
+		return imageITerm
+	case strings.HasPrefix(term, "foot") || strings.HasPrefix(term, "mlterm") || strings.Contains(term, "sixel"):
+		return imageSixel
+	}
+	return imageNone
+}
+
+// fetchImages downloads the image parts of a message, both attachments and
+// images embedded in its HTML, and loads the remote images it links to.
+// Remote images that fail to load are left out.
+func (m Model) fetchImages(id string) tea.Cmd {
+	return func() tea.Msg {
+		msg, err := m.mail.Get(m.ctx, id, "full")
+		if err != nil {
+			return errMsg(fmt.Errorf("unable to fetch message: %w", err))
+		}
+
+		var images []messageImage
+		for _, part := range imageParts(msg.Payload) {
+			name := part.Filename
+			if name == "" {
+				name = strings.Trim(mimepart.Header(part, "Content-ID"), "<>")
+			}
+			data, ok := mimepart.Data(part)
+			if !ok {
+				a := compose.Attachment{Name: name, MessageID: id, AttachmentID: part.Body.AttachmentId}
+				if data, err = a.Load(m.ctx, m.mail); err != nil {
+					return errMsg(err)
+				}
+			}
+			images = append(images, messageImage{name: name, data: data})
+		}
+
+		// Remote images are only loaded now that they were asked for.
+		var failed error
+		for _, src := range parseRemote(msg.Payload).images {
+			img, err := fetchRemoteImage(m.ctx, src)
+			if err != nil {
+				failed = err
+				continue
+			}
+			images = append(images, img)
+		}
+		if len(images) == 0 && failed != nil {
+			return errMsg(failed)
+		}
+		return imagesMsg(images)
+	}
+}
+
+func imageParts(part *gmail.MessagePart) []*gmail.MessagePart {
+	var parts []*gmail.MessagePart
+	if strings.HasPrefix(part.MimeType, "image/") && part.Body != nil &&
+		(part.Body.AttachmentId != "" || part.Body.Data != "") {
+		parts = append(parts, part)
+	}
+	for _, p := range part.Parts {
+		parts = append(parts, imageParts(p)...)
+	}
+	return parts
+}
+
+// showImages suspends the UI and draws images straight to the terminal,
+// since graphics escape codes cannot be mixed into the rendered view.
+func (m Model) showImages(images []messageImage) tea.Cmd {
+	v := &imageViewer{
+		images:   images,
+		protocol: imageProtocol(m.config.ImageProtocol),
+		cols:     max(m.width-4, 20),
+	}
+	return tea.Exec(v, func(err error) tea.Msg {
+		return imagesClosedMsg{err: err}
+	})
+}
+
+// imageViewer is a tea.ExecCommand that shows images until enter is
+// pressed.
+type imageViewer struct {
+	images   []messageImage
+	protocol string
+	cols     int
+
+	stdin  io.Reader
+	stdout io.Writer
+}
+
+func (v *imageViewer) SetStdin(r io.Reader)  { v.stdin = r }
+func (v *imageViewer) SetStdout(w io.Writer) { v.stdout = w }
+func (v *imageViewer) SetStderr(io.Writer)   {}
+
+func (v *imageViewer) Run() error {
+	w := bufio.NewWriter(v.stdout)
+	w.WriteString("\x1b[2J\x1b[H")
+	for _, img := range v.images {
+		fmt.Fprintf(w, "%s (%s)\n", img.name, view.Size(int64(len(img.data))))
+		if err := v.draw(w, img); err != nil {
+			fmt.Fprintf(w, "[image %s: %v]\n", img.name, err)
+		}
+		w.WriteString("\n")
+	}
+	w.WriteString("Press enter to return")
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	_, err := bufio.NewReader(v.stdin).ReadString('\n')
+	if v.protocol == imageKitty {
+		// Kitty keeps images until told otherwise.
+		io.WriteString(v.stdout, "\x1b_Ga=d\x1b\\")
+	}
+	if err == io.EOF {
+		err = nil
+	}
+	return err
+}
+
+func (v *imageViewer) draw(w io.Writer, img messageImage) error {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(img.data))
+	if err != nil {
+		return fmt.Errorf("unsupported image")
+	}
+	// Assume cells about 8 pixels wide, so small images are not blown up.
+	cols := min(v.cols, (cfg.Width+7)/8)
+
+	switch v.protocol {
+	case imageKitty:
+		data := img.data
+		if format != "png" {
+			if data, err = toPNG(img.data); err != nil {
+				return err
+			}
+		}
+		writeKitty(w, data, cols)
+	case imageITerm:
+		fmt.Fprintf(w, "\x1b]1337;File=inline=1;size=%d;width=%d;preserveAspectRatio=1:%s\a\n",
+			len(img.data), cols, base64.StdEncoding.EncodeToString(img.data))
+	case imageSixel:
+		decoded, _, err := image.Decode(bytes.NewReader(img.data))
+		if err != nil {
+			return err
+		}
+		writeSixel(w, scaleImage(decoded, min(cfg.Width, maxSixelWidth, v.cols*8)))
+		io.WriteString(w, "\n")
+	default:
+		fmt.Fprintf(w, "[%s image, %d×%d]\n", strings.ToUpper(format), cfg.Width, cfg.Height)
+	}
+	return nil
+}
+
+func toPNG(data []byte) ([]byte, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	if err := png.Encode(&b, img); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// writeKitty sends a PNG with the kitty graphics protocol, in the 4096 byte
+// chunks it requires, scaled to cols cells wide.
+func writeKitty(w io.Writer, data []byte, cols int) {
+	enc := base64.StdEncoding.EncodeToString(data)
+	first := true
+	for len(enc) > 0 {
+		chunk := enc[:min(len(enc), 4096)]
+		enc = enc[len(chunk):]
+		more := 0
+		if len(enc) > 0 {
+			more = 1
+		}
+		if first {
+			fmt.Fprintf(w, "\x1b_Ga=T,f=100,c=%d,m=%d;%s\x1b\\", cols, more, chunk)
+			first = false
+		} else {
+			fmt.Fprintf(w, "\x1b_Gm=%d;%s\x1b\\", more, chunk)
+		}
+	}
+	io.WriteString(w, "\n")
+}
+
+// scaleImage resizes img to width pixels, keeping its aspect ratio, with
+// nearest-neighbour sampling.
+func scaleImage(img image.Image, width int) image.Image {
+	b := img.Bounds()
+	if b.Dx() <= width || width <= 0 {
+		return img
+	}
+	height := max(b.Dy()*width/b.Dx(), 1)
+	out := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			out.Set(x, y, img.At(b.Min.X+x*b.Dx()/width, b.Min.Y+y*b.Dy()/height))
+		}
+	}
+	return out
+}
+
+// writeSixel encodes img as sixel graphics using a fixed 6×6×6 colour
+// cube, which is coarse but needs no per-image quantisation.
+func writeSixel(w io.Writer, img image.Image) {
+	b := img.Bounds()
+	bw := bufio.NewWriter(w)
+	defer bw.Flush()
+
+	fmt.Fprintf(bw, "\x1bPq\"1;1;%d;%d", b.Dx(), b.Dy())
+	for i := 0; i < 216; i++ {
+		fmt.Fprintf(bw, "#%d;2;%d;%d;%d", i, i/36*20, i/6%6*20, i%6*20)
+	}
+
+	index := func(x, y int) int {
+		r, g, bl, a := img.At(x, y).RGBA()
+		if a == 0 {
+			return -1
+		}
+		level := func(c uint32) int { return int((c*5 + 0x7fff) / 0xffff) }
+		return level(r)*36 + level(g)*6 + level(bl)
+	}
+
+	for top := b.Min.Y; top < b.Max.Y; top += 6 {
+		// Each colour used in this band of six rows is drawn in a pass
+		// over the band, with $ returning to its start.
+		bands := map[int][]byte{}
+		var order []int
+		for x := b.Min.X; x < b.Max.X; x++ {
+			for dy := 0; dy < 6 && top+dy < b.Max.Y; dy++ {
+				c := index(x, top+dy)
+				if c < 0 {
+					continue
+				}
+				row, ok := bands[c]
+				if !ok {
+					row = make([]byte, b.Dx())
+					order = append(order, c)
+				}
+				row[x-b.Min.X] |= 1 << dy
+				bands[c] = row
+			}
+		}
+		for i, c := range order {
+			if i > 0 {
+				bw.WriteByte('$')
+			}
+			fmt.Fprintf(bw, "#%d", c)
+			writeSixelRow(bw, bands[c])
+		}
+		bw.WriteByte('-')
+	}
+	bw.WriteString("\x1b\\")
+}
+
+// writeSixelRow writes one colour's pass over a band, run-length encoding
+// repeated columns.
+func writeSixelRow(w *bufio.Writer, row []byte) {
+	for i := 0; i < len(row); {
+		j := i
+		for j < len(row) && row[j] == row[i] {
+			j++
+		}
+		ch := byte(63 + row[i])
+		if n := j - i; n > 3 {
+			fmt.Fprintf(w, "!%d%c", n, ch)
+		} else {
+			for k := 0; k < n; k++ {
+				w.WriteByte(ch)
+			}
+		}
+		i = j
+	}
+}
@@ -0,0 +1,264 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/analysis/analyzer/keyword"
+	"github.com/blevesearch/bleve/v2/mapping"
+	"github.com/blevesearch/bleve/v2/search/query"
+)
+
+// Where a search result came from, shown on its list row.
+const (
+	hitLocal = "local"
+	hitGmail = "gmail"
+	hitBoth  = "local+gmail"
+)
+
+// maxLocalHits caps the local results merged into a search.
+const maxLocalHits = 50
+
+// mailIndex is the optional full-text index of message bodies, kept on disk
+// so searches cover bodies instantly and work offline. Only messages that
+// were opened or prefetched are in it. A nil index does nothing.
+type mailIndex struct {
+	idx bleve.Index
+}
+
+// indexedMail is the document stored for a message. Every field is stored
+// so a hit can be listed and read without the API.
+type indexedMail struct {
+	From     string    `json:"from"`
+	Subject  string    `json:"subject"`
+	Body     string    `json:"body"`
+	Snippet  string    `json:"snippet"`
+	Date     time.Time `json:"date"`
+	Labels   []string  `json:"labels"`
+	ThreadID string    `json:"thread"`
+}
+
+func mailIndexPath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gmail-tui", "index.bleve"), nil
+}
+
+// openMailIndex opens the index, creating it on first use. It fails rather
+// than waits if another gmail-tui has the index open.
+func openMailIndex() (*mailIndex, error) {
+	path, err := mailIndexPath()
+	if err != nil {
+		return nil, fmt.Errorf("unable to open local index: %v", err)
+	}
+	idx, err := bleve.OpenUsing(path, map[string]interface{}{"bolt_timeout": "1s"})
+	if errors.Is(err, bleve.ErrorIndexPathDoesNotExist) {
+		if err = os.MkdirAll(filepath.Dir(path), 0o700); err == nil {
+			idx, err = bleve.New(path, indexMapping())
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to open local index %s: %v", path, err)
+	}
+	return &mailIndex{idx: idx}, nil
+}
+
+func indexMapping() mapping.IndexMapping {
+	text := bleve.NewTextFieldMapping()
+	label := bleve.NewTextFieldMapping()
+	label.Analyzer = keyword.Name
+	stored := bleve.NewTextFieldMapping()
+	stored.Index = false
+	stored.IncludeInAll = false
+
+	doc := bleve.NewDocumentMapping()
+	doc.AddFieldMappingsAt("from", text)
+	doc.AddFieldMappingsAt("subject", text)
+	doc.AddFieldMappingsAt("body", text)
+	doc.AddFieldMappingsAt("snippet", stored)
+	doc.AddFieldMappingsAt("date", bleve.NewDateTimeFieldMapping())
+	doc.AddFieldMappingsAt("labels", label)
+	doc.AddFieldMappingsAt("thread", stored)
+
+	m := bleve.NewIndexMapping()
+	m.DefaultMapping = doc
+	return m
+}
+
+func (x *mailIndex) close() {
+	if x != nil {
+		x.idx.Close()
+	}
+}
+
+// add indexes a message with its body, replacing any earlier copy.
+// Failures are ignored; the message is indexed again when next opened.
+func (x *mailIndex) add(e Email, body string) {
+	if x == nil {
+		return
+	}
+	x.idx.Index(e.ID, indexedMail{
+		From:     e.From,
+		Subject:  e.Subject,
+		Body:     body,
+		Snippet:  e.Snippet,
+		Date:     e.Date,
+		Labels:   e.Labels,
+		ThreadID: e.ThreadID,
+	})
+}
+
+// search returns the newest messages matching a query in bleve's query
+// string syntax, with their bodies loaded.
+func (x *mailIndex) search(q string) ([]Email, error) {
+	req := bleve.NewSearchRequestOptions(query.NewQueryStringQuery(q), maxLocalHits, 0, false)
+	req.Fields = []string{"*"}
+	req.SortBy([]string{"-date"})
+	res, err := x.idx.Search(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to search local index: %v", err)
+	}
+
+	var emails []Email
+	for _, hit := range res.Hits {
+		str := func(name string) string {
+			s, _ := hit.Fields[name].(string)
+			return s
+		}
+		e := Email{
+			ID:       hit.ID,
+			From:     str("from"),
+			Subject:  str("subject"),
+			Snippet:  str("snippet"),
+			Body:     str("body"),
+			ThreadID: str("thread"),
+			hit:      hitLocal,
+		}
+		e.Date, _ = time.Parse(time.RFC3339, str("date"))
+		// A field with a single value comes back as that value.
+		switch labels := hit.Fields["labels"].(type) {
+		case string:
+			e.Labels = []string{labels}
+		case []interface{}:
+			for _, l := range labels {
+				if s, ok := l.(string); ok {
+					e.Labels = append(e.Labels, s)
+				}
+			}
+		}
+		emails = append(emails, e)
+	}
+	return emails, nil
+}
+
+// merge adds local hits for a Gmail query to the API's results, marking
+// where each came from. If the API failed, the local hits are shown alone
+// and can be read offline. Queries the index cannot answer are left to the
+// API.
+func (x *mailIndex) merge(gmailQuery string, remote []Email, apiErr error) ([]Email, string, error) {
+	q, ok := localQuery(gmailQuery)
+	if x == nil || !ok {
+		return remote, "", apiErr
+	}
+	local, err := x.search(q)
+	if err != nil {
+		return remote, err.Error(), apiErr
+	}
+
+	if apiErr != nil {
+		if len(local) == 0 {
+			return nil, "", apiErr
+		}
+		for i := range local {
+			local[i].loaded = true
+		}
+		return local, fmt.Sprintf("Offline: %d local result(s) • %v", len(local), apiErr), nil
+	}
+
+	found := map[string]bool{}
+	for _, e := range local {
+		found[e.ID] = true
+	}
+	merged := slices.Clone(remote)
+	seen := map[string]bool{}
+	for i := range merged {
+		merged[i].hit = hitGmail
+		if found[merged[i].ID] {
+			merged[i].hit = hitBoth
+		}
+		seen[merged[i].ID] = true
+	}
+	for _, e := range local {
+		if !seen[e.ID] {
+			// The stored body may be stale; the message is fetched again
+			// when opened.
+			e.Body = ""
+			merged = append(merged, e)
+		}
+	}
+	slices.SortStableFunc(merged, func(a, b Email) int { return b.Date.Compare(a.Date) })
+	return merged, "", nil
+}
+
+var queryTerm = regexp.MustCompile(`-?(\w+:)?("[^"]*"|\S+)`)
+
+// localLabels maps the Gmail operators the index understands to label IDs.
+var localLabels = map[string]string{
+	"is:unread":    "UNREAD",
+	"is:starred":   "STARRED",
+	"is:important": "IMPORTANT",
+	"in:inbox":     "INBOX",
+	"in:sent":      "SENT",
+	"in:drafts":    "DRAFT",
+	"in:spam":      "SPAM",
+	"in:trash":     "TRASH",
+}
+
+// localQuery translates a Gmail query into bleve's query string syntax:
+// words and phrases must all match, from: and subject: search those fields,
+// and a few is:/in: operators match labels. It reports false for queries
+// using anything else, such as OR, dates or sizes.
+func localQuery(q string) (string, bool) {
+	var terms []string
+	for _, t := range queryTerm.FindAllString(q, -1) {
+		op := "+"
+		if strings.HasPrefix(t, "-") && len(t) > 1 {
+			op, t = "-", t[1:]
+		}
+		if label, ok := localLabels[strings.ToLower(t)]; ok {
+			terms = append(terms, op+"labels:"+label)
+			continue
+		}
+
+		field, value := "", t
+		if name, v, ok := strings.Cut(t, ":"); ok && !strings.HasPrefix(t, `"`) {
+			switch strings.ToLower(name) {
+			case "from", "subject":
+				field, value = strings.ToLower(name)+":", v
+			default:
+				return "", false
+			}
+		}
+		if t == "OR" || t == "AND" || strings.ContainsAny(value, "{}()") {
+			return "", false
+		}
+		value = strings.Trim(value, `"`)
+		if value == "" {
+			continue
+		}
+		terms = append(terms, op+field+`"`+strings.ReplaceAll(value, `"`, `\"`)+`"`)
+	}
+	if len(terms) == 0 {
+		return "", false
+	}
+	return strings.Join(terms, " "), true
+}
@@ -0,0 +1,61 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// labelIndex maps Gmail label IDs to labels and lets them be looked up by
+// name.
+type labelIndex map[string]*gmail.Label
+
+func fetchLabels(ctx context.Context, svc *gmail.Service) (labelIndex, error) {
+	r, err := svc.Users.Labels.List("me").Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("unable to list labels: %v", err)
+	}
+	idx := labelIndex{}
+	for _, l := range r.Labels {
+		idx[l.Id] = l
+	}
+	return idx, nil
+}
+
+// name returns the display name of a label ID, or the ID itself if the
+// label is unknown.
+func (idx labelIndex) name(id string) string {
+	if l, ok := idx[id]; ok {
+		return l.Name
+	}
+	return id
+}
+
+// byName finds a label by its name, ignoring case.
+func (idx labelIndex) byName(name string) *gmail.Label {
+	for _, l := range idx {
+		if strings.EqualFold(l.Name, name) {
+			return l
+		}
+	}
+	return nil
+}
+
+// ensureLabel returns the ID of the named label, creating it if needed. idx
+// is not updated; refetch labels to pick up the new one.
+func ensureLabel(ctx context.Context, svc *gmail.Service, idx labelIndex, name string) (string, error) {
+	if l := idx.byName(name); l != nil {
+		return l.Id, nil
+	}
+	l, err := svc.Users.Labels.Create("me", &gmail.Label{
+		Name:                  name,
+		LabelListVisibility:   "labelShow",
+		MessageListVisibility: "show",
+	}).Context(ctx).Do()
+	if err != nil {
+		return "", fmt.Errorf("unable to create label %s: %v", name, err)
+	}
+	return l.Id, nil
+}
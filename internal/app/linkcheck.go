@@ -0,0 +1,140 @@
+package app
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"golang.org/x/net/idna"
+	"golang.org/x/net/publicsuffix"
+
+	"gmail-tui/internal/browser"
+)
+
+// linkOpenedMsg reports a link opened after it was confirmed.
+type linkOpenedMsg string
+
+// urlShorteners hide where a link goes until it is followed.
+var urlShorteners = map[string]bool{
+	"bit.ly": true, "t.co": true, "tinyurl.com": true, "goo.gl": true, "ow.ly": true,
+	"is.gd": true, "buff.ly": true, "rebrand.ly": true, "cutt.ly": true, "t.ly": true,
+	"shorturl.at": true, "lnkd.in": true, "tiny.cc": true, "rb.gy": true, "s.id": true,
+	"bl.ink": true, "v.gd": true,
+}
+
+// domainText matches link text that reads like an address.
+var domainText = regexp.MustCompile(`^(?i)(https?://)?[a-z0-9-]+(\.[a-z0-9-]+)+(/\S*)?$`)
+
+// linkTarget is the host a link really goes to, in the form it would be
+// read in: international names are decoded from punycode.
+func linkTarget(u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return u
+	}
+	if host, err := idna.Display.ToUnicode(parsed.Hostname()); err == nil {
+		return host
+	}
+	return parsed.Hostname()
+}
+
+// linkWarnings lists what is suspicious about u, found in body: text that
+// names one site over a link to another, a punycode or international
+// domain, a URL shortener, a user name in front of the host, or a bare IP
+// address.
+func linkWarnings(body, u string) []string {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return []string{"the link can't be read"}
+	}
+	host := strings.ToLower(parsed.Hostname())
+
+	var warnings []string
+	if text := anchorText(body, u); text != "" {
+		if shown := textHost(text); shown != "" && siteOf(shown) != siteOf(host) {
+			warnings = append(warnings, fmt.Sprintf("the text says %s but the link goes to %s", shown, linkTarget(u)))
+		}
+	}
+	if ascii, err := idna.Lookup.ToASCII(host); err == nil && ascii != host {
+		warnings = append(warnings, fmt.Sprintf("international domain %s, spelled %s", host, ascii))
+	} else if strings.HasPrefix(host, "xn--") || strings.Contains(host, ".xn--") {
+		warnings = append(warnings, fmt.Sprintf("punycode domain %s, which reads as %s", host, linkTarget(u)))
+	}
+	if urlShorteners[strings.TrimPrefix(host, "www.")] {
+		warnings = append(warnings, "URL shortener; where it leads is only known once it is opened")
+	}
+	if parsed.User != nil {
+		warnings = append(warnings, fmt.Sprintf("%q before the @ hides that the host is %s", parsed.User.Username(), host))
+	}
+	if net.ParseIP(host) != nil {
+		warnings = append(warnings, "goes to an IP address rather than a named site")
+	}
+	return warnings
+}
+
+// anchorText returns the text just before u in a body converted from HTML,
+// where a link is written as "text <url>".
+func anchorText(body, u string) string {
+	i := strings.Index(body, " <"+u+">")
+	if i < 0 {
+		return ""
+	}
+	fields := strings.Fields(body[:i])
+	if len(fields) == 0 {
+		return ""
+	}
+	return strings.TrimRight(fields[len(fields)-1], ".,;:!?")
+}
+
+// textHost returns the host named by link text, or "" if the text doesn't
+// read like an address.
+func textHost(text string) string {
+	if !domainText.MatchString(text) {
+		return ""
+	}
+	if !strings.Contains(text, "://") {
+		text = "http://" + text
+	}
+	parsed, err := url.Parse(text)
+	if err != nil {
+		return ""
+	}
+	// Text such as "report.pdf" doesn't end in a real top-level domain.
+	host := strings.ToLower(parsed.Hostname())
+	if _, icann := publicsuffix.PublicSuffix(host); !icann {
+		return ""
+	}
+	return host
+}
+
+// siteOf is the registered domain of host, so www.example.com and
+// mail.example.com are the same site.
+func siteOf(host string) string {
+	if site, err := publicsuffix.EffectiveTLDPlusOne(host); err == nil {
+		return site
+	}
+	return host
+}
+
+// checkLink opens u once it has been seen where it goes. A link that looks
+// suspicious, or one clicked in the body where it may be cut short, is
+// opened only after confirming.
+func (m Model) checkLink(u string, clicked bool) Model {
+	warnings := linkWarnings(m.selectedMail.Body, u)
+	if len(warnings) == 0 && !clicked {
+		return m.visitLink(u)
+	}
+	prompt := fmt.Sprintf("Open %s (%s)?", u, linkTarget(u))
+	if len(warnings) > 0 {
+		prompt = fmt.Sprintf("Open %s? Warning: %s.", u, strings.Join(warnings, "; "))
+	}
+	return m.confirm(prompt+" y: open • n: cancel", func() tea.Msg {
+		if err := browser.Open(u); err != nil {
+			return errMsg(fmt.Errorf("unable to open link: %w", err))
+		}
+		return linkOpenedMsg("Opened " + u)
+	})
+}
@@ -0,0 +1,103 @@
+package app
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"gmail-tui/internal/browser"
+	"gmail-tui/internal/ui/view"
+)
+
+var urlPattern = regexp.MustCompile(`https?://[^\s<>"'()\[\]{}]+`)
+
+// gmailWebURL links to a message in the Gmail web UI. accountIndex is the
+// /u/N index of the account when several are signed in to the browser.
+func gmailWebURL(accountIndex int, messageID string) string {
+	return fmt.Sprintf("https://mail.google.com/mail/u/%d/#all/%s", accountIndex, messageID)
+}
+
+// openInWeb opens e in the Gmail web UI.
+func (m Model) openInWeb(e Email) Model {
+	if err := browser.Open(gmailWebURL(m.config.AccountIndex, e.ID)); err != nil {
+		m.status = fmt.Sprintf("Unable to open browser: %v", err)
+		return m
+	}
+	m.status = "Opened in Gmail"
+	return m
+}
+
+// extractLinks returns the distinct URLs in text in order of appearance.
+func extractLinks(text string) []string {
+	seen := map[string]bool{}
+	var links []string
+	for _, u := range urlPattern.FindAllString(text, -1) {
+		u = strings.TrimRight(u, ".,;:!?>")
+		if seen[u] {
+			continue
+		}
+		seen[u] = true
+		links = append(links, u)
+	}
+	return links
+}
+
+func (m Model) openLinkPicker() Model {
+	if !m.selectedMail.loaded {
+		m.status = "Message is still loading"
+		return m
+	}
+	m.links = extractLinks(m.selectedMail.Body)
+	if len(m.links) == 0 {
+		m.status = "No links in this message"
+		return m
+	}
+	m.pickingLink = true
+	m.linkCursor = 0
+	return m
+}
+
+func (m Model) updateLinkPicker(msg tea.KeyMsg) (Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, m.keys.Back):
+		m.pickingLink = false
+	case key.Matches(msg, m.keys.Down):
+		if m.linkCursor < len(m.links)-1 {
+			m.linkCursor++
+		}
+	case key.Matches(msg, m.keys.Up):
+		if m.linkCursor > 0 {
+			m.linkCursor--
+		}
+	case key.Matches(msg, m.keys.Select):
+		return m.openLink(m.linkCursor), nil
+	case len(msg.Runes) == 1 && msg.Runes[0] >= '1' && msg.Runes[0] <= '9':
+		if i := int(msg.Runes[0] - '1'); i < len(m.links) {
+			return m.openLink(i), nil
+		}
+	}
+	return m, nil
+}
+
+func (m Model) openLink(i int) Model {
+	m.pickingLink = false
+	return m.checkLink(m.links[i], false)
+}
+
+func (m Model) linkPickerView() string {
+	lines := []string{view.TitleStyle.Render("Links"), ""}
+	for i, l := range m.links {
+		marker := "  "
+		if i == m.linkCursor {
+			marker = "> "
+		}
+		lines = append(lines, fmt.Sprintf("%s%2d. %s", marker, i+1, l))
+		for _, w := range linkWarnings(m.selectedMail.Body, l) {
+			lines = append(lines, "      ! "+w)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
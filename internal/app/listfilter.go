@@ -0,0 +1,132 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// FilterValue is what the list filter searches: the subject, sender,
+// recipients, snippet and label names, a line each so a word typed never
+// matches across two of them.
+func (e Email) FilterValue() string {
+	return strings.Join(append([]string{e.Subject, e.From, e.To, e.Cc, e.Snippet}, e.labelNames...), "\n")
+}
+
+// nameLabels fills in the label names of the messages in the list, once
+// the labels have loaded.
+func (m Model) nameLabels() Model {
+	items := slices.Clone(m.list.Items())
+	for i, item := range items {
+		if e, ok := item.(Email); ok {
+			e.labelNames = m.labels.names(e)
+			items[i] = e
+		}
+	}
+	m.list.SetItems(items)
+	return m
+}
+
+// filterEmails is the message list's filter. Every word typed must appear
+// in a message, ignoring case, for it to be kept. Unlike the default fuzzy
+// filter, letters scattered through a long snippet are not a match, so
+// typing a name finds that person's mail and little else. Matches keep the
+// list's order.
+func filterEmails(term string, targets []string) []list.Rank {
+	words := strings.Fields(term)
+	var ranks []list.Rank
+	for i, target := range targets {
+		var matched []int
+		for _, w := range words {
+			at := matchedRunes(target, w)
+			if at == nil {
+				matched = nil
+				break
+			}
+			matched = append(matched, at...)
+		}
+		if matched != nil || len(words) == 0 {
+			slices.Sort(matched)
+			ranks = append(ranks, list.Rank{Index: i, MatchedIndexes: slices.Compact(matched)})
+		}
+	}
+	return ranks
+}
+
+// matchedRunes returns the indexes of the runes of s that are part of an
+// occurrence of word, ignoring case, or nil if word does not occur.
+func matchedRunes(s, word string) []int {
+	text, w := foldRunes(s), foldRunes(word)
+	if len(w) == 0 {
+		return nil
+	}
+	var at []int
+	for i := 0; i+len(w) <= len(text); i++ {
+		if slices.Equal(text[i:i+len(w)], w) {
+			for j := range w {
+				at = append(at, i+j)
+			}
+		}
+	}
+	return at
+}
+
+// foldRunes lowercases s rune by rune, so indexes into the result are
+// indexes into s.
+func foldRunes(s string) []rune {
+	runes := []rune(s)
+	for i, r := range runes {
+		runes[i] = unicode.ToLower(r)
+	}
+	return runes
+}
+
+// filterWords returns the words the list is being filtered by, or nil when
+// it isn't.
+func filterWords(m list.Model) []string {
+	if m.FilterState() == list.Unfiltered {
+		return nil
+	}
+	return strings.Fields(m.FilterValue())
+}
+
+// highlight styles the words of s that match the filter in base with the
+// filter match style on top. s must be plain text.
+func (d emailDelegate) highlight(s string, words []string, base lipgloss.Style) string {
+	var at []int
+	for _, w := range words {
+		at = append(at, matchedRunes(s, w)...)
+	}
+	if len(at) == 0 {
+		return s
+	}
+	unmatched := base.Inline(true)
+	return lipgloss.StyleRunes(s, at, unmatched.Inherit(d.Styles.FilterMatch), unmatched)
+}
+
+// renderMatches draws e in the default layout while the list is filtered,
+// highlighting the words typed wherever they matched: the default delegate
+// only highlights the title, and takes matches to be in it.
+func (d emailDelegate) renderMatches(w io.Writer, m list.Model, index int, e Email, words []string) {
+	title, desc := d.Styles.NormalTitle, d.Styles.NormalDesc
+	if index == m.Index() && m.FilterState() != list.Filtering {
+		title, desc = d.Styles.SelectedTitle, d.Styles.SelectedDesc
+	}
+	width := max(m.Width()-title.GetHorizontalFrameSize(), 1)
+	line := d.highlight(e.Title(), words, title)
+	if chips := d.labels.chips(e); chips != "" {
+		line += " " + chips
+	}
+	fmt.Fprint(w, title.Render(ansi.Truncate(line, width, "…")))
+	if !d.ShowDescription {
+		return
+	}
+	text, _, _ := strings.Cut(e.Description(), "\n")
+	fmt.Fprint(w, "\n"+desc.Render(ansi.Truncate(d.highlight(text, words, desc), width, "…")))
+}
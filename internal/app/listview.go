@@ -0,0 +1,217 @@
+package app
+
+import (
+	"cmp"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/list"
+
+	"gmail-tui/internal/config"
+	"gmail-tui/internal/ui/maillist"
+	"gmail-tui/internal/ui/view"
+)
+
+// listView returns how the current list is sorted and grouped. The
+// Priority Inbox is grouped into its sections unless set otherwise.
+func (m Model) listView() config.ListView {
+	v, ok := m.config.ListViews[m.place]
+	if !ok && m.place == "priority" {
+		v.Group = "priority"
+	}
+	if !slices.Contains(maillist.Sorts, v.Sort) {
+		v.Sort = "date"
+	}
+	return v
+}
+
+// setListView changes how the current list is sorted and grouped, and
+// saves it in the config file under the list's place. Searches are not
+// saved.
+func (m Model) setListView(v config.ListView) Model {
+	views := maps.Clone(m.config.ListViews)
+	if views == nil {
+		views = map[string]config.ListView{}
+	}
+	views[m.place] = v
+	m.config.ListViews = views
+	if m.place != "" {
+		saved := maps.Clone(views)
+		delete(saved, "")
+		if err := config.SaveValue("list_views", saved); err != nil {
+			m.status = err.Error()
+		}
+	}
+	return m.arrangeList()
+}
+
+// cycleSort steps the list through the sort orders.
+func (m Model) cycleSort() Model {
+	v := m.listView()
+	v.Sort = maillist.Sorts[(slices.Index(maillist.Sorts, v.Sort)+1)%len(maillist.Sorts)]
+	m = m.setListView(v)
+	if m.status == "" {
+		m.status = "Sorted by " + maillist.SortNames[v.Sort]
+	}
+	return m
+}
+
+// cycleGroup steps the list through no grouping, day headers and the
+// Priority Inbox sections.
+func (m Model) cycleGroup() Model {
+	v := m.listView()
+	v.Group = maillist.Groups[(slices.Index(maillist.Groups, v.Group)+1)%len(maillist.Groups)]
+	m = m.setListView(v)
+	if m.status != "" {
+		return m
+	}
+	switch {
+	case v.Group == "day" && !v.ByDate():
+		m.status = "Day headers show when sorted by date"
+	case v.Group == "priority":
+		m.status = "Priority Inbox: " + strings.ToLower(strings.Join(maillist.PrioritySections, ", "))
+	}
+	return m
+}
+
+// listHeader returns what the list rows are grouped under, or nil if they
+// are not grouped.
+func listHeader(v config.ListView) func(list.Item) string {
+	var header func(Email) string
+	switch {
+	case v.Group == "day" && v.ByDate():
+		header = func(e Email) string { return view.DayGroup(e.Date, time.Now()) }
+	case v.Group == "priority":
+		header = prioritySection
+	default:
+		return nil
+	}
+	return func(item list.Item) string {
+		if e, ok := item.(Email); ok {
+			return header(e)
+		}
+		return ""
+	}
+}
+
+// prioritySection returns the Priority Inbox section e is listed in.
+func prioritySection(e Email) string {
+	switch {
+	case e.hasLabel("IMPORTANT") && e.hasLabel("UNREAD"):
+		return maillist.PrioritySections[0]
+	case e.hasLabel("STARRED"):
+		return maillist.PrioritySections[1]
+	}
+	return maillist.PrioritySections[2]
+}
+
+// arrangeList sorts the list's rows and sets up day headers for the
+// current list view, keeping the cursor on the same message.
+func (m Model) arrangeList() Model {
+	v := m.listView()
+	selected, _ := m.list.SelectedItem().(Email)
+
+	var emails []Email
+	for _, item := range m.list.Items() {
+		if e, ok := item.(Email); ok {
+			emails = append(emails, e)
+		}
+	}
+	sortEmails(emails, v.Sort)
+	if v.Group == "priority" {
+		slices.SortStableFunc(emails, func(a, b Email) int {
+			return slices.Index(maillist.PrioritySections, prioritySection(a)) - slices.Index(maillist.PrioritySections, prioritySection(b))
+		})
+	}
+	items := make([]list.Item, len(emails))
+	for i, e := range emails {
+		items[i] = e
+	}
+	m.list.SetItems(items)
+	m.list.SetDelegate(m.emailDelegate())
+
+	if i := m.emailIndex(selected.ID); i >= 0 {
+		m.list.Select(i)
+	}
+	return m
+}
+
+// restoreCursor puts the cursor back on message id after the list was
+// reloaded, or if it is gone, on the nearest message below it, then above
+// it, that is still there. old is the list before it was reloaded.
+func (m Model) restoreCursor(old []list.Item, id string) Model {
+	at := slices.IndexFunc(old, func(item list.Item) bool {
+		e, ok := item.(Email)
+		return ok && e.ID == id
+	})
+	for d := 0; at >= 0 && (at+d < len(old) || at-d >= 0); d++ {
+		for _, j := range []int{at + d, at - d} {
+			if j < 0 || j >= len(old) {
+				continue
+			}
+			if e, ok := old[j].(Email); ok {
+				if i := m.emailIndex(e.ID); i >= 0 {
+					m.list.Select(i)
+					return m
+				}
+			}
+		}
+	}
+	// Nothing near it is left; keep the cursor within the list.
+	if n := len(m.list.Items()); m.list.Index() >= n {
+		m.list.Select(max(n-1, 0))
+	}
+	return m
+}
+
+// sortEmails orders emails by one of maillist.Sorts. Ties keep the newest
+// message first.
+func sortEmails(emails []Email, order string) {
+	slices.SortStableFunc(emails, func(a, b Email) int {
+		var c int
+		switch order {
+		case "oldest":
+			return a.Date.Compare(b.Date)
+		case "sender":
+			_, whoA := a.correspondent()
+			_, whoB := b.correspondent()
+			c = strings.Compare(maillist.SenderName(whoA), maillist.SenderName(whoB))
+		case "subject":
+			c = strings.Compare(maillist.SortSubject(a.Subject), maillist.SortSubject(b.Subject))
+		case "size":
+			c = cmp.Compare(b.Size, a.Size)
+		}
+		if c != 0 {
+			return c
+		}
+		return b.Date.Compare(a.Date)
+	})
+}
+
+// emailDelegate draws list rows with the default delegate, or as columns
+// when they are configured.
+type emailDelegate struct {
+	list.DefaultDelegate
+	columns []config.ListColumn
+	labels  labelIndex
+}
+
+func (d emailDelegate) Render(w io.Writer, m list.Model, index int, item list.Item) {
+	if e, ok := item.(Email); ok {
+		if len(d.columns) > 0 {
+			d.renderColumns(w, m, index, e)
+			return
+		}
+		if words := filterWords(m); len(words) > 0 {
+			d.renderMatches(w, m, index, e, words)
+			return
+		}
+		if chips := d.labels.chips(e); chips != "" {
+			item = chippedEmail{e, chips}
+		}
+	}
+	d.DefaultDelegate.Render(w, m, index, item)
+}
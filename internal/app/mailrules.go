@@ -0,0 +1,156 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"gmail-tui/internal/config"
+	"gmail-tui/internal/ui/maillist"
+)
+
+// refreshIdleInterval is how often a list that isn't reloaded in the
+// background looks again, in case the list shown has changed to one that
+// is.
+const refreshIdleInterval = 15 * time.Second
+
+// watchMax is how many of the newest messages a rule's search looks at on
+// each check.
+const watchMax = 20
+
+// sameQuery reports whether two Gmail searches are the same, ignoring case
+// and spacing.
+func sameQuery(a, b string) bool {
+	return strings.EqualFold(strings.Join(strings.Fields(a), " "), strings.Join(strings.Fields(b), " "))
+}
+
+// mailRule returns the first mail rule for query.
+func (c Config) mailRule(query string) (config.MailRule, bool) {
+	for _, r := range c.MailRules {
+		if sameQuery(r.Query, query) {
+			return r, true
+		}
+	}
+	return config.MailRule{}, false
+}
+
+// listRefreshInterval is how often the list for query is reloaded in the
+// background: as its rule says, or else every refresh_seconds.
+func (c Config) listRefreshInterval(query string) time.Duration {
+	if r, ok := c.mailRule(query); ok && r.RefreshSeconds > 0 {
+		return time.Duration(r.RefreshSeconds) * time.Second
+	}
+	return c.refreshInterval()
+}
+
+// autoRefreshes reports whether any list is reloaded in the background.
+func (c Config) autoRefreshes() bool {
+	if c.RefreshSeconds > 0 {
+		return true
+	}
+	for _, r := range c.MailRules {
+		if r.RefreshSeconds > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// mailWatch checks the search of a rule that notifies for new mail. Its
+// query leaves out what the rules before it find, as they handle that
+// mail.
+type mailWatch struct {
+	rule     config.MailRule
+	query    string
+	interval time.Duration
+
+	// seen holds the messages found by the last check. It is nil until
+	// the first, which only notes what is already there.
+	seen map[string]bool
+}
+
+type watchTickMsg struct {
+	watch *mailWatch
+}
+
+type watchCheckedMsg struct {
+	watch  *mailWatch
+	emails []Email
+	err    error
+}
+
+// mailWatches returns a watch for each rule that notifies and is checked.
+func (c Config) mailWatches() []*mailWatch {
+	var watches []*mailWatch
+	for i, r := range c.MailRules {
+		interval := time.Duration(r.RefreshSeconds) * time.Second
+		if interval == 0 {
+			interval = c.refreshInterval()
+		}
+		if !r.Notify || interval == 0 || strings.TrimSpace(r.Query) == "" {
+			continue
+		}
+		query := r.Query + " is:unread"
+		for _, prev := range c.MailRules[:i] {
+			if strings.TrimSpace(prev.Query) != "" {
+				query += " -(" + prev.Query + ")"
+			}
+		}
+		watches = append(watches, &mailWatch{rule: r, query: query, interval: interval})
+	}
+	return watches
+}
+
+func watchTick(w *mailWatch) tea.Cmd {
+	return tea.Tick(w.interval, func(time.Time) tea.Msg {
+		return watchTickMsg{watch: w}
+	})
+}
+
+// checkWatch lists the newest unread mail the watch's search finds.
+func (m Model) checkWatch(w *mailWatch) tea.Cmd {
+	return func() tea.Msg {
+		emails, _, err := m.listEmails(m.ctx, w.query, watchMax)
+		return watchCheckedMsg{watch: w, emails: emails, err: err}
+	}
+}
+
+// updateWatch notifies of the mail a check found that the one before
+// didn't. A check that failed is tried again at the next tick without a
+// word, as the list's own refresh reports the trouble.
+func (m Model) updateWatch(msg watchCheckedMsg) (Model, tea.Cmd) {
+	w := msg.watch
+	if msg.err != nil {
+		return m, watchTick(w)
+	}
+	seen := map[string]bool{}
+	var fresh []Email
+	for _, e := range msg.emails {
+		seen[e.ID] = true
+		if w.seen != nil && !w.seen[e.ID] {
+			fresh = append(fresh, e)
+		}
+	}
+	w.seen = seen
+	notifyNewMail(w.rule.Query, fresh)
+	return m, watchTick(w)
+}
+
+// notifyNewMail shows a desktop notification for new mail found by query:
+// the sender and subject of a single message, or the subjects of several.
+func notifyNewMail(query string, fresh []Email) {
+	switch len(fresh) {
+	case 0:
+		return
+	case 1:
+		notify(maillist.SenderName(fresh[0].From), fresh[0].Subject)
+		return
+	}
+	var subjects []string
+	for _, e := range fresh[:min(len(fresh), 5)] {
+		subjects = append(subjects, e.Subject)
+	}
+	notify(fmt.Sprintf("%d new messages in %s", len(fresh), query), strings.Join(subjects, "\n"))
+}
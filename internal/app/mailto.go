@@ -0,0 +1,101 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"gmail-tui/internal/ui/compose"
+)
+
+// parseMailto builds a draft from a mailto: URL (RFC 6068). Recipients may
+// be given in the address part, a to= field, or both.
+func parseMailto(target string) (compose.Draft, error) {
+	u, err := url.Parse(target)
+	if err != nil || !strings.EqualFold(u.Scheme, "mailto") {
+		return compose.Draft{}, fmt.Errorf("invalid mailto URL %q", target)
+	}
+	to, err := url.PathUnescape(u.Opaque)
+	if err != nil {
+		return compose.Draft{}, fmt.Errorf("invalid mailto URL %q: %v", target, err)
+	}
+
+	d := compose.Draft{To: to}
+	for k, vs := range u.Query() {
+		v := strings.Join(vs, ", ")
+		switch strings.ToLower(k) {
+		case "to":
+			d.To = joinAddresses(d.To, v)
+		case "cc":
+			d.Cc = joinAddresses(d.Cc, v)
+		case "bcc":
+			d.Bcc = joinAddresses(d.Bcc, v)
+		case "subject":
+			d.Subject = v
+		case "body":
+			d.Body = strings.ReplaceAll(v, "\r\n", "\n")
+		}
+	}
+	return d, nil
+}
+
+func joinAddresses(a, b string) string {
+	if a == "" {
+		return b
+	}
+	return a + ", " + b
+}
+
+const mailtoDesktopFile = "gmail-tui.desktop"
+
+// installMailtoHandler registers gmail-tui as the mailto: handler on Linux
+// by writing a desktop entry and making it the default with xdg-mime.
+func installMailtoHandler() error {
+	if runtime.GOOS != "linux" {
+		return errors.New("registering as the mailto handler is only supported on Linux")
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("unable to find the gmail-tui binary: %v", err)
+	}
+
+	dataDir := os.Getenv("XDG_DATA_HOME")
+	if dataDir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return err
+		}
+		dataDir = filepath.Join(home, ".local", "share")
+	}
+	dir := filepath.Join(dataDir, "applications")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("unable to create %s: %v", dir, err)
+	}
+
+	entry := fmt.Sprintf(`[Desktop Entry]
+Type=Application
+Name=gmail-tui
+Comment=Write Gmail messages in the terminal
+Exec=%q %%u
+Terminal=true
+NoDisplay=true
+MimeType=x-scheme-handler/mailto;
+`, exe)
+	path := filepath.Join(dir, mailtoDesktopFile)
+	if err := os.WriteFile(path, []byte(entry), 0644); err != nil {
+		return fmt.Errorf("unable to write %s: %v", path, err)
+	}
+
+	out, err := exec.Command("xdg-mime", "default", mailtoDesktopFile, "x-scheme-handler/mailto").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("wrote %s, but unable to make it the default: %v %s", path, err, out)
+	}
+	fmt.Printf("Installed %s as the mailto: handler\n", path)
+	return nil
+}
@@ -0,0 +1,40 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/glamour"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// glamourStyle picks the preview style for the terminal's background. It
+// queries the terminal, so it is called once before the UI starts.
+func glamourStyle() string {
+	if lipgloss.HasDarkBackground() {
+		return "dark"
+	}
+	return "light"
+}
+
+// renderMarkdown renders src for the terminal, wrapped to width.
+func renderMarkdown(src, style string, width int) (string, error) {
+	r, err := glamour.NewTermRenderer(glamour.WithStandardStyle(style), glamour.WithWordWrap(width))
+	if err != nil {
+		return "", fmt.Errorf("unable to render markdown: %v", err)
+	}
+	return r.Render(src)
+}
+
+// composeContent is what the compose view shows: the body as written, or
+// its rendering while previewing.
+func (m Model) composeContent() string {
+	body := m.compose.Snapshot().Body
+	if !m.previewing {
+		return body
+	}
+	out, err := renderMarkdown(body, m.glamourStyle, m.viewport.Width-4)
+	if err != nil {
+		return body
+	}
+	return out
+}
@@ -0,0 +1,90 @@
+package app
+
+import (
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// reading is the unread message waiting to be marked read once it has been
+// open for mark_read_seconds.
+type reading struct {
+	id    string
+	since time.Time
+}
+
+// markReadMsg fires when the message opened at since has been open long
+// enough to be marked read.
+type markReadMsg struct {
+	id    string
+	since time.Time
+}
+
+// markedReadMsg carries the labels of a message after it was marked read on
+// opening.
+type markedReadMsg struct {
+	id     string
+	labels []string
+}
+
+// scheduleMarkRead marks e read now or after the configured delay, if it is
+// unread. A negative delay leaves it unread until marked by hand.
+func (m Model) scheduleMarkRead(e Email) (Model, tea.Cmd) {
+	m.reading = nil
+	delay := m.config.MarkReadSeconds
+	if delay < 0 || !e.hasLabel("UNREAD") {
+		return m, nil
+	}
+	if delay == 0 {
+		return m, m.markRead(e)
+	}
+	r := &reading{id: e.ID, since: time.Now()}
+	m.reading = r
+	return m, tea.Tick(time.Duration(delay)*time.Second, func(time.Time) tea.Msg {
+		return markReadMsg{id: r.id, since: r.since}
+	})
+}
+
+// applyMarkRead marks the message read if it is still the one open. Going
+// back to the list, opening another message or changing its labels in the
+// meantime cancels it.
+func (m Model) applyMarkRead(msg markReadMsg) (Model, tea.Cmd) {
+	r := m.reading
+	if r == nil || r.id != msg.id || !r.since.Equal(msg.since) {
+		return m, nil
+	}
+	m.reading = nil
+	if m.state != messageView || m.selectedMail == nil || m.selectedMail.ID != msg.id {
+		return m, nil
+	}
+	return m, m.markRead(*m.selectedMail)
+}
+
+// markRead removes UNREAD from e. Unlike toggleRead it leaves the status
+// and the undo history alone, as nothing was asked for.
+func (m Model) markRead(e Email) tea.Cmd {
+	return func() tea.Msg {
+		msg, err := m.mail.Modify(m.ctx, e.ID, nil, []string{"UNREAD"})
+		if err != nil {
+			return errMsg(fmt.Errorf("unable to mark message as read: %w", err))
+		}
+		return markedReadMsg{id: e.ID, labels: msg.LabelIds}
+	}
+}
+
+// applyMarkedRead updates the labels of the message in the list and, if it
+// is open, of the open one, keeping the body loaded since.
+func (m Model) applyMarkedRead(msg markedReadMsg) Model {
+	if i := m.emailIndex(msg.id); i >= 0 {
+		e := m.list.Items()[i].(Email)
+		e.Labels = msg.labels
+		m.list.SetItem(i, e)
+	}
+	if m.selectedMail != nil && m.selectedMail.ID == msg.id {
+		e := *m.selectedMail
+		e.Labels = msg.labels
+		m.selectedMail = &e
+	}
+	return m
+}
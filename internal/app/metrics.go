@@ -0,0 +1,358 @@
+package app
+
+import (
+	"bufio"
+	"cmp"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// hudLines is how many lines the performance HUD takes.
+const hudLines = 3
+
+// sessionMetrics counts the mail operations, API requests and cache
+// lookups of this run, for the performance HUD and the debug log.
+var sessionMetrics = newMetrics()
+
+type metrics struct {
+	mu    sync.Mutex
+	start time.Time
+	// ops are the mail operations by name (list, get, ...), whichever
+	// backend they went to.
+	ops map[string]*opStats
+	// requests are the API requests by method, e.g. messages.get. The
+	// requests in a batch are counted one by one.
+	requests map[string]*opStats
+	// quota is the Gmail quota units the requests are estimated to have
+	// used.
+	quota  int
+	caches map[string]*cacheStats
+}
+
+type opStats struct {
+	count  int
+	failed int
+	total  time.Duration
+	max    time.Duration
+}
+
+func (s *opStats) add(took time.Duration, err error) {
+	s.count++
+	if err != nil {
+		s.failed++
+	}
+	s.total += took
+	s.max = max(s.max, took)
+}
+
+func (s *opStats) avg() time.Duration {
+	if s.count == 0 {
+		return 0
+	}
+	return s.total / time.Duration(s.count)
+}
+
+type cacheStats struct {
+	hits, misses int
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		start:    time.Now(),
+		ops:      map[string]*opStats{},
+		requests: map[string]*opStats{},
+		caches:   map[string]*cacheStats{},
+	}
+}
+
+func stats(m map[string]*opStats, name string) *opStats {
+	s, ok := m[name]
+	if !ok {
+		s = &opStats{}
+		m[name] = s
+	}
+	return s
+}
+
+// op records a mail operation.
+func (s *metrics) op(name string, took time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats(s.ops, name).add(took, err)
+}
+
+// request records an API request made with the HTTP method to host and
+// path.
+func (s *metrics) request(method, host, path string, took time.Duration, err error) {
+	name := apiMethod(method, host, path)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats(s.requests, name).add(took, err)
+	s.quota += quotaUnits(name)
+}
+
+// cache records whether a lookup in the named cache found what it wanted.
+func (s *metrics) cache(name string, hit bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.caches[name]
+	if !ok {
+		c = &cacheStats{}
+		s.caches[name] = c
+	}
+	if hit {
+		c.hits++
+	} else {
+		c.misses++
+	}
+}
+
+// gmailQuotaUnits is what each Gmail API method takes from the per-user
+// quota, as Gmail documents it. Other Gmail methods are counted as 5 and
+// settings methods as 1; the People API has a quota of its own.
+var gmailQuotaUnits = map[string]int{
+	"messages.list":            5,
+	"messages.get":             5,
+	"messages.attachments.get": 5,
+	"messages.modify":          5,
+	"messages.trash":           5,
+	"messages.untrash":         5,
+	"messages.delete":          10,
+	"messages.batchModify":     50,
+	"messages.batchDelete":     50,
+	"messages.insert":          25,
+	"messages.import":          25,
+	"messages.send":            100,
+	"drafts.list":              5,
+	"drafts.get":               5,
+	"drafts.create":            10,
+	"drafts.update":            15,
+	"drafts.delete":            10,
+	"drafts.send":              100,
+	"threads.list":             10,
+	"threads.get":              10,
+	"threads.modify":           10,
+	"threads.trash":            10,
+	"threads.untrash":          10,
+	"labels.list":              1,
+	"labels.get":               1,
+	"history.list":             2,
+	"getProfile":               1,
+}
+
+func quotaUnits(name string) int {
+	switch {
+	case strings.HasPrefix(name, "people."):
+		return 0
+	case strings.HasPrefix(name, "settings."):
+		return 1
+	}
+	if units, ok := gmailQuotaUnits[name]; ok {
+		return units
+	}
+	return 5
+}
+
+// gmailActions are the path segments that name a method rather than a
+// resource.
+var gmailActions = map[string]bool{
+	"modify": true, "trash": true, "untrash": true, "send": true, "import": true,
+	"batchModify": true, "batchDelete": true,
+}
+
+// gmailCollections are the path segments that are followed by an ID.
+var gmailCollections = map[string]bool{
+	"messages": true, "drafts": true, "threads": true, "labels": true, "history": true,
+	"attachments": true, "settings": true, "filters": true, "sendAs": true,
+	"forwardingAddresses": true, "delegates": true,
+}
+
+// apiMethod names the API method a request calls, such as messages.get,
+// from its HTTP method and URL. Requests in a batch have no host and are
+// Gmail's.
+func apiMethod(method, host, path string) string {
+	if strings.HasPrefix(host, "people.") {
+		// As in /v1/people/me/connections or /v1/people:searchContacts.
+		last := path[strings.LastIndexAny(path, "/:")+1:]
+		return "people." + last
+	}
+	_, rest, ok := strings.Cut(path, "/users/me/")
+	if !ok {
+		return strings.TrimPrefix(path, "/")
+	}
+	if rest == "profile" {
+		return "getProfile"
+	}
+
+	var name []string
+	id := false
+	for _, seg := range strings.Split(rest, "/") {
+		switch {
+		case gmailActions[seg]:
+			return strings.Join(append(name, seg), ".")
+		case gmailCollections[seg]:
+			name, id = append(name, seg), false
+		default:
+			id = true
+		}
+	}
+	switch method {
+	case http.MethodGet:
+		if id {
+			name = append(name, "get")
+		} else {
+			name = append(name, "list")
+		}
+	case http.MethodPost:
+		name = append(name, "create")
+	case http.MethodPut:
+		name = append(name, "update")
+	case http.MethodPatch:
+		name = append(name, "patch")
+	case http.MethodDelete:
+		name = append(name, "delete")
+	}
+	return strings.Join(name, ".")
+}
+
+// metricsTransport counts each API request attempt, and each request in
+// a batch, for sessionMetrics.
+type metricsTransport struct {
+	base http.RoundTripper
+}
+
+func (t metricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	took := time.Since(start)
+	failed := err
+	if err == nil && resp.StatusCode >= 400 {
+		failed = errors.New(resp.Status)
+	}
+	if !strings.HasPrefix(req.URL.Path, "/batch/") || req.GetBody == nil {
+		sessionMetrics.request(req.Method, req.URL.Host, req.URL.Path, took, failed)
+		return resp, err
+	}
+
+	// Each request in the batch is a part starting with its request
+	// line, as "GET /gmail/v1/users/me/messages/ID?format=metadata".
+	body, berr := req.GetBody()
+	if berr != nil {
+		return resp, err
+	}
+	defer body.Close()
+	sc := bufio.NewScanner(body)
+	for sc.Scan() {
+		method, target, ok := strings.Cut(sc.Text(), " ")
+		if !ok || !strings.HasPrefix(target, "/") {
+			continue
+		}
+		path, _, _ := strings.Cut(target, "?")
+		sessionMetrics.request(method, "", path, took, failed)
+	}
+	return resp, err
+}
+
+// hudView is the performance HUD: what this run has asked of the mail
+// server, how long it took, and how often caches had what was wanted.
+func (m Model) hudView() string {
+	s := sessionMetrics
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var requests, failed int
+	for _, r := range s.requests {
+		requests += r.count
+		failed += r.failed
+	}
+	elapsed := time.Since(s.start)
+	first := fmt.Sprintf("Session %s • %d API requests", elapsed.Round(time.Second), requests)
+	if failed > 0 {
+		first += fmt.Sprintf(", %d failed", failed)
+	}
+	if m.daemon {
+		first += " (the daemon's are its own)"
+	}
+	if m.config.GmailAPI() {
+		first += fmt.Sprintf(" • ~%d quota units (%.1f/s)", s.quota, float64(s.quota)/max(elapsed.Seconds(), 1))
+	}
+	first += fmt.Sprintf(" • %d prefetching", m.prefetch.count())
+
+	var ops []string
+	for _, name := range topOps(s.ops, 6) {
+		o := s.ops[name]
+		op := fmt.Sprintf("%s %d×%s", name, o.count, o.avg().Round(time.Millisecond))
+		if o.max > 2*o.avg() {
+			op += fmt.Sprintf(" (max %s)", o.max.Round(time.Millisecond))
+		}
+		if o.failed > 0 {
+			op += fmt.Sprintf(" %d failed", o.failed)
+		}
+		ops = append(ops, op)
+	}
+	if len(ops) == 0 {
+		ops = []string{"no mail operations yet"}
+	}
+
+	caches := []string{"Caches:"}
+	names := make([]string, 0, len(s.caches))
+	for name := range s.caches {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		c := s.caches[name]
+		caches = append(caches, fmt.Sprintf("%s %d/%d hits (%d%%)", name, c.hits, c.hits+c.misses, 100*c.hits/(c.hits+c.misses)))
+	}
+	if len(names) == 0 {
+		caches = append(caches, "no lookups yet")
+	}
+	return first + "\n" + strings.Join(ops, " • ") + "\n" + strings.Join(caches, " ")
+}
+
+// topOps returns the names of the n operations run most often.
+func topOps(ops map[string]*opStats, n int) []string {
+	names := make([]string, 0, len(ops))
+	for name := range ops {
+		names = append(names, name)
+	}
+	slices.SortFunc(names, func(a, b string) int {
+		return cmp.Or(cmp.Compare(ops[b].count, ops[a].count), cmp.Compare(a, b))
+	})
+	return names[:min(n, len(names))]
+}
+
+// logMetrics writes the session's metrics to the debug log as it ends.
+func logMetrics() {
+	s := sessionMetrics
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for name, o := range s.ops {
+		debugLog.Info("operation metrics", "op", name, "count", o.count, "failed", o.failed, "avg", o.avg(), "max", o.max)
+	}
+	for name, r := range s.requests {
+		debugLog.Info("api metrics", "method", name, "count", r.count, "failed", r.failed, "avg", r.avg(), "max", r.max, "quota", r.count*quotaUnits(name))
+	}
+	for name, c := range s.caches {
+		debugLog.Info("cache metrics", "cache", name, "hits", c.hits, "misses", c.misses)
+	}
+	debugLog.Info("session metrics", "took", time.Since(s.start), "quota", s.quota)
+}
+
+// toggleHUD shows or hides the performance HUD. The views are laid out
+// again for the height left, which m.height is while the HUD shows.
+func (m Model) toggleHUD() (Model, tea.Cmd) {
+	size := tea.WindowSizeMsg{Width: m.width, Height: m.height}
+	if m.hud {
+		size.Height += hudLines
+	}
+	m.hud = !m.hud
+	return m, func() tea.Msg { return size }
+}
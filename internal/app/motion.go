@@ -0,0 +1,128 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// maxCount caps a count prefix.
+const maxCount = 9999
+
+// motion is a vim-style count prefix and pending g being typed before a
+// motion, as in 5j or gg.
+type motion struct {
+	count int
+	g     bool
+}
+
+// prefix records a digit of a count or the first g of gg, and reports
+// whether msg was one. A leading 0 is not a count.
+func (mo *motion) prefix(msg tea.KeyMsg, top key.Binding) bool {
+	if msg.Type == tea.KeyRunes && len(msg.Runes) == 1 && !mo.g {
+		if r := msg.Runes[0]; r >= '1' && r <= '9' || r == '0' && mo.count > 0 {
+			mo.count = min(mo.count*10+int(r-'0'), maxCount)
+			return true
+		}
+	}
+	if key.Matches(msg, top) && !mo.g {
+		mo.g = true
+		return true
+	}
+	return false
+}
+
+// take returns the count, or 1 if none was typed, and clears the prefix.
+func (mo *motion) take() int {
+	n := max(mo.count, 1)
+	*mo = motion{}
+	return n
+}
+
+func (mo motion) String() string {
+	s := ""
+	if mo.count > 0 {
+		s = fmt.Sprint(mo.count)
+	}
+	if mo.g {
+		s += "g"
+	}
+	return s
+}
+
+// listMotion moves the list cursor for counts, gg, G, ctrl+d and ctrl+u,
+// runs g chords, and reports whether msg was handled. Other keys clear a
+// count.
+func (m Model) listMotion(msg tea.KeyMsg) (Model, tea.Cmd, bool) {
+	if m.motion.prefix(msg, m.keys.Top) {
+		return m, nil, true
+	}
+	if m.motion.g && !key.Matches(msg, m.keys.Top) {
+		m.motion = motion{}
+		return m.chord(msg)
+	}
+	n := len(m.list.VisibleItems())
+	last := max(n-1, 0)
+	counted := m.motion.count > 0
+	switch {
+	case m.motion.g && key.Matches(msg, m.keys.Top):
+		line := m.motion.take()
+		m.list.Select(min(line-1, last))
+	case key.Matches(msg, m.keys.Bottom):
+		line := m.motion.take()
+		if !counted {
+			line = n
+		}
+		m.list.Select(min(line-1, last))
+	case key.Matches(msg, m.keys.HalfDown):
+		m.list.Select(min(m.list.Index()+m.motion.take()*max(m.list.Paginator.PerPage/2, 1), last))
+	case key.Matches(msg, m.keys.HalfUp):
+		m.list.Select(max(m.list.Index()-m.motion.take()*max(m.list.Paginator.PerPage/2, 1), 0))
+	case counted && key.Matches(msg, m.keys.Down):
+		m.list.Select(min(m.list.Index()+m.motion.take(), last))
+	case counted && key.Matches(msg, m.keys.Up):
+		m.list.Select(max(m.list.Index()-m.motion.take(), 0))
+	default:
+		m.motion = motion{}
+		return m, nil, false
+	}
+	return m, m.prefetchBodies(), true
+}
+
+// viewportMotion scrolls an open message for counts, gg, G, ctrl+d and
+// ctrl+u, runs g chords, and reports whether msg was handled. Other keys
+// clear a count.
+func (m Model) viewportMotion(msg tea.KeyMsg) (Model, tea.Cmd, bool) {
+	if m.motion.prefix(msg, m.keys.Top) {
+		return m, nil, true
+	}
+	if m.motion.g && !key.Matches(msg, m.keys.Top) {
+		m.motion = motion{}
+		return m.chord(msg)
+	}
+	counted := m.motion.count > 0
+	switch {
+	case m.motion.g && key.Matches(msg, m.keys.Top):
+		m.viewport.SetYOffset(m.motion.take() - 1)
+	case key.Matches(msg, m.keys.Bottom):
+		line := m.motion.take()
+		if !counted {
+			m.viewport.GotoBottom()
+		} else {
+			m.viewport.SetYOffset(line - 1)
+		}
+	case key.Matches(msg, m.keys.HalfDown):
+		m.viewport.LineDown(m.motion.take() * max(m.viewport.Height/2, 1))
+	case key.Matches(msg, m.keys.HalfUp):
+		m.viewport.LineUp(m.motion.take() * max(m.viewport.Height/2, 1))
+	case counted && key.Matches(msg, m.keys.Down):
+		m.viewport.LineDown(m.motion.take())
+	case counted && key.Matches(msg, m.keys.Up):
+		m.viewport.LineUp(m.motion.take())
+	default:
+		m.motion = motion{}
+		return m, nil, false
+	}
+	return m, nil, true
+}
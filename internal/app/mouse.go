@@ -0,0 +1,140 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+
+	"gmail-tui/internal/browser"
+	"gmail-tui/internal/ui/view"
+)
+
+// updateMouse handles the mouse in the list and reading views. In the list,
+// the wheel moves the cursor and clicking a message selects it, or opens it
+// if it was already selected. In an open message the wheel scrolls and
+// clicking a link opens it.
+func (m Model) updateMouse(msg tea.MouseMsg) (Model, tea.Cmd) {
+	if m.reauth != nil || m.palette != nil || m.unsubscribe != nil || m.confirmation != nil ||
+		m.snooze != nil || m.followUp != nil || m.profile != nil || m.purge != nil || m.helpScreen != nil || m.loading {
+		return m, nil
+	}
+	click := msg.Button == tea.MouseButtonLeft && msg.Action == tea.MouseActionPress
+
+	if m.state == messageView {
+		if click && !m.pickingLink && !m.pickingAttach {
+			if u := m.linkAt(msg.X, msg.Y); u != "" {
+				return m.checkLink(u, true), nil
+			}
+			if line, ok := m.viewLine(msg.Y); ok && m.source == "" && view.IsCollapsedMarker(line) {
+				return m.toggleQuotes(), nil
+			}
+			return m, nil
+		}
+		var cmd tea.Cmd
+		m.viewport, cmd = m.viewport.Update(msg)
+		return m, cmd
+	}
+
+	if m.list.FilterState() == list.Filtering || m.yankPending {
+		return m, nil
+	}
+	switch {
+	case msg.Button == tea.MouseButtonWheelUp:
+		m.list.CursorUp()
+	case msg.Button == tea.MouseButtonWheelDown:
+		m.list.CursorDown()
+	case click && msg.Y < lipgloss.Height(m.tabBar()):
+		if t, ok := m.tabAt(msg.X); ok {
+			return m.showTab(t)
+		}
+		return m, nil
+	case click:
+		i, ok := m.listRowAt(msg.Y)
+		if !ok {
+			return m, nil
+		}
+		if i == m.list.Index() {
+			return m.openSelected()
+		}
+		m.list.Select(i)
+	default:
+		return m, nil
+	}
+	return m, m.prefetchBodies()
+}
+
+// listRowAt returns the index of the message shown on screen line y of the
+// list view.
+func (m Model) listRowAt(y int) (int, bool) {
+	s := m.list.Styles
+	top := lipgloss.Height(s.TitleBar.Render(s.Title.Render(m.list.Title))) +
+		lipgloss.Height(s.StatusBar.Render(" "))
+	if tabs := m.tabBar(); tabs != "" {
+		top += lipgloss.Height(tabs)
+	}
+
+	d := m.emailDelegate()
+	off := y - top
+	if off < 0 || off%(d.Height()+d.Spacing()) >= d.Height() {
+		return 0, false
+	}
+	row := off / (d.Height() + d.Spacing())
+	i := m.list.Paginator.Page*m.list.Paginator.PerPage + row
+	if row >= m.list.Paginator.PerPage || i >= len(m.list.VisibleItems()) {
+		return 0, false
+	}
+	return i, true
+}
+
+// viewLine returns the text of the reading viewport on screen line y.
+func (m Model) viewLine(y int) (string, bool) {
+	lines := strings.Split(m.viewport.View(), "\n")
+	i := y - strings.Count(m.messageHeader(), "\n") - 1
+	if i < 0 || i >= len(lines) {
+		return "", false
+	}
+	return ansi.Strip(lines[i]), true
+}
+
+// linkAt returns the URL drawn at column x of screen line y of the reading
+// view, or "". A link wrapped over several lines is found from its first
+// line.
+func (m Model) linkAt(x, y int) string {
+	line, ok := m.viewLine(y)
+	if !ok {
+		return ""
+	}
+
+	for _, loc := range urlPattern.FindAllStringIndex(line, -1) {
+		start := ansi.StringWidth(line[:loc[0]])
+		if x < start || x >= start+ansi.StringWidth(line[loc[0]:loc[1]]) {
+			continue
+		}
+		u := strings.TrimRight(line[loc[0]:loc[1]], ".,;:!?>")
+		text := m.selectedMail.Body
+		if m.source != "" {
+			text = m.source
+		}
+		for _, l := range extractLinks(text) {
+			if strings.HasPrefix(l, u) {
+				return l
+			}
+		}
+		return u
+	}
+	return ""
+}
+
+// visitLink opens u in the browser.
+func (m Model) visitLink(u string) Model {
+	if err := browser.Open(u); err != nil {
+		m.status = fmt.Sprintf("Unable to open link: %v", err)
+		return m
+	}
+	m.status = "Opened " + u
+	return m
+}
@@ -0,0 +1,163 @@
+package app
+
+import (
+	"fmt"
+	"slices"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"google.golang.org/api/gmail/v1"
+)
+
+// Gmail's own mute can't be set through the API, so muted threads get this
+// label instead. They are archived, and archived again whenever a new
+// message brings them back to the inbox.
+const mutedLabel = "gmail-tui/muted"
+
+// mutedMsg carries the muted label's ID, empty if nothing was ever muted,
+// and how many muted threads were archived again.
+type mutedMsg struct {
+	label    string
+	archived int
+}
+
+// toggleMute mutes e's thread, or unmutes it if it is muted. Unmuting
+// leaves the thread where it is.
+func (m Model) toggleMute(e Email) tea.Cmd {
+	if e.muted {
+		return withMuted(m.modifyThread(e, nil, []string{m.mutedLabel}, false, "Conversation unmuted"), false)
+	}
+	mute := func() tea.Msg {
+		labels, err := fetchLabels(m.ctx, m.gmailSvc)
+		if err != nil {
+			return errMsg(err)
+		}
+		id, err := ensureLabel(m.ctx, m.gmailSvc, labels, mutedLabel)
+		if err != nil {
+			return errMsg(err)
+		}
+		mute := m.modifyThread(e, []string{id}, []string{"INBOX"}, true, "Conversation muted")
+		return withArchiveHook(m.config, e, withMuted(mute, true))()
+	}
+	// Looking for muted threads again picks up the label if it was just
+	// created.
+	return tea.Sequence(mute, m.archiveMuted)
+}
+
+// withMuted sets the muted flag of the message a label change reports.
+func withMuted(cmd tea.Cmd, muted bool) tea.Cmd {
+	return func() tea.Msg {
+		msg := cmd()
+		if changed, ok := msg.(labelsChangedMsg); ok {
+			changed.email.muted = muted
+			return changed
+		}
+		return msg
+	}
+}
+
+// modifyThread changes the labels of every message in e's thread. The
+// change is undone for the whole thread too.
+func (m Model) modifyThread(e Email, add, remove []string, removed bool, status string) tea.Cmd {
+	return func() tea.Msg {
+		_, err := m.gmailSvc.Users.Threads.Modify("me", e.ThreadID, &gmail.ModifyThreadRequest{
+			AddLabelIds:    add,
+			RemoveLabelIds: remove,
+		}).Context(m.ctx).Do()
+		if err != nil {
+			return errMsg(fmt.Errorf("unable to update conversation: %w", err))
+		}
+		var after []string
+		for _, l := range e.Labels {
+			if !slices.Contains(remove, l) {
+				after = append(after, l)
+			}
+		}
+		for _, l := range add {
+			if !slices.Contains(after, l) {
+				after = append(after, l)
+			}
+		}
+		undo := reverseOf(e, after, status)
+		undo.thread = true
+		e.Labels = after
+		return labelsChangedMsg{email: e, removed: removed, status: status, undo: undo}
+	}
+}
+
+// archiveMuted archives muted threads that new mail brought back to the
+// inbox, and looks up the muted label for the list's indicator. Like
+// wakeSnoozed it runs in the background and leaves failures for the next
+// run.
+func (m Model) archiveMuted() tea.Msg {
+	labels, err := fetchLabels(m.ctx, m.gmailSvc)
+	if err != nil {
+		return nil
+	}
+	l := labels.byName(mutedLabel)
+	if l == nil {
+		return mutedMsg{}
+	}
+
+	inbox, err := m.gmailSvc.Users.Messages.List("me").LabelIds("INBOX").MaxResults(100).Context(m.ctx).Do()
+	if err != nil || len(inbox.Messages) == 0 {
+		return mutedMsg{label: l.Id}
+	}
+	muted := map[string]bool{}
+	err = m.gmailSvc.Users.Threads.List("me").LabelIds(l.Id).Pages(m.ctx, func(r *gmail.ListThreadsResponse) error {
+		for _, t := range r.Threads {
+			muted[t.Id] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return mutedMsg{label: l.Id}
+	}
+
+	archived := 0
+	done := map[string]bool{}
+	for _, msg := range inbox.Messages {
+		if !muted[msg.ThreadId] || done[msg.ThreadId] {
+			continue
+		}
+		done[msg.ThreadId] = true
+		_, err := m.gmailSvc.Users.Threads.Modify("me", msg.ThreadId, &gmail.ModifyThreadRequest{
+			AddLabelIds:    []string{l.Id},
+			RemoveLabelIds: []string{"INBOX"},
+		}).Context(m.ctx).Do()
+		if err == nil {
+			archived++
+			runHook("archive", m.config.Hooks.OnArchive, hookEvent{ID: msg.Id, ThreadID: msg.ThreadId})
+		}
+	}
+	return mutedMsg{label: l.Id, archived: archived}
+}
+
+func (m Model) updateMuted(msg mutedMsg) (Model, tea.Cmd) {
+	if msg.label != m.mutedLabel {
+		m.mutedLabel = msg.label
+		m = m.markMuted()
+	}
+	if msg.archived == 0 {
+		return m, nil
+	}
+	if m.status == "" {
+		m.status = fmt.Sprintf("%d muted conversation(s) archived again", msg.archived)
+	}
+	if m.state != listView {
+		return m, nil
+	}
+	return m.refreshEmails()
+}
+
+// markMuted flags the listed messages in muted threads.
+func (m Model) markMuted() Model {
+	for i, item := range m.list.Items() {
+		if e, ok := item.(Email); ok {
+			if muted := m.mutedLabel != "" && e.hasLabel(m.mutedLabel); muted != e.muted {
+				e.muted = muted
+				m.list.SetItem(i, e)
+			}
+		}
+	}
+	return m
+}
@@ -0,0 +1,289 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"gmail-tui/internal/vault"
+)
+
+const (
+	// maxCachedLists caps how many lists are kept for offline use; the
+	// ones loaded longest ago are dropped first.
+	maxCachedLists = 20
+
+	reconnectFirstDelay = 5 * time.Second
+	reconnectMaxDelay   = 2 * time.Minute
+)
+
+var offlineStyle = lipgloss.NewStyle().
+	Foreground(lipgloss.Color("#1A1A1A")).
+	Background(lipgloss.Color("#FFB454")).
+	Padding(0, 1)
+
+// offlineState is set while mail can't be reached. The list shows what was
+// last loaded for the same query, and reconnecting is retried with a
+// growing delay.
+type offlineState struct {
+	err error
+	// cachedAt is when the list shown was loaded, zero if nothing was
+	// cached for it.
+	cachedAt time.Time
+	// since identifies this spell offline, so a reconnect scheduled
+	// during an earlier one is ignored.
+	since time.Time
+	delay time.Duration
+	next  time.Time
+}
+
+// offlineMsg reports that loading the list failed because mail could not
+// be reached. emails is the cached copy of the list, if there is one.
+type offlineMsg struct {
+	err      error
+	emails   []Email
+	cachedAt time.Time
+}
+
+type reconnectMsg struct {
+	since time.Time
+}
+
+// listCache keeps the headers of the last lists loaded, keyed by query, so
+// gmail-tui can start and be browsed without a connection. It is stored
+// next to the contact and sender caches.
+type listCache struct {
+	vault *vault.Vault
+	mu    sync.Mutex
+	lists map[string]cachedList
+}
+
+type cachedList struct {
+	Loaded time.Time     `json:"loaded"`
+	Emails []cachedEmail `json:"emails"`
+}
+
+type cachedEmail struct {
+	ID                  string    `json:"id"`
+	ThreadID            string    `json:"thread,omitempty"`
+	From                string    `json:"from"`
+	To                  string    `json:"to,omitempty"`
+	Cc                  string    `json:"cc,omitempty"`
+	Subject             string    `json:"subject"`
+	Date                time.Time `json:"date"`
+	Snippet             string    `json:"snippet,omitempty"`
+	Labels              []string  `json:"labels,omitempty"`
+	Size                int64     `json:"size,omitempty"`
+	ListUnsubscribe     string    `json:"list_unsubscribe,omitempty"`
+	ListUnsubscribePost string    `json:"list_unsubscribe_post,omitempty"`
+	Attached            bool      `json:"attached,omitempty"`
+}
+
+func listCachePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gmail-tui", "lists.json"), nil
+}
+
+// loadListCache reads the cached lists. A missing or unreadable cache just
+// yields an empty one.
+func loadListCache(v *vault.Vault) *listCache {
+	c := &listCache{vault: v, lists: map[string]cachedList{}}
+	path, err := listCachePath()
+	if err != nil {
+		return c
+	}
+	data, err := v.ReadFile(path)
+	if err != nil {
+		return c
+	}
+	json.Unmarshal(data, &c.lists)
+	if c.lists == nil {
+		c.lists = map[string]cachedList{}
+	}
+	return c
+}
+
+// put replaces the cached list for query and writes the cache out.
+func (c *listCache) put(query string, emails []Email) error {
+	if c == nil {
+		return nil
+	}
+	list := cachedList{Loaded: time.Now()}
+	for _, e := range emails {
+		list.Emails = append(list.Emails, cachedEmail{
+			ID:                  e.ID,
+			ThreadID:            e.ThreadID,
+			From:                e.From,
+			To:                  e.To,
+			Cc:                  e.Cc,
+			Subject:             e.Subject,
+			Date:                e.Date,
+			Snippet:             e.Snippet,
+			Labels:              e.Labels,
+			Size:                e.Size,
+			ListUnsubscribe:     e.ListUnsubscribe,
+			ListUnsubscribePost: e.ListUnsubscribePost,
+			Attached:            e.attached,
+		})
+	}
+
+	c.mu.Lock()
+	c.lists[query] = list
+	for len(c.lists) > maxCachedLists {
+		oldest := query
+		for q, l := range c.lists {
+			if l.Loaded.Before(c.lists[oldest].Loaded) {
+				oldest = q
+			}
+		}
+		delete(c.lists, oldest)
+	}
+	data, err := json.Marshal(c.lists)
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	path, err := listCachePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	if err := c.vault.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("unable to write list cache: %v", err)
+	}
+	return nil
+}
+
+// get returns the cached list for query and when it was loaded.
+func (c *listCache) get(query string) ([]Email, time.Time, bool) {
+	if c == nil {
+		return nil, time.Time{}, false
+	}
+	c.mu.Lock()
+	list, ok := c.lists[query]
+	c.mu.Unlock()
+	if !ok {
+		return nil, time.Time{}, false
+	}
+	emails := make([]Email, 0, len(list.Emails))
+	for _, e := range list.Emails {
+		emails = append(emails, Email{
+			ID:                  e.ID,
+			ThreadID:            e.ThreadID,
+			From:                e.From,
+			To:                  e.To,
+			Cc:                  e.Cc,
+			Subject:             e.Subject,
+			Date:                e.Date,
+			Snippet:             e.Snippet,
+			Labels:              e.Labels,
+			Size:                e.Size,
+			ListUnsubscribe:     e.ListUnsubscribe,
+			ListUnsubscribePost: e.ListUnsubscribePost,
+			attached:            e.Attached,
+		})
+	}
+	return emails, list.Loaded, true
+}
+
+// offlineList answers a list load that failed to reach mail with the
+// cached copy of the list.
+func (m Model) offlineList(query string, err error) offlineMsg {
+	emails, loaded, ok := m.lists.get(query)
+	sessionMetrics.cache("offline lists", ok)
+	for i := range emails {
+		emails[i].Extra = m.plugins.columnText(emails[i])
+	}
+	return offlineMsg{err: err, emails: emails, cachedAt: loaded}
+}
+
+func reconnectTick(d time.Duration, since time.Time) tea.Cmd {
+	return tea.Tick(d, func(time.Time) tea.Msg {
+		return reconnectMsg{since: since}
+	})
+}
+
+// updateOffline shows the cached list, and on going offline starts trying
+// to reconnect. Loads that fail while already offline, such as
+// auto-refresh, just show the cached list again.
+func (m Model) updateOffline(msg offlineMsg) (Model, tea.Cmd) {
+	m.loading = false
+	m = m.setEmails(msg.emails)
+	var resume tea.Cmd
+	if m.resume != nil {
+		m, resume = m.resumeSession()
+	}
+	if m.offline != nil {
+		off := *m.offline
+		off.err, off.cachedAt = msg.err, msg.cachedAt
+		m.offline = &off
+		return m, resume
+	}
+	debugLog.Warn("offline", "err", msg.err)
+	now := time.Now()
+	m.offline = &offlineState{
+		err:      msg.err,
+		cachedAt: msg.cachedAt,
+		since:    now,
+		delay:    reconnectFirstDelay,
+		next:     now.Add(reconnectFirstDelay),
+	}
+	return m, tea.Batch(resume, reconnectTick(reconnectFirstDelay, now))
+}
+
+// reconnect tries loading the list again, and schedules the next try with
+// twice the delay in case this one fails.
+func (m Model) reconnect(msg reconnectMsg) (Model, tea.Cmd) {
+	if m.offline == nil || !m.offline.since.Equal(msg.since) {
+		return m, nil
+	}
+	off := *m.offline
+	off.delay = min(2*off.delay, reconnectMaxDelay)
+	off.next = time.Now().Add(off.delay)
+	m.offline = &off
+
+	if m.cancelFetch != nil {
+		m.cancelFetch()
+	}
+	ctx, cancel := context.WithCancel(m.ctx)
+	m.cancelFetch = cancel
+	return m, tea.Batch(m.fetchEmails(ctx), reconnectTick(off.delay, off.since))
+}
+
+// backOnline leaves the offline state once a list loads again, catching up
+// on what couldn't be fetched while offline.
+func (m Model) backOnline() (Model, tea.Cmd) {
+	m.offline = nil
+	m.status = "Back online"
+	debugLog.Info("online")
+	cmds := []tea.Cmd{m.dispatchOutbox}
+	if m.config.GmailAPI() {
+		cmds = append(cmds, m.fetchAliases)
+	}
+	return m, tea.Batch(cmds...)
+}
+
+// offlineBanner leads the status line while offline.
+func (m Model) offlineBanner() string {
+	if m.offline == nil {
+		return ""
+	}
+	text := "Offline: nothing cached for this list"
+	if !m.offline.cachedAt.IsZero() {
+		text = "Offline: showing mail from " + m.offline.cachedAt.Format("Jan 2 15:04")
+	}
+	return offlineStyle.Render(text + ", reconnecting " + relativeUntil(m.offline.next))
+}
@@ -0,0 +1,505 @@
+package app
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"google.golang.org/api/googleapi"
+
+	"gmail-tui/internal/config"
+	"gmail-tui/internal/safefile"
+	"gmail-tui/internal/ui/compose"
+	"gmail-tui/internal/ui/view"
+	"gmail-tui/internal/vault"
+)
+
+const (
+	outboxInterval = 30 * time.Second
+
+	// outboxMaxBackoff caps the wait between attempts to send a message
+	// that failed.
+	outboxMaxBackoff = 10 * time.Minute
+)
+
+// outboxEntry is a message queued to be sent later, either scheduled or
+// after a failed send. The message usually stays a Gmail draft until it is
+// sent, so only the draft ID and a summary for the Scheduled view are
+// stored locally. One that could not be uploaded, because it was written
+// offline or failed as it was signed or encrypted for sending, is kept
+// whole in Raw until it is.
+type outboxEntry struct {
+	DraftID string    `json:"draft_id"`
+	To      string    `json:"to"`
+	Subject string    `json:"subject"`
+	SendAt  time.Time `json:"send_at"`
+
+	// Raw is the message as the Gmail API's Raw field takes it, and Local
+	// identifies the entry while it may not have a draft ID.
+	Raw   string `json:"raw,omitempty"`
+	Local string `json:"local,omitempty"`
+
+	Attempts    int       `json:"attempts,omitempty"`
+	LastError   string    `json:"last_error,omitempty"`
+	NextAttempt time.Time `json:"next_attempt,omitempty"`
+}
+
+func (e outboxEntry) Title() string {
+	if e.Subject == "" {
+		return "(no subject)"
+	}
+	return e.Subject
+}
+func (e outboxEntry) Description() string {
+	if e.Attempts > 0 {
+		return fmt.Sprintf("To: %s | failed %d time(s), retrying %s: %s", e.To, e.Attempts, relativeUntil(e.NextAttempt), e.LastError)
+	}
+	return fmt.Sprintf("To: %s | %s", e.To, view.FullDate(e.SendAt))
+}
+func (e outboxEntry) FilterValue() string { return e.Subject }
+
+// key identifies e in the outbox.
+func (e outboxEntry) key() string {
+	if e.Local != "" {
+		return e.Local
+	}
+	return e.DraftID
+}
+
+// outbox is the persistent queue of scheduled messages, stored in the
+// gmail-tui config directory so it survives restarts. Other instances
+// share the file: changes are made to it under a lock, and only one
+// instance at a time sends what is due.
+type outbox struct {
+	mu          sync.Mutex
+	entries     []outboxEntry
+	dispatching bool
+
+	// vault encrypts the file, which names recipients and subjects.
+	vault *vault.Vault
+}
+
+// outboxSentMsg reports the result of a dispatch run.
+type outboxSentMsg struct {
+	sent int
+}
+
+type outboxTickMsg struct{}
+
+func outboxPath() (string, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "outbox.json"), nil
+}
+
+// loadOutbox reads the queued messages, decrypting them with v. A missing
+// file is an empty outbox.
+func loadOutbox(v *vault.Vault) (*outbox, error) {
+	o := &outbox{vault: v}
+
+	path, err := outboxPath()
+	if err != nil {
+		return o, nil
+	}
+	o.entries, err = readOutbox(path, v)
+	return o, err
+}
+
+func readOutbox(path string, v *vault.Vault) ([]outboxEntry, error) {
+	data, err := v.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read outbox: %v", err)
+	}
+	var entries []outboxEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("unable to parse outbox: %v", err)
+	}
+	return entries, nil
+}
+
+// reload picks up the changes other instances have made to the outbox. If
+// it can't be read, the entries in hand are kept.
+func (o *outbox) reload() {
+	path, err := outboxPath()
+	if err != nil {
+		return
+	}
+	entries, err := readOutbox(path, o.vault)
+	if err != nil {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.entries = entries
+}
+
+// update applies change to the outbox as saved, so that changes other
+// instances made since it was read are kept, and saves the result. The
+// file is locked throughout.
+func (o *outbox) update(change func([]outboxEntry) []outboxEntry) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	path, err := outboxPath()
+	if err != nil {
+		return err
+	}
+	unlock, err := safefile.Lock(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	entries, err := readOutbox(path, o.vault)
+	if err != nil {
+		return err
+	}
+	entries = change(entries)
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	if err := o.vault.WriteFile(path, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("unable to write outbox: %v", err)
+	}
+	o.entries = entries
+	return nil
+}
+
+// add queues e, replacing any entry for the same draft.
+func (o *outbox) add(e outboxEntry) error {
+	return o.update(func(entries []outboxEntry) []outboxEntry {
+		entries = slices.DeleteFunc(entries, func(x outboxEntry) bool { return x.key() == e.key() })
+		entries = append(entries, e)
+		slices.SortFunc(entries, func(a, b outboxEntry) int { return a.SendAt.Compare(b.SendAt) })
+		return entries
+	})
+}
+
+func (o *outbox) remove(key string) error {
+	return o.update(func(entries []outboxEntry) []outboxEntry {
+		return slices.DeleteFunc(entries, func(x outboxEntry) bool { return x.key() == key })
+	})
+}
+
+// uploaded records that the message kept whole in an entry is now the
+// Gmail draft draftID, so a failed send doesn't upload it again.
+func (o *outbox) uploaded(key, draftID string) error {
+	return o.update(func(entries []outboxEntry) []outboxEntry {
+		for i := range entries {
+			if entries[i].key() == key {
+				entries[i].DraftID = draftID
+				entries[i].Raw = ""
+			}
+		}
+		return entries
+	})
+}
+
+func (o *outbox) list() []outboxEntry {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return slices.Clone(o.entries)
+}
+
+// due returns the entries whose send time, and retry time after a failure,
+// has passed.
+func (o *outbox) due(now time.Time) []outboxEntry {
+	var due []outboxEntry
+	for _, e := range o.list() {
+		if !e.SendAt.After(now) && !e.NextAttempt.After(now) {
+			due = append(due, e)
+		}
+	}
+	return due
+}
+
+// failed records a failed attempt to send, doubling the wait before the
+// next one.
+func (o *outbox) failed(key string, err error, now time.Time) error {
+	return o.update(func(entries []outboxEntry) []outboxEntry {
+		for i := range entries {
+			e := &entries[i]
+			if e.key() != key {
+				continue
+			}
+			e.Attempts++
+			e.LastError = err.Error()
+			backoff := outboxInterval << min(e.Attempts-1, 10)
+			e.NextAttempt = now.Add(min(backoff, outboxMaxBackoff))
+		}
+		return entries
+	})
+}
+
+// unsent returns the entries that have failed at least once.
+func (o *outbox) unsent() []outboxEntry {
+	var unsent []outboxEntry
+	for _, e := range o.list() {
+		if e.Attempts > 0 {
+			unsent = append(unsent, e)
+		}
+	}
+	return unsent
+}
+
+// queueFailed puts a message that could not be sent into the outbox so it
+// is retried instead of lost.
+func (m Model) queueFailed(d compose.Draft, err error) error {
+	now := time.Now()
+	if err := m.outbox.add(outboxEntry{DraftID: d.ID, To: d.To, Subject: d.Subject, SendAt: now}); err != nil {
+		return err
+	}
+	return m.outbox.failed(d.ID, err, now)
+}
+
+// queueRaw puts a message that could not be uploaded into the outbox whole,
+// as raw, to be uploaded and sent once Gmail can be reached. The draft it
+// replaces, if it was saved before, is d.ID.
+func (m Model) queueRaw(d compose.Draft, raw string, err error) error {
+	b := make([]byte, 8)
+	if _, rerr := rand.Read(b); rerr != nil {
+		return fmt.Errorf("unable to queue message: %v", rerr)
+	}
+	e := outboxEntry{DraftID: d.ID, To: d.To, Subject: d.Subject, SendAt: time.Now(), Raw: raw, Local: "local-" + hex.EncodeToString(b)}
+	if err := m.outbox.add(e); err != nil {
+		return err
+	}
+	return m.outbox.failed(e.key(), err, time.Now())
+}
+
+// dispatchOutbox sends every message whose time has come, uploading those
+// kept whole first. A draft that no longer exists was deleted or sent
+// elsewhere, so its entry is dropped.
+// Failures are retried with backoff and shown in the status bar.
+func (m Model) dispatchOutbox() tea.Msg {
+	var msg outboxSentMsg
+	if !m.outbox.startDispatch() {
+		return msg
+	}
+	defer m.outbox.endDispatch()
+
+	path, err := outboxPath()
+	if err != nil {
+		return msg
+	}
+	// Another instance sending what is due would send it twice; it holds
+	// this lock while it does, and whatever it leaves is sent next time.
+	unlock, ok, err := safefile.TryLock(path + ".dispatch")
+	if err != nil || !ok {
+		return msg
+	}
+	defer unlock()
+	m.outbox.reload()
+
+	for _, e := range m.outbox.due(time.Now()) {
+		if e.Raw != "" {
+			id, err := m.mail.SaveDraft(m.ctx, e.DraftID, e.Raw)
+			if isNotFound(err) {
+				m.outbox.remove(e.key())
+				continue
+			}
+			if err != nil {
+				m.outbox.failed(e.key(), err, time.Now())
+				continue
+			}
+			m.outbox.uploaded(e.key(), id)
+			e.DraftID = id
+		}
+		sent, err := m.mail.SendDraft(m.ctx, e.DraftID)
+		if isNotFound(err) {
+			m.outbox.remove(e.key())
+			continue
+		}
+		if err != nil {
+			m.outbox.failed(e.key(), err, time.Now())
+			continue
+		}
+		m.outbox.remove(e.key())
+		runHook("send", m.config.Hooks.OnSend, sentEvent(sent, compose.Draft{To: e.To, Subject: e.Subject}))
+		msg.sent++
+	}
+	return msg
+}
+
+// startDispatch reports whether the caller may send; it is false while an
+// earlier, slow dispatch is still running so nothing is sent twice.
+func (o *outbox) startDispatch() bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.dispatching {
+		return false
+	}
+	o.dispatching = true
+	return true
+}
+
+func (o *outbox) endDispatch() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.dispatching = false
+}
+
+func outboxTick() tea.Cmd {
+	return tea.Tick(outboxInterval, func(time.Time) tea.Msg {
+		return outboxTickMsg{}
+	})
+}
+
+func newScheduleInput() textinput.Model {
+	ti := textinput.New()
+	ti.Prompt = "Send at: "
+	ti.Placeholder = futureTimeHint
+	return ti
+}
+
+func newScheduledList(delegate list.ItemDelegate) list.Model {
+	l := list.New([]list.Item{}, delegate, 40, 20)
+	l.SetShowTitle(true)
+	l.SetFilteringEnabled(true)
+	l.SetShowHelp(true)
+	l.DisableQuitKeybindings()
+	l.Title = "Outbox"
+	l.Styles.Title = view.TitleStyle
+	return l
+}
+
+func (m Model) updateSchedule(msg tea.KeyMsg) (Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, m.keys.Back):
+		m.scheduling = false
+		return m, nil
+	case key.Matches(msg, m.keys.Select):
+		at, err := parseFutureTime(m.scheduleInput.Value(), time.Now())
+		if err != nil {
+			m.status = err.Error()
+			return m, nil
+		}
+		m.scheduling = false
+
+		d := m.compose.Snapshot()
+		err = m.outbox.add(outboxEntry{DraftID: d.ID, To: d.To, Subject: d.Subject, SendAt: at})
+		if err != nil {
+			m = m.fail(err)
+			return m, nil
+		}
+		m = m.closeCompose()
+		m.status = "Scheduled for " + at.Format("Mon Jan 2 15:04")
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.scheduleInput, cmd = m.scheduleInput.Update(msg)
+	return m, cmd
+}
+
+func (m Model) openScheduled() Model {
+	m.state = scheduledView
+	return m.refreshScheduled()
+}
+
+func (m Model) refreshScheduled() Model {
+	m.outbox.reload()
+	var items []list.Item
+	for _, e := range m.outbox.list() {
+		items = append(items, e)
+	}
+	m.scheduled.SetItems(items)
+	return m
+}
+
+func (m Model) updateScheduled(msg tea.KeyMsg) (Model, tea.Cmd) {
+	if m.scheduled.FilterState() != list.Filtering {
+		switch {
+		case key.Matches(msg, m.keys.Back) && m.scheduled.FilterState() == list.Unfiltered:
+			m.state = listView
+			return m, nil
+		case key.Matches(msg, m.keys.Quit):
+			return m.quit()
+		case key.Matches(msg, m.keys.Fetch):
+			return m.refreshScheduled(), nil
+		case key.Matches(msg, m.keys.Discard):
+			if e, ok := m.scheduled.SelectedItem().(outboxEntry); ok {
+				if err := m.outbox.remove(e.key()); err != nil {
+					m = m.fail(err)
+					return m, nil
+				}
+				m.status = "Removed from the outbox; the message is kept in Drafts"
+				if e.Raw != "" {
+					m.status = "Removed from the outbox; the message was never uploaded, so it is discarded"
+				}
+				return m.refreshScheduled(), nil
+			}
+			return m, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	m.scheduled, cmd = m.scheduled.Update(msg)
+	return m, cmd
+}
+
+func (m Model) scheduledView() string {
+	return fmt.Sprintf(
+		"%s\n\n%s",
+		m.scheduled.View(),
+		view.HelpStyle.Render(m.statusLine()+"x: cancel • r: refresh • esc: back"),
+	)
+}
+
+// outboxStatus summarizes messages waiting to be retried.
+func (m Model) outboxStatus() string {
+	unsent := m.outbox.unsent()
+	if len(unsent) == 0 {
+		return ""
+	}
+	next := unsent[0].NextAttempt
+	for _, e := range unsent[1:] {
+		if e.NextAttempt.Before(next) {
+			next = e.NextAttempt
+		}
+	}
+	return fmt.Sprintf("Outbox: %d unsent, retrying %s • L: view", len(unsent), relativeUntil(next))
+}
+
+// relativeUntil describes how long until t, for retry countdowns.
+func relativeUntil(t time.Time) string {
+	d := time.Until(t).Round(time.Second)
+	if d <= 0 {
+		return "now"
+	}
+	return "in " + d.String()
+}
+
+// isTransient reports whether err is a network failure or a server-side
+// error worth retrying, as opposed to a rejected request.
+func isTransient(err error) bool {
+	var nerr net.Error
+	if errors.As(err, &nerr) {
+		return true
+	}
+	var gerr *googleapi.Error
+	return errors.As(err, &gerr) && (gerr.Code >= 500 || gerr.Code == http.StatusTooManyRequests)
+}
+
+func isNotFound(err error) bool {
+	var gerr *googleapi.Error
+	return errors.As(err, &gerr) && gerr.Code == http.StatusNotFound
+}
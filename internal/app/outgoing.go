@@ -0,0 +1,46 @@
+package app
+
+import (
+	"strings"
+
+	"gmail-tui/internal/mimepart"
+)
+
+// outgoing reports whether e is mail you sent, which the list shows by
+// who it went to rather than by its sender, you.
+func (e Email) outgoing() bool {
+	return e.hasLabel("SENT")
+}
+
+// correspondent is who a list row is about, after what they are to the
+// message: the recipients of mail you sent, and otherwise the sender.
+func (e Email) correspondent() (role, who string) {
+	if e.outgoing() && (e.To != "" || e.Cc != "") {
+		return "To", recipientNames(e.To, e.Cc)
+	}
+	return "From", e.From
+}
+
+// recipientNames lists the recipients in To and Cc by name, or by address
+// for those without one.
+func recipientNames(headers ...string) string {
+	var names []string
+	for _, h := range headers {
+		if strings.TrimSpace(h) == "" {
+			continue
+		}
+		addrs, err := mimepart.AddressParser.ParseList(h)
+		if err != nil {
+			names = append(names, strings.TrimSpace(h))
+			continue
+		}
+		for _, a := range addrs {
+			if a.Name != "" {
+				names = append(names, a.Name)
+			} else {
+				names = append(names, a.Address)
+			}
+		}
+	}
+	return strings.Join(names, ", ")
+}
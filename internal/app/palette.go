@@ -0,0 +1,359 @@
+package app
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"gmail-tui/internal/ui/compose"
+	"gmail-tui/internal/ui/palette"
+)
+
+// gotoPlaces maps goto targets that are Gmail searches to their query and
+// list title. Anything else is treated as a label name.
+var gotoPlaces = map[string]struct{ query, title string }{
+	"inbox":   {"in:inbox", "Inbox"},
+	"sent":    {"in:sent", "Sent"},
+	"starred": {"is:starred", "Starred"},
+	"spam":    {"in:spam", "Spam"},
+	"trash":   {"in:trash", "Trash"},
+	"all":     {"", "Gmail Inbox"},
+
+	"priority":   {"in:inbox", "Priority Inbox"},
+	"follow-ups": {`label:"` + followUpDueLabel + `"`, "Follow-ups"},
+}
+
+func paletteCommands() []palette.Command[Model] {
+	return []palette.Command[Model]{
+		{Name: "archive", Help: "remove the message from the inbox", Run: func(m Model, _ string) (Model, tea.Cmd) {
+			return m.withCurrent(m.archive)
+		}},
+		{Name: "label", Args: "NAME", Help: "add a label, creating it if needed", Run: func(m Model, arg string) (Model, tea.Cmd) {
+			return m.withCurrent(func(e Email) tea.Cmd { return m.labelEmail(e, arg, false) })
+		}},
+		{Name: "unlabel", Args: "NAME", Help: "remove a label", Run: func(m Model, arg string) (Model, tea.Cmd) {
+			return m.withCurrent(func(e Email) tea.Cmd { return m.labelEmail(e, arg, true) })
+		}},
+		{Name: "search", Args: "QUERY", Help: "list messages matching a Gmail query", Run: func(m Model, arg string) (Model, tea.Cmd) {
+			title := "Search: " + arg
+			if arg == "" {
+				title = gotoPlaces["all"].title
+			}
+			m.localSearch = m.index != nil && arg != ""
+			if arg != "" {
+				m.searches = m.searches.add(arg)
+				m.searches.save()
+			}
+			return m.showQuery(arg, title)
+		}},
+		{Name: "pin", Args: "[QUERY]", Help: "pin a Gmail query, or the list's, to be offered first at :search", Run: func(m Model, arg string) (Model, tea.Cmd) {
+			return m.pinSearch(arg, false), nil
+		}},
+		{Name: "unpin", Args: "[QUERY]", Help: "unpin a Gmail query, or the list's", Run: func(m Model, arg string) (Model, tea.Cmd) {
+			return m.pinSearch(arg, true), nil
+		}},
+		{Name: "larger", Args: "[SIZE]", Help: "list messages larger than SIZE, e.g. 5M; 10M if not given", Run: func(m Model, arg string) (Model, tea.Cmd) {
+			if arg == "" {
+				arg = "10M"
+			}
+			return m.showQuery("larger:"+arg, "Larger than "+arg)
+		}},
+		{Name: "goto", Args: "PLACE", Help: "inbox, priority, sent, starred, spam, trash, all, follow-ups, a category, drafts, filters, vacation, signatures, outbox, contacts or a label", Run: func(m Model, arg string) (Model, tea.Cmd) {
+			return m.gotoPlace(arg)
+		}},
+		{Name: "compose", Args: "[ADDRESS]", Help: "write a new message", Run: func(m Model, arg string) (Model, tea.Cmd) {
+			return m.openCompose(compose.Draft{To: arg})
+		}},
+		{Name: "trash", Help: "move the message to Trash, or restore it from Trash", Run: func(m Model, _ string) (Model, tea.Cmd) {
+			return m.withCurrent(m.trashEmail)
+		}},
+		{Name: "empty", Args: "trash|spam", Help: "permanently delete everything in Trash or Spam", Run: func(m Model, arg string) (Model, tea.Cmd) {
+			if arg == "" {
+				arg = m.place
+			}
+			return m.startPurge(strings.ToLower(arg))
+		}},
+		{Name: "important", Help: "mark the message as important, or as not important", Run: func(m Model, _ string) (Model, tea.Cmd) {
+			return m.withCurrent(m.toggleImportant)
+		}},
+		{Name: "read", Help: "mark the message as read, or as unread", Run: func(m Model, _ string) (Model, tea.Cmd) {
+			return m.withCurrent(m.toggleRead)
+		}},
+		{Name: "undo", Help: "undo the last archive, trash, label or read change", Run: func(m Model, _ string) (Model, tea.Cmd) {
+			return m.undoLast()
+		}},
+		{Name: "mute", Help: "mute the conversation, or unmute it", Run: func(m Model, _ string) (Model, tea.Cmd) {
+			return m.withCurrent(m.toggleMute)
+		}},
+		{Name: "spam", Help: "report the message as spam, or undo it", Run: func(m Model, _ string) (Model, tea.Cmd) {
+			return m.withCurrent(m.toggleSpam)
+		}},
+		{Name: "snooze", Args: "TIME", Help: "hide the message until " + futureTimeHint, Run: func(m Model, arg string) (Model, tea.Cmd) {
+			e, ok := m.currentEmail()
+			if !ok {
+				return m, nil
+			}
+			if arg == "" {
+				return m.startSnooze(e)
+			}
+			until, err := parseFutureTime(arg, time.Now())
+			if err != nil {
+				m.status = err.Error()
+				return m, nil
+			}
+			return m, m.snoozeEmail(e, until)
+		}},
+		{Name: "followup", Args: "TIME", Help: "remind you if the message gets no reply by " + futureTimeHint, Run: func(m Model, arg string) (Model, tea.Cmd) {
+			e, ok := m.currentEmail()
+			if !ok {
+				return m, nil
+			}
+			if arg == "" {
+				return m.startFollowUp(&e)
+			}
+			by, err := parseFutureTime(arg, time.Now())
+			if err != nil {
+				m.status = err.Error()
+				return m, nil
+			}
+			return m, m.setFollowUp(e, by)
+		}},
+		{Name: "unsubscribe", Help: "unsubscribe from the mailing list", Run: func(m Model, _ string) (Model, tea.Cmd) {
+			if e, ok := m.currentEmail(); ok {
+				m = m.startUnsubscribe(e)
+			}
+			return m, nil
+		}},
+		{Name: "export", Args: "FORMAT [PATH]", Help: "save the message, or all of the list's search, as eml, mbox, maildir, txt or pdf", Run: func(m Model, arg string) (Model, tea.Cmd) {
+			format, path, _ := strings.Cut(arg, " ")
+			return m.startExport(strings.ToLower(format), strings.TrimSpace(path))
+		}},
+		{Name: "print", Args: "[PATH]", Help: "export as pdf, or as txt without a PDF converter", Run: func(m Model, arg string) (Model, tea.Cmd) {
+			format := exportPDF
+			if _, err := pdfConverter(); err != nil {
+				format = exportText
+			}
+			return m.startExport(format, arg)
+		}},
+		{Name: "sync", Help: "sync the Maildir set in config.json now", Run: func(m Model, _ string) (Model, tea.Cmd) {
+			return m.syncInBackground(true)
+		}},
+		{Name: "density", Args: "[compact|comfortable]", Help: "show one or two lines per message", Run: func(m Model, arg string) (Model, tea.Cmd) {
+			return m.setDensity(strings.ToLower(arg))
+		}},
+		{Name: "refresh", Help: "fetch the list again", Run: func(m Model, _ string) (Model, tea.Cmd) {
+			m.loading = m.state == listView
+			return m.refreshEmails()
+		}},
+		{Name: "hud", Help: "show or hide request counts, latencies and cache hit rates", Run: func(m Model, _ string) (Model, tea.Cmd) {
+			return m.toggleHUD()
+		}},
+		{Name: "quit", Help: "exit gmail-tui", Run: func(m Model, _ string) (Model, tea.Cmd) {
+			return m.quit()
+		}},
+	}
+}
+
+func (m Model) openPalette() (Model, tea.Cmd) {
+	m.palette = palette.New(len(m.history), len(m.searches.Recent))
+	return m, m.palette.Input.Focus()
+}
+
+func (m Model) updatePalette(msg tea.KeyMsg) (Model, tea.Cmd) {
+	p := m.palette
+	switch msg.Type {
+	case tea.KeyEsc:
+		m.palette = nil
+		return m, nil
+	case tea.KeyEnter:
+		m.palette = nil
+		return m.runCommandLine(p.Input.Value())
+	case tea.KeyTab:
+		name, arg, hasArgs := strings.Cut(p.Input.Value(), " ")
+		if matches := palette.Match(paletteCommands(), name); !hasArgs && len(matches) > 0 {
+			p.Input.SetValue(matches[0].Name + " ")
+			p.Input.CursorEnd()
+		}
+		if labels := m.matchLabels(strings.TrimSpace(arg)); hasArgs && name == "goto" && len(labels) > 0 {
+			p.Input.SetValue("goto " + labels[0])
+			p.Input.CursorEnd()
+		}
+		if queries := m.searches.suggest(strings.TrimSpace(arg)); hasArgs && name == "search" && len(queries) > 0 {
+			p.Input.SetValue(searchQueryPrefix + queries[0])
+			p.Input.CursorEnd()
+		}
+		return m, nil
+	case tea.KeyUp, tea.KeyDown:
+		if isSearchLine(p.Input.Value()) && p.Pos == len(m.history) {
+			return m.browseSearches(msg.Type == tea.KeyUp), nil
+		}
+	}
+
+	if msg.Type == tea.KeyUp || msg.Type == tea.KeyDown {
+		p.Browse(m.history, msg.Type == tea.KeyUp)
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	p.Input, cmd = p.Input.Update(msg)
+	return m, cmd
+}
+
+// runCommandLine runs a command typed at the prompt and records it in the
+// history.
+func (m Model) runCommandLine(line string) (Model, tea.Cmd) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return m, nil
+	}
+	m.history = palette.AppendHistory(m.history, line)
+	palette.SaveHistory(m.config.vault, m.history)
+
+	name, arg, _ := strings.Cut(line, " ")
+	c, ok := palette.Resolve(paletteCommands(), name)
+	if !ok {
+		m.status = fmt.Sprintf("Unknown command %q", name)
+		return m, nil
+	}
+	return c.Run(m, strings.TrimSpace(arg))
+}
+
+func (m Model) paletteView() string {
+	p := m.palette
+	lines := []string{p.Input.View()}
+
+	name, arg, hasArgs := strings.Cut(p.Input.Value(), " ")
+	if !hasArgs {
+		matches := palette.Match(paletteCommands(), name)
+		for _, c := range matches[:min(len(matches), palette.MaxMatches)] {
+			lines = append(lines, c.Line())
+		}
+	}
+	// Label names are suggested for goto once the labels are loaded, as
+	// they are by g l.
+	if hasArgs && name == "goto" {
+		labels := m.matchLabels(strings.TrimSpace(arg))
+		for _, l := range labels[:min(len(labels), palette.MaxMatches)] {
+			lines = append(lines, "  "+l)
+		}
+	}
+	if hasArgs && name == "search" {
+		queries := m.searches.suggest(strings.TrimSpace(arg))
+		for _, q := range queries[:min(len(queries), palette.MaxMatches)] {
+			mark := " "
+			if slices.Contains(m.searches.Pinned, q) {
+				mark = "★"
+			}
+			lines = append(lines, mark+" "+q)
+		}
+	}
+	lines = append(lines, palette.Keys)
+	return strings.Join(lines, "\n")
+}
+
+// currentEmail is the open message, or the one under the list cursor.
+func (m Model) currentEmail() (Email, bool) {
+	if m.state == messageView && m.selectedMail != nil {
+		return *m.selectedMail, true
+	}
+	if m.state == listView {
+		e, ok := m.list.SelectedItem().(Email)
+		return e, ok
+	}
+	return Email{}, false
+}
+
+func (m Model) withCurrent(fn func(Email) tea.Cmd) (Model, tea.Cmd) {
+	e, ok := m.currentEmail()
+	if !ok {
+		m.status = "No message selected"
+		return m, nil
+	}
+	return m, fn(e)
+}
+
+// labelEmail adds the label called name to e, creating it if needed, or
+// removes it.
+func (m Model) labelEmail(e Email, name string, remove bool) tea.Cmd {
+	if name == "" {
+		return func() tea.Msg { return errMsg(fmt.Errorf("a label name is required")) }
+	}
+	return func() tea.Msg {
+		labels, err := fetchLabels(m.ctx, m.gmailSvc)
+		if err != nil {
+			return errMsg(err)
+		}
+		if remove {
+			l := labels.byName(name)
+			if l == nil {
+				return errMsg(fmt.Errorf("no label named %q", name))
+			}
+			return m.modifyLabels(e, nil, []string{l.Id}, false, "Removed label "+l.Name)()
+		}
+		id, err := ensureLabel(m.ctx, m.gmailSvc, labels, name)
+		if err != nil {
+			return errMsg(err)
+		}
+		return m.modifyLabels(e, []string{id}, nil, false, "Labelled "+name)()
+	}
+}
+
+// showQuery lists the messages matching query under the given title.
+func (m Model) showQuery(query, title string) (Model, tea.Cmd) {
+	m.query = query
+	m.place = ""
+	m.quick = nil
+	m.title = title
+	m.list.Title = title
+	m.list.ResetFilter()
+	// A different list starts at the top rather than on a message it
+	// shares with this one.
+	m.list.SetItems(nil)
+	m.list.Select(0)
+	m.selectedMail = nil
+	m.resume = nil
+	m.state = listView
+	m.loading = true
+	return m.refreshEmails()
+}
+
+func (m Model) gotoPlace(place string) (Model, tea.Cmd) {
+	m.localSearch = false
+	if p, ok := gotoPlaces[strings.ToLower(place)]; ok {
+		m, cmd := m.showQuery(p.query, p.title)
+		m.place = strings.ToLower(place)
+		return m, cmd
+	}
+	if t, ok := findTab(place); ok {
+		return m.showTab(t)
+	}
+
+	m.selectedMail = nil
+	switch strings.ToLower(place) {
+	case "drafts":
+		m.state = draftsView
+		m.loading = true
+		return m, m.fetchDrafts
+	case "filters":
+		return m.openFilters()
+	case "vacation":
+		return m.openVacation()
+	case "signatures":
+		m.state = signaturesView
+		m.loading = true
+		return m, m.fetchAliases
+	case "outbox":
+		return m.openScheduled(), nil
+	case "contacts":
+		return m.openContacts()
+	case "":
+		m.status = "Where to? Try :goto inbox"
+		return m, nil
+	}
+	m, cmd := m.showQuery(fmt.Sprintf("label:%q", place), place)
+	m.place = strings.ToLower(place)
+	return m, cmd
+}
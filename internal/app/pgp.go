@@ -0,0 +1,500 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/url"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"google.golang.org/api/gmail/v1"
+
+	"gmail-tui/internal/mimepart"
+	"gmail-tui/internal/ui/compose"
+)
+
+// armorBlock matches an inline PGP encrypted or clearsigned block.
+var armorBlock = regexp.MustCompile(`(?s)-----BEGIN PGP (?:MESSAGE|SIGNED MESSAGE)-----.*?-----END PGP (?:MESSAGE|SIGNATURE)-----`)
+
+// pgpResult collects what gpg reported while opening a message.
+type pgpResult struct {
+	encrypted bool
+	// signature is the gpg status keyword for the signature, such as
+	// GOODSIG or BADSIG, or "" if the message is not signed.
+	signature string
+	signer    string
+	trusted   bool
+	failure   string
+	// unprotected is set when an inline PGP message has text outside its
+	// signed or encrypted blocks.
+	unprotected bool
+}
+
+// read records gpg --status-fd lines.
+func (r *pgpResult) read(status []string) {
+	for _, s := range status {
+		f := strings.Fields(s)
+		if len(f) == 0 {
+			continue
+		}
+		switch f[0] {
+		case "DECRYPTION_OKAY":
+			r.encrypted = true
+		case "GOODSIG", "BADSIG", "EXPSIG", "EXPKEYSIG", "REVKEYSIG":
+			r.signature = f[0]
+			if len(f) > 2 {
+				uid := strings.Join(f[2:], " ")
+				if u, err := url.PathUnescape(uid); err == nil {
+					uid = u
+				}
+				r.signer = uid
+			}
+		case "ERRSIG":
+			r.signature = f[0]
+			if len(f) > 1 {
+				r.signer = f[1]
+			}
+		case "TRUST_FULLY", "TRUST_ULTIMATE":
+			r.trusted = true
+		}
+	}
+}
+
+// String summarises the result for the message header, or returns "" for
+// a message gpg had nothing to say about.
+func (r pgpResult) String() string {
+	var parts []string
+	if r.encrypted {
+		parts = append(parts, "encrypted")
+	}
+	switch r.signature {
+	case "GOODSIG":
+		s := "good signature from " + r.signer
+		if !r.trusted {
+			s += " (unverified key)"
+		}
+		parts = append(parts, s)
+	case "BADSIG":
+		parts = append(parts, "BAD signature from "+r.signer)
+	case "EXPSIG":
+		parts = append(parts, "expired signature from "+r.signer)
+	case "EXPKEYSIG":
+		parts = append(parts, "signature from "+r.signer+" with an expired key")
+	case "REVKEYSIG":
+		parts = append(parts, "signature from "+r.signer+" with a revoked key")
+	case "ERRSIG":
+		parts = append(parts, "signed with unknown key "+r.signer)
+	}
+	if r.failure != "" {
+		parts = append(parts, r.failure)
+	}
+	if r.unprotected && len(parts) > 0 {
+		// Lead with it, so a good signature isn't taken for the whole body.
+		return "only the marked text is " + r.kind() + ": " + strings.Join(parts, ", ")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// kind is what gpg found the message to be: signed, encrypted, or both.
+func (r pgpResult) kind() string {
+	switch {
+	case r.encrypted && r.signature != "":
+		return "signed and encrypted"
+	case r.encrypted:
+		return "encrypted"
+	}
+	return "signed"
+}
+
+// runGPG runs gpg non-interactively on input, returning its output and
+// status lines. Passphrases come from gpg-agent, so its pinentry must not
+// need this terminal.
+func runGPG(input []byte, args ...string) ([]byte, []string, error) {
+	cmd := exec.Command("gpg", append([]string{"--batch", "--no-tty", "--status-fd", "2"}, args...)...)
+	cmd.Stdin = bytes.NewReader(input)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err := cmd.Run()
+
+	var status []string
+	var message string
+	for _, l := range strings.Split(stderr.String(), "\n") {
+		if s, ok := strings.CutPrefix(l, "[GNUPG:] "); ok {
+			status = append(status, s)
+		} else if l = strings.TrimSpace(strings.TrimPrefix(l, "gpg: ")); l != "" {
+			message = l
+		}
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && message != "" {
+		err = errors.New(message)
+	}
+	return stdout.Bytes(), status, err
+}
+
+// isPGP reports whether a message is PGP/MIME encrypted or signed, or has
+// inline PGP blocks in its body.
+func isPGP(payload *gmail.MessagePart, body string) bool {
+	return pgpMIME(payload) || armorBlock.MatchString(body)
+}
+
+// pgpEncrypted reports whether a message is PGP/MIME encrypted.
+func pgpEncrypted(payload *gmail.MessagePart) bool {
+	return payload.MimeType == "multipart/encrypted" && pgpMIME(payload)
+}
+
+func pgpMIME(payload *gmail.MessagePart) bool {
+	_, params, _ := mime.ParseMediaType(mimepart.Header(payload, "Content-Type"))
+	protocol := strings.ToLower(params["protocol"])
+	switch payload.MimeType {
+	case "multipart/encrypted":
+		return protocol == "application/pgp-encrypted"
+	case "multipart/signed":
+		return protocol == "application/pgp-signature"
+	}
+	return false
+}
+
+// openPGP decrypts and verifies a PGP message, returning its readable text
+// and a summary of what gpg found for the header. If gpg fails, body is
+// returned unchanged with the failure in the summary.
+func (m Model) openPGP(id string, payload *gmail.MessagePart, body string) (string, string, error) {
+	var res pgpResult
+	if !pgpMIME(payload) {
+		return openInline(body, &res), res.String(), nil
+	}
+
+	// A signature covers the exact bytes of the signed part, which only the
+	// raw message has.
+	raw, err := m.fetchRaw(id)
+	if err != nil {
+		return "", "", err
+	}
+	inner, err := unwrapPGP(raw, &res)
+	if err != nil {
+		res.failure = err.Error()
+		return body, res.String(), nil
+	}
+	return mimepart.EntityText(inner), res.String(), nil
+}
+
+// openInline replaces each inline PGP block in body with its decrypted or
+// verified text. When the body has text outside the blocks, which nothing
+// vouches for, each block's text is marked where it starts and ends.
+func openInline(body string, res *pgpResult) string {
+	blocks := armorBlock.FindAllStringIndex(body, -1)
+	var out strings.Builder
+	var opened []string
+	at := 0
+	for _, b := range blocks {
+		if strings.TrimSpace(body[at:b[0]]) != "" {
+			res.unprotected = true
+		}
+		opened = append(opened, body[at:b[0]])
+
+		block := body[b[0]:b[1]]
+		text, status, err := runGPG([]byte(block), "--decrypt")
+		res.read(status)
+		if len(text) == 0 && err != nil {
+			res.failure = fmt.Sprintf("unable to decrypt: %v", err)
+			opened = append(opened, block)
+		} else {
+			opened = append(opened, strings.TrimRight(string(text), "\r\n"))
+		}
+		at = b[1]
+	}
+	if strings.TrimSpace(body[at:]) != "" {
+		res.unprotected = true
+	}
+	opened = append(opened, body[at:])
+
+	for i, part := range opened {
+		if i%2 == 1 && res.unprotected {
+			part = "[-- Begin " + res.kind() + " text --]\n" + part + "\n[-- End of " + res.kind() + " text --]"
+		}
+		out.WriteString(part)
+	}
+	return out.String()
+}
+
+// openEncryptedDraft decrypts a draft that was saved encrypted, taking its
+// body and attachments from the decrypted message.
+func (m Model) openEncryptedDraft(d *compose.Draft, messageID string) error {
+	raw, err := m.fetchRaw(messageID)
+	if err != nil {
+		return err
+	}
+	var res pgpResult
+	inner, err := unwrapPGP(raw, &res)
+	if err != nil {
+		return fmt.Errorf("unable to open encrypted draft: %v", err)
+	}
+	d.Body = mimepart.EntityText(inner)
+	for _, f := range mimepart.EntityFiles(inner) {
+		d.Attachments = append(d.Attachments, compose.Attachment{Name: f.Name, MimeType: f.MimeType, Size: int64(len(f.Data)), Data: f.Data})
+	}
+	d.Encrypt = true
+	return nil
+}
+
+// unwrapPGP peels the PGP/MIME layers off a raw MIME entity, decrypting
+// multipart/encrypted and verifying multipart/signed, and returns the
+// entity inside.
+func unwrapPGP(raw []byte, res *pgpResult) ([]byte, error) {
+	for {
+		header, body, err := mimepart.ReadEntity(raw)
+		if err != nil {
+			return nil, err
+		}
+		mt, params, _ := mime.ParseMediaType(header.Get("Content-Type"))
+		protocol := strings.ToLower(params["protocol"])
+
+		switch {
+		case mt == "multipart/encrypted" && protocol == "application/pgp-encrypted":
+			parts := mimepart.RawParts(body, params["boundary"])
+			if len(parts) < 2 {
+				return nil, errors.New("malformed encrypted message")
+			}
+			_, data, err := mimepart.ReadEntity(parts[1])
+			if err != nil {
+				return nil, err
+			}
+			out, status, err := runGPG(data, "--decrypt")
+			res.read(status)
+			if len(out) == 0 && err != nil {
+				return nil, fmt.Errorf("unable to decrypt: %v", err)
+			}
+			raw = out
+		case mt == "multipart/signed" && protocol == "application/pgp-signature":
+			parts := mimepart.RawParts(body, params["boundary"])
+			if len(parts) < 2 {
+				return nil, errors.New("malformed signed message")
+			}
+			_, sig, err := mimepart.ReadEntity(parts[1])
+			if err != nil {
+				return nil, err
+			}
+			if err := verifyDetached(mimepart.CRLF(parts[0]), sig, res); err != nil {
+				return nil, err
+			}
+			raw = parts[0]
+		default:
+			return raw, nil
+		}
+	}
+}
+
+// verifyDetached checks a detached signature over data.
+func verifyDetached(data, sig []byte, res *pgpResult) error {
+	f, err := os.CreateTemp("", "gmail-tui-*.asc")
+	if err != nil {
+		return fmt.Errorf("unable to verify signature: %v", err)
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(sig)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("unable to verify signature: %v", err)
+	}
+
+	_, status, err := runGPG(data, "--verify", f.Name(), "-")
+	res.read(status)
+	if res.signature == "" && err != nil {
+		return fmt.Errorf("unable to verify signature: %v", err)
+	}
+	return nil
+}
+
+// protectEntity signs and/or encrypts a MIME entity as PGP/MIME (RFC 3156),
+// signing with the key for the draft's From address.
+func protectEntity(entity []byte, d compose.Draft) ([]byte, error) {
+	var signer string
+	if a, err := mail.ParseAddress(d.From); err == nil {
+		signer = a.Address
+	}
+	if !d.Encrypt {
+		return signEntity(entity, signer)
+	}
+	return encryptEntity(entity, d, signer)
+}
+
+func signEntity(entity []byte, signer string) ([]byte, error) {
+	args := []string{"--armor", "--detach-sign", "--digest-algo", "SHA256"}
+	if signer != "" {
+		args = append(args, "--local-user", signer)
+	}
+	sig, _, err := runGPG(entity, args...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to sign message: %v", err)
+	}
+
+	boundary := multipart.NewWriter(nil).Boundary()
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "Content-Type: multipart/signed; micalg=pgp-sha256; protocol=\"application/pgp-signature\"; boundary=%q\r\n\r\n", boundary)
+	fmt.Fprintf(&b, "--%s\r\n", boundary)
+	b.Write(entity)
+	fmt.Fprintf(&b, "\r\n--%s\r\n", boundary)
+	b.WriteString("Content-Type: application/pgp-signature; name=\"signature.asc\"\r\n")
+	b.WriteString("Content-Description: OpenPGP digital signature\r\n\r\n")
+	b.Write(mimepart.CRLF(sig))
+	fmt.Fprintf(&b, "\r\n--%s--\r\n", boundary)
+	return b.Bytes(), nil
+}
+
+// encryptEntity encrypts to every recipient, and to the sender so the sent
+// copy stays readable. Bcc recipients' key IDs are hidden. Recipients'
+// keys must be valid under the user's gpg trust model.
+func encryptEntity(entity []byte, d compose.Draft, signer string) ([]byte, error) {
+	to, bcc, err := draftRecipients(d)
+	if err != nil {
+		return nil, err
+	}
+	if problems := keyProblems(append(to, bcc...)); len(problems) > 0 {
+		return nil, fmt.Errorf("unable to encrypt message: %s", strings.Join(problems, ", "))
+	}
+
+	args := []string{"--armor", "--encrypt"}
+	if d.Sign {
+		args = append(args, "--sign")
+		if signer != "" {
+			args = append(args, "--local-user", signer)
+		}
+	}
+	for _, a := range to {
+		args = append(args, "--recipient", a)
+	}
+	for _, a := range bcc {
+		args = append(args, "--hidden-recipient", a)
+	}
+	if signer != "" && len(keyProblems([]string{signer})) == 0 {
+		args = append(args, "--recipient", signer)
+	}
+	out, _, err := runGPG(entity, args...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to encrypt message: %v", err)
+	}
+
+	boundary := multipart.NewWriter(nil).Boundary()
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "Content-Type: multipart/encrypted; protocol=\"application/pgp-encrypted\"; boundary=%q\r\n\r\n", boundary)
+	fmt.Fprintf(&b, "--%s\r\n", boundary)
+	b.WriteString("Content-Type: application/pgp-encrypted\r\n")
+	b.WriteString("Content-Description: PGP/MIME version identification\r\n\r\n")
+	b.WriteString("Version: 1\r\n")
+	fmt.Fprintf(&b, "\r\n--%s\r\n", boundary)
+	b.WriteString("Content-Type: application/octet-stream; name=\"encrypted.asc\"\r\n")
+	b.WriteString("Content-Disposition: inline; filename=\"encrypted.asc\"\r\n\r\n")
+	b.Write(mimepart.CRLF(out))
+	fmt.Fprintf(&b, "\r\n--%s--\r\n", boundary)
+	return b.Bytes(), nil
+}
+
+// draftRecipients returns the addresses a draft goes to, with Bcc
+// recipients separate.
+func draftRecipients(d compose.Draft) (to, bcc []string, err error) {
+	for _, f := range []struct {
+		value string
+		list  *[]string
+	}{{d.To, &to}, {d.Cc, &to}, {d.Bcc, &bcc}} {
+		if strings.TrimSpace(f.value) == "" {
+			continue
+		}
+		addrs, err := mail.ParseAddressList(f.value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to parse recipients: %v", err)
+		}
+		for _, a := range addrs {
+			*f.list = append(*f.list, a.Address)
+		}
+	}
+	return to, bcc, nil
+}
+
+// keyProblems returns why gpg won't encrypt to each of addrs that it
+// refuses: there is no key for it, or under the user's trust model its key
+// isn't known to belong to it. Only the local keyring is searched.
+func keyProblems(addrs []string) []string {
+	var problems []string
+	for _, a := range addrs {
+		_, status, err := runGPG(nil, "--auto-key-locate", "local", "--encrypt", "--recipient", a)
+		if err == nil {
+			continue
+		}
+		problem := "no PGP key for " + a
+		for _, st := range status {
+			if f := strings.Fields(st); len(f) > 1 && f[0] == "INV_RECP" && f[1] == "10" {
+				problem = "untrusted PGP key for " + a
+			}
+		}
+		problems = append(problems, problem)
+	}
+	return problems
+}
+
+// pgpMode describes how a draft will be protected when sent.
+func pgpMode(d compose.Draft) string {
+	switch {
+	case d.Sign && d.Encrypt:
+		return "signed and encrypted"
+	case d.Sign:
+		return "signed"
+	case d.Encrypt:
+		return "encrypted"
+	}
+	return ""
+}
+
+// cyclePGP steps the message being composed through unsigned, signed, and
+// signed and encrypted. Encryption is only offered when every recipient
+// has a trusted key. The draft is saved again when encryption is turned on
+// or off, so the copy in Gmail is encrypted from then on.
+func (m Model) cyclePGP() (Model, tea.Cmd) {
+	d := m.compose.Snapshot()
+	encrypted := d.Encrypt
+	var sign, encrypt bool
+	switch {
+	case !d.Sign && !d.Encrypt:
+		sign = true
+	case d.Sign && !d.Encrypt:
+		sign, encrypt = true, true
+	}
+
+	if encrypt {
+		to, bcc, err := draftRecipients(d)
+		if err != nil {
+			m.status = err.Error()
+			return m, nil
+		}
+		if len(to)+len(bcc) == 0 {
+			m.status = "Add a recipient before encrypting"
+			return m, nil
+		}
+		if problems := keyProblems(append(to, bcc...)); len(problems) > 0 {
+			m.compose.SetPGP(false, false)
+			m.status = "Not encrypting: " + strings.Join(problems, ", ")
+			return m, nil
+		}
+	}
+
+	m.compose.SetPGP(sign, encrypt)
+	d.Sign, d.Encrypt = sign, encrypt
+	if mode := pgpMode(d); mode != "" {
+		m.status = "Message will be " + mode
+	} else {
+		m.status = "Message will be sent without PGP"
+	}
+	if encrypt != encrypted {
+		m.loading = true
+		return m, m.saveCompose(m.compose, m.status)
+	}
+	return m, nil
+}
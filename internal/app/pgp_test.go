@@ -0,0 +1,178 @@
+package app
+
+import (
+	"encoding/base64"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"gmail-tui/internal/ui/compose"
+)
+
+// withKeyring points gpg at a new keyring holding a key of our own for
+// me@example.com and, imported without being certified, one for
+// bob@example.com.
+func withKeyring(t *testing.T) {
+	t.Helper()
+	if _, err := exec.LookPath("gpg"); err != nil {
+		t.Skip("gpg is not installed")
+	}
+	home, other := t.TempDir(), t.TempDir()
+	for _, dir := range []string{home, other} {
+		dir := dir
+		os.Chmod(dir, 0700)
+		t.Cleanup(func() {
+			cmd := exec.Command("gpgconf", "--kill", "gpg-agent")
+			cmd.Env = append(os.Environ(), "GNUPGHOME="+dir)
+			cmd.Run()
+		})
+	}
+	gpg := func(dir string, input []byte, args ...string) []byte {
+		cmd := exec.Command("gpg", append([]string{"--batch", "--no-tty", "--passphrase", ""}, args...)...)
+		cmd.Env = append(os.Environ(), "GNUPGHOME="+dir)
+		if input != nil {
+			cmd.Stdin = strings.NewReader(string(input))
+		}
+		out, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("gpg %v: %v", args, err)
+		}
+		return out
+	}
+	gpg(home, nil, "--quick-gen-key", "Me <me@example.com>", "default", "default", "never")
+	gpg(other, nil, "--quick-gen-key", "Bob <bob@example.com>", "default", "default", "never")
+	gpg(home, gpg(other, nil, "--armor", "--export", "bob@example.com"), "--import")
+	t.Setenv("GNUPGHOME", home)
+}
+
+func clearsign(t *testing.T, text string) string {
+	t.Helper()
+	out, _, err := runGPG([]byte(text), "--clearsign")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func TestInlineSignedBody(t *testing.T) {
+	withKeyring(t)
+	var res pgpResult
+	text := openInline(clearsign(t, "Meet at ten."), &res)
+	if strings.TrimSpace(text) != "Meet at ten." {
+		t.Errorf("opened body is %q", text)
+	}
+	if got := res.String(); got != "good signature from Me <me@example.com>" {
+		t.Errorf("status is %q", got)
+	}
+}
+
+func TestInlineSignedBodyWithAddedText(t *testing.T) {
+	withKeyring(t)
+	var res pgpResult
+	body := "Wire the money to account 1234.\n\n" + clearsign(t, "Meet at ten.")
+	text := openInline(body, &res)
+	if !strings.Contains(text, "[-- Begin signed text --]\nMeet at ten.\n[-- End of signed text --]") {
+		t.Errorf("signed text is not marked:\n%s", text)
+	}
+	if got := res.String(); !strings.HasPrefix(got, "only the marked text is signed: ") {
+		t.Errorf("status is %q", got)
+	}
+}
+
+func TestKeyProblems(t *testing.T) {
+	withKeyring(t)
+	got := keyProblems([]string{"me@example.com", "bob@example.com", "nobody@example.com"})
+	want := []string{"untrusted PGP key for bob@example.com", "no PGP key for nobody@example.com"}
+	if strings.Join(got, "; ") != strings.Join(want, "; ") {
+		t.Errorf("keyProblems = %q, want %q", got, want)
+	}
+	if _, err := encryptEntity([]byte("Content-Type: text/plain\r\n\r\nhi\r\n"), compose.Draft{To: "bob@example.com"}, ""); err == nil {
+		t.Error("encrypted to an untrusted key")
+	}
+}
+
+func TestEncryptedDraftIsSavedEncrypted(t *testing.T) {
+	withKeyring(t)
+	m, mail := newTestModel(t)
+	d := compose.Draft{From: "me@example.com", To: "me@example.com", Subject: "Plans", Encrypt: true}
+	c, err := compose.NewSession(d, protectEntity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(c.Remove)
+	d.Body = "The secret plans."
+	if err := c.Write(compose.Text(d)); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Save(m.ctx, mail); err != nil {
+		t.Fatal(err)
+	}
+
+	id := c.Snapshot().ID
+	msg, err := mail.Get(m.ctx, id, "raw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := base64.URLEncoding.DecodeString(msg.Raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(raw), "secret plans") || !strings.Contains(string(raw), "multipart/encrypted") {
+		t.Fatalf("draft was saved as:\n%s", raw)
+	}
+
+	reopened := compose.Draft{ID: id}
+	if err := m.openEncryptedDraft(&reopened, id); err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(reopened.Body) != "The secret plans." || !reopened.Encrypt {
+		t.Errorf("reopened draft has body %q, encrypt %v", reopened.Body, reopened.Encrypt)
+	}
+}
+
+func TestProtectedMessageQueuedWhenUploadFails(t *testing.T) {
+	withKeyring(t)
+	m, mail := newTestModel(t)
+	off := &offlineMail{Provider: mail}
+	m.mail = off
+
+	c, err := compose.NewSession(compose.Draft{From: "me@example.com", To: "me@example.com", Subject: "Plans"}, protectEntity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(c.Remove)
+	if err := c.Write(compose.Text(compose.Draft{From: "me@example.com", To: "me@example.com", Subject: "Plans", Body: "The secret plans."})); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Save(m.ctx, off); err != nil {
+		t.Fatal(err)
+	}
+	c.SetPGP(true, true)
+	if err := c.Save(m.ctx, off); err != nil {
+		t.Fatal(err)
+	}
+	id := c.Snapshot().ID
+
+	off.down = true
+	if msg, ok := m.sendDraft(c)().(sentMsg); !ok || !msg.queued {
+		t.Fatalf("sending offline returned %#v", msg)
+	}
+	queued := m.outbox.list()
+	if len(queued) != 1 || queued[0].DraftID != id {
+		t.Fatalf("outbox holds %+v", queued)
+	}
+	raw, err := base64.URLEncoding.DecodeString(queued[0].Raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(raw), "secret plans") || !strings.Contains(string(raw), "multipart/encrypted") {
+		t.Fatalf("queued message is:\n%s", raw)
+	}
+
+	off.down = false
+	sendQueued(t, m)
+	if len(off.sent) != 1 || off.sent[0] != id {
+		t.Errorf("sent %v, want the draft %s", off.sent, id)
+	}
+}
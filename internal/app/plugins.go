@@ -0,0 +1,349 @@
+package app
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	lua "github.com/yuin/gopher-lua"
+	"google.golang.org/api/gmail/v1"
+
+	"gmail-tui/internal/config"
+	"gmail-tui/internal/mimepart"
+	"gmail-tui/internal/ui/compose"
+)
+
+// plugins runs the Lua scripts in the plugins directory of the config
+// directory. Scripts use the gmail module to register key bindings, list
+// columns and body transforms, and to search, label and send mail.
+//
+// A Lua state is not safe for concurrent use, so every call into it holds
+// mu. Calls are made from commands rather than Update, so a slow script
+// does not freeze the UI.
+type plugins struct {
+	mu         sync.Mutex
+	L          *lua.LState
+	api        Model
+	bindings   map[string]pluginBinding
+	columns    []*lua.LFunction
+	transforms []*lua.LFunction
+
+	// status and changed are set by a running binding.
+	status  string
+	changed bool
+}
+
+type pluginBinding struct {
+	help string
+	fn   *lua.LFunction
+}
+
+// pluginDoneMsg reports that a plugin key binding finished. changed is set
+// when it modified messages, so the list is refreshed.
+type pluginDoneMsg struct {
+	status  string
+	changed bool
+}
+
+// loadPlugins runs every .lua file in the plugins directory, in name order.
+// It returns nil if there are none.
+func loadPlugins(api Model) (*plugins, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return nil, nil
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "plugins", "*.lua"))
+	if len(files) == 0 {
+		return nil, nil
+	}
+	sort.Strings(files)
+
+	p := &plugins{L: lua.NewState(), api: api, bindings: map[string]pluginBinding{}}
+	p.L.SetGlobal("gmail", p.L.SetFuncs(p.L.NewTable(), map[string]lua.LGFunction{
+		"bind":           p.bind,
+		"column":         p.column,
+		"transform_body": p.transformBody,
+		"search":         p.search,
+		"label":          p.label,
+		"archive":        p.archive,
+		"send":           p.send,
+		"status":         p.setStatus,
+	}))
+
+	for _, f := range files {
+		if err := p.L.DoFile(f); err != nil {
+			return p, fmt.Errorf("unable to load plugin %s: %s", filepath.Base(f), luaError(err))
+		}
+	}
+	return p, nil
+}
+
+// bind implements gmail.bind(key, help, function(msg)).
+func (p *plugins) bind(L *lua.LState) int {
+	k := L.CheckString(1)
+	help := L.CheckString(2)
+	p.bindings[k] = pluginBinding{help: help, fn: L.CheckFunction(3)}
+	return 0
+}
+
+// column implements gmail.column(function(msg) return text end).
+func (p *plugins) column(L *lua.LState) int {
+	p.columns = append(p.columns, L.CheckFunction(1))
+	return 0
+}
+
+// transformBody implements gmail.transform_body(function(body, msg) return
+// body end).
+func (p *plugins) transformBody(L *lua.LState) int {
+	p.transforms = append(p.transforms, L.CheckFunction(1))
+	return 0
+}
+
+// search implements gmail.search(query [, max]), returning a list of
+// messages.
+func (p *plugins) search(L *lua.LState) int {
+	emails, _, err := p.api.listEmails(p.api.ctx, L.CheckString(1), int64(L.OptInt(2, 20)))
+	if err != nil {
+		L.RaiseError("unable to search: %v", err)
+	}
+	t := L.NewTable()
+	for _, e := range emails {
+		t.Append(p.emailTable(e))
+	}
+	L.Push(t)
+	return 1
+}
+
+// label implements gmail.label(id, add, remove), where add and remove are
+// lists of label names or IDs. Labels to add are created if needed.
+func (p *plugins) label(L *lua.LState) int {
+	id := L.CheckString(1)
+	add := luaStrings(L.OptTable(2, L.NewTable()))
+	remove := luaStrings(L.OptTable(3, L.NewTable()))
+
+	idx, err := fetchLabels(p.api.ctx, p.api.gmailSvc)
+	if err != nil {
+		L.RaiseError("%v", err)
+	}
+	var addIDs, removeIDs []string
+	for _, name := range add {
+		lid, err := ensureLabel(p.api.ctx, p.api.gmailSvc, idx, name)
+		if err != nil {
+			L.RaiseError("%v", err)
+		}
+		addIDs = append(addIDs, lid)
+	}
+	for _, name := range remove {
+		if l := idx.byName(name); l != nil {
+			name = l.Id
+		}
+		removeIDs = append(removeIDs, name)
+	}
+	p.modify(L, id, addIDs, removeIDs)
+	return 0
+}
+
+// archive implements gmail.archive(id).
+func (p *plugins) archive(L *lua.LState) int {
+	msg := p.modify(L, L.CheckString(1), nil, []string{"INBOX"})
+	runHook("archive", p.api.config.Hooks.OnArchive, hookEvent{ID: msg.Id, ThreadID: msg.ThreadId, Labels: msg.LabelIds})
+	return 0
+}
+
+func (p *plugins) modify(L *lua.LState, id string, add, remove []string) *gmail.Message {
+	msg, err := p.api.mail.Modify(p.api.ctx, id, add, remove)
+	if err != nil {
+		L.RaiseError("unable to update message: %v", err)
+	}
+	p.changed = true
+	return msg
+}
+
+// send implements gmail.send{to=, cc=, bcc=, subject=, body=, markdown=,
+// sign=, encrypt=}, returning the ID of the sent message.
+func (p *plugins) send(L *lua.LState) int {
+	t := L.CheckTable(1)
+	field := func(name string) string { return lua.LVAsString(t.RawGetString(name)) }
+	d := compose.Draft{
+		From:    field("from"),
+		To:      field("to"),
+		Cc:      field("cc"),
+		Bcc:     field("bcc"),
+		Subject: field("subject"),
+		Body:    field("body"),
+	}
+	d.Markdown = lua.LVAsBool(t.RawGetString("markdown"))
+	d.Sign = lua.LVAsBool(t.RawGetString("sign"))
+	d.Encrypt = lua.LVAsBool(t.RawGetString("encrypt"))
+
+	raw, err := rawMessage(p.api.ctx, p.api.mail, d)
+	if err != nil {
+		L.RaiseError("%v", err)
+	}
+	msg, err := p.api.mail.Send(p.api.ctx, raw)
+	if err != nil {
+		L.RaiseError("unable to send message: %v", err)
+	}
+	runHook("send", p.api.config.Hooks.OnSend, sentEvent(msg, d))
+	p.changed = true
+	L.Push(lua.LString(msg.Id))
+	return 1
+}
+
+// setStatus implements gmail.status(text), shown once the binding ends.
+func (p *plugins) setStatus(L *lua.LState) int {
+	p.status = L.CheckString(1)
+	return 0
+}
+
+func (p *plugins) emailTable(e Email) *lua.LTable {
+	t := p.L.NewTable()
+	t.RawSetString("id", lua.LString(e.ID))
+	t.RawSetString("thread_id", lua.LString(e.ThreadID))
+	t.RawSetString("from", lua.LString(e.From))
+	t.RawSetString("subject", lua.LString(e.Subject))
+	t.RawSetString("snippet", lua.LString(e.Snippet))
+	if !e.Date.IsZero() {
+		t.RawSetString("date", lua.LString(e.Date.Format(time.RFC3339)))
+	}
+	if e.loaded {
+		t.RawSetString("body", lua.LString(e.Body))
+	}
+	labels := p.L.NewTable()
+	for _, l := range e.Labels {
+		labels.Append(lua.LString(l))
+	}
+	t.RawSetString("labels", labels)
+	return t
+}
+
+func luaStrings(t *lua.LTable) []string {
+	var out []string
+	t.ForEach(func(_, v lua.LValue) {
+		out = append(out, lua.LVAsString(v))
+	})
+	return out
+}
+
+// call runs fn and returns its result.
+func (p *plugins) call(fn *lua.LFunction, args ...lua.LValue) (lua.LValue, error) {
+	if err := p.L.CallByParam(lua.P{Fn: fn, NRet: 1, Protect: true}, args...); err != nil {
+		return lua.LNil, err
+	}
+	ret := p.L.Get(-1)
+	p.L.Pop(1)
+	return ret, nil
+}
+
+// luaError returns the message of a Lua error without its stack traceback,
+// so it fits in the status bar.
+func luaError(err error) string {
+	if aerr, ok := err.(*lua.ApiError); ok && aerr.Object != nil {
+		return aerr.Object.String()
+	}
+	return err.Error()
+}
+
+// keyBindings lists the plugin bindings for the help view.
+func (p *plugins) keyBindings() []key.Binding {
+	if p == nil {
+		return nil
+	}
+	keys := make([]string, 0, len(p.bindings))
+	for k := range p.bindings {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var bindings []key.Binding
+	for _, k := range keys {
+		bindings = append(bindings, key.NewBinding(key.WithKeys(k), key.WithHelp(k, p.bindings[k].help)))
+	}
+	return bindings
+}
+
+// bound reports whether a plugin binds k.
+func (p *plugins) bound(k string) bool {
+	if p == nil {
+		return false
+	}
+	_, ok := p.bindings[k]
+	return ok
+}
+
+// columnText returns the extra list text the plugins give e, or "" if a
+// column function fails.
+func (p *plugins) columnText(e Email) string {
+	if p == nil || len(p.columns) == 0 {
+		return ""
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var parts []string
+	for _, fn := range p.columns {
+		ret, err := p.call(fn, p.emailTable(e))
+		if s := lua.LVAsString(ret); err == nil && s != "" {
+			parts = append(parts, s)
+		}
+	}
+	return strings.Join(parts, " | ")
+}
+
+// body runs the plugins' body transforms on body. A transform that fails
+// or returns nothing leaves the body as it was.
+func (p *plugins) body(body string, e Email) string {
+	if p == nil || len(p.transforms) == 0 {
+		return body
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, fn := range p.transforms {
+		if ret, err := p.call(fn, lua.LString(body), p.emailTable(e)); err == nil && ret != lua.LNil {
+			body = lua.LVAsString(ret)
+		}
+	}
+	return body
+}
+
+// runBinding runs the plugin bound to k for e.
+func (m Model) runBinding(k string, e Email) tea.Cmd {
+	p := m.plugins
+	return func() tea.Msg {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		p.status, p.changed = "", false
+		if _, err := p.call(p.bindings[k].fn, p.emailTable(e)); err != nil {
+			return pluginDoneMsg{status: "Plugin error: " + luaError(err), changed: p.changed}
+		}
+		return pluginDoneMsg{status: p.status, changed: p.changed}
+	}
+}
+
+// listKey reports whether k is used by the list itself, so plugins cannot
+// take over navigation or filtering.
+func listKey(km list.KeyMap, msg tea.KeyMsg) bool {
+	return key.Matches(msg, km.CursorUp, km.CursorDown, km.PrevPage, km.NextPage,
+		km.GoToStart, km.GoToEnd, km.Filter, km.ShowFullHelp, km.CloseFullHelp)
+}
+
+// messageEmail describes a fully fetched message for body transforms.
+func messageEmail(msg *gmail.Message) Email {
+	return Email{
+		ID:       msg.Id,
+		ThreadID: msg.ThreadId,
+		From:     mimepart.DecodeHeader(mimepart.Header(msg.Payload, "From")),
+		Subject:  mimepart.DecodeHeader(mimepart.Header(msg.Payload, "Subject")),
+		Date:     parseDate(mimepart.Header(msg.Payload, "Date")),
+		Snippet:  msg.Snippet,
+		Labels:   msg.LabelIds,
+	}
+}
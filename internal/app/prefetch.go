@@ -0,0 +1,171 @@
+package app
+
+import (
+	"gmail-tui/internal/mimepart"
+
+	"context"
+	"slices"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// bodyCacheBytes caps the memory used by message bodies kept on list rows.
+// The least recently used bodies are dropped first and fetched again when
+// needed.
+const bodyCacheBytes = 4 << 20
+
+// prefetcher tracks body fetches started ahead of the cursor so they are
+// not duplicated and can be cancelled once the cursor moves far away.
+type prefetcher struct {
+	mu       sync.Mutex
+	inflight map[string]context.CancelFunc
+}
+
+func newPrefetcher() *prefetcher {
+	return &prefetcher{inflight: map[string]context.CancelFunc{}}
+}
+
+// start registers a fetch of id, returning false if one is already running.
+// The fetch's context is derived from parent.
+func (p *prefetcher) start(parent context.Context, id string) (context.Context, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.inflight[id]; ok {
+		return nil, false
+	}
+	ctx, cancel := context.WithCancel(parent)
+	p.inflight[id] = cancel
+	return ctx, true
+}
+
+func (p *prefetcher) done(id string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if cancel, ok := p.inflight[id]; ok {
+		cancel()
+		delete(p.inflight, id)
+	}
+}
+
+// count is how many prefetches are running.
+func (p *prefetcher) count() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.inflight)
+}
+
+func (p *prefetcher) running(id string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, ok := p.inflight[id]
+	return ok
+}
+
+// cancelExcept cancels every prefetch whose message is not in keep.
+func (p *prefetcher) cancelExcept(keep map[string]bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for id, cancel := range p.inflight {
+		if !keep[id] {
+			cancel()
+			delete(p.inflight, id)
+		}
+	}
+}
+
+// prefetchBodies starts fetching the bodies of the rows around the cursor
+// that are not loaded yet, and cancels prefetches for rows the cursor has
+// moved well away from.
+func (m Model) prefetchBodies() tea.Cmd {
+	n := m.config.PrefetchCount
+	if n <= 0 {
+		return nil
+	}
+
+	items := m.list.VisibleItems()
+	cursor := m.list.Index()
+
+	keep := map[string]bool{}
+	for i := max(cursor-2*n, 0); i < min(cursor+2*n+1, len(items)); i++ {
+		if e, ok := items[i].(Email); ok {
+			keep[e.ID] = true
+		}
+	}
+	m.prefetch.cancelExcept(keep)
+
+	var cmds []tea.Cmd
+	for i := max(cursor-1, 0); i < min(cursor+n+1, len(items)); i++ {
+		e, ok := items[i].(Email)
+		if !ok || e.loaded {
+			continue
+		}
+		ctx, ok := m.prefetch.start(m.ctx, e.ID)
+		if !ok {
+			continue
+		}
+		cmds = append(cmds, m.prefetchBody(ctx, e.ID))
+	}
+	return tea.Batch(cmds...)
+}
+
+// prefetchFailedMsg reports a prefetch that failed or was cancelled.
+type prefetchFailedMsg struct {
+	id string
+}
+
+// prefetchBody fetches a body in the background. Failures are not shown;
+// the body is fetched again, with errors reported, when the message is
+// opened.
+func (m Model) prefetchBody(ctx context.Context, id string) tea.Cmd {
+	return func() tea.Msg {
+		defer m.prefetch.done(id)
+		msg, err := m.mail.Get(ctx, id, "full")
+		if err != nil {
+			return prefetchFailedMsg{id: id}
+		}
+		// Signed and encrypted messages are opened when they are read, so
+		// gpg never asks for a passphrase for a message that was only
+		// scrolled past.
+		body, cut := mimepart.TextLimit(msg.Payload, m.config.MaxBodyBytes())
+		if isPGP(msg.Payload, body) || isSMIME(msg.Payload) {
+			return prefetchFailedMsg{id: id}
+		}
+		m.index.add(messageEmail(msg), body)
+		return bodyMsg{
+			id:     id,
+			body:   m.plugins.body(body, messageEmail(msg)),
+			invite: parseInvite(msg.Payload),
+			files:  messageAttachments(id, msg.Payload),
+			remote: parseRemote(msg.Payload),
+			cut:    cut,
+		}
+	}
+}
+
+// cacheBody marks id as the most recently used body and drops the bodies
+// of the least recently used rows once the cache is over its cap.
+func (m Model) cacheBody(id string) Model {
+	m.bodyLRU = append(slices.DeleteFunc(slices.Clone(m.bodyLRU), func(x string) bool { return x == id }), id)
+
+	size := 0
+	sizes := map[string]int{}
+	for _, item := range m.list.Items() {
+		if e, ok := item.(Email); ok && e.loaded {
+			sizes[e.ID] = len(e.Body)
+			size += len(e.Body)
+		}
+	}
+
+	for len(m.bodyLRU) > 1 && size > bodyCacheBytes {
+		oldest := m.bodyLRU[0]
+		m.bodyLRU = m.bodyLRU[1:]
+		if i := m.emailIndex(oldest); i >= 0 {
+			e := m.list.Items()[i].(Email)
+			e.Body, e.cut, e.loaded = "", 0, false
+			m.list.SetItem(i, e)
+			size -= sizes[oldest]
+		}
+	}
+	return m
+}
@@ -0,0 +1,240 @@
+package app
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+
+	"gmail-tui/internal/browser"
+	"gmail-tui/internal/mimepart"
+	"gmail-tui/internal/ui/compose"
+	"gmail-tui/internal/ui/view"
+)
+
+// maxPreviewBytes caps how much of a text attachment is shown.
+const maxPreviewBytes = 1 << 20
+
+// maxPreviewRows caps the rows of a CSV attachment drawn as a table.
+const maxPreviewRows = 500
+
+// openedMsg reports an attachment handed to the system's default
+// application.
+type openedMsg struct {
+	name string
+	err  error
+}
+
+func (m Model) openAttachPicker() Model {
+	if !m.selectedMail.loaded {
+		m.status = "Message is still loading"
+		return m
+	}
+	if len(m.selectedMail.files) == 0 {
+		m.status = "No attachments in this message"
+		return m
+	}
+	m.pickingAttach = true
+	m.attachCursor = 0
+	return m
+}
+
+func (m Model) updateAttachPicker(msg tea.KeyMsg) (Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, m.keys.Back):
+		m.pickingAttach = false
+	case key.Matches(msg, m.keys.Down):
+		if m.attachCursor < len(m.selectedMail.files)-1 {
+			m.attachCursor++
+		}
+	case key.Matches(msg, m.keys.Up):
+		if m.attachCursor > 0 {
+			m.attachCursor--
+		}
+	case key.Matches(msg, m.keys.Select):
+		return m.previewAttachment(m.attachCursor)
+	case key.Matches(msg, m.keys.Links):
+		return m.openAttachment(m.attachCursor)
+	case key.Matches(msg, m.keys.SaveAll):
+		return m.saveAllAttachments()
+	case len(msg.Runes) == 1 && msg.Runes[0] >= '1' && msg.Runes[0] <= '9':
+		if i := int(msg.Runes[0] - '1'); i < len(m.selectedMail.files) {
+			return m.previewAttachment(i)
+		}
+	}
+	return m, nil
+}
+
+func (m Model) attachPickerView() string {
+	lines := []string{view.TitleStyle.Render("Attachments"), ""}
+	for i, a := range m.selectedMail.files {
+		marker := "  "
+		if i == m.attachCursor {
+			marker = "> "
+		}
+		lines = append(lines, fmt.Sprintf("%s%2d. %s (%s)", marker, i+1, a.Name, view.Size(a.Size)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// previewAttachment shows attachment i in the reading view in place of the
+// body, like the message source. Types that cannot be shown as text are
+// opened with the system's default application instead.
+func (m Model) previewAttachment(i int) (Model, tea.Cmd) {
+	a := m.selectedMail.files[i]
+	if previewKind(a) == "" {
+		return m.openAttachment(i)
+	}
+	m.pickingAttach = false
+	m.status = "Loading " + a.Name + "..."
+	id := m.selectedMail.ID
+	return m, func() tea.Msg {
+		data, err := a.Load(m.ctx, m.mail)
+		if err != nil {
+			return errMsg(err)
+		}
+		text, err := previewText(a, data, m.viewport.Width-m.viewport.Style.GetHorizontalFrameSize())
+		if err != nil {
+			return errMsg(err)
+		}
+		return sourceMsg{id: id, text: text}
+	}
+}
+
+// openAttachment downloads attachment i to a temporary directory and opens
+// it with the system's default application. The file is left behind for the
+// application to read.
+func (m Model) openAttachment(i int) (Model, tea.Cmd) {
+	a := m.selectedMail.files[i]
+	m.pickingAttach = false
+	m.status = "Opening " + a.Name + "..."
+	return m, func() tea.Msg {
+		data, err := a.Load(m.ctx, m.mail)
+		if err != nil {
+			return openedMsg{name: a.Name, err: err}
+		}
+		dir, err := os.MkdirTemp("", "gmail-tui-")
+		if err != nil {
+			return openedMsg{name: a.Name, err: err}
+		}
+		path := filepath.Join(dir, filepath.Base(a.Name))
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			return openedMsg{name: a.Name, err: err}
+		}
+		return openedMsg{name: a.Name, err: browser.Open(path)}
+	}
+}
+
+// previewKind says how an attachment is previewed: "text", "csv", "json",
+// "pdf", or "" if it cannot be.
+func previewKind(a compose.Attachment) string {
+	mt := strings.ToLower(a.MimeType)
+	ext := strings.ToLower(filepath.Ext(a.Name))
+	switch {
+	case mt == "text/csv" || ext == ".csv":
+		return "csv"
+	case mt == "application/json" || strings.HasSuffix(mt, "+json") || ext == ".json":
+		return "json"
+	case mt == "application/pdf" || ext == ".pdf":
+		return "pdf"
+	case strings.HasPrefix(mt, "text/") && mt != "text/html":
+		return "text"
+	}
+	switch ext {
+	case ".txt", ".log", ".md", ".ini", ".conf", ".cfg", ".yaml", ".yml", ".toml", ".xml", ".diff", ".patch":
+		return "text"
+	}
+	return ""
+}
+
+// previewText renders attachment data as text for a viewport width columns
+// wide.
+func previewText(a compose.Attachment, data []byte, width int) (string, error) {
+	title := fmt.Sprintf("%s (%s)", a.Name, view.Size(int64(len(data))))
+	var text string
+	switch previewKind(a) {
+	case "pdf":
+		cmd := exec.Command("pdftotext", "-layout", "-", "-")
+		cmd.Stdin = bytes.NewReader(data)
+		out, err := cmd.Output()
+		if errors.Is(err, exec.ErrNotFound) {
+			return "", fmt.Errorf("unable to preview %s: pdftotext is not installed", a.Name)
+		}
+		if err != nil {
+			return "", fmt.Errorf("unable to preview %s: %v", a.Name, err)
+		}
+		text = string(out)
+	case "json":
+		var b bytes.Buffer
+		if err := json.Indent(&b, data, "", "  "); err != nil {
+			text = previewPlain(data)
+		} else {
+			text = b.String()
+		}
+	case "csv":
+		if t, ok := csvTable(data); ok {
+			text = t
+		} else {
+			text = previewPlain(data)
+		}
+	default:
+		text = previewPlain(data)
+	}
+	return title + "\n" + strings.Repeat("─", max(width, 0)) + "\n\n" + text, nil
+}
+
+// previewPlain decodes text of unknown charset, truncating it at
+// maxPreviewBytes.
+func previewPlain(data []byte) string {
+	truncated := len(data) > maxPreviewBytes
+	if truncated {
+		data = data[:maxPreviewBytes]
+	}
+	text := string(data)
+	if !utf8.Valid(data) {
+		text = mimepart.ToUTF8(data, "windows-1252")
+	}
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	if truncated {
+		text += "\n[… truncated]"
+	}
+	return text
+}
+
+// csvTable draws CSV data as a table, or reports false if it does not
+// parse.
+func csvTable(data []byte) (string, bool) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	rows, err := r.ReadAll()
+	if err != nil || len(rows) == 0 {
+		return "", false
+	}
+
+	more := len(rows) - 1 - maxPreviewRows
+	if more > 0 {
+		rows = rows[:maxPreviewRows+1]
+	}
+	cell := lipgloss.NewStyle().Padding(0, 1)
+	t := table.New().
+		Border(view.TableBorder()).
+		StyleFunc(func(row, col int) lipgloss.Style { return cell }).
+		Headers(rows[0]...).
+		Rows(rows[1:]...)
+	out := t.String()
+	if more > 0 {
+		out += fmt.Sprintf("\n[… %d more rows]", more)
+	}
+	return out, true
+}
@@ -0,0 +1,261 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+
+	"golang.org/x/net/html"
+	"google.golang.org/api/gmail/v1"
+
+	"gmail-tui/internal/mimepart"
+	"gmail-tui/internal/ui/view"
+)
+
+// errNoPDFConverter is what a PDF export fails with when nothing to render
+// PDFs is installed.
+var errNoPDFConverter = errors.New("PDF export needs wkhtmltopdf, Chromium or Google Chrome; export txt instead")
+
+// pdfBrowsers are the headless browsers tried, in order, when wkhtmltopdf
+// isn't installed.
+var pdfBrowsers = []string{
+	"chromium", "chromium-browser", "google-chrome", "google-chrome-stable",
+	"/Applications/Google Chrome.app/Contents/MacOS/Google Chrome",
+	"/Applications/Chromium.app/Contents/MacOS/Chromium",
+}
+
+// printFormat reports whether format writes messages to read or archive
+// rather than for other mail clients.
+func printFormat(format string) bool {
+	return format == exportText || format == exportPDF
+}
+
+// pdfConverter finds the program that renders PDFs: wkhtmltopdf, or else a
+// headless browser.
+func pdfConverter() (string, error) {
+	for _, name := range append([]string{"wkhtmltopdf"}, pdfBrowsers...) {
+		if path, err := exec.LookPath(name); err == nil {
+			return path, nil
+		}
+	}
+	return "", errNoPDFConverter
+}
+
+// printedHeader is a header line of a printed message.
+type printedHeader struct {
+	Name, Value string
+}
+
+// printed is a message as it is printed: its main headers, its body as
+// text and, for PDFs, as HTML when it has an HTML part, and the names of
+// its attachments.
+type printed struct {
+	Subject     string
+	Headers     []printedHeader
+	Text        string
+	HTML        template.HTML
+	Attachments []string
+}
+
+// newPrinted reads a message fetched in full format.
+func newPrinted(msg *gmail.Message) printed {
+	p := printed{Subject: "(no subject)", Text: mimepart.Text(msg.Payload)}
+	if s := mimepart.DecodeHeader(mimepart.Header(msg.Payload, "Subject")); s != "" {
+		p.Subject = s
+	}
+	for _, name := range []string{"From", "To", "Cc", "Date"} {
+		value := mimepart.DecodeHeader(mimepart.Header(msg.Payload, name))
+		if name == "Date" {
+			value = time.UnixMilli(msg.InternalDate).Format("Mon, 2 Jan 2006 15:04 MST")
+		}
+		if value != "" {
+			p.Headers = append(p.Headers, printedHeader{name, value})
+		}
+	}
+	if part := mimepart.Find(msg.Payload, "text/html"); part != nil {
+		if data, ok := mimepart.Data(part); ok {
+			p.HTML = template.HTML(printableHTML(mimepart.ToUTF8(data, mimepart.Charset(part))))
+		}
+	}
+	for _, a := range messageAttachments(msg.Id, msg.Payload) {
+		p.Attachments = append(p.Attachments, fmt.Sprintf("%s (%s)", a.Name, view.Size(a.Size)))
+	}
+	return p
+}
+
+// text lays the message out as plain text: the subject underlined, the
+// headers, the body and a list of attachments.
+func (p printed) text() string {
+	var b strings.Builder
+	b.WriteString(p.Subject + "\n")
+	b.WriteString(strings.Repeat("=", min(len([]rune(p.Subject)), 72)) + "\n\n")
+	for _, h := range p.Headers {
+		fmt.Fprintf(&b, "%-6s %s\n", h.Name+":", h.Value)
+	}
+	b.WriteString("\n" + strings.TrimRight(p.Text, "\n") + "\n")
+	if len(p.Attachments) > 0 {
+		b.WriteString("\nAttachments:\n")
+		for _, a := range p.Attachments {
+			b.WriteString("  - " + a + "\n")
+		}
+	}
+	return b.String()
+}
+
+// printTemplate is the page PDFs are rendered from. Its content security
+// policy stops the message from running scripts or loading anything remote,
+// such as tracking images.
+var printTemplate = template.Must(template.New("print").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="Content-Security-Policy" content="default-src 'none'; style-src 'unsafe-inline'; img-src data:; font-src data:">
+<title>{{.Subject}}</title>
+<style>
+body { font-family: sans-serif; font-size: 11pt; margin: 2em; color: #222; }
+h1 { font-size: 16pt; margin: 0 0 0.5em; }
+table.headers { border-collapse: collapse; margin-bottom: 1em; }
+table.headers th { text-align: left; padding: 0 1em 0.2em 0; color: #666; font-weight: normal; vertical-align: top; }
+table.headers td { padding: 0 0 0.2em; }
+hr { border: 0; border-top: 1px solid #ccc; margin: 1em 0; }
+pre { font-family: inherit; white-space: pre-wrap; word-wrap: break-word; }
+.attachments { color: #666; }
+</style>
+</head>
+<body>
+<h1>{{.Subject}}</h1>
+<table class="headers">
+{{range .Headers}}<tr><th>{{.Name}}</th><td>{{.Value}}</td></tr>
+{{end}}</table>
+<hr>
+{{if .HTML}}<div class="body">{{.HTML}}</div>{{else}}<pre>{{.Text}}</pre>{{end}}
+{{if .Attachments}}<hr>
+<div class="attachments">Attachments:<ul>
+{{range .Attachments}}<li>{{.}}</li>
+{{end}}</ul></div>{{end}}
+</body>
+</html>
+`))
+
+// page renders the message as the HTML page PDFs are made from.
+func (p printed) page() ([]byte, error) {
+	var b bytes.Buffer
+	if err := printTemplate.Execute(&b, p); err != nil {
+		return nil, fmt.Errorf("unable to render message: %v", err)
+	}
+	return b.Bytes(), nil
+}
+
+// printableHTML keeps what a message's HTML shows, to be put in the print
+// page: the document's own html, head and body tags, and anything that
+// runs or loads other documents, are dropped.
+func printableHTML(s string) string {
+	var b strings.Builder
+	z := html.NewTokenizer(strings.NewReader(s))
+	skip := 0
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			return b.String()
+		}
+		name, _ := z.TagName()
+		switch string(name) {
+		case "script", "noscript", "title", "iframe", "object", "embed":
+			switch tt {
+			case html.StartTagToken:
+				skip++
+			case html.EndTagToken:
+				if skip > 0 {
+					skip--
+				}
+			}
+			continue
+		case "html", "head", "body", "meta", "link", "base":
+			continue
+		}
+		if skip == 0 && tt != html.DoctypeToken {
+			b.Write(z.Raw())
+		}
+	}
+}
+
+// printName names the file a message is printed to: its date and subject,
+// so a directory of receipts sorts by date, and its ID, so printing it
+// again replaces it.
+func printName(msg *gmail.Message, ext string) string {
+	subject := mimepart.DecodeHeader(mimepart.Header(msg.Payload, "Subject"))
+	subject = strings.Join(strings.FieldsFunc(subject, func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsControl(r) || strings.ContainsRune(`/\:*?"<>|`, r)
+	}), " ")
+	if runes := []rune(subject); len(runes) > 60 {
+		subject = strings.TrimSpace(string(runes[:60]))
+	}
+	name := time.UnixMilli(msg.InternalDate).Format("2006-01-02")
+	if subject != "" {
+		name += " " + subject
+	}
+	return name + " " + msg.Id + "." + ext
+}
+
+// addPrinted writes a message fetched in full format as a text or PDF file.
+func (x *exporter) addPrinted(msg *gmail.Message) error {
+	p := newPrinted(msg)
+	path := filepath.Join(x.path, printName(msg, x.format))
+	if x.format == exportText {
+		if err := os.WriteFile(path, []byte(p.text()), 0o600); err != nil {
+			return err
+		}
+		x.last = path
+		return nil
+	}
+
+	page, err := p.page()
+	if err != nil {
+		return err
+	}
+	if err := writePDF(x.pdf, page, path); err != nil {
+		return err
+	}
+	x.last = path
+	return nil
+}
+
+// writePDF renders an HTML page to a PDF at path with converter, which is
+// wkhtmltopdf or a headless browser.
+func writePDF(converter string, page []byte, path string) error {
+	var cmd *exec.Cmd
+	if filepath.Base(converter) == "wkhtmltopdf" {
+		cmd = exec.Command(converter, "--quiet", "--encoding", "utf-8", "--disable-javascript",
+			"--disable-local-file-access", "-", path)
+		cmd.Stdin = bytes.NewReader(page)
+	} else {
+		// The browser reads the page from a file and gets a profile of its
+		// own, so it doesn't hand the job to a browser already running.
+		dir, err := os.MkdirTemp("", "gmail-tui-print-*")
+		if err != nil {
+			return fmt.Errorf("unable to create print directory: %v", err)
+		}
+		defer os.RemoveAll(dir)
+		file := filepath.Join(dir, "message.html")
+		if err := os.WriteFile(file, page, 0o600); err != nil {
+			return fmt.Errorf("unable to write print page: %v", err)
+		}
+		cmd = exec.Command(converter, "--headless", "--disable-gpu", "--no-pdf-header-footer",
+			"--print-to-pdf-no-header", "--user-data-dir="+filepath.Join(dir, "profile"),
+			"--print-to-pdf="+path, "file://"+file)
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s failed: %v: %s", filepath.Base(converter), err, bytes.TrimSpace(out))
+	}
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("%s wrote no PDF", filepath.Base(converter))
+	}
+	return nil
+}
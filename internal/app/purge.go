@@ -0,0 +1,143 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/googleapi"
+)
+
+// maxBatchDelete is the most messages Gmail deletes in one request.
+const maxBatchDelete = 1000
+
+// purgeWord must be typed to confirm emptying a folder.
+const purgeWord = "delete"
+
+// purgeFolders are the lists that can be emptied, by place.
+var purgeFolders = map[string]struct{ label, title string }{
+	"trash": {"TRASH", "Trash"},
+	"spam":  {"SPAM", "Spam"},
+}
+
+// purgePrompt asks to type purgeWord before every message in a folder is
+// deleted for good.
+type purgePrompt struct {
+	place string
+	ids   []string
+	input textinput.Model
+}
+
+// purgeCountedMsg carries the messages found in a folder to be emptied.
+type purgeCountedMsg struct {
+	place string
+	ids   []string
+}
+
+// purgedMsg reports the messages deleted from a folder.
+type purgedMsg struct {
+	place string
+	ids   []string
+}
+
+// startPurge lists every message in Trash or Spam, then asks for
+// confirmation before deleting them.
+func (m Model) startPurge(place string) (Model, tea.Cmd) {
+	f, ok := purgeFolders[place]
+	if !ok {
+		m.status = "Only Trash and Spam can be emptied; :goto trash or spam first"
+		return m, nil
+	}
+	m.status = "Counting messages in " + f.title + "..."
+	return m, func() tea.Msg {
+		var ids []string
+		err := m.gmailSvc.Users.Messages.List("me").LabelIds(f.label).IncludeSpamTrash(true).
+			MaxResults(500).Pages(m.ctx, func(r *gmail.ListMessagesResponse) error {
+			for _, msg := range r.Messages {
+				ids = append(ids, msg.Id)
+			}
+			return nil
+		})
+		if err != nil {
+			return errMsg(fmt.Errorf("unable to list %s: %w", f.title, err))
+		}
+		return purgeCountedMsg{place: place, ids: ids}
+	}
+}
+
+func (m Model) updatePurgeCounted(msg purgeCountedMsg) (Model, tea.Cmd) {
+	f := purgeFolders[msg.place]
+	if len(msg.ids) == 0 {
+		m.status = f.title + " is already empty"
+		return m, nil
+	}
+	m.status = ""
+	ti := textinput.New()
+	ti.Prompt = fmt.Sprintf("Permanently delete all %d messages in %s? This cannot be undone. Type %q to confirm: ", len(msg.ids), f.title, purgeWord)
+	m.purge = &purgePrompt{place: msg.place, ids: msg.ids, input: ti}
+	return m, m.purge.input.Focus()
+}
+
+func (m Model) updatePurge(msg tea.KeyMsg) (Model, tea.Cmd) {
+	p := m.purge
+	switch {
+	case key.Matches(msg, m.keys.Back):
+		m.purge = nil
+		m.status = "Cancelled"
+		return m, nil
+	case key.Matches(msg, m.keys.Select):
+		m.purge = nil
+		if strings.TrimSpace(p.input.Value()) != purgeWord {
+			m.status = "Cancelled; nothing was deleted"
+			return m, nil
+		}
+		m.status = fmt.Sprintf("Deleting %d messages...", len(p.ids))
+		return m, m.purgeMessages(p.place, p.ids)
+	}
+
+	var cmd tea.Cmd
+	m.purge.input, cmd = p.input.Update(msg)
+	return m, cmd
+}
+
+// purgeMessages deletes messages for good, in batches.
+func (m Model) purgeMessages(place string, ids []string) tea.Cmd {
+	f := purgeFolders[place]
+	return func() tea.Msg {
+		for start := 0; start < len(ids); start += maxBatchDelete {
+			batch := ids[start:min(start+maxBatchDelete, len(ids))]
+			err := m.gmailSvc.Users.Messages.BatchDelete("me", &gmail.BatchDeleteMessagesRequest{Ids: batch}).Context(m.ctx).Do()
+			var gerr *googleapi.Error
+			if errors.As(err, &gerr) && gerr.Code == http.StatusForbidden {
+				return errMsg(fmt.Errorf("unable to empty %s: deleting messages needs full Gmail access; sign in again to grant it (see Security in the README)", f.title))
+			}
+			if err != nil {
+				return errMsg(fmt.Errorf("unable to empty %s after %d messages: %w", f.title, start, err))
+			}
+		}
+		return purgedMsg{place: place, ids: ids}
+	}
+}
+
+func (m Model) updatePurged(msg purgedMsg) (Model, tea.Cmd) {
+	m.status = fmt.Sprintf("Deleted %d messages from %s", len(msg.ids), purgeFolders[msg.place].title)
+	// Changes to the deleted messages can no longer be undone.
+	m.undo = slices.DeleteFunc(slices.Clone(m.undo), func(s undoStep) bool {
+		return slices.Contains(msg.ids, s.email.ID)
+	})
+	if m.place != msg.place || m.state != listView {
+		return m, nil
+	}
+	m.loading = true
+	return m.refreshEmails()
+}
+
+func (m Model) purgePrompt() string {
+	return m.purge.input.View() + "\nenter: confirm • esc: cancel"
+}
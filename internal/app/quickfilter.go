@@ -0,0 +1,58 @@
+package app
+
+import (
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// quickFilters narrow the list to messages matching an extra query term.
+// They are listed in the order the title shows them.
+var quickFilters = []struct{ name, query string }{
+	{"unread", "is:unread"},
+	{"starred", "is:starred"},
+	{"attachments", "has:attachment"},
+}
+
+// listQuery is the list's query with the quick filters in force added.
+func (m Model) listQuery() string {
+	terms := []string{m.query}
+	for _, f := range quickFilters {
+		if slices.Contains(m.quick, f.name) {
+			terms = append(terms, f.query)
+		}
+	}
+	return strings.TrimSpace(strings.Join(terms, " "))
+}
+
+// toggleQuick turns a quick filter on or off and reloads the list.
+func (m Model) toggleQuick(name string) (Model, tea.Cmd) {
+	if i := slices.Index(m.quick, name); i >= 0 {
+		m.quick = slices.Delete(slices.Clone(m.quick), i, i+1)
+	} else {
+		m.quick = append(slices.Clone(m.quick), name)
+	}
+	return m.applyQuick()
+}
+
+// clearQuick turns off every quick filter.
+func (m Model) clearQuick() (Model, tea.Cmd) {
+	m.quick = nil
+	return m.applyQuick()
+}
+
+func (m Model) applyQuick() (Model, tea.Cmd) {
+	var names []string
+	for _, f := range quickFilters {
+		if slices.Contains(m.quick, f.name) {
+			names = append(names, f.name)
+		}
+	}
+	m.list.Title = m.title
+	if len(names) > 0 {
+		m.list.Title += " [" + strings.Join(names, ", ") + " only • esc: all]"
+	}
+	m.loading = true
+	return m.refreshEmails()
+}
@@ -0,0 +1,160 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"golang.org/x/oauth2"
+
+	"gmail-tui/internal/auth"
+	"gmail-tui/internal/ui/view"
+)
+
+// reauthPrompt asks to sign in again after the token was revoked. Once the
+// user agrees, signIn is the flow in progress: cancel is set while the
+// browser flow is waiting, and pasting while the code is being entered in
+// --no-browser mode.
+type reauthPrompt struct {
+	signIn  *auth.SignIn
+	cancel  context.CancelFunc
+	pasting bool
+	code    textinput.Model
+	err     error
+}
+
+func newCodeInput() textinput.Model {
+	ti := textinput.New()
+	ti.Prompt = "Code: "
+	ti.Placeholder = "http://127.0.0.1:8080/?state=...&code=..."
+	ti.Focus()
+	return ti
+}
+
+type reauthMsg struct {
+	err error
+}
+
+// reauthorize runs the browser flow and, on success, saves the new token and
+// hands it to the clients already in use.
+func (m Model) reauthorize(ctx context.Context, s *auth.SignIn) tea.Cmd {
+	return func() tea.Msg {
+		tok, err := s.FromBrowser(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return reauthMsg{err: err}
+		}
+		return m.finishReauth(tok)
+	}
+}
+
+// exchangeCode signs in with a code pasted in --no-browser mode.
+func (m Model) exchangeCode(s *auth.SignIn, code string) tea.Cmd {
+	return func() tea.Msg {
+		tok, err := s.Exchange(m.ctx, code)
+		if err != nil {
+			return reauthMsg{err: err}
+		}
+		return m.finishReauth(tok)
+	}
+}
+
+func (m Model) finishReauth(tok *oauth2.Token) tea.Msg {
+	return reauthMsg{err: m.auth.Save(tok)}
+}
+
+func (m Model) updateReauth(msg tea.KeyMsg) (Model, tea.Cmd) {
+	if m.reauth.cancel != nil {
+		if key.Matches(msg, m.keys.Back) {
+			m.reauth.cancel()
+			m.reauth = &reauthPrompt{}
+		}
+		return m, nil
+	}
+
+	if m.reauth.pasting {
+		if !m.reauth.code.Focused() {
+			return m, nil
+		}
+		switch msg.Type {
+		case tea.KeyEnter:
+			code, err := m.reauth.signIn.PastedCode(m.reauth.code.Value())
+			m.reauth.err = err
+			if err != nil {
+				return m, nil
+			}
+			m.reauth.code.Blur()
+			return m, m.exchangeCode(m.reauth.signIn, code)
+		case tea.KeyEsc:
+			m.reauth = &reauthPrompt{}
+			return m, nil
+		case tea.KeyCtrlC:
+			return m, tea.Quit
+		}
+		var cmd tea.Cmd
+		m.reauth.code, cmd = m.reauth.code.Update(msg)
+		return m, cmd
+	}
+
+	switch {
+	case key.Matches(msg, m.keys.Confirm):
+		s, err := m.auth.NewSignIn()
+		if err != nil {
+			m.reauth = nil
+			m = m.fail(err)
+			return m, nil
+		}
+		if m.auth.NoBrowser() {
+			m.reauth = &reauthPrompt{signIn: s, pasting: true, code: newCodeInput()}
+			return m, textinput.Blink
+		}
+		ctx, cancel := context.WithCancel(m.ctx)
+		m.reauth = &reauthPrompt{signIn: s, cancel: cancel}
+		return m, m.reauthorize(ctx, s)
+	case key.Matches(msg, m.keys.Quit), key.Matches(msg, m.keys.Cancel):
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+func (m Model) reauthView() string {
+	if m.reauth.pasting {
+		problem := ""
+		if m.reauth.err != nil {
+			problem = m.reauth.err.Error()
+		}
+		return fmt.Sprintf(
+			"\n%s\n\n%s\n\n%s\n\n%s\n\n%s\n%s\n\n%s",
+			view.TitleStyle.Render("Sign in"),
+			view.InfoStyle.Render("Open this URL in a browser on any machine:"),
+			m.reauth.signIn.AuthURL(),
+			view.InfoStyle.Render(auth.PasteInstructions),
+			m.reauth.code.View(),
+			problem,
+			view.HelpStyle.Render("enter: sign in • esc: cancel"),
+		)
+	}
+	if m.reauth.cancel == nil {
+		how := "y: sign in with the browser • n: quit"
+		if m.auth.NoBrowser() {
+			how = "y: sign in • n: quit"
+		}
+		return fmt.Sprintf(
+			"\n%s\n\n%s\n\n%s",
+			view.TitleStyle.Render("Signed out"),
+			view.InfoStyle.Render("Gmail access has expired or was revoked. Sign in again to carry on where you left off."),
+			view.HelpStyle.Render(how),
+		)
+	}
+	return fmt.Sprintf(
+		"\n%s\n\n%s\n\n%s\n\n%s",
+		view.TitleStyle.Render("Waiting for sign-in"),
+		view.InfoStyle.Render("Finish signing in in your browser. If it did not open, visit:"),
+		m.reauth.signIn.AuthURL(),
+		view.HelpStyle.Render("esc: cancel"),
+	)
+}
@@ -0,0 +1,149 @@
+package app
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+
+	"gmail-tui/internal/mimepart"
+	"gmail-tui/internal/ui/compose"
+)
+
+var warningStyle = lipgloss.NewStyle().
+	Foreground(lipgloss.Color("#E67E22")).
+	Bold(true)
+
+// commonDomains are mail providers whose names are often mistyped. A
+// recipient at a domain one slip away from one of them is flagged before
+// sending.
+var commonDomains = []string{
+	"gmail.com", "googlemail.com", "yahoo.com", "hotmail.com", "outlook.com",
+	"live.com", "msn.com", "icloud.com", "me.com", "aol.com", "proton.me",
+	"protonmail.com", "gmx.com", "gmx.de", "web.de", "yandex.com", "mail.com",
+	"fastmail.com", "zoho.com",
+}
+
+// recipientsByHeader returns the recipients of d by header. If a header can't
+// be read, bad is it and its value.
+func recipientsByHeader(d compose.Draft) (recipients map[string][]*mail.Address, bad string) {
+	recipients = map[string][]*mail.Address{}
+	for _, h := range []struct{ name, value string }{{"To", d.To}, {"Cc", d.Cc}, {"Bcc", d.Bcc}} {
+		value := strings.TrimRight(strings.TrimSpace(h.value), ",")
+		if value == "" {
+			continue
+		}
+		addrs, err := mimepart.AddressParser.ParseList(value)
+		if err != nil {
+			return nil, h.name + ": " + value
+		}
+		recipients[h.name] = addrs
+	}
+	return recipients, ""
+}
+
+// addressDomain is the lowercased domain of an address.
+func addressDomain(addr string) string {
+	_, domain, _ := strings.Cut(addr, "@")
+	return strings.ToLower(domain)
+}
+
+// internal reports whether domain is one of the internal domains, or a
+// subdomain of one.
+func (c Config) internal(domain string) bool {
+	for _, d := range c.InternalDomains {
+		d = strings.ToLower(strings.TrimPrefix(d, "@"))
+		if domain == d || strings.HasSuffix(domain, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+// recipientWarnings lists what deserves a second look before d is sent:
+// domains that look like a typo for a common one, and, with internal
+// domains set, recipients outside them.
+func (c Config) recipientWarnings(d compose.Draft) []string {
+	recipients, bad := recipientsByHeader(d)
+	if bad != "" {
+		return nil
+	}
+	var warnings, external []string
+	seen := map[string]bool{}
+	for _, h := range []string{"To", "Cc", "Bcc"} {
+		for _, a := range recipients[h] {
+			domain := addressDomain(a.Address)
+			if c.internal(domain) {
+				continue
+			}
+			if len(c.InternalDomains) > 0 {
+				external = append(external, a.Address)
+			}
+			if seen[domain] {
+				continue
+			}
+			seen[domain] = true
+			if typo, ok := likelyTypo(domain); ok {
+				warnings = append(warnings, fmt.Sprintf("%s looks like a typo for %s (%s)", domain, typo, a.Address))
+			}
+		}
+	}
+	if len(external) > 0 {
+		warnings = append(warnings, fmt.Sprintf("Outside %s: %s", strings.Join(c.InternalDomains, ", "), strings.Join(external, ", ")))
+	}
+	return warnings
+}
+
+// likelyTypo returns the common domain that domain is one slip away from:
+// a letter missing, added, changed or swapped with the next.
+func likelyTypo(domain string) (string, bool) {
+	for _, common := range commonDomains {
+		if domain == common {
+			return "", false
+		}
+	}
+	for _, common := range commonDomains {
+		if editDistance(domain, common) == 1 {
+			return common, true
+		}
+	}
+	return "", false
+}
+
+// editDistance is the optimal string alignment distance between a and b:
+// how many insertions, deletions, substitutions and swaps of adjacent
+// letters turn one into the other.
+func editDistance(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	d := make([][]int, len(s)+1)
+	for i := range d {
+		d[i] = make([]int, len(t)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && s[i-1] == t[j-2] && s[i-2] == t[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(s)][len(t)]
+}
+
+// warningLines renders warnings for a prompt.
+func warningLines(warnings []string) string {
+	var lines []string
+	for _, w := range warnings {
+		lines = append(lines, warningStyle.Render("! "+w))
+	}
+	return strings.Join(lines, "\n")
+}
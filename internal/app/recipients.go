@@ -0,0 +1,107 @@
+package app
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"gmail-tui/internal/ui/compose"
+)
+
+const maxSuggestions = 5
+
+func newAddressInput() textinput.Model {
+	ti := textinput.New()
+	ti.Placeholder = "name or address, comma separated"
+	return ti
+}
+
+func fieldValue(d compose.Draft, field string) string {
+	switch field {
+	case "To":
+		return d.To
+	case "Cc":
+		return d.Cc
+	case "Bcc":
+		return d.Bcc
+	}
+	return ""
+}
+
+// lastAddress splits a comma-separated field into the completed addresses
+// and the one still being typed.
+func lastAddress(value string) (string, string) {
+	i := strings.LastIndex(value, ",")
+	if i < 0 {
+		return "", strings.TrimSpace(value)
+	}
+	return value[:i+1] + " ", strings.TrimSpace(value[i+1:])
+}
+
+func (m Model) editAddress(field string) (Model, tea.Cmd) {
+	m.addressField = field
+	m.addressInput.Prompt = field + ": "
+	m.addressInput.SetValue(fieldValue(m.compose.Snapshot(), field))
+	m.addressInput.CursorEnd()
+	m.suggestions = nil
+	m.suggestion = -1
+	return m, m.addressInput.Focus()
+}
+
+func (m Model) updateAddress(msg tea.KeyMsg) (Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, m.keys.SuggestNext) && len(m.suggestions) > 0:
+		m.suggestion = (m.suggestion + 1) % len(m.suggestions)
+		return m, nil
+	case key.Matches(msg, m.keys.SuggestPrev) && len(m.suggestions) > 0:
+		m.suggestion--
+		if m.suggestion < 0 {
+			m.suggestion = len(m.suggestions) - 1
+		}
+		return m, nil
+	case key.Matches(msg, m.keys.Select) && m.suggestion >= 0:
+		done, _ := lastAddress(m.addressInput.Value())
+		m.addressInput.SetValue(done + m.suggestions[m.suggestion].String() + ", ")
+		m.addressInput.CursorEnd()
+		m.suggestions = nil
+		m.suggestion = -1
+		return m, nil
+	case key.Matches(msg, m.keys.Select):
+		value := strings.TrimRight(strings.TrimSpace(m.addressInput.Value()), ",")
+		field := m.addressField
+		m.addressField = ""
+		m.suggestions = nil
+		if err := m.compose.SetField(field, value); err != nil {
+			m = m.fail(err)
+			return m, nil
+		}
+		m.loading = true
+		return m, m.saveCompose(m.compose, "Recipients updated")
+	case key.Matches(msg, m.keys.Back):
+		m.addressField = ""
+		m.suggestions = nil
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.addressInput, cmd = m.addressInput.Update(msg)
+	_, query := lastAddress(m.addressInput.Value())
+	m.suggestions = m.contacts.search(query, maxSuggestions)
+	m.suggestion = -1
+	return m, cmd
+}
+
+func (m Model) addressView() string {
+	var b strings.Builder
+	b.WriteString(m.addressInput.View())
+	for i, c := range m.suggestions {
+		marker := "  "
+		if i == m.suggestion {
+			marker = "> "
+		}
+		b.WriteString("\n" + marker + c.String())
+	}
+	return b.String()
+}
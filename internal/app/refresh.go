@@ -0,0 +1,83 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// refreshTickMsg asks for the list to be refreshed in the background.
+type refreshTickMsg struct{}
+
+// refreshFailedMsg reports a background refresh that failed. Unlike a
+// refresh the user asked for, it only shows in the status line.
+type refreshFailedMsg struct {
+	err error
+}
+
+func refreshTick(interval time.Duration) tea.Cmd {
+	return tea.Tick(interval, func(time.Time) tea.Msg {
+		return refreshTickMsg{}
+	})
+}
+
+func (c Config) refreshInterval() time.Duration {
+	return time.Duration(c.RefreshSeconds) * time.Second
+}
+
+// nextRefresh waits to reload the current list as often as its mail rule,
+// or refresh_seconds, says.
+func (m Model) nextRefresh() tea.Cmd {
+	if interval := m.config.listRefreshInterval(m.query); interval > 0 {
+		return refreshTick(interval)
+	}
+	return refreshTick(refreshIdleInterval)
+}
+
+// autoRefresh reloads the current list without the loading screen while
+// the list or a message is shown. The cursor stays on the same message and
+// a filter being typed is kept.
+func (m Model) autoRefresh() (Model, tea.Cmd) {
+	tick := m.nextRefresh()
+	if m.config.listRefreshInterval(m.query) == 0 {
+		return m, tick
+	}
+	if m.loading || (m.state != listView && m.state != messageView) {
+		return m, tick
+	}
+	if m.cancelFetch != nil {
+		m.cancelFetch()
+	}
+	ctx, cancel := context.WithCancel(m.ctx)
+	m.cancelFetch = cancel
+	cmds := []tea.Cmd{tick, m.pollEmails(ctx), m.writeStatus}
+	if len(m.config.tabs()) > 0 {
+		cmds = append(cmds, m.fetchTabCounts)
+	}
+	m, sync := m.syncInBackground(false)
+	return m, tea.Batch(append(cmds, sync)...)
+}
+
+// pollEmails loads the list like fetchEmails, reporting a failure as a
+// refreshFailedMsg.
+func (m Model) pollEmails(ctx context.Context) tea.Cmd {
+	return func() tea.Msg {
+		msg := m.loadEmails(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err, ok := msg.(errMsg); ok {
+			return refreshFailedMsg{err: err}
+		}
+		return msg
+	}
+}
+
+func (m Model) updateRefreshFailed(msg refreshFailedMsg) Model {
+	if m.status == "" {
+		m.status = fmt.Sprintf("Auto-refresh failed: %v", msg.err)
+	}
+	return m
+}
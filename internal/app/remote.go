@@ -0,0 +1,148 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"google.golang.org/api/gmail/v1"
+
+	"gmail-tui/internal/mimepart"
+)
+
+// maxRemoteImage bounds the size of a remote image that is loaded.
+const maxRemoteImage = 10 << 20
+
+// remoteClient loads remote images. It is separate from the API client so
+// the OAuth token is never sent to a sender's servers.
+var remoteClient = &http.Client{Timeout: 30 * time.Second}
+
+// remoteContent is what a message's HTML would load from the web when
+// shown. Nothing is loaded until asked for with the images key, and
+// tracking pixels are never loaded at all.
+type remoteContent struct {
+	images   []string
+	trackers int
+	// receipt is the address a read receipt was asked to be sent to.
+	// Receipts are never sent.
+	receipt string
+}
+
+// parseRemote finds the remote images in the HTML part of a message,
+// setting aside the ones that only exist to report the message was opened.
+func parseRemote(payload *gmail.MessagePart) remoteContent {
+	var rc remoteContent
+	rc.receipt = mimepart.Header(payload, "Disposition-Notification-To")
+	part := mimepart.Find(payload, "text/html")
+	if part == nil {
+		return rc
+	}
+	data, ok := mimepart.Data(part)
+	if !ok {
+		return rc
+	}
+
+	seen := map[string]bool{}
+	z := html.NewTokenizer(strings.NewReader(mimepart.ToUTF8(data, mimepart.Charset(part))))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return rc
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			if string(name) != "img" {
+				continue
+			}
+			attrs := map[string]string{}
+			for hasAttr {
+				var k, v []byte
+				k, v, hasAttr = z.TagAttr()
+				attrs[string(k)] = string(v)
+			}
+			src := attrs["src"]
+			if !strings.HasPrefix(src, "http://") && !strings.HasPrefix(src, "https://") {
+				continue
+			}
+			if isTracker(attrs) {
+				rc.trackers++
+			} else if !seen[src] {
+				seen[src] = true
+				rc.images = append(rc.images, src)
+			}
+		}
+	}
+}
+
+// isTracker reports whether an image is a tracking pixel: one or zero
+// pixels in size, or hidden.
+func isTracker(attrs map[string]string) bool {
+	if _, ok := attrs["hidden"]; ok {
+		return true
+	}
+	tiny := func(s string) bool {
+		n, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(s), "px"))
+		return err == nil && n <= 1
+	}
+	if tiny(attrs["width"]) || tiny(attrs["height"]) {
+		return true
+	}
+	style := strings.ToLower(strings.ReplaceAll(attrs["style"], " ", ""))
+	for _, hide := range []string{"display:none", "visibility:hidden", "width:1px", "height:1px", "width:0", "height:0"} {
+		if strings.Contains(style, hide) {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteNote is shown after the date of an open message when it has remote
+// content that was blocked or asked for a read receipt.
+func remoteNote(rc remoteContent) string {
+	var note string
+	if n := len(rc.images) + rc.trackers; n > 0 {
+		note = fmt.Sprintf(" • Remote content blocked (%d items", n)
+		if rc.trackers > 0 {
+			note += fmt.Sprintf(", %d tracking", rc.trackers)
+		}
+		note += ")"
+		if len(rc.images) > 0 {
+			note += " • I: load"
+		}
+	}
+	if rc.receipt != "" {
+		note += " • Read receipt not sent"
+	}
+	return note
+}
+
+// fetchRemoteImage downloads an image the message links to, without
+// cookies or credentials.
+func fetchRemoteImage(ctx context.Context, src string) (messageImage, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, src, nil)
+	if err != nil {
+		return messageImage{}, fmt.Errorf("unable to load %s: %v", src, err)
+	}
+	resp, err := remoteClient.Do(req)
+	if err != nil {
+		return messageImage{}, fmt.Errorf("unable to load %s: %v", src, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return messageImage{}, fmt.Errorf("unable to load %s: %s", src, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxRemoteImage))
+	if err != nil {
+		return messageImage{}, fmt.Errorf("unable to load %s: %v", src, err)
+	}
+	name := path.Base(resp.Request.URL.Path)
+	if name == "/" || name == "." {
+		name = resp.Request.URL.Host
+	}
+	return messageImage{name: name, data: data}, nil
+}
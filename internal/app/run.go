@@ -0,0 +1,207 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/option"
+	"google.golang.org/api/people/v1"
+
+	"gmail-tui/internal/auth"
+	"gmail-tui/internal/backend"
+	"gmail-tui/internal/network"
+	"gmail-tui/internal/ui/compose"
+	"gmail-tui/internal/ui/view"
+)
+
+// Options are the command line flags of gmail-tui.
+type Options struct {
+	// NoBrowser signs in by pasting a code instead of opening a local
+	// browser.
+	NoBrowser bool
+	// Inline draws in the normal screen instead of the alternate one.
+	Inline bool
+	// ReducedMotion shows a static marker instead of the spinner.
+	ReducedMotion bool
+	// Accessible announces changes as plain lines for screen readers.
+	Accessible bool
+	// Demo uses the sample mailbox instead of a mail account.
+	Demo bool
+	// Debug logs API calls, UI messages and errors to debug.log.
+	Debug bool
+}
+
+// Run runs the subcommand in args, or the interactive client when there is
+// none or it is a mailto: URL.
+func Run(opts Options, args []string) error {
+	var debugFile *os.File
+	if opts.Debug {
+		f, err := openDebugLog()
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		debugFile = f
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if opts.Demo {
+		cfg.Backend = "demo"
+	}
+	view.ListDateFormat = cfg.DateFormat
+	view.Accessible = cfg.Accessible || opts.Accessible
+
+	var command string
+	if len(args) > 0 {
+		command = args[0]
+	}
+	var mailto *compose.Draft
+	switch {
+	case command == "install-mailto-handler":
+		return installMailtoHandler()
+	case command == "status" && cfg.Backend != "demo":
+		done, err := cachedStatusCommand(args[1:])
+		if err != nil || done {
+			return err
+		}
+	case strings.HasPrefix(strings.ToLower(command), "mailto:"):
+		d, err := parseMailto(command)
+		if err != nil {
+			return err
+		}
+		mailto = &d
+	}
+
+	if err := cfg.openVault(); err != nil {
+		return err
+	}
+	// Requests to senders' servers go through the proxy like the rest.
+	web, err := network.New(cfg.Network)
+	if err != nil {
+		return err
+	}
+	remoteClient.Transport = web.Transport()
+	unsubscribeClient.Transport = remoteClient.Transport
+	srv, psrv, client, source, err := getServices(cfg, opts.NoBrowser)
+	if err != nil {
+		return err
+	}
+	mail, err := backend.New(cfg.Config, srv, client)
+	if err != nil {
+		return err
+	}
+	usingDaemon := false
+	if command != "daemon" {
+		mail, usingDaemon = cfg.daemonProvider(mail)
+	}
+	mail = debugProvider{mail}
+
+	// Quitting cancels ctx, aborting any network work still in flight.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if command != "" && mailto == nil {
+		m := Model{ctx: ctx, gmailSvc: srv, mail: mail, httpClient: client, config: cfg, daemon: usingDaemon}
+		return m.runCommand(args)
+	}
+
+	ob, err := loadOutbox(cfg.vault)
+	if err != nil {
+		return err
+	}
+
+	m := initialModel(ctx, srv, mail, psrv, client, source, cfg, ob)
+	if cfg.RestoreSession {
+		m = m.withSession(loadSessions(cfg.vault)[cfg.account()])
+	}
+	m.mailto = mailto
+	m.daemon = usingDaemon
+	switch {
+	case cfg.LocalIndex && cfg.vault.Enabled():
+		// The index holds message bodies and can't be encrypted.
+		m.status = "local_index is ignored while encrypt_cache is set, as the index would keep messages unencrypted"
+	case cfg.LocalIndex:
+		if m.index, err = openMailIndex(); err != nil {
+			m.status = err.Error()
+		}
+		defer m.index.close()
+	}
+	m.glamourStyle = glamourStyle()
+	if m.plugins, err = loadPlugins(m); err != nil {
+		m.status = err.Error()
+	}
+	m.keys.Plugins = m.plugins.keyBindings()
+	if opts.ReducedMotion || view.Accessible {
+		m = m.withoutAnimation()
+	}
+	teaOpts := []tea.ProgramOption{tea.WithContext(ctx)}
+	if !opts.Inline && !view.Accessible {
+		teaOpts = append(teaOpts, tea.WithAltScreen())
+	}
+	if cfg.Mouse {
+		teaOpts = append(teaOpts, tea.WithMouseCellMotion())
+	}
+	p := tea.NewProgram(m, teaOpts...)
+	restoreLog := quietLog(debugFile)
+	final, err := p.Run()
+	restoreLog()
+	logMetrics()
+	if err != nil {
+		debugLog.Error("exit", "err", err)
+		return err
+	}
+	if m, ok := final.(Model); ok && cfg.RestoreSession {
+		if err := saveSession(m); err != nil {
+			log.Print(err)
+		}
+	}
+	return nil
+}
+
+func getServices(cfg Config, noBrowser bool) (*gmail.Service, *people.Service, *http.Client, *auth.Source, error) {
+	if !cfg.GmailAPI() {
+		srv, psrv, client, err := backend.NoGmailServices()
+		return srv, psrv, client, &auth.Source{}, err
+	}
+	b, err := loadCredentials()
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	oauthConfig, err := google.ConfigFromJSON(b,
+		gmail.MailGoogleComScope,
+		gmail.GmailComposeScope,
+		gmail.GmailSettingsBasicScope,
+		people.ContactsReadonlyScope,
+	)
+	if err != nil {
+		return nil, nil, nil, nil, fmt.Errorf("unable to parse client secret file to config: %v", err)
+	}
+
+	client, source, err := auth.NewClient(oauthConfig, cfg.Config, cfg.vault, noBrowser)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	client.Transport = backend.NewTransport(metricsTransport{debugTransport{client.Transport}}, cfg.RequestTimeout())
+	srv, err := gmail.NewService(context.Background(), option.WithHTTPClient(client))
+	if err != nil {
+		return nil, nil, nil, nil, fmt.Errorf("unable to retrieve Gmail client: %v", err)
+	}
+
+	psrv, err := people.NewService(context.Background(), option.WithHTTPClient(client))
+	if err != nil {
+		return nil, nil, nil, nil, fmt.Errorf("unable to retrieve People client: %v", err)
+	}
+
+	return srv, psrv, client, source, nil
+}
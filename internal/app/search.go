@@ -0,0 +1,174 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"gmail-tui/internal/ui/view"
+)
+
+var (
+	matchStyle = lipgloss.NewStyle().
+			Background(lipgloss.Color("#5C5C00")).
+			Foreground(lipgloss.Color("#FFFFFF"))
+
+	currentMatchStyle = lipgloss.NewStyle().
+				Background(lipgloss.Color("#FF75B7")).
+				Foreground(lipgloss.Color("#000000"))
+)
+
+// searchMatch is an occurrence of the search query in the message body,
+// as byte offsets within a line.
+type searchMatch struct {
+	line, start, end int
+}
+
+func newSearchInput() textinput.Model {
+	ti := textinput.New()
+	ti.Prompt = "/"
+	ti.Placeholder = "search message"
+	return ti
+}
+
+// findMatches returns every case-insensitive occurrence of query in text.
+func findMatches(text, query string) []searchMatch {
+	if query == "" {
+		return nil
+	}
+
+	var matches []searchMatch
+	q := strings.ToLower(query)
+	for i, line := range strings.Split(text, "\n") {
+		haystack, needle := strings.ToLower(line), q
+		if len(haystack) != len(line) {
+			// Lower-casing changed byte offsets; fall back to an exact
+			// match so the offsets stay valid.
+			haystack, needle = line, query
+		}
+		for off := 0; ; {
+			j := strings.Index(haystack[off:], needle)
+			if j < 0 {
+				break
+			}
+			start := off + j
+			matches = append(matches, searchMatch{line: i, start: start, end: start + len(needle)})
+			off = start + len(needle)
+		}
+	}
+	return matches
+}
+
+// highlightMatches styles every match in text, with the current one
+// standing out.
+func highlightMatches(text string, matches []searchMatch, current int) string {
+	if len(matches) == 0 {
+		return text
+	}
+
+	lines := strings.Split(text, "\n")
+	// Work backwards so earlier offsets on the same line stay valid.
+	for i := len(matches) - 1; i >= 0; i-- {
+		mt := matches[i]
+		line := lines[mt.line]
+		style := matchStyle
+		if i == current {
+			style = currentMatchStyle
+		}
+		match := style.Render(line[mt.start:mt.end])
+		if view.Accessible {
+			// Brackets mark the matches for those who can't see the
+			// colors; the current one gets two.
+			match = "[" + match + "]"
+			if i == current {
+				match = "[" + match + "]"
+			}
+		}
+		lines[mt.line] = line[:mt.start] + match + line[mt.end:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// messageContent is the text shown in the reading viewport. Lines with a
+// search match are left unstyled so the highlight shows.
+func (m Model) messageContent() string {
+	lines := m.bodyLines()
+	out := strings.Split(highlightMatches(m.bodyText(), m.matches, m.match), "\n")
+	matched := map[int]bool{}
+	for _, mt := range m.matches {
+		matched[mt.line] = true
+	}
+	for i, l := range lines {
+		if !matched[i] {
+			out[i] = l.Styled()
+		}
+	}
+	if m.source == "" && m.selectedMail != nil && m.selectedMail.cut > 0 {
+		out = append(out, "", view.InfoStyle.Render(m.cutNotice(m.selectedMail.cut)))
+	}
+	return strings.Join(out, "\n")
+}
+
+// toggleQuotes expands or collapses quoted text and the signature, keeping
+// the search matches in step with the new layout.
+func (m Model) toggleQuotes() Model {
+	m.expandQuotes = !m.expandQuotes
+	m.matches = findMatches(m.bodyText(), m.searchQuery)
+	m.match = min(m.match, max(len(m.matches)-1, 0))
+	return m.showMatch()
+}
+
+// showMatch scrolls the viewport so the current match is visible.
+func (m Model) showMatch() Model {
+	m.viewport.SetContent(m.messageContent())
+	if m.match >= 0 && m.match < len(m.matches) {
+		line := m.matches[m.match].line
+		if line < m.viewport.YOffset || line >= m.viewport.YOffset+m.viewport.Height {
+			m.viewport.SetYOffset(line - m.viewport.Height/2)
+		}
+	}
+	return m
+}
+
+func (m Model) clearSearch() Model {
+	m.searching = false
+	m.searchQuery = ""
+	m.matches = nil
+	m.match = 0
+	return m
+}
+
+func (m Model) updateSearch(msg tea.KeyMsg) (Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, m.keys.Select):
+		m.searching = false
+		m.searchQuery = m.searchInput.Value()
+		m.matches = findMatches(m.bodyText(), m.searchQuery)
+		m.match = 0
+		if len(m.matches) == 0 && m.searchQuery != "" {
+			m.status = fmt.Sprintf("No matches for %q", m.searchQuery)
+		}
+		return m.showMatch(), nil
+	case key.Matches(msg, m.keys.Back):
+		m = m.clearSearch()
+		return m.showMatch(), nil
+	}
+
+	var cmd tea.Cmd
+	m.searchInput, cmd = m.searchInput.Update(msg)
+	return m, cmd
+}
+
+func (m Model) searchStatus() string {
+	if m.searching {
+		return m.searchInput.View()
+	}
+	if len(m.matches) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("/%s (%d/%d) • n/N: next/prev match • esc: clear", m.searchQuery, m.match+1, len(m.matches))
+}
@@ -0,0 +1,170 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gmail-tui/internal/config"
+	"gmail-tui/internal/vault"
+)
+
+// maxSearchHistory caps how many recent Gmail queries are remembered.
+// Pinned queries are kept however many there are.
+const maxSearchHistory = 100
+
+// searchHistory is the Gmail queries run with :search, oldest first, and
+// the ones pinned to be offered first whatever was searched since.
+type searchHistory struct {
+	Recent []string `json:"recent,omitempty"`
+	Pinned []string `json:"pinned,omitempty"`
+
+	vault *vault.Vault
+}
+
+func searchHistoryPath() (string, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "search_history.json"), nil
+}
+
+// loadSearchHistory reads the search history, decrypting it with v. A
+// missing or unreadable file just means there is none yet.
+func loadSearchHistory(v *vault.Vault) searchHistory {
+	h := searchHistory{vault: v}
+	path, err := searchHistoryPath()
+	if err != nil {
+		return h
+	}
+	data, err := v.ReadFile(path)
+	if err != nil {
+		return h
+	}
+	json.Unmarshal(data, &h)
+	return h
+}
+
+// save writes the history so it survives restarts. Failures only lose
+// history, so they are ignored, as for the command history.
+func (h searchHistory) save() {
+	path, err := searchHistoryPath()
+	if err != nil {
+		return
+	}
+	data, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return
+	}
+	h.vault.WriteFile(path, data, 0600)
+}
+
+// add records query as the latest search, moving it to the end if it was
+// run before.
+func (h searchHistory) add(query string) searchHistory {
+	recent := slices.DeleteFunc(slices.Clone(h.Recent), func(q string) bool { return q == query })
+	recent = append(recent, query)
+	if len(recent) > maxSearchHistory {
+		recent = recent[len(recent)-maxSearchHistory:]
+	}
+	h.Recent = recent
+	return h
+}
+
+// pin adds query to the pinned searches, or takes it off them if unpin is
+// set. It reports whether anything changed.
+func (h searchHistory) pin(query string, unpin bool) (searchHistory, bool) {
+	i := slices.Index(h.Pinned, query)
+	switch {
+	case unpin && i >= 0:
+		h.Pinned = slices.Delete(slices.Clone(h.Pinned), i, i+1)
+	case !unpin && i < 0:
+		h.Pinned = append(slices.Clone(h.Pinned), query)
+	default:
+		return h, false
+	}
+	return h, true
+}
+
+// suggest returns the pinned and then the recent searches containing text,
+// ignoring case, most recent first.
+func (h searchHistory) suggest(text string) []string {
+	text = strings.ToLower(text)
+	var out []string
+	add := func(q string) {
+		if strings.Contains(strings.ToLower(q), text) && !slices.Contains(out, q) {
+			out = append(out, q)
+		}
+	}
+	for _, q := range h.Pinned {
+		add(q)
+	}
+	for i := len(h.Recent) - 1; i >= 0; i-- {
+		add(h.Recent[i])
+	}
+	return out
+}
+
+// searchQueryPrefix is how a Gmail search starts at the : prompt.
+const searchQueryPrefix = "search "
+
+// isSearchLine reports whether a palette line is a :search, whose history
+// up and down walk instead of the command history.
+func isSearchLine(line string) bool {
+	return strings.HasPrefix(line, searchQueryPrefix)
+}
+
+// browseSearches puts the previous search, or the next one, at the prompt.
+// Past the newest it is back to an empty :search.
+func (m Model) browseSearches(older bool) Model {
+	p := m.palette
+	recent := m.searches.Recent
+	switch {
+	case older && p.Query > 0:
+		p.Query--
+	case !older && p.Query < len(recent):
+		p.Query++
+	default:
+		return m
+	}
+	query := ""
+	if p.Query < len(recent) {
+		query = recent[p.Query]
+	}
+	p.Input.SetValue(searchQueryPrefix + query)
+	p.Input.CursorEnd()
+	return m
+}
+
+// pinSearch pins query, or the current list's query if it is empty, or
+// unpins it.
+func (m Model) pinSearch(query string, unpin bool) Model {
+	if query == "" {
+		query = m.query
+	}
+	if query == "" {
+		m.status = "No search to pin; give a query or run :search first"
+		return m
+	}
+	searches, changed := m.searches.pin(query, unpin)
+	switch {
+	case !changed && unpin:
+		m.status = fmt.Sprintf("%q is not pinned", query)
+	case !changed:
+		m.status = fmt.Sprintf("%q is already pinned", query)
+	case unpin:
+		m.status = "Unpinned " + query
+	default:
+		m.status = "Pinned " + query
+	}
+	m.searches = searches
+	m.searches.save()
+	return m
+}
@@ -0,0 +1,243 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"gmail-tui/internal/ui/compose"
+	"gmail-tui/internal/ui/view"
+)
+
+// pendingSend is a confirmed message held back for the undo-send window.
+// It stays a Gmail draft until the deadline passes.
+type pendingSend struct {
+	session  *compose.Session
+	deadline time.Time
+}
+
+type sendTickMsg struct {
+	session *compose.Session
+}
+
+// sentMsg reports that the message behind session was sent, or, when
+// queued is set, that sending failed transiently and it is in the outbox.
+// quit is set when the message was sent on the way out.
+type sentMsg struct {
+	session *compose.Session
+	queued  bool
+	quit    bool
+}
+
+func sendTick(c *compose.Session) tea.Cmd {
+	return tea.Tick(time.Second, func(time.Time) tea.Msg {
+		return sendTickMsg{session: c}
+	})
+}
+
+// sendDraft sends the saved draft behind c, which also removes it from the
+// Drafts folder. If Gmail cannot be reached the message is queued in the
+// outbox and retried; one that could not be uploaded at all is queued as
+// it stands, to be uploaded first.
+func (m Model) sendDraft(c *compose.Session) tea.Cmd {
+	return func() tea.Msg {
+		// A draft last saved while offline gets another try here.
+		if err := c.Save(m.ctx, m.mail); err != nil {
+			if !isTransient(err) {
+				return errMsg(err)
+			}
+			return m.queueUnsent(c, c.Snapshot(), "", err)
+		}
+		d := c.Snapshot()
+		// The saved draft is replaced with its signed or encrypted form
+		// just before it is sent.
+		if d.Sign || d.Encrypt {
+			raw, err := rawMessage(m.ctx, m.mail, d)
+			if err != nil {
+				return errMsg(err)
+			}
+			id, err := m.mail.SaveDraft(m.ctx, d.ID, raw)
+			if err != nil {
+				if !isTransient(err) {
+					return errMsg(err)
+				}
+				return m.queueUnsent(c, d, raw, err)
+			}
+			d.ID = id
+		}
+		sent, err := m.mail.SendDraft(m.ctx, d.ID)
+		if err != nil && isTransient(err) {
+			if qerr := m.queueFailed(d, err); qerr != nil {
+				return errMsg(qerr)
+			}
+			return sentMsg{session: c, queued: true}
+		}
+		if err != nil {
+			return errMsg(fmt.Errorf("unable to send message: %w", err))
+		}
+		runHook("send", m.config.Hooks.OnSend, sentEvent(sent, d))
+		if !d.FollowUp.IsZero() {
+			// The message is already sent, so a reminder that can't be
+			// set is not worth failing over.
+			m.labelSentFollowUp(sent.Id, d.FollowUp)
+		}
+		return sentMsg{session: c}
+	}
+}
+
+// queueUnsent puts d, which could not be uploaded, into the outbox as raw,
+// building raw first if it is "".
+func (m Model) queueUnsent(c *compose.Session, d compose.Draft, raw string, err error) tea.Msg {
+	if raw == "" {
+		var rerr error
+		if raw, rerr = rawMessage(m.ctx, m.mail, d); rerr != nil {
+			return errMsg(rerr)
+		}
+	}
+	if qerr := m.queueRaw(d, raw, err); qerr != nil {
+		return errMsg(qerr)
+	}
+	return sentMsg{session: c, queued: true}
+}
+
+// confirmSend leaves the compose view and either sends straight away or
+// starts the undo-send countdown.
+func (m Model) confirmSend() (Model, tea.Cmd) {
+	c := m.compose
+	m.confirming = false
+	m.compose = nil
+	m.state = m.composeReturn
+
+	delay := m.config.UndoSendDelay()
+	if delay <= 0 {
+		m.status = "Sending..."
+		return m, m.sendDraft(c)
+	}
+
+	m.pending = &pendingSend{session: c, deadline: time.Now().Add(delay)}
+	return m, sendTick(c)
+}
+
+// undoSend cancels the pending send and reopens the message in the compose
+// view.
+func (m Model) undoSend() Model {
+	c := m.pending.session
+	m.pending = nil
+	m.composeReturn = m.state
+	m.compose = c
+	m.state = composeView
+	m.viewport.Width = m.width - 4
+	m.viewport.Height = m.height - 7
+	m.viewport.SetContent(m.composeContent())
+	m.status = "Sending undone"
+	return m
+}
+
+func (m Model) updateConfirmSend(msg tea.KeyMsg) (Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, m.keys.Confirm):
+		return m.confirmSend()
+	case key.Matches(msg, m.keys.Cancel):
+		m.confirming = false
+	}
+	return m, nil
+}
+
+func (m Model) confirmSendView() string {
+	d := m.compose.Snapshot()
+
+	subject := d.Subject
+	if subject == "" {
+		subject = "(no subject)"
+	}
+
+	lines := []string{
+		view.TitleStyle.Render("Send this message?"),
+		"",
+		view.InfoStyle.Render(fmt.Sprintf("To: %s", d.To)),
+	}
+	if d.Cc != "" {
+		lines = append(lines, view.InfoStyle.Render(fmt.Sprintf("Cc: %s", d.Cc)))
+	}
+	if d.Bcc != "" {
+		lines = append(lines, view.InfoStyle.Render(fmt.Sprintf("Bcc: %s", d.Bcc)))
+	}
+	lines = append(lines, view.InfoStyle.Render(fmt.Sprintf("Subject: %s", subject)))
+	lines = append(lines, view.InfoStyle.Render(fmt.Sprintf("Attachments: %d", len(d.Attachments))))
+
+	help := "y: send • n: cancel"
+	if warnings := m.config.sendWarnings(d); len(warnings) > 0 {
+		lines = append(lines, "", warningLines(warnings))
+		help = "y: send anyway • n: cancel"
+	}
+
+	return fmt.Sprintf(
+		"\n%s\n\n%s%s",
+		strings.Join(lines, "\n"),
+		m.statusLine(),
+		view.HelpStyle.Render(help),
+	)
+}
+
+// sendWarnings lists what deserves a second look before d is sent.
+func (c Config) sendWarnings(d compose.Draft) []string {
+	warnings := c.recipientWarnings(d)
+	if k, ok := c.missingAttachment(d); ok {
+		warnings = append(warnings, fmt.Sprintf("Mentions an attachment (%q) but has none", k))
+	}
+	return warnings
+}
+
+// quit exits, unless a message is still waiting out the undo-send window,
+// in which case it asks whether to send it first.
+func (m Model) quit() (Model, tea.Cmd) {
+	if m.pending == nil {
+		return m, tea.Quit
+	}
+	m.quitting = true
+	return m, nil
+}
+
+func (m Model) updateQuitPrompt(msg tea.KeyMsg) (Model, tea.Cmd) {
+	m.quitting = false
+	switch {
+	case key.Matches(msg, m.keys.Confirm):
+		c := m.pending.session
+		m.pending = nil
+		m.status = "Sending..."
+		send := m.sendDraft(c)
+		return m, func() tea.Msg {
+			msg := send()
+			if sent, ok := msg.(sentMsg); ok {
+				sent.quit = true
+				return sent
+			}
+			return msg
+		}
+	case key.Matches(msg, m.keys.Discard):
+		// Not sending leaves the message where undo would have put it.
+		m.pending.session.Remove()
+		m.pending = nil
+		return m, tea.Quit
+	}
+	m.status = "Cancelled"
+	return m, nil
+}
+
+func (m Model) quitPrompt() string {
+	return "A message is waiting to be sent.\ny: send it now and quit • x: don't send, keep it in Drafts and quit • n: cancel"
+}
+
+func (m Model) pendingStatus() string {
+	if m.pending == nil {
+		return ""
+	}
+	left := time.Until(m.pending.deadline).Round(time.Second)
+	if left < 0 {
+		left = 0
+	}
+	return fmt.Sprintf("Sending in %s • u: undo", left)
+}
@@ -0,0 +1,242 @@
+package app
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"google.golang.org/api/gmail/v1"
+
+	"gmail-tui/internal/backend"
+	"gmail-tui/internal/config"
+	"gmail-tui/internal/ui/compose"
+)
+
+// newTestModel returns a Model on the demo mailbox, with the state and
+// config directories kept out of the user's.
+func newTestModel(t *testing.T) (Model, backend.Provider) {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("XDG_STATE_HOME", dir)
+	t.Setenv("XDG_CACHE_HOME", dir)
+
+	cfg := Config{Config: config.Default()}
+	cfg.Backend = "demo"
+	srv, psrv, client, err := backend.NoGmailServices()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mail, err := backend.New(cfg.Config, srv, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ob, err := loadOutbox(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	return initialModel(ctx, srv, mail, psrv, client, nil, cfg, ob), mail
+}
+
+func keyPress(s string) tea.KeyMsg {
+	if s == "ctrl+c" {
+		return tea.KeyMsg{Type: tea.KeyCtrlC}
+	}
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)}
+}
+
+// withPendingSend saves a draft and starts its undo-send countdown.
+func withPendingSend(t *testing.T, m Model, mail backend.Provider) (Model, string) {
+	t.Helper()
+	raw := "To: ada@example.com\r\nSubject: Minutes\r\n\r\nAttached.\r\n"
+	id, err := mail.SaveDraft(m.ctx, "", base64.URLEncoding.EncodeToString([]byte(raw)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := compose.NewSession(compose.Draft{ID: id, To: "ada@example.com", Subject: "Minutes", Body: "Attached."}, protectEntity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(c.Remove)
+	m.pending = &pendingSend{session: c, deadline: time.Now().Add(time.Minute)}
+	return m, id
+}
+
+func update(t *testing.T, m Model, msg tea.Msg) (Model, tea.Cmd) {
+	t.Helper()
+	next, cmd := m.Update(msg)
+	return next.(Model), cmd
+}
+
+func isQuit(cmd tea.Cmd) bool {
+	if cmd == nil {
+		return false
+	}
+	_, ok := cmd().(tea.QuitMsg)
+	return ok
+}
+
+func TestQuitWithoutPendingSend(t *testing.T) {
+	m, _ := newTestModel(t)
+	if _, cmd := update(t, m, keyPress("Q")); !isQuit(cmd) {
+		t.Fatal("Q did not quit")
+	}
+}
+
+func TestQuitDuringUndoSendSendsNow(t *testing.T) {
+	m, mail := newTestModel(t)
+	m, id := withPendingSend(t, m, mail)
+
+	m, cmd := update(t, m, keyPress("Q"))
+	if cmd != nil {
+		t.Fatal("Q quit with a message waiting to be sent")
+	}
+	if !strings.Contains(m.View(), "A message is waiting to be sent") {
+		t.Fatalf("no quit prompt in the view:\n%s", m.View())
+	}
+
+	m, cmd = update(t, m, keyPress("y"))
+	if m.pending != nil {
+		t.Fatal("the send is still pending")
+	}
+	if cmd == nil {
+		t.Fatal("y did not send")
+	}
+	msg := cmd()
+	if _, ok := msg.(sentMsg); !ok {
+		t.Fatalf("sending returned %#v", msg)
+	}
+	if _, cmd = update(t, m, msg); !isQuit(cmd) {
+		t.Fatal("did not quit after sending")
+	}
+
+	sent, err := mail.Get(m.ctx, id, "metadata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sent.LabelIds) != 1 || sent.LabelIds[0] != "SENT" {
+		t.Errorf("message has labels %v, want SENT", sent.LabelIds)
+	}
+}
+
+func TestQuitDuringUndoSendKeepsDraft(t *testing.T) {
+	m, mail := newTestModel(t)
+	m, id := withPendingSend(t, m, mail)
+
+	m, _ = update(t, m, keyPress("ctrl+c"))
+	m, cmd := update(t, m, keyPress("x"))
+	if !isQuit(cmd) {
+		t.Fatal("x did not quit")
+	}
+	draft, err := mail.Get(m.ctx, id, "metadata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(draft.LabelIds) != 1 || draft.LabelIds[0] != "DRAFT" {
+		t.Errorf("message has labels %v, want DRAFT", draft.LabelIds)
+	}
+}
+
+func TestQuitDuringUndoSendCancelled(t *testing.T) {
+	m, mail := newTestModel(t)
+	m, _ = withPendingSend(t, m, mail)
+
+	m, _ = update(t, m, keyPress("Q"))
+	m, cmd := update(t, m, keyPress("n"))
+	if cmd != nil {
+		t.Fatal("n did something other than cancel")
+	}
+	if m.pending == nil || m.quitting {
+		t.Fatal("cancelling did not go back to the countdown")
+	}
+}
+
+// offlineMail fails to save or send drafts while down, as Gmail does when
+// it can't be reached, and records what it sends.
+type offlineMail struct {
+	backend.Provider
+	down bool
+	sent []string
+}
+
+func (p *offlineMail) SaveDraft(ctx context.Context, id, raw string) (string, error) {
+	if p.down {
+		return "", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("network is unreachable")}
+	}
+	return p.Provider.SaveDraft(ctx, id, raw)
+}
+
+func (p *offlineMail) SendDraft(ctx context.Context, id string) (*gmail.Message, error) {
+	if p.down {
+		return nil, &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("network is unreachable")}
+	}
+	p.sent = append(p.sent, id)
+	return p.Provider.SendDraft(ctx, id)
+}
+
+// sendQueued makes everything in the outbox due and dispatches it.
+func sendQueued(t *testing.T, m Model) {
+	t.Helper()
+	err := m.outbox.update(func(entries []outboxEntry) []outboxEntry {
+		for i := range entries {
+			entries[i].NextAttempt = time.Time{}
+		}
+		return entries
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg := m.dispatchOutbox().(outboxSentMsg); msg.sent != 1 {
+		t.Fatalf("sent %d messages, want 1", msg.sent)
+	}
+	if left := m.outbox.list(); len(left) != 0 {
+		t.Fatalf("outbox still holds %v", left)
+	}
+}
+
+func TestMessageWrittenOfflineIsQueued(t *testing.T) {
+	m, mail := newTestModel(t)
+	off := &offlineMail{Provider: mail, down: true}
+	m.mail = off
+
+	c, err := compose.NewSession(compose.Draft{}, protectEntity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(c.Remove)
+	if err := c.Write(compose.Text(compose.Draft{To: "ada@example.com", Subject: "Minutes", Body: "Attached."})); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Save(m.ctx, off); err == nil {
+		t.Fatal("saved while offline")
+	}
+	if why := unsendable(c); why != "" {
+		t.Fatalf("unsendable: %s", why)
+	}
+
+	if msg, ok := m.sendDraft(c)().(sentMsg); !ok || !msg.queued {
+		t.Fatalf("sending offline returned %#v", msg)
+	}
+	queued := m.outbox.list()
+	if len(queued) != 1 || queued[0].Raw == "" || queued[0].Subject != "Minutes" {
+		t.Fatalf("outbox holds %+v", queued)
+	}
+
+	off.down = false
+	sendQueued(t, m)
+	sent, err := mail.Get(m.ctx, off.sent[0], "metadata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sent.LabelIds) != 1 || sent.LabelIds[0] != "SENT" {
+		t.Errorf("message has labels %v, want SENT", sent.LabelIds)
+	}
+}
@@ -0,0 +1,271 @@
+package app
+
+import (
+	"fmt"
+	"html"
+	"net/mail"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"google.golang.org/api/gmail/v1"
+
+	"gmail-tui/internal/mimepart"
+	"gmail-tui/internal/ui/compose"
+	"gmail-tui/internal/ui/view"
+)
+
+// Alias is a send-as identity: the account's own address or one added
+// under "Send mail as" in Gmail settings.
+type Alias struct {
+	*gmail.SendAs
+
+	// local is the signature config.json gives the address, used instead
+	// of its Gmail one; nil if there is none.
+	local *string
+}
+
+func (a Alias) Title() string { return a.address() }
+func (a Alias) Description() string {
+	sig := strings.TrimSpace(a.signature())
+	if sig == "" {
+		return "No signature"
+	}
+	first, _, _ := strings.Cut(sig, "\n")
+	if a.local != nil {
+		return "Signature (config.json): " + first
+	}
+	return "Signature: " + first
+}
+func (a Alias) FilterValue() string { return a.SendAsEmail }
+
+// address is the alias formatted for a From header.
+func (a Alias) address() string {
+	if a.DisplayName == "" {
+		return a.SendAsEmail
+	}
+	return (&mail.Address{Name: a.DisplayName, Address: a.SendAsEmail}).String()
+}
+
+// signature is the alias's signature as plain text. Gmail stores
+// signatures as HTML.
+func (a Alias) signature() string {
+	if a.local != nil {
+		return *a.local
+	}
+	return mimepart.HTMLToText(a.Signature)
+}
+
+// signatureBlock is the text added to a message body for the alias.
+func (a Alias) signatureBlock() string {
+	return signatureBlock(a.signature())
+}
+
+type aliasesMsg []Alias
+
+type signatureEditedMsg struct {
+	alias Alias
+	path  string
+	err   error
+}
+
+type signatureSavedMsg string
+
+func newSignaturesList(delegate list.ItemDelegate) list.Model {
+	l := list.New([]list.Item{}, delegate, 40, 20)
+	l.SetShowTitle(true)
+	l.SetFilteringEnabled(true)
+	l.SetShowHelp(true)
+	l.DisableQuitKeybindings()
+	l.Title = "Signatures"
+	l.Styles.Title = view.TitleStyle
+	return l
+}
+
+// fetchAliases lists the send-as identities. Failure is not fatal: compose
+// then leaves From empty and Gmail uses the account's address.
+func (m Model) fetchAliases() tea.Msg {
+	r, err := m.gmailSvc.Users.Settings.SendAs.List("me").Context(m.ctx).Do()
+	if err != nil {
+		return aliasesMsg(nil)
+	}
+	var aliases []Alias
+	for _, a := range r.SendAs {
+		aliases = append(aliases, m.config.alias(a))
+	}
+	return aliasesMsg(aliases)
+}
+
+func (m Model) defaultAlias() (Alias, bool) {
+	for _, a := range m.aliases {
+		if a.IsDefault {
+			return a, true
+		}
+	}
+	if len(m.aliases) > 0 {
+		return m.aliases[0], true
+	}
+	return Alias{}, false
+}
+
+// aliasFor returns the alias whose address appears in a From header.
+func (m Model) aliasFor(from string) (Alias, bool) {
+	addr := senderAddress(from)
+	for _, a := range m.aliases {
+		if strings.EqualFold(a.SendAsEmail, addr) {
+			return a, true
+		}
+	}
+	return Alias{}, false
+}
+
+// withAlias sends d from a, replacing the signature of the alias it was
+// previously from if the body still has it.
+func (m Model) withAlias(d compose.Draft, a Alias) compose.Draft {
+	if prev, ok := m.aliasFor(d.From); ok {
+		d.Body = removeSignature(d.Body, prev.signatureBlock())
+	}
+	d.From = a.address()
+	d.Body = m.config.addSignature(d.Body, a.signatureBlock())
+	return d
+}
+
+func (m Model) openAliasPicker() Model {
+	if len(m.aliases) == 0 {
+		m.status = "No send-as addresses"
+		return m
+	}
+	m.pickingAlias = true
+	m.aliasCursor = 0
+	if a, ok := m.aliasFor(m.compose.Snapshot().From); ok {
+		for i := range m.aliases {
+			if m.aliases[i].SendAsEmail == a.SendAsEmail {
+				m.aliasCursor = i
+			}
+		}
+	}
+	return m
+}
+
+func (m Model) updateAliasPicker(msg tea.KeyMsg) (Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, m.keys.Back):
+		m.pickingAlias = false
+	case key.Matches(msg, m.keys.Down):
+		if m.aliasCursor < len(m.aliases)-1 {
+			m.aliasCursor++
+		}
+	case key.Matches(msg, m.keys.Up):
+		if m.aliasCursor > 0 {
+			m.aliasCursor--
+		}
+	case key.Matches(msg, m.keys.Select):
+		m.pickingAlias = false
+		d := m.withAlias(m.compose.Snapshot(), m.aliases[m.aliasCursor])
+		if err := m.compose.SetDraft(d); err != nil {
+			m = m.fail(err)
+			return m, nil
+		}
+		m.loading = true
+		return m, m.saveCompose(m.compose, "Sending as "+d.From)
+	}
+	return m, nil
+}
+
+func (m Model) aliasPickerView() string {
+	lines := []string{"Send as:"}
+	for i, a := range m.aliases {
+		marker := "  "
+		if i == m.aliasCursor {
+			marker = "> "
+		}
+		lines = append(lines, marker+a.address())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// editSignature opens the alias's signature, as plain text, in the user's
+// editor.
+func (m Model) editSignature(a Alias) (Model, tea.Cmd) {
+	f, err := os.CreateTemp("", "gmail-tui-signature-*.txt")
+	if err != nil {
+		m = m.fail(fmt.Errorf("unable to create signature file: %v", err))
+		return m, nil
+	}
+	defer f.Close()
+	if _, err := f.WriteString(a.signature()); err != nil {
+		m = m.fail(fmt.Errorf("unable to write signature file: %v", err))
+		return m, nil
+	}
+
+	path := f.Name()
+	return m, tea.ExecProcess(compose.EditorCommand(path), func(err error) tea.Msg {
+		return signatureEditedMsg{alias: a, path: path, err: err}
+	})
+}
+
+// signatureHTML converts a plain-text signature to the HTML Gmail stores.
+func signatureHTML(text string) string {
+	text = strings.TrimRight(text, "\n")
+	return strings.ReplaceAll(html.EscapeString(text), "\n", "<br>")
+}
+
+func (m Model) saveSignature(msg signatureEditedMsg) tea.Cmd {
+	return func() tea.Msg {
+		defer os.Remove(msg.path)
+		data, err := os.ReadFile(msg.path)
+		if err != nil {
+			return errMsg(fmt.Errorf("unable to read signature file: %w", err))
+		}
+		if strings.TrimRight(string(data), "\n") == strings.TrimRight(msg.alias.signature(), "\n") {
+			return signatureSavedMsg("Signature unchanged")
+		}
+
+		patch := &gmail.SendAs{
+			Signature:       signatureHTML(string(data)),
+			ForceSendFields: []string{"Signature"},
+		}
+		if _, err := m.gmailSvc.Users.Settings.SendAs.Patch("me", msg.alias.SendAsEmail, patch).Context(m.ctx).Do(); err != nil {
+			return errMsg(fmt.Errorf("unable to update signature: %w", err))
+		}
+		return signatureSavedMsg("Signature updated for " + msg.alias.SendAsEmail)
+	}
+}
+
+func (m Model) updateSignatures(msg tea.KeyMsg) (Model, tea.Cmd) {
+	if m.signatures.FilterState() != list.Filtering {
+		switch {
+		case key.Matches(msg, m.keys.Back) && m.signatures.FilterState() == list.Unfiltered:
+			m.state = listView
+			return m, nil
+		case key.Matches(msg, m.keys.Quit):
+			return m.quit()
+		case key.Matches(msg, m.keys.Fetch):
+			m.loading = true
+			return m, m.fetchAliases
+		case key.Matches(msg, m.keys.Select):
+			a, ok := m.signatures.SelectedItem().(Alias)
+			switch {
+			case ok && a.local != nil:
+				m.status = "The signature for " + a.SendAsEmail + " is set in config.json"
+			case ok:
+				return m.editSignature(a)
+			}
+			return m, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	m.signatures, cmd = m.signatures.Update(msg)
+	return m, cmd
+}
+
+func (m Model) signaturesView() string {
+	return fmt.Sprintf(
+		"%s\n\n%s",
+		m.signatures.View(),
+		view.HelpStyle.Render(m.statusLine()+"enter: edit signature • r: refresh • esc: back"),
+	)
+}
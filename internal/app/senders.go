@@ -0,0 +1,324 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"google.golang.org/api/gmail/v1"
+
+	"gmail-tui/internal/ui/view"
+	"gmail-tui/internal/vault"
+)
+
+// maxSenderMessages caps the messages remembered per sender.
+const maxSenderMessages = 500
+
+var profileStyle = lipgloss.NewStyle().
+	Border(lipgloss.RoundedBorder()).
+	BorderForeground(lipgloss.Color("#FF75B7")).
+	Padding(1, 2)
+
+// senderStats aggregates the messages gmail-tui has listed by sender, so a
+// sender's profile shows straight away. It is cached on disk like the
+// contact index.
+type senderStats struct {
+	vault   *vault.Vault
+	mu      sync.Mutex
+	senders map[string]*senderRecord
+}
+
+type senderRecord struct {
+	Name     string                   `json:"name,omitempty"`
+	Messages map[string]senderMessage `json:"messages"`
+	// LastSent is when you last wrote to the sender, if known.
+	LastSent time.Time `json:"last_sent,omitempty"`
+}
+
+type senderMessage struct {
+	Date   time.Time `json:"date"`
+	Labels []string  `json:"labels,omitempty"`
+}
+
+// senderProfile is the open profile popup. labels names the label IDs in
+// the profile; it is nil until they are loaded.
+type senderProfile struct {
+	email   Email
+	address string
+	labels  labelIndex
+	loading bool
+}
+
+// senderProfileMsg reports that a sender's stats were brought up to date.
+type senderProfileMsg struct {
+	address string
+	labels  labelIndex
+	err     error
+}
+
+func sendersCachePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gmail-tui", "senders.json"), nil
+}
+
+// loadSenderStats reads the cached stats. A missing or unreadable cache
+// just yields empty stats.
+func loadSenderStats(v *vault.Vault) *senderStats {
+	s := &senderStats{vault: v, senders: map[string]*senderRecord{}}
+	path, err := sendersCachePath()
+	if err != nil {
+		return s
+	}
+	data, err := v.ReadFile(path)
+	if err != nil {
+		return s
+	}
+	json.Unmarshal(data, &s.senders)
+	if s.senders == nil {
+		s.senders = map[string]*senderRecord{}
+	}
+	return s
+}
+
+func (s *senderStats) save() error {
+	path, err := sendersCachePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	data, err := json.Marshal(s.senders)
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := s.vault.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("unable to write senders cache: %v", err)
+	}
+	return nil
+}
+
+// record adds listed messages to their senders' stats, and reports whether
+// anything changed. Messages you sent are not counted.
+func (s *senderStats) record(emails []Email) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	changed := false
+	for _, e := range emails {
+		if e.hasLabel("SENT") || e.hasLabel("DRAFT") {
+			continue
+		}
+		addr := strings.ToLower(senderAddress(e.From))
+		if addr == "" {
+			continue
+		}
+		r := s.senders[addr]
+		if r == nil {
+			r = &senderRecord{Messages: map[string]senderMessage{}}
+			s.senders[addr] = r
+		}
+		if a, err := mail.ParseAddress(e.From); err == nil && a.Name != "" && a.Name != r.Name {
+			r.Name, changed = a.Name, true
+		}
+		msg := senderMessage{Date: e.Date, Labels: e.Labels}
+		if old, ok := r.Messages[e.ID]; ok && old.Date.Equal(msg.Date) && slices.Equal(old.Labels, msg.Labels) {
+			continue
+		}
+		r.Messages[e.ID] = msg
+		changed = true
+		if len(r.Messages) > maxSenderMessages {
+			r.dropOldest()
+		}
+	}
+	return changed
+}
+
+func (r *senderRecord) dropOldest() {
+	ids := make([]string, 0, len(r.Messages))
+	for id := range r.Messages {
+		ids = append(ids, id)
+	}
+	slices.SortFunc(ids, func(a, b string) int {
+		return r.Messages[b].Date.Compare(r.Messages[a].Date)
+	})
+	for _, id := range ids[maxSenderMessages:] {
+		delete(r.Messages, id)
+	}
+}
+
+func (s *senderStats) setLastSent(addr string, t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r := s.senders[strings.ToLower(addr)]; r != nil && t.After(r.LastSent) {
+		r.LastSent = t
+	}
+}
+
+// senderSummary is what the profile shows for a sender.
+type senderSummary struct {
+	name     string
+	count    int
+	labels   []string
+	counts   map[string]int
+	lastFrom time.Time
+	lastSent time.Time
+}
+
+func (s *senderStats) summary(addr string) senderSummary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sum := senderSummary{counts: map[string]int{}}
+	r := s.senders[strings.ToLower(addr)]
+	if r == nil {
+		return sum
+	}
+	sum.name, sum.count, sum.lastSent = r.Name, len(r.Messages), r.LastSent
+	for _, msg := range r.Messages {
+		if msg.Date.After(sum.lastFrom) {
+			sum.lastFrom = msg.Date
+		}
+		for _, l := range msg.Labels {
+			if l != "UNREAD" {
+				sum.counts[l]++
+			}
+		}
+	}
+	for l := range sum.counts {
+		sum.labels = append(sum.labels, l)
+	}
+	slices.SortFunc(sum.labels, func(a, b string) int {
+		if c := sum.counts[b] - sum.counts[a]; c != 0 {
+			return c
+		}
+		return strings.Compare(a, b)
+	})
+	return sum
+}
+
+// recordEmails adds listed messages to the sender stats in the background.
+func (m Model) recordEmails(emails []Email) {
+	if m.senders.record(emails) {
+		m.senders.save()
+	}
+}
+
+// openProfile shows the profile of e's sender from the cached stats, and
+// brings them up to date with the sender's latest mail.
+func (m Model) openProfile(e Email) (Model, tea.Cmd) {
+	addr := senderAddress(e.From)
+	m.profile = &senderProfile{email: e, address: addr, loading: true}
+	return m, func() tea.Msg {
+		labels, err := fetchLabels(m.ctx, m.gmailSvc)
+		if err != nil {
+			return senderProfileMsg{address: addr, err: err}
+		}
+		from, _, err := m.listEmails(m.ctx, "from:"+addr, 100)
+		if err != nil {
+			return senderProfileMsg{address: addr, labels: labels, err: err}
+		}
+		m.senders.record(from)
+		if sent, _, err := m.listEmails(m.ctx, "in:sent to:"+addr, 1); err == nil && len(sent) > 0 {
+			m.senders.setLastSent(addr, sent[0].Date)
+		}
+		m.senders.save()
+		return senderProfileMsg{address: addr, labels: labels}
+	}
+}
+
+func (m Model) applyProfile(msg senderProfileMsg) Model {
+	if m.profile == nil || m.profile.address != msg.address {
+		return m
+	}
+	p := *m.profile
+	p.loading = false
+	p.labels = msg.labels
+	m.profile = &p
+	if msg.err != nil {
+		m.status = msg.err.Error()
+	}
+	return m
+}
+
+func (m Model) updateProfile(msg tea.KeyMsg) (Model, tea.Cmd) {
+	p := m.profile
+	switch {
+	case key.Matches(msg, m.keys.Back), key.Matches(msg, m.keys.Profile):
+		m.profile = nil
+	case key.Matches(msg, m.keys.Quit):
+		m.profile = nil
+		return m.quit()
+	case key.Matches(msg, m.keys.MailFrom):
+		m.profile = nil
+		m.localSearch = m.index != nil
+		return m.showQuery("from:"+p.address, "From: "+p.address)
+	case key.Matches(msg, m.keys.Block):
+		m.profile = nil
+		return m.blockSender(p.email), nil
+	case key.Matches(msg, m.keys.NewFilter):
+		m.profile = nil
+		m, cmd := m.openFilters()
+		m, blink := m.openFilterForm(Filter{filter: &gmail.Filter{Criteria: &gmail.FilterCriteria{From: p.address}}})
+		return m, tea.Batch(cmd, blink)
+	}
+	return m, nil
+}
+
+func (m Model) profileView() string {
+	p := m.profile
+	sum := m.senders.summary(p.address)
+
+	title := p.address
+	if sum.name != "" {
+		title = sum.name + " <" + p.address + ">"
+	}
+	lines := []string{view.TitleStyle.Render(title), ""}
+
+	count := fmt.Sprint(sum.count)
+	if sum.count >= maxSenderMessages {
+		count += "+"
+	}
+	lines = append(lines, "Messages from them: "+count)
+	if !sum.lastFrom.IsZero() {
+		lines = append(lines, "Last message from them: "+view.FullDate(sum.lastFrom))
+	}
+	if !sum.lastSent.IsZero() {
+		lines = append(lines, "Last message to them: "+view.FullDate(sum.lastSent))
+	}
+	if len(sum.labels) > 0 && p.labels != nil {
+		var used []string
+		for _, l := range sum.labels[:min(len(sum.labels), 6)] {
+			used = append(used, fmt.Sprintf("%s (%d)", p.labels.name(l), sum.counts[l]))
+		}
+		lines = append(lines, "Labels: "+strings.Join(used, ", "))
+	}
+	if p.loading {
+		lines = append(lines, "", m.spinner.View()+" Updating...")
+	}
+	lines = append(lines, "", view.HelpStyle.Render("s: all mail from sender • B: block • n: new filter • esc: close"))
+
+	style := profileStyle
+	if view.Accessible {
+		style = style.BorderStyle(view.TableBorder())
+	}
+	box := style.Render(strings.Join(lines, "\n"))
+	if s := m.statusLine(); s != "" {
+		box += "\n" + view.HelpStyle.Render(s)
+	}
+	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, box)
+}
@@ -0,0 +1,150 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"slices"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"gmail-tui/internal/safefile"
+	"gmail-tui/internal/vault"
+)
+
+// session is where gmail-tui was when it last quit: the list shown, the
+// message under the cursor, and how far it was scrolled if it was open.
+// Sessions are kept per account, so trying --demo doesn't lose your place.
+type session struct {
+	Place    string   `json:"place,omitempty"`
+	Query    string   `json:"query,omitempty"`
+	Title    string   `json:"title"`
+	Quick    []string `json:"quick,omitempty"`
+	Selected string   `json:"selected,omitempty"`
+	// Index is where the cursor was, for when the selected message is no
+	// longer in the list.
+	Index  int  `json:"index,omitempty"`
+	Open   bool `json:"open,omitempty"`
+	Scroll int  `json:"scroll,omitempty"`
+}
+
+func sessionPath() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "session.json"), nil
+}
+
+// account names the mailbox sessions are saved for.
+func (c Config) account() string {
+	switch c.Backend {
+	case "imap":
+		return "imap:" + c.IMAP.Username + "@" + c.IMAP.Host
+	case "demo":
+		return "demo"
+	}
+	return fmt.Sprintf("gmail:%d", c.AccountIndex)
+}
+
+// loadSessions reads the saved sessions, decrypting them with v. A missing
+// or unreadable file just means there is nothing to restore.
+func loadSessions(v *vault.Vault) map[string]session {
+	sessions := map[string]session{}
+	path, err := sessionPath()
+	if err != nil {
+		return sessions
+	}
+	data, err := v.ReadFile(path)
+	if err != nil {
+		return sessions
+	}
+	json.Unmarshal(data, &sessions)
+	if sessions == nil {
+		sessions = map[string]session{}
+	}
+	return sessions
+}
+
+// saveSession remembers where m is for the next start.
+func saveSession(m Model) error {
+	s := session{
+		Place: m.place,
+		Query: m.query,
+		Title: m.title,
+		Quick: m.quick,
+		Index: m.list.Index(),
+	}
+	if e, ok := m.list.SelectedItem().(Email); ok {
+		s.Selected = e.ID
+	}
+	if m.state == messageView && m.selectedMail != nil {
+		s.Selected = m.selectedMail.ID
+		s.Open = true
+		s.Scroll = m.viewport.YOffset
+	}
+	// Before the first list loaded, the cursor is not where the user left
+	// it; keep what was to be restored.
+	if m.resume != nil {
+		s = *m.resume
+	}
+
+	path, err := sessionPath()
+	if err != nil {
+		return err
+	}
+	// Instances signed in to other accounts save to the same file.
+	unlock, err := safefile.Lock(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	sessions := loadSessions(m.config.vault)
+	sessions[m.config.account()] = s
+	data, err := json.MarshalIndent(sessions, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := m.config.vault.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("unable to save session: %v", err)
+	}
+	return nil
+}
+
+// withSession starts on the list the last session ended on. The cursor is
+// put back once the list has loaded.
+func (m Model) withSession(s session) Model {
+	if s.Title == "" {
+		return m
+	}
+	m.place, m.query, m.title, m.quick = s.Place, s.Query, s.Title, slices.Clone(s.Quick)
+	m.list.Title = s.Title
+	m.list.SetDelegate(m.emailDelegate())
+	m.resume = &s
+	return m
+}
+
+// resumeSession puts the cursor back on the message it was on, reopening it
+// if it was open. The scroll position is restored when its body arrives.
+func (m Model) resumeSession() (Model, tea.Cmd) {
+	s := *m.resume
+	m.resume = nil
+	i := m.emailIndex(s.Selected)
+	if i < 0 {
+		if n := len(m.list.Items()); n > 0 {
+			m.list.Select(min(s.Index, n-1))
+		}
+		return m, nil
+	}
+	m.list.Select(i)
+	if !s.Open || m.state != listView {
+		return m, nil
+	}
+	m, cmd := m.openSelected()
+	if m.selectedMail.loaded {
+		m.viewport.SetYOffset(s.Scroll)
+	} else {
+		m.resumeScroll = s.Scroll
+	}
+	return m, cmd
+}
@@ -0,0 +1,213 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"golang.org/x/oauth2/google"
+
+	"gmail-tui/internal/config"
+	"gmail-tui/internal/safefile"
+	"gmail-tui/internal/ui/view"
+)
+
+const credentialsFile = "credentials.json"
+
+// loadCredentials reads the OAuth client from the config directory, or from
+// the working directory where earlier versions expected it. If there is
+// none, the setup screen asks for one and saves it to the config directory.
+func loadCredentials() ([]byte, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, credentialsFile)
+
+	for _, p := range []string{path, credentialsFile} {
+		b, err := os.ReadFile(p)
+		if err == nil {
+			return b, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("unable to read client secret file: %v", err)
+		}
+	}
+
+	res, err := tea.NewProgram(newSetupModel(path), tea.WithAltScreen()).Run()
+	if err != nil {
+		return nil, err
+	}
+	setup := res.(setupModel)
+	if setup.credentials == nil {
+		return nil, errors.New("setup cancelled")
+	}
+	return setup.credentials, nil
+}
+
+const (
+	setupPath = iota
+	setupClientID
+	setupSecret
+	setupFields
+)
+
+// setupModel is the first-run screen shown when no OAuth client is
+// configured. It takes either the path to a downloaded credentials.json or
+// a pasted client ID and secret.
+type setupModel struct {
+	dest        string
+	inputs      []textinput.Model
+	focus       int
+	err         error
+	credentials []byte
+}
+
+func newSetupModel(dest string) setupModel {
+	m := setupModel{dest: dest}
+	for i, prompt := range []string{
+		"credentials.json: ",
+		"Client ID:        ",
+		"Client secret:    ",
+	} {
+		ti := textinput.New()
+		ti.Prompt = prompt
+		if i == setupPath {
+			ti.Placeholder = "~/Downloads/client_secret_....json"
+			ti.Focus()
+		}
+		if i == setupSecret {
+			ti.EchoMode = textinput.EchoPassword
+		}
+		m.inputs = append(m.inputs, ti)
+	}
+	return m
+}
+
+func (m setupModel) Init() tea.Cmd {
+	return textinput.Blink
+}
+
+func (m setupModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch msg.String() {
+		case "ctrl+c", "esc":
+			return m, tea.Quit
+		case "tab", "down":
+			return m, m.setFocus(m.focus + 1)
+		case "shift+tab", "up":
+			return m, m.setFocus(m.focus - 1)
+		case "enter":
+			b, err := m.save()
+			if err != nil {
+				m.err = err
+				return m, nil
+			}
+			m.credentials = b
+			return m, tea.Quit
+		}
+	}
+
+	var cmd tea.Cmd
+	m.inputs[m.focus], cmd = m.inputs[m.focus].Update(msg)
+	return m, cmd
+}
+
+func (m *setupModel) setFocus(i int) tea.Cmd {
+	m.focus = (i + setupFields) % setupFields
+	var cmd tea.Cmd
+	for j := range m.inputs {
+		if j == m.focus {
+			cmd = m.inputs[j].Focus()
+		} else {
+			m.inputs[j].Blur()
+		}
+	}
+	return cmd
+}
+
+func (m setupModel) value(i int) string {
+	return strings.TrimSpace(m.inputs[i].Value())
+}
+
+// save checks the credentials entered and writes them to the config
+// directory, returning their contents.
+func (m setupModel) save() ([]byte, error) {
+	var b []byte
+	switch {
+	case m.value(setupPath) != "":
+		path := m.value(setupPath)
+		if rest, ok := strings.CutPrefix(path, "~/"); ok {
+			if home, err := os.UserHomeDir(); err == nil {
+				path = filepath.Join(home, rest)
+			}
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read %s: %v", path, err)
+		}
+		b = data
+	case m.value(setupClientID) != "" && m.value(setupSecret) != "":
+		data, err := json.Marshal(map[string]any{"installed": map[string]any{
+			"client_id":     m.value(setupClientID),
+			"client_secret": m.value(setupSecret),
+			"auth_uri":      google.Endpoint.AuthURL,
+			"token_uri":     google.Endpoint.TokenURL,
+			"redirect_uris": []string{"http://localhost"},
+		}})
+		if err != nil {
+			return nil, err
+		}
+		b = data
+	default:
+		return nil, errors.New("enter a path to credentials.json, or both a client ID and secret")
+	}
+
+	if _, err := google.ConfigFromJSON(b); err != nil {
+		return nil, fmt.Errorf("unable to parse client secret file to config: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(m.dest), 0700); err != nil {
+		return nil, fmt.Errorf("unable to create config directory: %v", err)
+	}
+	if err := safefile.WriteFile(m.dest, b, 0600); err != nil {
+		return nil, fmt.Errorf("unable to save credentials: %v", err)
+	}
+	return b, nil
+}
+
+func (m setupModel) View() string {
+	intro := []string{
+		"gmail-tui needs an OAuth client to talk to Gmail. To create one:",
+		"",
+		"  1. Open https://console.cloud.google.com/ and create or pick a project",
+		"  2. Enable the Gmail API and the People API",
+		"  3. Under APIs & Services > Credentials, create an OAuth client ID",
+		"     with the Desktop app type",
+		"  4. Download its JSON, or copy the client ID and secret",
+		"",
+		"Then enter the path to the downloaded file, or paste the ID and secret.",
+		"They are saved to " + m.dest + ".",
+	}
+
+	lines := []string{view.TitleStyle.Render("Welcome to gmail-tui"), "", view.InfoStyle.Render(strings.Join(intro, "\n")), ""}
+	for i, in := range m.inputs {
+		lines = append(lines, "  "+in.View())
+		if i == setupPath {
+			lines = append(lines, "", "  or", "")
+		}
+	}
+	if m.err != nil {
+		lines = append(lines, "", m.err.Error())
+	}
+
+	return fmt.Sprintf(
+		"\n%s\n\n%s",
+		strings.Join(lines, "\n"),
+		view.HelpStyle.Render("tab/shift+tab: move • enter: continue to sign-in • esc: quit"),
+	)
+}
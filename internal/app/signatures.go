@@ -0,0 +1,87 @@
+package app
+
+import (
+	"strings"
+
+	"google.golang.org/api/gmail/v1"
+
+	"gmail-tui/internal/mimepart"
+	"gmail-tui/internal/ui/view"
+)
+
+// defaultSignatureKey is the key in the signatures setting for addresses
+// without a signature of their own.
+const defaultSignatureKey = "default"
+
+// localSignature returns the signature config.json sets for mail from
+// address, if it sets one.
+func (c Config) localSignature(address string) (string, bool) {
+	for addr, sig := range c.Signatures {
+		if addr != defaultSignatureKey && strings.EqualFold(addr, address) {
+			return sig, true
+		}
+	}
+	return "", false
+}
+
+// alias is a send-as address with its signature: the one config.json sets
+// for it, or else its Gmail one, or else the default from config.json.
+func (c Config) alias(sa *gmail.SendAs) Alias {
+	a := Alias{SendAs: sa}
+	if sig, ok := c.localSignature(sa.SendAsEmail); ok {
+		a.local = &sig
+	} else if sig, ok := c.Signatures[defaultSignatureKey]; ok && mimepart.HTMLToText(sa.Signature) == "" {
+		a.local = &sig
+	}
+	return a
+}
+
+// signatureBlock is the text a signature adds to a message body, using the
+// conventional "-- " delimiter.
+func signatureBlock(sig string) string {
+	sig = strings.TrimRight(sig, "\n")
+	if strings.TrimSpace(sig) == "" {
+		return ""
+	}
+	return "\n\n-- \n" + sig
+}
+
+// quoteStart returns where the quoted text of the message body answers
+// begins, or -1 if it quotes nothing.
+func quoteStart(body string) int {
+	lines := strings.Split(body, "\n")
+	offset := 0
+	for i, line := range lines {
+		if view.QuoteLen(lines[i:]) > 0 {
+			return offset
+		}
+		offset += len(line) + 1
+	}
+	return -1
+}
+
+// addSignature puts a signature block in body: at the end, or just above
+// the quoted text with signature_placement "above". A body that already
+// has it is left alone.
+func (c Config) addSignature(body, block string) string {
+	if block == "" || strings.Contains(body, block) {
+		return body
+	}
+	if c.SignaturePlacement == "above" {
+		if i := quoteStart(body); i >= 0 {
+			return strings.TrimRight(body[:i], "\n") + block + "\n\n" + body[i:]
+		}
+	}
+	return body + block
+}
+
+// removeSignature takes a block put in by addSignature back out of body.
+func removeSignature(body, block string) string {
+	switch {
+	case block == "":
+		return body
+	case strings.HasSuffix(body, block):
+		return strings.TrimSuffix(body, block)
+	}
+	return strings.Replace(body, block+"\n\n", "\n\n", 1)
+}
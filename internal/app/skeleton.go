@@ -0,0 +1,173 @@
+package app
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"google.golang.org/api/gmail/v1"
+
+	"gmail-tui/internal/backend"
+	"gmail-tui/internal/ui/view"
+)
+
+// loadPollInterval is how often a list being loaded is redrawn with the
+// messages that have arrived so far.
+const loadPollInterval = 100 * time.Millisecond
+
+// listLoad is the progress of loading the list, written by the fetch as
+// the message IDs and then each message's headers arrive.
+type listLoad struct {
+	// fresh is set when the list was empty when loading started, so it is
+	// filled in as messages arrive instead of being replaced at the end.
+	fresh bool
+
+	mu    sync.Mutex
+	ids   []string
+	found bool
+	got   map[string]Email
+}
+
+type loadTickMsg struct {
+	load *listLoad
+}
+
+// pendingRow stands in for a message in the list until its headers arrive.
+// Being no Email, it is skipped by everything acting on the selected
+// message.
+type pendingRow int
+
+func (r pendingRow) FilterValue() string { return "" }
+
+// Title and Description draw bars of varying length, so the placeholders
+// look like rows rather than a block.
+func (r pendingRow) Title() string {
+	return strings.Repeat("░", 18+int(r)*7%20)
+}
+
+func (r pendingRow) Description() string {
+	return strings.Repeat("░", 30+int(r)*13%30)
+}
+
+func pendingRows(n int) []list.Item {
+	items := make([]list.Item, n)
+	for i := range items {
+		items[i] = pendingRow(i)
+	}
+	return items
+}
+
+// progress reports the fetch's progress into l.
+func (l *listLoad) progress() backend.ListProgress {
+	return backend.ListProgress{
+		Found: func(ids []string) {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			l.ids, l.found, l.got = ids, true, map[string]Email{}
+		},
+		Fetched: func(msg *gmail.Message) {
+			e := headerEmail(msg)
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			if l.got != nil {
+				l.got[e.ID] = e
+			}
+		},
+	}
+}
+
+// counts returns how many messages have arrived and how many were listed.
+// ok is false until the list of messages is known.
+func (l *listLoad) counts() (got, total int, ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.got), len(l.ids), l.found
+}
+
+// items is the list as loaded so far: the messages that have arrived, in
+// their place, and placeholders for the rest.
+func (l *listLoad) items() ([]list.Item, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.found {
+		return nil, false
+	}
+	items := make([]list.Item, len(l.ids))
+	for i, id := range l.ids {
+		if e, ok := l.got[id]; ok {
+			items[i] = e
+		} else {
+			items[i] = pendingRow(i)
+		}
+	}
+	return items, true
+}
+
+func loadTick(l *listLoad) tea.Cmd {
+	return tea.Tick(loadPollInterval, func(time.Time) tea.Msg {
+		return loadTickMsg{load: l}
+	})
+}
+
+// skeleton reports whether the list is shown while it loads, rather than
+// the loading screen, so messages that have arrived can already be opened.
+// Screen readers get the loading screen, as rows of placeholders would only
+// be noise.
+func (m Model) skeleton() bool {
+	return m.loading && (m.state == listView || m.state == messageView) && m.compose == nil && !view.Accessible
+}
+
+// startLoad begins following a load of the list. A list loaded from
+// scratch shows placeholder rows straight away.
+func (m Model) startLoad() Model {
+	m.load = &listLoad{fresh: !slices.ContainsFunc(m.list.Items(), isEmail)}
+	if m.skeleton() && m.load.fresh {
+		m.list.SetItems(pendingRows(listSize))
+	}
+	return m
+}
+
+func isEmail(item list.Item) bool {
+	_, ok := item.(Email)
+	return ok
+}
+
+// updateLoad shows the messages that have arrived, until the list is
+// loaded.
+func (m Model) updateLoad(msg loadTickMsg) (Model, tea.Cmd) {
+	if msg.load != m.load || !m.skeleton() {
+		return m, nil
+	}
+	if items, ok := m.load.items(); ok && m.load.fresh {
+		index := m.list.Index()
+		m.list.SetItems(items)
+		m.list.Select(min(index, max(len(items)-1, 0)))
+	}
+	return m, loadTick(m.load)
+}
+
+// clearPending takes the placeholders out of a list that failed to load.
+func (m Model) clearPending() Model {
+	items := m.list.Items()
+	if !slices.ContainsFunc(items, func(item list.Item) bool { _, ok := item.(pendingRow); return ok }) {
+		return m
+	}
+	m.list.SetItems(slices.DeleteFunc(slices.Clone(items), func(item list.Item) bool { return !isEmail(item) }))
+	return m
+}
+
+// loadStatus says how far loading the list has got, while it is shown.
+func (m Model) loadStatus() string {
+	if !m.skeleton() || m.load == nil {
+		return ""
+	}
+	got, total, ok := m.load.counts()
+	if !ok {
+		return "Loading messages..."
+	}
+	return fmt.Sprintf("Loading messages: %d of %d", got, total)
+}
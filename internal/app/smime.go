@@ -0,0 +1,178 @@
+package app
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"mime"
+	"net/mail"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"google.golang.org/api/gmail/v1"
+
+	"gmail-tui/internal/mimepart"
+)
+
+// isSMIME reports whether a message is S/MIME signed with a detached
+// signature.
+func isSMIME(payload *gmail.MessagePart) bool {
+	if payload.MimeType != "multipart/signed" {
+		return false
+	}
+	_, params, _ := mime.ParseMediaType(mimepart.Header(payload, "Content-Type"))
+	switch strings.ToLower(params["protocol"]) {
+	case "application/pkcs7-signature", "application/x-pkcs7-signature":
+		return true
+	}
+	return false
+}
+
+// verifySMIME checks the S/MIME signature of message id, sent by from, and
+// summarises the result for the header.
+func (m Model) verifySMIME(id, from string) (string, error) {
+	raw, err := m.fetchRaw(id)
+	if err != nil {
+		return "", err
+	}
+	return smimeStatus(raw, from, m.config.SMIMECAFile, time.Now()), nil
+}
+
+// smimeStatus verifies a signed message with openssl, first against the
+// system's trusted roots plus any in caFile and, if the certificate is not
+// trusted, again for the signature alone, so a broken signature can be told
+// from an unknown issuer.
+func smimeStatus(raw []byte, from, caFile string, now time.Time) string {
+	f, err := os.CreateTemp("", "gmail-tui-*.pem")
+	if err != nil {
+		return fmt.Sprintf("unable to verify: %v", err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	verify := []string{"smime", "-verify", "-signer", f.Name(), "-out", os.DevNull}
+	trust := verify
+	if caFile != "" {
+		trust = append(trust, "-CAfile", expandHome(caFile))
+	}
+	untrusted := ""
+	if err := runOpenSSL(raw, trust...); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return "openssl is needed to check the signature"
+		}
+		untrusted = err.Error()
+		if err := runOpenSSL(raw, append(verify, "-noverify")...); err != nil {
+			return "BAD signature"
+		}
+	}
+
+	data, err := os.ReadFile(f.Name())
+	if err != nil {
+		return fmt.Sprintf("unable to read signer certificate: %v", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return "signed, but the signer certificate is missing"
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return fmt.Sprintf("unable to read signer certificate: %v", err)
+	}
+
+	signer := cert.Subject.CommonName
+	if emails := certEmails(cert); len(emails) > 0 {
+		signer = fmt.Sprintf("%s <%s>", signer, emails[0])
+	}
+	parts := []string{"signed by " + signer}
+	if untrusted != "" {
+		parts[0] = "UNTRUSTED signature by " + signer + " (" + untrusted + ")"
+	}
+	switch {
+	case now.After(cert.NotAfter):
+		parts = append(parts, "certificate expired "+cert.NotAfter.Format("Jan 2 2006"))
+	case now.Before(cert.NotBefore):
+		parts = append(parts, "certificate not valid until "+cert.NotBefore.Format("Jan 2 2006"))
+	default:
+		parts = append(parts, "certificate valid until "+cert.NotAfter.Format("Jan 2 2006"))
+	}
+	if problem := senderBinding(cert, from); problem != "" {
+		parts = append(parts, problem)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// senderBinding explains why cert doesn't vouch for the From address, or
+// returns "". A certificate that names no email address at all vouches for
+// no sender, however trusted it is.
+func senderBinding(cert *x509.Certificate, from string) string {
+	a, err := mail.ParseAddress(from)
+	if err != nil {
+		return "signer NOT bound to sender"
+	}
+	if !certCovers(cert, a.Address) {
+		return "signer NOT bound to sender (certificate not issued to " + a.Address + ")"
+	}
+	return ""
+}
+
+func certCovers(cert *x509.Certificate, addr string) bool {
+	for _, e := range certEmails(cert) {
+		if strings.EqualFold(e, addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// oidEmailAddress is the PKCS #9 emailAddress attribute, which older
+// certificates put in the subject instead of the subjectAltName.
+var oidEmailAddress = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 1}
+
+// certEmails returns the addresses cert was issued to: its subjectAltName
+// email addresses or, failing those, the subject's emailAddress.
+func certEmails(cert *x509.Certificate) []string {
+	if len(cert.EmailAddresses) > 0 {
+		return cert.EmailAddresses
+	}
+	var emails []string
+	for _, name := range cert.Subject.Names {
+		if e, ok := name.Value.(string); ok && name.Type.Equal(oidEmailAddress) {
+			emails = append(emails, e)
+		}
+	}
+	return emails
+}
+
+// runOpenSSL runs openssl on input. A failure is reported with openssl's
+// reason, such as "self-signed certificate".
+func runOpenSSL(input []byte, args ...string) error {
+	cmd := exec.Command("openssl", args...)
+	cmd.Stdin = bytes.NewReader(input)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return err
+	}
+	out := stderr.String()
+	if _, reason, ok := strings.Cut(out, "Verify error:"); ok {
+		return errors.New(strings.TrimSpace(strings.SplitN(reason, "\n", 2)[0]))
+	}
+	return errors.New("verification failed")
+}
+
+// signatureNote is how a signature check is shown after the date in the
+// message header.
+func signatureNote(name, status string) string {
+	if status == "" {
+		return ""
+	}
+	return " • " + name + ": " + status
+}
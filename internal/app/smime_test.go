@@ -0,0 +1,33 @@
+package app
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"testing"
+)
+
+func TestSenderBinding(t *testing.T) {
+	withSAN := &x509.Certificate{EmailAddresses: []string{"ada@example.com"}}
+	withSubject := &x509.Certificate{Subject: pkix.Name{Names: []pkix.AttributeTypeAndValue{
+		{Type: oidEmailAddress, Value: "ada@example.com"},
+	}}}
+	noEmail := &x509.Certificate{Subject: pkix.Name{CommonName: "Ada"}}
+
+	tests := []struct {
+		name string
+		cert *x509.Certificate
+		from string
+		want string
+	}{
+		{"subjectAltName", withSAN, "Ada <ADA@example.com>", ""},
+		{"subject emailAddress", withSubject, "ada@example.com", ""},
+		{"other sender", withSAN, "eve@example.com", "signer NOT bound to sender (certificate not issued to eve@example.com)"},
+		{"no email", noEmail, "ada@example.com", "signer NOT bound to sender (certificate not issued to ada@example.com)"},
+		{"no sender", withSAN, "", "signer NOT bound to sender"},
+	}
+	for _, tt := range tests {
+		if got := senderBinding(tt.cert, tt.from); got != tt.want {
+			t.Errorf("%s: senderBinding = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
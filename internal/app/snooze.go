@@ -0,0 +1,144 @@
+package app
+
+import (
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"google.golang.org/api/gmail/v1"
+)
+
+// Snoozed messages are taken out of the inbox and given a label named after
+// the time they are due back, so the snooze survives restarts and is
+// visible in Gmail web. The label is removed when the message returns.
+const (
+	snoozeLabelPrefix = "gmail-tui/snoozed/"
+	snoozeLabelLayout = "20060102T1504Z"
+	snoozeInterval    = time.Minute
+)
+
+type snoozeTickMsg struct{}
+
+// snoozeWokeMsg reports how many snoozed messages were returned to the
+// inbox.
+type snoozeWokeMsg int
+
+func newSnoozeInput() textinput.Model {
+	ti := textinput.New()
+	ti.Prompt = "Snooze until: "
+	ti.Placeholder = futureTimeHint
+	return ti
+}
+
+func snoozeLabelName(t time.Time) string {
+	return snoozeLabelPrefix + t.UTC().Format(snoozeLabelLayout)
+}
+
+// snoozeLabelTime returns the time encoded in a snooze label name.
+func snoozeLabelTime(name string) (time.Time, bool) {
+	s, ok := strings.CutPrefix(name, snoozeLabelPrefix)
+	if !ok {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(snoozeLabelLayout, s)
+	return t, err == nil
+}
+
+func (m Model) startSnooze(e Email) (Model, tea.Cmd) {
+	m.snooze = &e
+	m.snoozeInput.Reset()
+	return m, m.snoozeInput.Focus()
+}
+
+func (m Model) updateSnooze(msg tea.KeyMsg) (Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, m.keys.Back):
+		m.snooze = nil
+		m.status = "Cancelled"
+		return m, nil
+	case key.Matches(msg, m.keys.Select):
+		until, err := parseFutureTime(m.snoozeInput.Value(), time.Now())
+		if err != nil {
+			m.status = err.Error()
+			return m, nil
+		}
+		e := *m.snooze
+		m.snooze = nil
+		return m, m.snoozeEmail(e, until)
+	}
+
+	var cmd tea.Cmd
+	m.snoozeInput, cmd = m.snoozeInput.Update(msg)
+	return m, cmd
+}
+
+// snoozeEmail moves e out of the inbox until the given time.
+func (m Model) snoozeEmail(e Email, until time.Time) tea.Cmd {
+	return func() tea.Msg {
+		labels, err := fetchLabels(m.ctx, m.gmailSvc)
+		if err != nil {
+			return errMsg(err)
+		}
+		id, err := ensureLabel(m.ctx, m.gmailSvc, labels, snoozeLabelName(until))
+		if err != nil {
+			return errMsg(err)
+		}
+		status := "Snoozed until " + until.Local().Format("Mon Jan 2 15:04")
+		return m.modifyLabels(e, []string{id}, []string{"INBOX"}, true, status)()
+	}
+}
+
+// wakeSnoozed returns messages whose snooze has passed to the inbox and
+// deletes their snooze labels. It runs in the background, so failures are
+// left for the next run rather than reported.
+func (m Model) wakeSnoozed() tea.Msg {
+	labels, err := fetchLabels(m.ctx, m.gmailSvc)
+	if err != nil {
+		return snoozeWokeMsg(0)
+	}
+
+	woken := 0
+	for _, l := range labels {
+		t, ok := snoozeLabelTime(l.Name)
+		if !ok || t.After(time.Now()) {
+			continue
+		}
+
+		var ids []string
+		err := m.gmailSvc.Users.Messages.List("me").LabelIds(l.Id).IncludeSpamTrash(false).
+			Pages(m.ctx, func(r *gmail.ListMessagesResponse) error {
+				for _, msg := range r.Messages {
+					ids = append(ids, msg.Id)
+				}
+				return nil
+			})
+		if err != nil {
+			continue
+		}
+		if len(ids) > 0 {
+			err := m.gmailSvc.Users.Messages.BatchModify("me", &gmail.BatchModifyMessagesRequest{
+				Ids:            ids,
+				AddLabelIds:    []string{"INBOX"},
+				RemoveLabelIds: []string{l.Id},
+			}).Context(m.ctx).Do()
+			if err != nil {
+				continue
+			}
+		}
+		m.gmailSvc.Users.Labels.Delete("me", l.Id).Context(m.ctx).Do()
+		woken += len(ids)
+	}
+	return snoozeWokeMsg(woken)
+}
+
+func snoozeTick() tea.Cmd {
+	return tea.Tick(snoozeInterval, func(time.Time) tea.Msg {
+		return snoozeTickMsg{}
+	})
+}
+
+func (m Model) snoozePrompt() string {
+	return m.snoozeInput.View() + "\nenter: snooze • esc: cancel"
+}
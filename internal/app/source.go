@@ -0,0 +1,94 @@
+package app
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"gmail-tui/internal/ui/view"
+)
+
+// sourceMsg carries the raw source or header dump of a message.
+type sourceMsg struct {
+	id   string
+	text string
+}
+
+// fetchSource loads the complete RFC 822 source of a message.
+func (m Model) fetchSource(id string) tea.Cmd {
+	return func() tea.Msg {
+		data, err := m.fetchRaw(id)
+		if err != nil {
+			return errMsg(err)
+		}
+		return sourceMsg{id: id, text: strings.ReplaceAll(string(data), "\r\n", "\n")}
+	}
+}
+
+// fetchRaw returns the RFC 822 source of a message, byte for byte.
+func (m Model) fetchRaw(id string) ([]byte, error) {
+	msg, err := m.mail.Get(m.ctx, id, "raw")
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch message: %v", err)
+	}
+	data, err := base64.URLEncoding.DecodeString(msg.Raw)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode message source: %v", err)
+	}
+	return data, nil
+}
+
+// fetchHeaders loads every header of a message, in order, including the
+// Received chain.
+func (m Model) fetchHeaders(id string) tea.Cmd {
+	return func() tea.Msg {
+		msg, err := m.mail.Get(m.ctx, id, "metadata")
+		if err != nil {
+			return errMsg(err)
+		}
+
+		var b strings.Builder
+		for _, h := range msg.Payload.Headers {
+			fmt.Fprintf(&b, "%s: %s\n", h.Name, h.Value)
+		}
+		return sourceMsg{id: id, text: b.String()}
+	}
+}
+
+// bodyText is the text the reading view is showing: the message body, or
+// its source when that has been requested.
+func (m Model) bodyText() string {
+	lines := m.bodyLines()
+	texts := make([]string, len(lines))
+	for i, l := range lines {
+		texts[i] = l.Text
+	}
+	return strings.Join(texts, "\n")
+}
+
+// bodyLines is bodyText laid out for the viewport. The source is shown as
+// it is.
+func (m Model) bodyLines() []view.Line {
+	text := m.source
+	if text == "" && m.selectedMail != nil {
+		text = m.selectedMail.Body
+	}
+	if m.source != "" {
+		return view.PlainLines(text)
+	}
+
+	// An invitation is described above the body.
+	width := m.viewport.Width - m.viewport.Style.GetHorizontalFrameSize()
+	var lines []view.Line
+	if inv := m.selectedMail.invite; inv != nil {
+		for _, l := range inviteLines(inv) {
+			lines = view.AppendWrapped(lines, "", l, width, view.InviteLine)
+		}
+	}
+	if !m.config.FormatBody {
+		return append(lines, view.PlainLines(text)...)
+	}
+	return append(lines, view.FormatBody(text, width, !m.expandQuotes)...)
+}
@@ -0,0 +1,200 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"gmail-tui/internal/safefile"
+	"gmail-tui/internal/ui/maillist"
+	"gmail-tui/internal/vault"
+)
+
+// statusQuery finds the mail the status counts.
+const statusQuery = "in:inbox is:unread"
+
+// statusCountMax is how many unread messages are counted without the Gmail
+// API, which has the inbox's count at hand.
+const statusCountMax = 100
+
+// mailStatus is the unread count of the inbox and its newest unread
+// message, as kept in status.json for status lines to read.
+type mailStatus struct {
+	Unread  int64     `json:"unread"`
+	More    bool      `json:"more,omitempty"`
+	From    string    `json:"from,omitempty"`
+	Subject string    `json:"subject,omitempty"`
+	Date    time.Time `json:"date,omitempty"`
+	Updated time.Time `json:"updated"`
+}
+
+func statusPath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gmail-tui", "status.json"), nil
+}
+
+// loadStatus reads status.json. ok is false if there is none.
+func loadStatus() (s mailStatus, ok bool) {
+	path, err := statusPath()
+	if err != nil {
+		return s, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return s, false
+	}
+	return s, json.Unmarshal(data, &s) == nil
+}
+
+// save writes status.json whole, so a status line reading it never sees
+// half of it. Status lines read it without the key, so with the caches
+// encrypted (v not nil) only the count is saved.
+func (s mailStatus) save(v *vault.Vault) error {
+	if v.Enabled() {
+		s.From, s.Subject, s.Date = "", "", time.Time{}
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	path, err := statusPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	if err := safefile.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("unable to write status file: %v", err)
+	}
+	return nil
+}
+
+// fetchStatus counts the unread mail in the inbox and finds the newest.
+func (m Model) fetchStatus(ctx context.Context) (mailStatus, error) {
+	max := int64(statusCountMax)
+	if m.config.GmailAPI() {
+		max = 1
+	}
+	emails, _, err := m.listEmails(ctx, statusQuery, max)
+	if err != nil {
+		return mailStatus{}, fmt.Errorf("unable to list unread messages: %v", err)
+	}
+	s := mailStatus{Unread: int64(len(emails)), More: len(emails) == statusCountMax, Updated: time.Now()}
+	if m.config.GmailAPI() {
+		l, err := m.gmailSvc.Users.Labels.Get("me", "INBOX").Context(ctx).Do()
+		if err != nil {
+			return mailStatus{}, fmt.Errorf("unable to count unread messages: %v", err)
+		}
+		s.Unread, s.More = l.MessagesUnread, false
+	}
+	if len(emails) > 0 {
+		s.From, s.Subject, s.Date = maillist.SenderName(emails[0].From), emails[0].Subject, emails[0].Date
+	}
+	return s, nil
+}
+
+// writeStatus keeps status.json up to date for status lines while the app
+// runs. Failures are ignored; the status command fetches the status itself
+// when the file is out of date. The demo mailbox leaves the file alone, as
+// does the app when the daemon keeps it.
+func (m Model) writeStatus() tea.Msg {
+	if m.config.Backend == "demo" || m.daemon {
+		return nil
+	}
+	if s, err := m.fetchStatus(m.ctx); err == nil {
+		m.saveStatus(s)
+	}
+	return nil
+}
+
+// saveStatus saves s for status lines.
+func (m Model) saveStatus(s mailStatus) {
+	s.save(m.config.vault)
+}
+
+// statusOptions are the flags of the status command.
+type statusOptions struct {
+	format string
+	maxAge time.Duration
+	json   bool
+}
+
+func parseStatusFlags(args []string) (statusOptions, error) {
+	fs := flag.NewFlagSet("status", flag.ExitOnError)
+	var o statusOptions
+	fs.StringVar(&o.format, "format", "", "text to print, with {unread}, {from} and {subject} replaced (default: \"3 unread • Sender: Subject\")")
+	fs.DurationVar(&o.maxAge, "max-age", 2*time.Minute, "use the status the app last saved if it is no older than this")
+	asJSON := outputFlags(fs)
+	fs.Parse(args)
+
+	var err error
+	o.json, err = asJSON()
+	return o, err
+}
+
+// text renders the status for a status line.
+func (s mailStatus) text(format string) string {
+	unread := strconv.FormatInt(s.Unread, 10)
+	if s.More {
+		unread += "+"
+	}
+	if format == "" {
+		format = "{unread} unread"
+		if s.Subject != "" {
+			format += " • {from}: {subject}"
+		}
+	}
+	return strings.NewReplacer("{unread}", unread, "{from}", s.From, "{subject}", s.Subject).Replace(format)
+}
+
+func (s mailStatus) print(o statusOptions) error {
+	if o.json {
+		return printJSONLines([]mailStatus{s})
+	}
+	fmt.Println(s.text(o.format))
+	return nil
+}
+
+// cachedStatusCommand prints the status the app last saved if it is recent
+// enough, so the status command needn't sign in or touch the network. done
+// is false when it has to fetch the status itself.
+func cachedStatusCommand(args []string) (done bool, err error) {
+	o, err := parseStatusFlags(args)
+	if err != nil {
+		return true, err
+	}
+	s, ok := loadStatus()
+	if !ok || time.Since(s.Updated) > o.maxAge {
+		return false, nil
+	}
+	return true, s.print(o)
+}
+
+// statusCommand prints the unread count of the inbox and its newest unread
+// message, fetching them and saving them for the next call.
+func (m Model) statusCommand(args []string) error {
+	o, err := parseStatusFlags(args)
+	if err != nil {
+		return err
+	}
+	s, err := m.fetchStatus(m.ctx)
+	if err != nil {
+		return err
+	}
+	if m.config.Backend != "demo" {
+		m.saveStatus(s)
+	}
+	return s.print(o)
+}
@@ -0,0 +1,432 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"google.golang.org/api/gmail/v1"
+
+	"gmail-tui/internal/mimepart"
+	"gmail-tui/internal/safefile"
+)
+
+// A synced Maildir holds all mail but Spam and Trash, kept up to date from
+// Gmail's history after the first run. The history ID reached is kept in
+// the Maildir itself, so each Maildir syncs on its own.
+const (
+	syncQuery     = "-in:spam -in:trash"
+	syncStateFile = ".gmail-tui-sync.json"
+)
+
+// systemTags are the notmuch tags for Gmail's system labels, following
+// notmuch's own names where it has them. Other system labels, such as
+// categories, are not tagged.
+var systemTags = map[string]string{
+	"INBOX":     "inbox",
+	"UNREAD":    "unread",
+	"STARRED":   "flagged",
+	"DRAFT":     "draft",
+	"SENT":      "sent",
+	"IMPORTANT": "important",
+}
+
+// syncState is what a Maildir remembers between syncs.
+type syncState struct {
+	HistoryID uint64 `json:"history_id"`
+}
+
+// syncResult counts what a sync changed in the Maildir.
+type syncResult struct {
+	added, updated, removed int
+}
+
+func (r syncResult) String() string {
+	return fmt.Sprintf("%d new, %d updated, %d removed", r.added, r.updated, r.removed)
+}
+
+// maildirSyncedMsg reports a finished sync. shown is set if it was asked
+// for, rather than run with the auto-refresh.
+type maildirSyncedMsg struct {
+	result syncResult
+	err    error
+	shown  bool
+}
+
+// maildirSync brings a Maildir up to date with Gmail and, when notmuch is
+// set, the notmuch tags of the messages it touched.
+type maildirSync struct {
+	m      Model
+	x      *exporter
+	labels labelIndex
+	// files maps the ID of each message in the Maildir to its file.
+	files map[string]string
+	// tags holds the labels of each message touched, by Message-ID.
+	tags   map[string][]string
+	result syncResult
+}
+
+// syncMaildir syncs dir. The first sync copies the max most recent
+// messages, or all of them when max is 0; later ones only fetch what
+// changed since. progress, if set, is told how far a full sync has got.
+func (m Model) syncMaildir(ctx context.Context, dir string, max int64, notmuch bool, progress func(done, total int)) (syncResult, error) {
+	x, err := newExporter(exportMaildir, dir)
+	if err != nil {
+		return syncResult{}, err
+	}
+	labels, err := fetchLabels(ctx, m.gmailSvc)
+	if err != nil {
+		return syncResult{}, err
+	}
+	s := &maildirSync{m: m, x: x, labels: labels, files: maildirFiles(dir), tags: map[string][]string{}}
+
+	state := loadSyncState(dir)
+	historyID, err := s.changes(ctx, state.HistoryID)
+	if isNotFound(err) || state.HistoryID == 0 {
+		// Gmail only keeps about a week of history.
+		historyID, err = s.full(ctx, max, progress)
+	}
+	if err != nil {
+		return s.result, err
+	}
+	if err := saveSyncState(dir, syncState{HistoryID: historyID}); err != nil {
+		return s.result, err
+	}
+	if notmuch {
+		if err := s.tagNotmuch(); err != nil {
+			return s.result, err
+		}
+	}
+	return s.result, nil
+}
+
+// full copies every message matching syncQuery that isn't in the Maildir
+// yet, updates the flags of those that are, and removes the rest.
+func (s *maildirSync) full(ctx context.Context, max int64, progress func(done, total int)) (uint64, error) {
+	profile, err := s.m.gmailSvc.Users.GetProfile("me").Context(ctx).Do()
+	if err != nil {
+		return 0, fmt.Errorf("unable to read mailbox history: %v", err)
+	}
+	ids, err := s.m.queryIDs(ctx, syncQuery, max)
+	if err != nil {
+		return 0, err
+	}
+	kept := map[string]bool{}
+	for i, id := range ids {
+		kept[id] = true
+		if err := s.update(ctx, id); err != nil {
+			return 0, err
+		}
+		if progress != nil {
+			progress(i+1, len(ids))
+		}
+	}
+	// With max set, older messages were only left out, not deleted.
+	if max == 0 {
+		for id := range s.files {
+			if !kept[id] {
+				s.remove(id)
+			}
+		}
+	}
+	return profile.HistoryId, nil
+}
+
+// changes applies the history since historyID, returning the history ID
+// reached. It returns a not-found error once that history has expired.
+func (s *maildirSync) changes(ctx context.Context, historyID uint64) (uint64, error) {
+	if historyID == 0 {
+		return 0, nil
+	}
+	var changed, deleted []string
+	call := s.m.gmailSvc.Users.History.List("me").StartHistoryId(historyID)
+	for {
+		r, err := call.Context(ctx).Do()
+		if err != nil {
+			return 0, err
+		}
+		for _, h := range r.History {
+			for _, a := range h.MessagesAdded {
+				changed = append(changed, a.Message.Id)
+			}
+			for _, a := range h.LabelsAdded {
+				changed = append(changed, a.Message.Id)
+			}
+			for _, r := range h.LabelsRemoved {
+				changed = append(changed, r.Message.Id)
+			}
+			for _, d := range h.MessagesDeleted {
+				deleted = append(deleted, d.Message.Id)
+			}
+		}
+		historyID = r.HistoryId
+		if r.NextPageToken == "" {
+			break
+		}
+		call.PageToken(r.NextPageToken)
+	}
+
+	for _, id := range deleted {
+		s.remove(id)
+	}
+	slices.Sort(changed)
+	for _, id := range slices.Compact(changed) {
+		if slices.Contains(deleted, id) {
+			continue
+		}
+		if err := s.update(ctx, id); err != nil {
+			return 0, err
+		}
+	}
+	return historyID, nil
+}
+
+// update copies message id into the Maildir, or only renames its file when
+// just its labels changed. Messages moved to Spam or Trash are removed.
+func (s *maildirSync) update(ctx context.Context, id string) error {
+	msg, err := s.m.gmailSvc.Users.Messages.Get("me", id).Format("metadata").
+		MetadataHeaders("Message-ID").Context(ctx).Do()
+	if isNotFound(err) {
+		s.remove(id)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("unable to fetch message: %v", err)
+	}
+	if slices.Contains(msg.LabelIds, "SPAM") || slices.Contains(msg.LabelIds, "TRASH") {
+		s.remove(id)
+		return nil
+	}
+
+	if old, ok := s.files[id]; ok {
+		name := maildirName(msg) + ":2," + maildirFlags(msg.LabelIds)
+		if filepath.Base(old) != name {
+			if err := os.Rename(old, filepath.Join(s.x.path, "cur", name)); err != nil {
+				return fmt.Errorf("unable to update %s: %v", old, err)
+			}
+			s.result.updated++
+		}
+	} else {
+		raw, err := s.m.gmailSvc.Users.Messages.Get("me", id).Format("raw").Context(ctx).Do()
+		if err != nil {
+			return fmt.Errorf("unable to fetch message: %v", err)
+		}
+		if err := s.x.add(raw); err != nil {
+			return err
+		}
+		s.result.added++
+	}
+	s.files[id] = filepath.Join(s.x.path, "cur", maildirName(msg)+":2,"+maildirFlags(msg.LabelIds))
+	if messageID := mimepart.Header(msg.Payload, "Message-ID"); messageID != "" {
+		s.tags[messageID] = msg.LabelIds
+	}
+	return nil
+}
+
+// remove deletes message id from the Maildir, if it is there.
+func (s *maildirSync) remove(id string) {
+	if f, ok := s.files[id]; ok {
+		if os.Remove(f) == nil {
+			s.result.removed++
+		}
+		delete(s.files, id)
+	}
+}
+
+// tagNotmuch has notmuch index the Maildir's changes, then sets the tags of
+// the messages touched from their labels. Tags that don't come from a label
+// are left alone.
+func (s *maildirSync) tagNotmuch() error {
+	if out, err := exec.Command("notmuch", "new", "--quiet").CombinedOutput(); err != nil {
+		return fmt.Errorf("unable to run notmuch new: %v: %s", err, bytes.TrimSpace(out))
+	}
+	if len(s.tags) == 0 {
+		return nil
+	}
+
+	managed := map[string]bool{}
+	for id := range s.labels {
+		if tag := s.labels.tag(id); tag != "" {
+			managed[tag] = true
+		}
+	}
+	var batch strings.Builder
+	for messageID, labelIDs := range s.tags {
+		has := map[string]bool{}
+		for _, id := range labelIDs {
+			if tag := s.labels.tag(id); tag != "" {
+				has[tag] = true
+				batch.WriteString("+" + encodeTag(tag) + " ")
+			}
+		}
+		for tag := range managed {
+			if !has[tag] {
+				batch.WriteString("-" + encodeTag(tag) + " ")
+			}
+		}
+		id := strings.Trim(strings.TrimSpace(messageID), "<>")
+		fmt.Fprintf(&batch, "-- id:\"%s\"\n", strings.ReplaceAll(id, `"`, `""`))
+	}
+	cmd := exec.Command("notmuch", "tag", "--batch")
+	cmd.Stdin = strings.NewReader(batch.String())
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("unable to run notmuch tag: %v: %s", err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+// tag is the notmuch tag for a label: a fixed name for the system labels
+// notmuch users expect, and the label's own name for yours.
+func (idx labelIndex) tag(id string) string {
+	if tag, ok := systemTags[id]; ok {
+		return tag
+	}
+	if l, ok := idx[id]; ok && l.Type == "user" {
+		return l.Name
+	}
+	return ""
+}
+
+// encodeTag hex-encodes the characters notmuch's batch format can't take
+// in a tag as they are, such as spaces.
+func encodeTag(tag string) string {
+	var b strings.Builder
+	for _, c := range []byte(tag) {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9', strings.IndexByte("_-./@:", c) >= 0:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02x", c)
+		}
+	}
+	return b.String()
+}
+
+// maildirName is the file name a message has in a Maildir, before its
+// flags.
+func maildirName(msg *gmail.Message) string {
+	return fmt.Sprintf("%d.%s.gmail-tui", time.UnixMilli(msg.InternalDate).Unix(), msg.Id)
+}
+
+// maildirFlags are the draft, flagged and seen flags for a message's
+// labels.
+func maildirFlags(labels []string) string {
+	var flags string
+	if slices.Contains(labels, "DRAFT") {
+		flags += "D"
+	}
+	if slices.Contains(labels, "STARRED") {
+		flags += "F"
+	}
+	if !slices.Contains(labels, "UNREAD") {
+		flags += "S"
+	}
+	return flags
+}
+
+// maildirFiles maps the IDs of the messages gmail-tui put in a Maildir's
+// cur directory to their files.
+func maildirFiles(dir string) map[string]string {
+	files := map[string]string{}
+	paths, _ := filepath.Glob(filepath.Join(dir, "cur", "*.gmail-tui:2,*"))
+	for _, p := range paths {
+		parts := strings.Split(filepath.Base(p), ".")
+		if len(parts) >= 3 {
+			files[parts[1]] = p
+		}
+	}
+	return files
+}
+
+func loadSyncState(dir string) syncState {
+	var state syncState
+	if data, err := os.ReadFile(filepath.Join(dir, syncStateFile)); err == nil {
+		json.Unmarshal(data, &state)
+	}
+	return state
+}
+
+func saveSyncState(dir string, state syncState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	if err := safefile.WriteFile(filepath.Join(dir, syncStateFile), data, 0o600); err != nil {
+		return fmt.Errorf("unable to save sync state: %v", err)
+	}
+	return nil
+}
+
+// syncInBackground syncs the configured Maildir. shown reports the result
+// in the status line; otherwise only a failure is shown.
+func (m Model) syncInBackground(shown bool) (Model, tea.Cmd) {
+	// The daemon syncs the Maildir itself.
+	if m.daemon && !shown {
+		return m, nil
+	}
+	if m.config.Maildir == "" {
+		if shown {
+			m.status = "Set maildir in config.json to sync mail into a Maildir"
+		}
+		return m, nil
+	}
+	if m.syncing {
+		if shown {
+			m.status = "A Maildir sync is already running"
+		}
+		return m, nil
+	}
+	m.syncing = true
+	if shown {
+		m.status = "Syncing Maildir..."
+	}
+	return m, func() tea.Msg {
+		result, err := m.syncMaildir(m.ctx, expandHome(m.config.Maildir), 0, m.config.Notmuch, nil)
+		return maildirSyncedMsg{result: result, err: err, shown: shown}
+	}
+}
+
+func (m Model) applyMaildirSynced(msg maildirSyncedMsg) Model {
+	m.syncing = false
+	switch {
+	case msg.err != nil:
+		m.status = fmt.Sprintf("Maildir sync failed: %v", msg.err)
+	case msg.shown:
+		m.status = "Maildir synced: " + msg.result.String()
+	}
+	return m
+}
+
+// syncCommand syncs a Maildir from the command line, for running from cron
+// or a timer without the interactive client.
+func (m Model) syncCommand(args []string) error {
+	fs := flag.NewFlagSet("sync", flag.ExitOnError)
+	dir := fs.String("maildir", m.config.Maildir, "Maildir to sync into")
+	max := fs.Int64("max", 0, "on the first sync, copy only this many recent messages (0 for all)")
+	notmuch := fs.Bool("notmuch", m.config.Notmuch, "run notmuch new and tag messages with their labels")
+	fs.Parse(args)
+	if *dir == "" {
+		return fmt.Errorf("sync: --maildir or maildir in config.json is required")
+	}
+
+	result, err := m.syncMaildir(m.ctx, expandHome(*dir), *max, *notmuch, func(done, total int) {
+		fmt.Fprintf(os.Stderr, "\r%d/%d", done, total)
+		if done == total {
+			fmt.Fprintln(os.Stderr)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Synced %s: %s\n", strings.TrimSuffix(*dir, "/"), result)
+	return nil
+}
@@ -0,0 +1,139 @@
+package app
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+
+	"gmail-tui/internal/ui/view"
+)
+
+var (
+	tabStyle = lipgloss.NewStyle().
+			Padding(0, 1).
+			Foreground(lipgloss.Color("#9B9B9B"))
+
+	activeTabStyle = tabStyle.
+			Bold(true).
+			Underline(true).
+			Foreground(lipgloss.Color("#FF75B7"))
+)
+
+// categoryTab is one of Gmail's inbox categories.
+type categoryTab struct {
+	name  string
+	label string
+	title string
+}
+
+// categoryTabs lists the categories in the order Gmail shows them.
+var categoryTabs = []categoryTab{
+	{"primary", "CATEGORY_PERSONAL", "Primary"},
+	{"social", "CATEGORY_SOCIAL", "Social"},
+	{"promotions", "CATEGORY_PROMOTIONS", "Promotions"},
+	{"updates", "CATEGORY_UPDATES", "Updates"},
+	{"forums", "CATEGORY_FORUMS", "Forums"},
+}
+
+func findTab(name string) (categoryTab, bool) {
+	i := slices.IndexFunc(categoryTabs, func(t categoryTab) bool { return t.name == strings.ToLower(name) })
+	if i < 0 {
+		return categoryTab{}, false
+	}
+	return categoryTabs[i], true
+}
+
+// tabs returns the category tabs to show, in the configured order. Unknown
+// names are skipped.
+func (c Config) tabs() []categoryTab {
+	var tabs []categoryTab
+	for _, name := range c.Tabs {
+		if t, ok := findTab(name); ok {
+			tabs = append(tabs, t)
+		}
+	}
+	return tabs
+}
+
+// tabCountsMsg carries the unread count of each category label.
+type tabCountsMsg map[string]int64
+
+// fetchTabCounts loads the unread counts of the shown tabs. Failures just
+// leave the counts off.
+func (m Model) fetchTabCounts() tea.Msg {
+	counts := tabCountsMsg{}
+	for _, t := range m.config.tabs() {
+		l, err := m.gmailSvc.Users.Labels.Get("me", t.label).Context(m.ctx).Do()
+		if err == nil {
+			counts[t.name] = l.MessagesUnread
+		}
+	}
+	return counts
+}
+
+// showTab lists the inbox messages of a category.
+func (m Model) showTab(t categoryTab) (Model, tea.Cmd) {
+	m, cmd := m.showQuery("in:inbox category:"+t.name, t.title)
+	m.place = t.name
+	return m, cmd
+}
+
+// switchTab moves to the next (step 1) or previous (step -1) tab. From a
+// list that is not a tab, it goes to the first or last one.
+func (m Model) switchTab(step int) (Model, tea.Cmd) {
+	tabs := m.config.tabs()
+	if len(tabs) == 0 {
+		return m, nil
+	}
+	i := slices.IndexFunc(tabs, func(t categoryTab) bool { return t.name == m.place })
+	switch {
+	case i < 0 && step > 0:
+		i = 0
+	case i < 0:
+		i = len(tabs) - 1
+	default:
+		i = (i + step + len(tabs)) % len(tabs)
+	}
+	return m.showTab(tabs[i])
+}
+
+// tabBar draws the tabs above the list, with their unread counts. It is
+// empty when no tabs are configured.
+func (m Model) tabBar() string {
+	var cells []string
+	for _, t := range m.config.tabs() {
+		cells = append(cells, m.tabCell(t))
+	}
+	if len(cells) == 0 {
+		return ""
+	}
+	return view.InfoStyle.Render(strings.Join(cells, ""))
+}
+
+func (m Model) tabCell(t categoryTab) string {
+	text := t.title
+	if n := m.tabUnread[t.name]; n > 0 {
+		text += fmt.Sprintf(" (%d)", n)
+	}
+	if t.name == m.place {
+		return activeTabStyle.Render(text)
+	}
+	return tabStyle.Render(text)
+}
+
+// tabAt returns the tab drawn at column x of the tab bar.
+func (m Model) tabAt(x int) (categoryTab, bool) {
+	x -= view.InfoStyle.GetMarginLeft()
+	for _, t := range m.config.tabs() {
+		w := ansi.StringWidth(m.tabCell(t))
+		if x >= 0 && x < w {
+			return t, true
+		}
+		x -= w
+	}
+	return categoryTab{}, false
+}
@@ -0,0 +1,184 @@
+package app
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"google.golang.org/api/gmail/v1"
+)
+
+// maxUndo caps how many changes can be undone.
+const maxUndo = 20
+
+// undoStep reverses a change to a message's labels: archiving, trashing,
+// marking read, labelling, reporting spam or snoozing.
+type undoStep struct {
+	// email is the message as it was before the change, and index its
+	// position in the list.
+	email Email
+	index int
+	// id is the message's ID after the change, when the provider gave it a
+	// new one by moving it to another folder.
+	id string
+	// add and remove are the labels to put back and take off again.
+	add    []string
+	remove []string
+	// untrash is set if the change moved the message to Trash.
+	untrash bool
+	// thread is set if the change was made to the whole thread.
+	thread bool
+	status string
+}
+
+// undoneMsg reports a change that was undone.
+type undoneMsg struct {
+	step  undoStep
+	email Email
+}
+
+// reverseOf returns the step that puts e back as it was before its labels
+// became after.
+func reverseOf(e Email, after []string, status string) *undoStep {
+	step := &undoStep{email: e, status: status}
+	for _, l := range e.Labels {
+		if !slices.Contains(after, l) {
+			step.add = append(step.add, l)
+		}
+	}
+	for _, l := range after {
+		if l == "TRASH" && !e.hasLabel(l) {
+			step.untrash = true
+		} else if !e.hasLabel(l) {
+			step.remove = append(step.remove, l)
+		}
+	}
+	return step
+}
+
+// changes reports whether undoing the step would change anything.
+func (s *undoStep) changes() bool {
+	return s != nil && (len(s.add) > 0 || len(s.remove) > 0 || s.untrash)
+}
+
+// pushUndo records a change that can be undone, dropping the oldest once
+// there are maxUndo. It is called before the list reflects the change.
+func (m Model) pushUndo(step undoStep) Model {
+	step.index = max(m.emailIndex(step.email.ID), 0)
+	m.undo = append(slices.Clone(m.undo), step)
+	if len(m.undo) > maxUndo {
+		m.undo = m.undo[len(m.undo)-maxUndo:]
+	}
+	return m
+}
+
+// undoLast reverses the most recent change.
+func (m Model) undoLast() (Model, tea.Cmd) {
+	if len(m.undo) == 0 {
+		m.status = "Nothing to undo"
+		return m, nil
+	}
+	step := m.undo[len(m.undo)-1]
+	m.undo = m.undo[:len(m.undo)-1]
+	m.status = "Undoing..."
+	return m, func() tea.Msg {
+		id := cmp.Or(step.id, step.email.ID)
+		if step.untrash {
+			msg, err := m.mail.Untrash(m.ctx, id)
+			if err != nil {
+				return errMsg(fmt.Errorf("unable to undo: %w", err))
+			}
+			id = cmp.Or(msg.Id, id)
+		}
+		if step.thread {
+			_, err := m.gmailSvc.Users.Threads.Modify("me", step.email.ThreadID, &gmail.ModifyThreadRequest{
+				AddLabelIds:    step.add,
+				RemoveLabelIds: step.remove,
+			}).Context(m.ctx).Do()
+			if err != nil {
+				return errMsg(fmt.Errorf("unable to undo: %w", err))
+			}
+			return undoneMsg{step: step, email: step.email}
+		}
+		msg, err := m.mail.Modify(m.ctx, id, step.add, step.remove)
+		if err != nil {
+			return errMsg(fmt.Errorf("unable to undo: %w", err))
+		}
+		e := step.email
+		e.ID = cmp.Or(msg.Id, id)
+		e.Labels = msg.LabelIds
+		return undoneMsg{step: step, email: e}
+	}
+}
+
+// applyUndone puts the message back in the list where it was, or updates
+// it if it never left, and moves the cursor to it.
+func (m Model) applyUndone(msg undoneMsg) Model {
+	m.status = "Undone: " + msg.step.status
+	e := msg.email
+	if i := m.emailIndex(e.ID); i >= 0 {
+		if old := m.list.Items()[i].(Email); old.loaded && !e.loaded {
+			e.Body, e.cut, e.loaded = old.Body, old.cut, true
+		}
+		m.list.SetItem(i, e)
+	} else {
+		m.list.InsertItem(min(msg.step.index, len(m.list.Items())), e)
+	}
+	if m.state == listView {
+		m.list.Select(m.emailIndex(e.ID))
+	}
+	if m.selectedMail != nil && m.selectedMail.ID == e.ID {
+		m.selectedMail = &e
+	}
+	return m
+}
+
+// trashEmail moves e to Trash, or restores it if it is in Trash.
+func (m Model) trashEmail(e Email) tea.Cmd {
+	if e.hasLabel("TRASH") {
+		return m.untrashEmail(e)
+	}
+	return func() tea.Msg {
+		msg, err := m.mail.Trash(m.ctx, e.ID)
+		if err != nil {
+			return errMsg(fmt.Errorf("unable to move message to Trash: %w", err))
+		}
+		after := e
+		after.Labels = msg.LabelIds
+		undo := reverseOf(e, msg.LabelIds, "Moved to Trash")
+		undo.id = msg.Id
+		return labelsChangedMsg{email: after, removed: true, status: "Moved to Trash", undo: undo}
+	}
+}
+
+// untrashEmail takes e out of Trash, back to where it was before.
+func (m Model) untrashEmail(e Email) tea.Cmd {
+	return func() tea.Msg {
+		msg, err := m.mail.Untrash(m.ctx, e.ID)
+		if err != nil {
+			return errMsg(fmt.Errorf("unable to restore message: %w", err))
+		}
+		after := e
+		after.Labels = msg.LabelIds
+		undo := reverseOf(e, msg.LabelIds, "Restored from Trash")
+		undo.id = msg.Id
+		return labelsChangedMsg{email: after, removed: true, status: "Restored from Trash", undo: undo}
+	}
+}
+
+// toggleImportant marks e as important, or as not important if it is.
+func (m Model) toggleImportant(e Email) tea.Cmd {
+	if e.hasLabel("IMPORTANT") {
+		return m.modifyLabels(e, nil, []string{"IMPORTANT"}, false, "Marked as not important")
+	}
+	return m.modifyLabels(e, []string{"IMPORTANT"}, nil, false, "Marked as important")
+}
+
+// toggleRead marks e as read, or as unread if it has been read.
+func (m Model) toggleRead(e Email) tea.Cmd {
+	if e.hasLabel("UNREAD") {
+		return m.modifyLabels(e, nil, []string{"UNREAD"}, false, "Marked as read")
+	}
+	return m.modifyLabels(e, []string{"UNREAD"}, nil, false, "Marked as unread")
+}
@@ -0,0 +1,49 @@
+package app
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// nextUnread returns the index of the first unread message after from,
+// stepping by step through the visible rows, or -1 if there is none.
+func (m Model) nextUnread(from, step int) int {
+	items := m.list.VisibleItems()
+	for i := from + step; i >= 0 && i < len(items); i += step {
+		if e, ok := items[i].(Email); ok && e.hasLabel("UNREAD") {
+			return i
+		}
+	}
+	return -1
+}
+
+// jumpUnread moves to the next unread message below the cursor, or above
+// it if step is negative. In the reading view that message is opened.
+func (m Model) jumpUnread(step int) (Model, tea.Cmd) {
+	i := m.nextUnread(m.list.Index(), step)
+	if i < 0 {
+		m.status = "No more unread messages above"
+		if step > 0 {
+			m.status = "No more unread messages below"
+		}
+		return m, nil
+	}
+	return m.selectUnread(i)
+}
+
+// firstUnread moves to the topmost unread message in the list.
+func (m Model) firstUnread() (Model, tea.Cmd) {
+	i := m.nextUnread(-1, 1)
+	if i < 0 {
+		m.status = "No unread messages in the list"
+		return m, nil
+	}
+	return m.selectUnread(i)
+}
+
+func (m Model) selectUnread(i int) (Model, tea.Cmd) {
+	m.list.Select(i)
+	if m.state == messageView {
+		return m.openSelected()
+	}
+	return m, m.prefetchBodies()
+}
@@ -0,0 +1,189 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"google.golang.org/api/gmail/v1"
+
+	"gmail-tui/internal/browser"
+)
+
+// unsubscribeRequest is an unsubscribe waiting for confirmation, built from
+// a message's List-Unsubscribe and List-Unsubscribe-Post headers.
+type unsubscribeRequest struct {
+	email    Email
+	httpURL  string
+	mailto   string
+	oneClick bool
+}
+
+type unsubscribedMsg string
+
+// parseListUnsubscribe extracts the web and mailto targets from a
+// List-Unsubscribe header. A plain HTTP page is only used when there is
+// neither an HTTPS page nor an address.
+// One-click (RFC 8058) applies when the POST header is present and the
+// page is HTTPS.
+func parseListUnsubscribe(header, post string) unsubscribeRequest {
+	var req unsubscribeRequest
+	var plain string
+	for _, item := range listTargets(header) {
+		lower := strings.ToLower(item)
+		switch {
+		case strings.HasPrefix(lower, "https://") && req.httpURL == "":
+			req.httpURL = item
+		case strings.HasPrefix(lower, "http://") && plain == "":
+			plain = item
+		case strings.HasPrefix(lower, "mailto:") && req.mailto == "":
+			req.mailto = item
+		}
+	}
+	req.oneClick = req.httpURL != "" && strings.Contains(post, "List-Unsubscribe=One-Click")
+	if req.httpURL == "" && req.mailto == "" {
+		req.httpURL = plain
+	}
+	return req
+}
+
+// listTargets returns the URLs of a List-Unsubscribe header. As RFC 2369
+// has it, each is enclosed in angle brackets, so commas inside one don't
+// separate it, and whitespace inside one is folding to be removed.
+func listTargets(header string) []string {
+	var targets []string
+	for {
+		_, rest, ok := strings.Cut(header, "<")
+		if !ok {
+			return targets
+		}
+		target, rest, ok := strings.Cut(rest, ">")
+		if !ok {
+			return targets
+		}
+		if target = strings.Join(strings.Fields(target), ""); target != "" {
+			targets = append(targets, target)
+		}
+		header = rest
+	}
+}
+
+func (m Model) startUnsubscribe(e Email) Model {
+	req := parseListUnsubscribe(e.ListUnsubscribe, e.ListUnsubscribePost)
+	if req.httpURL == "" && req.mailto == "" {
+		m.status = "This message has no unsubscribe link"
+		return m
+	}
+	req.email = e
+	m.unsubscribe = &req
+	return m
+}
+
+func (m Model) updateUnsubscribe(msg tea.KeyMsg) (Model, tea.Cmd) {
+	req := *m.unsubscribe
+	m.unsubscribe = nil
+
+	switch {
+	case key.Matches(msg, m.keys.Confirm):
+		return m, m.unsubscribeCmd(req, false, false)
+	case key.Matches(msg, m.keys.UnsubArchive):
+		return m, m.unsubscribeCmd(req, true, false)
+	case key.Matches(msg, m.keys.UnsubFilter):
+		return m, m.unsubscribeCmd(req, true, true)
+	}
+	return m, nil
+}
+
+func (m Model) unsubscribePrompt() string {
+	req := m.unsubscribe
+	how := "by opening the unsubscribe page"
+	switch {
+	case req.oneClick:
+		how = "with one-click HTTPS"
+	case strings.HasPrefix(strings.ToLower(req.httpURL), "http://"):
+		how = "by opening the unencrypted page " + req.httpURL
+	case req.httpURL == "":
+		how = "by email to " + strings.TrimPrefix(req.mailto, "mailto:")
+	}
+	return fmt.Sprintf("Unsubscribe from %s %s?\ny: unsubscribe • a: and archive • f: archive and filter future mail • n: cancel", req.email.From, how)
+}
+
+// unsubscribeCmd performs the unsubscribe, then optionally archives the
+// message and adds a filter that skips the inbox for the sender.
+func (m Model) unsubscribeCmd(req unsubscribeRequest, archive, filter bool) tea.Cmd {
+	return func() tea.Msg {
+		var err error
+		status := "Unsubscribed"
+		switch {
+		case req.oneClick:
+			err = oneClickUnsubscribe(req.httpURL)
+		case req.httpURL != "":
+			err = browser.Open(req.httpURL)
+			status = "Opened unsubscribe page"
+		default:
+			err = m.mailtoUnsubscribe(req.mailto)
+			status = "Unsubscribe email sent"
+		}
+		if err != nil {
+			return errMsg(fmt.Errorf("unable to unsubscribe: %w", err))
+		}
+
+		if archive {
+			if _, err := m.mail.Modify(m.ctx, req.email.ID, nil, []string{"INBOX"}); err != nil {
+				return errMsg(fmt.Errorf("unable to archive message: %w", err))
+			}
+			runHook("archive", m.config.Hooks.OnArchive, emailEvent(req.email))
+			status += ", archived"
+		}
+
+		if filter {
+			_, err := m.gmailSvc.Users.Settings.Filters.Create("me", &gmail.Filter{
+				Criteria: &gmail.FilterCriteria{From: senderAddress(req.email.From)},
+				Action:   &gmail.FilterAction{RemoveLabelIds: []string{"INBOX"}},
+			}).Context(m.ctx).Do()
+			if err != nil {
+				return errMsg(fmt.Errorf("unable to create filter: %w", err))
+			}
+			status += ", future mail filtered"
+		}
+
+		return unsubscribedMsg(status)
+	}
+}
+
+// unsubscribeClient makes one-click unsubscribe requests, without the
+// OAuth token, like remoteClient.
+var unsubscribeClient = &http.Client{Timeout: 15 * time.Second}
+
+func oneClickUnsubscribe(target string) error {
+	resp, err := unsubscribeClient.Post(target, "application/x-www-form-urlencoded", strings.NewReader("List-Unsubscribe=One-Click"))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unsubscribe endpoint returned %s", resp.Status)
+	}
+	return nil
+}
+
+// mailtoUnsubscribe sends the message described by a mailto: URL.
+func (m Model) mailtoUnsubscribe(target string) error {
+	d, err := parseMailto(target)
+	if err != nil {
+		return err
+	}
+	if d.Subject == "" {
+		d.Subject = "unsubscribe"
+	}
+
+	raw, err := rawMessage(m.ctx, m.mail, d)
+	if err != nil {
+		return err
+	}
+	_, err = m.mail.Send(m.ctx, raw)
+	return err
+}
@@ -0,0 +1,883 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"gmail-tui/internal/ui/compose"
+)
+
+func (m Model) update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmds []tea.Cmd
+
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		if m.hud {
+			msg.Height -= hudLines
+		}
+		m.width = msg.Width
+		m.height = msg.Height
+		m.list.SetWidth(msg.Width)
+		m.list.SetHeight(msg.Height - 6 - lipgloss.Height(m.tabBar()))
+		m.drafts.SetWidth(msg.Width)
+		m.drafts.SetHeight(msg.Height - 6)
+		m.filters.SetWidth(msg.Width)
+		m.filters.SetHeight(msg.Height - 6)
+		m.signatures.SetWidth(msg.Width)
+		m.signatures.SetHeight(msg.Height - 6)
+		m.scheduled.SetWidth(msg.Width)
+		m.scheduled.SetHeight(msg.Height - 6)
+		m.contactList.SetWidth(msg.Width)
+		m.contactList.SetHeight(msg.Height - 6)
+		if m.failure != nil && m.failure.details != nil {
+			m.failure.details.Width = msg.Width
+			m.failure.details.Height = max(msg.Height-4, 1)
+		}
+		if m.helpScreen != nil {
+			m.helpScreen.viewport.Width = msg.Width
+			m.helpScreen.viewport.Height = max(msg.Height-4, 1)
+		}
+
+		if m.state == messageView || m.state == composeView {
+			m.viewport.Width = msg.Width - 4
+			m.viewport.Height = msg.Height - 7
+		}
+		if m.state == composeView && m.previewing {
+			m.viewport.SetContent(m.composeContent())
+		}
+		if m.editor != nil {
+			m.editor.resize(msg.Width, msg.Height)
+		}
+		if m.state == messageView && m.selectedMail != nil {
+			m.matches = findMatches(m.bodyText(), m.searchQuery)
+			m.match = min(m.match, max(len(m.matches)-1, 0))
+			m.viewport.SetContent(m.messageContent())
+		}
+
+	case tea.MouseMsg:
+		if m.state == listView || m.state == messageView {
+			return m.updateMouse(msg)
+		}
+
+	case tea.KeyMsg:
+		m.status = ""
+
+		if m.reauth != nil {
+			return m.updateReauth(msg)
+		}
+		if m.failure != nil {
+			return m.updateFailure(msg)
+		}
+		if m.helpScreen != nil {
+			return m.updateHelp(msg)
+		}
+		if m.palette != nil {
+			return m.updatePalette(msg)
+		}
+		if m.rsvp != nil {
+			return m.updateRSVP(msg)
+		}
+		if m.unsubscribe != nil {
+			return m.updateUnsubscribe(msg)
+		}
+		if m.confirmation != nil {
+			return m.updateConfirmation(msg)
+		}
+		if m.quitting {
+			return m.updateQuitPrompt(msg)
+		}
+		if m.snooze != nil {
+			return m.updateSnooze(msg)
+		}
+		if m.followUp != nil {
+			return m.updateFollowUp(msg)
+		}
+		if m.profile != nil {
+			return m.updateProfile(msg)
+		}
+		if m.purge != nil {
+			return m.updatePurge(msg)
+		}
+		if key.Matches(msg, m.keys.HUD) {
+			return m.toggleHUD()
+		}
+
+		// The draft is saved over whichever view compose was opened from.
+		if m.loading && m.compose != nil && key.Matches(msg, m.keys.Back) && m.compose.StopUpload() {
+			m.status = "Cancelling upload..."
+			return m, nil
+		}
+
+		if m.pending != nil && key.Matches(msg, m.keys.Undo) && m.state != composeView &&
+			m.list.FilterState() != list.Filtering && m.drafts.FilterState() != list.Filtering &&
+			m.filters.FilterState() != list.Filtering && m.filterForm == nil && m.state != vacationView &&
+			m.signatures.FilterState() != list.Filtering && m.scheduled.FilterState() != list.Filtering &&
+			m.contactList.FilterState() != list.Filtering {
+			return m.undoSend(), nil
+		}
+
+		switch m.state {
+		case composeView:
+			if m.confirming {
+				return m.updateConfirmSend(msg)
+			}
+			return m.updateCompose(msg)
+		case draftsView:
+			return m.updateDrafts(msg)
+		case filtersView:
+			return m.updateFilters(msg)
+		case vacationView:
+			return m.updateVacation(msg)
+		case signaturesView:
+			return m.updateSignatures(msg)
+		case scheduledView:
+			return m.updateScheduled(msg)
+		case contactsView:
+			return m.updateContacts(msg)
+		case messageView:
+			return m.updateMessage(msg)
+		}
+
+		if m.list.FilterState() == list.Filtering {
+			break
+		}
+
+		if m.yankPending {
+			if i, ok := m.list.SelectedItem().(Email); ok {
+				return m.updateYank(msg, i)
+			}
+			m.yankPending = false
+			return m, nil
+		}
+
+		var (
+			moved bool
+			cmd   tea.Cmd
+		)
+		if m, cmd, moved = m.listMotion(msg); moved {
+			return m, cmd
+		}
+
+		switch {
+		case key.Matches(msg, m.keys.Quit):
+			return m.quit()
+		case key.Matches(msg, m.keys.Help):
+			return m.openHelp()
+		case key.Matches(msg, m.keys.Fetch):
+			m.loading = true
+			return m.refreshEmails()
+		case key.Matches(msg, m.keys.Select):
+			return m.openSelected()
+		case key.Matches(msg, m.keys.OpenWeb):
+			if i, ok := m.list.SelectedItem().(Email); ok {
+				m = m.openInWeb(i)
+			}
+		case key.Matches(msg, m.keys.Yank):
+			m.yankPending = true
+			return m, nil
+		case key.Matches(msg, m.keys.Unsubscribe):
+			if i, ok := m.list.SelectedItem().(Email); ok {
+				m = m.startUnsubscribe(i)
+			}
+		case key.Matches(msg, m.keys.Spam):
+			if i, ok := m.list.SelectedItem().(Email); ok {
+				return m, m.toggleSpam(i)
+			}
+		case key.Matches(msg, m.keys.Block):
+			if i, ok := m.list.SelectedItem().(Email); ok {
+				m = m.blockSender(i)
+			}
+		case key.Matches(msg, m.keys.Profile):
+			if i, ok := m.list.SelectedItem().(Email); ok {
+				return m.openProfile(i)
+			}
+		case key.Matches(msg, m.keys.Empty):
+			return m.startPurge(m.place)
+		case key.Matches(msg, m.keys.Mute):
+			if i, ok := m.list.SelectedItem().(Email); ok {
+				return m, m.toggleMute(i)
+			}
+		case key.Matches(msg, m.keys.Priority):
+			if i, ok := m.list.SelectedItem().(Email); ok {
+				return m, m.toggleImportant(i)
+			}
+		case key.Matches(msg, m.keys.Snooze):
+			if i, ok := m.list.SelectedItem().(Email); ok {
+				return m.startSnooze(i)
+			}
+		case key.Matches(msg, m.keys.FollowUp):
+			if i, ok := m.list.SelectedItem().(Email); ok {
+				return m.startFollowUp(&i)
+			}
+		case key.Matches(msg, m.keys.Compose):
+			return m.openCompose(compose.Draft{})
+		case key.Matches(msg, m.keys.Drafts):
+			m.state = draftsView
+			m.loading = true
+			return m, m.fetchDrafts
+		case key.Matches(msg, m.keys.Filters):
+			return m.openFilters()
+		case key.Matches(msg, m.keys.Vacation):
+			return m.openVacation()
+		case key.Matches(msg, m.keys.Scheduled):
+			return m.openScheduled(), nil
+		case key.Matches(msg, m.keys.Signatures):
+			m.state = signaturesView
+			m.loading = true
+			return m, m.fetchAliases
+		case key.Matches(msg, m.keys.Contacts):
+			return m.openContacts()
+		case key.Matches(msg, m.keys.Command):
+			return m.openPalette()
+		case key.Matches(msg, m.keys.NextTab):
+			return m.switchTab(1)
+		case key.Matches(msg, m.keys.PrevTab):
+			return m.switchTab(-1)
+		case key.Matches(msg, m.keys.Sort):
+			m = m.cycleSort()
+			return m, m.prefetchBodies()
+		case key.Matches(msg, m.keys.Group):
+			return m.cycleGroup(), nil
+		case key.Matches(msg, m.keys.Trash):
+			if i, ok := m.list.SelectedItem().(Email); ok {
+				return m, m.trashEmail(i)
+			}
+		case key.Matches(msg, m.keys.Read):
+			if i, ok := m.list.SelectedItem().(Email); ok {
+				return m, m.toggleRead(i)
+			}
+		case key.Matches(msg, m.keys.Undo):
+			return m.undoLast()
+		case key.Matches(msg, m.keys.Unread):
+			return m.toggleQuick("unread")
+		case key.Matches(msg, m.keys.NextUnread):
+			return m.jumpUnread(1)
+		case key.Matches(msg, m.keys.PrevUnread):
+			return m.jumpUnread(-1)
+		case key.Matches(msg, m.keys.Starred):
+			return m.toggleQuick("starred")
+		case key.Matches(msg, m.keys.HasFiles):
+			return m.toggleQuick("attachments")
+		case key.Matches(msg, m.keys.Back) && len(m.quick) > 0 && m.list.FilterState() == list.Unfiltered:
+			return m.clearQuick()
+		case m.plugins.bound(msg.String()) && !listKey(m.list.KeyMap, msg):
+			if i, ok := m.list.SelectedItem().(Email); ok {
+				return m, m.runBinding(msg.String(), i)
+			}
+		}
+
+	case EmailsMsg:
+		m.loading = false
+		m = m.setEmails(msg.emails)
+		if m.offline != nil {
+			var cmd tea.Cmd
+			m, cmd = m.backOnline()
+			cmds = append(cmds, cmd)
+		}
+		if m.resume != nil {
+			var cmd tea.Cmd
+			m, cmd = m.resumeSession()
+			cmds = append(cmds, cmd)
+		}
+		var fresh []Email
+		fresh, m.newestMail = newMail(msg.emails, m.newestMail)
+		for _, e := range fresh {
+			runHook("new_mail", m.config.Hooks.OnNewMail, emailEvent(e))
+		}
+		if msg.failed > 0 {
+			m.status = fmt.Sprintf("%d message(s) could not be loaded • r: retry", msg.failed)
+		}
+		if msg.status != "" {
+			m.status = msg.status
+		}
+		if m.labels.missing(msg.emails) {
+			cmds = append(cmds, m.fetchListLabels)
+		}
+
+	case tabCountsMsg:
+		m.tabUnread = msg
+
+	case DraftsMsg:
+		m.loading = false
+		var items []list.Item
+		for _, d := range msg {
+			items = append(items, d)
+		}
+		m.drafts.SetItems(items)
+
+	case labelsMsg:
+		m.labels = msg.labels
+		m.list.SetDelegate(m.emailDelegate())
+		m = m.nameLabels()
+
+	case FiltersMsg:
+		m.loading = false
+		m.labels = msg.labels
+		var items []list.Item
+		for _, f := range msg.filters {
+			items = append(items, f)
+		}
+		m.filters.SetItems(items)
+
+	case filterSavedMsg:
+		m.loading = false
+		m.status = string(msg)
+		if m.state == filtersView {
+			m.loading = true
+			return m, m.fetchFilters
+		}
+		return m, nil
+
+	case aliasesMsg:
+		m.aliases = msg
+		if m.mailto != nil {
+			// Opened as a mailto: handler; compose once the default
+			// alias is known.
+			d := *m.mailto
+			m.mailto = nil
+			return m.openCompose(d)
+		}
+		if m.state == signaturesView {
+			m.loading = false
+			var items []list.Item
+			for _, a := range msg {
+				items = append(items, a)
+			}
+			m.signatures.SetItems(items)
+		}
+		return m, nil
+
+	case signatureEditedMsg:
+		if msg.err != nil {
+			os.Remove(msg.path)
+			m = m.fail(fmt.Errorf("editor failed: %v", msg.err))
+			return m, nil
+		}
+		m.loading = true
+		return m, m.saveSignature(msg)
+
+	case signatureSavedMsg:
+		m.status = string(msg)
+		return m, m.fetchAliases
+
+	case purgeCountedMsg:
+		return m.updatePurgeCounted(msg)
+
+	case purgedMsg:
+		return m.updatePurged(msg)
+
+	case senderProfileMsg:
+		return m.applyProfile(msg), nil
+
+	case contactCardsMsg:
+		return m.applyContactCards(msg), nil
+
+	case contactMailMsg:
+		return m.applyContactMail(msg), nil
+
+	case vacationMsg:
+		m.loading = false
+		m.vacation = newVacationForm(msg.settings, m.width, m.height)
+		return m, m.vacation.setFocus(vacationEnabled)
+
+	case vacationSavedMsg:
+		m.loading = false
+		m.vacation = nil
+		m.state = listView
+		m.status = string(msg)
+		return m, nil
+
+	case draftDeletedMsg:
+		m.status = "Draft deleted"
+		if m.state == draftsView {
+			m.loading = true
+			return m, m.fetchDrafts
+		}
+		return m, nil
+
+	case editorFinishedMsg:
+		if msg.err != nil {
+			msg.session.StopAutosave()
+			m = m.fail(fmt.Errorf("editor failed: %v", msg.err))
+			return m, nil
+		}
+		m.loading = true
+		return m, m.finishCompose(msg.session)
+
+	case composeSavedMsg:
+		m.loading = false
+		m.status = msg.status
+		m.state = composeView
+		m.viewport.Width = m.width - 4
+		m.viewport.Height = m.height - 7
+		m.viewport.SetContent(m.composeContent())
+		return m, nil
+
+	case uploadTickMsg:
+		return m.updateUpload(msg)
+
+	case composeChangedMsg:
+		m.loading = false
+		m.status = msg.status
+		m.viewport.SetContent(m.composeContent())
+		return m, nil
+
+	case contactsTickMsg:
+		return m, tea.Batch(m.refreshContacts, contactsTick(m.config.ContactsRefreshInterval()))
+
+	case contactsRefreshedMsg:
+		return m, nil
+
+	case outboxTickMsg:
+		return m, tea.Batch(m.dispatchOutbox, outboxTick())
+
+	case outboxSentMsg:
+		if msg.sent > 0 {
+			m.status = fmt.Sprintf("Sent %d message(s) from the outbox", msg.sent)
+		}
+		if m.state == scheduledView {
+			m = m.refreshScheduled()
+		}
+		return m, nil
+
+	case refreshTickMsg:
+		return m.autoRefresh()
+
+	case refreshFailedMsg:
+		return m.updateRefreshFailed(msg), nil
+
+	case watchTickMsg:
+		return m, m.checkWatch(msg.watch)
+
+	case watchCheckedMsg:
+		return m.updateWatch(msg)
+
+	case snoozeTickMsg:
+		return m, tea.Batch(m.wakeSnoozed, m.checkFollowUps, m.archiveMuted, snoozeTick())
+
+	case mutedMsg:
+		return m.updateMuted(msg)
+
+	case followUpsDueMsg:
+		return m.updateFollowUpsDue(msg), nil
+
+	case snoozeWokeMsg:
+		if msg == 0 {
+			return m, nil
+		}
+		m.status = fmt.Sprintf("%d snoozed message(s) back in the inbox", int(msg))
+		return m.refreshEmails()
+
+	case exportStartedMsg, exportedMsg:
+		return m.updateExport(msg)
+
+	case attachmentSavedMsg:
+		return m.updateAttachmentSaved(msg)
+
+	case openedMsg:
+		if msg.err != nil {
+			m.status = fmt.Sprintf("Unable to open %s: %v", msg.name, msg.err)
+		} else {
+			m.status = "Opened " + msg.name
+		}
+		return m, nil
+
+	case sourceMsg:
+		if m.state != messageView || m.selectedMail == nil || m.selectedMail.ID != msg.id {
+			return m, nil
+		}
+		m.source = msg.text
+		m.status = ""
+		m = m.clearSearch()
+		m.viewport.SetContent(m.messageContent())
+		m.viewport.GotoTop()
+		return m, nil
+
+	case sendTickMsg:
+		if m.pending == nil || m.pending.session != msg.session {
+			return m, nil
+		}
+		if time.Now().Before(m.pending.deadline) {
+			return m, sendTick(msg.session)
+		}
+		m.pending = nil
+		m.status = "Sending..."
+		return m, m.sendDraft(msg.session)
+
+	case labelsChangedMsg:
+		return m.applyLabelsChanged(msg)
+
+	case markReadMsg:
+		return m.applyMarkRead(msg)
+
+	case markedReadMsg:
+		return m.applyMarkedRead(msg), nil
+
+	case undoneMsg:
+		return m.applyUndone(msg), nil
+
+	case bodyMsg:
+		return m.applyBody(msg), nil
+
+	case prefetchFailedMsg:
+		// The open message was waiting on this prefetch.
+		if m.selectedMail != nil && m.selectedMail.ID == msg.id && !m.selectedMail.loaded {
+			return m, m.fetchBody(msg.id, false)
+		}
+		return m, nil
+
+	case filterCreatedMsg:
+		m.status = string(msg)
+		return m, nil
+
+	case maildirSyncedMsg:
+		return m.applyMaildirSynced(msg), nil
+
+	case linkOpenedMsg:
+		m.status = string(msg)
+		return m, nil
+
+	case unsubscribedMsg:
+		m.status = string(msg)
+		return m, nil
+
+	case pluginDoneMsg:
+		m.status = msg.status
+		if msg.changed {
+			return m.refreshEmails()
+		}
+		return m, nil
+
+	case sentMsg:
+		msg.session.Remove()
+		m.status = "Message sent"
+		if msg.queued {
+			// Stay, so the outbox can retry it.
+			m.status = "Unable to reach Gmail; message queued in the outbox"
+			return m, nil
+		}
+		if msg.quit {
+			return m, tea.Quit
+		}
+		return m, nil
+
+	case errMsg:
+		if m.auth.Expired() {
+			if m.reauth == nil {
+				m.reauth = &reauthPrompt{}
+			}
+			return m, nil
+		}
+		if m.offline != nil && isTransient(msg) {
+			m = m.clearPending()
+			m.loading = false
+			m.status = fmt.Sprintf("Offline: %v", msg)
+			return m, nil
+		}
+		m = m.fail(msg)
+		return m, nil
+
+	case rsvpSentMsg:
+		m.status = string(msg)
+		return m, nil
+
+	case imagesMsg:
+		if len(msg) == 0 {
+			m.status = "No images in this message"
+			return m, nil
+		}
+		m.status = ""
+		return m, m.showImages(msg)
+
+	case imagesClosedMsg:
+		if msg.err != nil {
+			m.status = fmt.Sprintf("Unable to show images: %v", msg.err)
+		}
+		return m, nil
+
+	case reauthMsg:
+		m.reauth = nil
+		if msg.err != nil {
+			m = m.fail(msg.err)
+			return m, nil
+		}
+		m.status = "Signed in again"
+		m.loading = m.state == listView
+		return m.refreshEmails()
+
+	case offlineMsg:
+		return m.updateOffline(msg)
+
+	case loadTickMsg:
+		return m.updateLoad(msg)
+
+	case reconnectMsg:
+		return m.reconnect(msg)
+
+	case spinner.TickMsg:
+		var cmd tea.Cmd
+		m.spinner, cmd = m.spinner.Update(msg)
+		return m, cmd
+	}
+
+	if m.snooze != nil {
+		var cmd tea.Cmd
+		m.snoozeInput, cmd = m.snoozeInput.Update(msg)
+		cmds = append(cmds, cmd)
+	}
+	if m.followUp != nil {
+		var cmd tea.Cmd
+		m.followUp.input, cmd = m.followUp.input.Update(msg)
+		cmds = append(cmds, cmd)
+	}
+	if m.purge != nil {
+		var cmd tea.Cmd
+		m.purge.input, cmd = m.purge.input.Update(msg)
+		cmds = append(cmds, cmd)
+	}
+	if m.palette != nil {
+		var cmd tea.Cmd
+		m.palette.Input, cmd = m.palette.Input.Update(msg)
+		cmds = append(cmds, cmd)
+	}
+	if m.helpScreen != nil {
+		var cmd tea.Cmd
+		m.helpScreen.filter, cmd = m.helpScreen.filter.Update(msg)
+		cmds = append(cmds, cmd)
+	}
+	if m.reauth != nil && m.reauth.pasting {
+		var cmd tea.Cmd
+		m.reauth.code, cmd = m.reauth.code.Update(msg)
+		cmds = append(cmds, cmd)
+	}
+
+	switch m.state {
+	case listView:
+		newList, cmd := m.list.Update(msg)
+		m.list = newList
+		cmds = append(cmds, cmd, m.prefetchBodies())
+	case draftsView:
+		var cmd tea.Cmd
+		m.drafts, cmd = m.drafts.Update(msg)
+		cmds = append(cmds, cmd)
+	case filtersView:
+		var cmd tea.Cmd
+		if m.filterForm != nil && m.filterForm.focus < len(m.filterForm.inputs) {
+			f := m.filterForm.focus
+			m.filterForm.inputs[f], cmd = m.filterForm.inputs[f].Update(msg)
+		} else {
+			m.filters, cmd = m.filters.Update(msg)
+		}
+		cmds = append(cmds, cmd)
+	case signaturesView:
+		var cmd tea.Cmd
+		m.signatures, cmd = m.signatures.Update(msg)
+		cmds = append(cmds, cmd)
+	case scheduledView:
+		var cmd tea.Cmd
+		m.scheduled, cmd = m.scheduled.Update(msg)
+		cmds = append(cmds, cmd)
+	case contactsView:
+		if m.contact == nil {
+			var cmd tea.Cmd
+			m.contactList, cmd = m.contactList.Update(msg)
+			cmds = append(cmds, cmd)
+		}
+	case vacationView:
+		if m.vacation != nil {
+			var cmd tea.Cmd
+			m.vacation.subject, cmd = m.vacation.subject.Update(msg)
+			cmds = append(cmds, cmd)
+			m.vacation.start, cmd = m.vacation.start.Update(msg)
+			cmds = append(cmds, cmd)
+			m.vacation.end, cmd = m.vacation.end.Update(msg)
+			cmds = append(cmds, cmd)
+			m.vacation.body, cmd = m.vacation.body.Update(msg)
+			cmds = append(cmds, cmd)
+		}
+	default:
+		var cmd tea.Cmd
+		m.viewport, cmd = m.viewport.Update(msg)
+		cmds = append(cmds, cmd)
+		if m.attaching {
+			m.attachInput, cmd = m.attachInput.Update(msg)
+			cmds = append(cmds, cmd)
+		}
+		if m.addressField != "" {
+			m.addressInput, cmd = m.addressInput.Update(msg)
+			cmds = append(cmds, cmd)
+		}
+		if m.searching {
+			m.searchInput, cmd = m.searchInput.Update(msg)
+			cmds = append(cmds, cmd)
+		}
+		if m.scheduling {
+			m.scheduleInput, cmd = m.scheduleInput.Update(msg)
+			cmds = append(cmds, cmd)
+		}
+	}
+
+	return m, tea.Batch(cmds...)
+}
+
+func (m Model) updateMessage(msg tea.KeyMsg) (Model, tea.Cmd) {
+	if m.searching {
+		return m.updateSearch(msg)
+	}
+	if m.pickingLink {
+		return m.updateLinkPicker(msg)
+	}
+	if m.pickingAttach {
+		return m.updateAttachPicker(msg)
+	}
+	if m.yankPending {
+		return m.updateYank(msg, *m.selectedMail)
+	}
+	var (
+		moved bool
+		cmd   tea.Cmd
+	)
+	if m, cmd, moved = m.viewportMotion(msg); moved {
+		return m, cmd
+	}
+
+	switch {
+	case key.Matches(msg, m.keys.Back) && len(m.matches) > 0:
+		m = m.clearSearch()
+		m.viewport.SetContent(m.messageContent())
+	case key.Matches(msg, m.keys.Back) && m.source != "":
+		m.source = ""
+		m = m.clearSearch()
+		m.viewport.SetContent(m.messageContent())
+		m.viewport.GotoTop()
+	case key.Matches(msg, m.keys.Back):
+		m.selectedMail = nil
+		m.state = listView
+	case key.Matches(msg, m.keys.PageDown):
+		m.viewport.HalfViewDown()
+	case key.Matches(msg, m.keys.PageUp):
+		m.viewport.HalfViewUp()
+	case key.Matches(msg, m.keys.Down):
+		m.viewport.LineDown(1)
+	case key.Matches(msg, m.keys.Up):
+		m.viewport.LineUp(1)
+	case key.Matches(msg, m.keys.NextMsg):
+		if m.list.Index() < len(m.list.VisibleItems())-1 {
+			m.list.CursorDown()
+			return m.openSelected()
+		}
+	case key.Matches(msg, m.keys.PrevMsg):
+		if m.list.Index() > 0 {
+			m.list.CursorUp()
+			return m.openSelected()
+		}
+	case key.Matches(msg, m.keys.NextUnread):
+		return m.jumpUnread(1)
+	case key.Matches(msg, m.keys.PrevUnread):
+		return m.jumpUnread(-1)
+	case key.Matches(msg, m.keys.Links):
+		m = m.openLinkPicker()
+	case key.Matches(msg, m.keys.OpenWeb):
+		m = m.openInWeb(*m.selectedMail)
+	case key.Matches(msg, m.keys.Yank):
+		m.yankPending = true
+	case key.Matches(msg, m.keys.Unsubscribe):
+		m = m.startUnsubscribe(*m.selectedMail)
+	case key.Matches(msg, m.keys.Spam):
+		return m, m.toggleSpam(*m.selectedMail)
+	case key.Matches(msg, m.keys.Block):
+		m = m.blockSender(*m.selectedMail)
+	case key.Matches(msg, m.keys.Help):
+		return m.openHelp()
+	case key.Matches(msg, m.keys.Profile):
+		return m.openProfile(*m.selectedMail)
+	case key.Matches(msg, m.keys.Mute):
+		return m, m.toggleMute(*m.selectedMail)
+	case key.Matches(msg, m.keys.Priority):
+		return m, m.toggleImportant(*m.selectedMail)
+	case key.Matches(msg, m.keys.Snooze):
+		return m.startSnooze(*m.selectedMail)
+	case key.Matches(msg, m.keys.FollowUp):
+		e := *m.selectedMail
+		return m.startFollowUp(&e)
+	case key.Matches(msg, m.keys.Trash):
+		return m, m.trashEmail(*m.selectedMail)
+	case key.Matches(msg, m.keys.Read):
+		return m, m.toggleRead(*m.selectedMail)
+	case key.Matches(msg, m.keys.Undo):
+		return m.undoLast()
+	case key.Matches(msg, m.keys.Filters):
+		return m.openFilters()
+	case key.Matches(msg, m.keys.Headers):
+		m.status = "Loading headers..."
+		return m, m.fetchHeaders(m.selectedMail.ID)
+	case key.Matches(msg, m.keys.Source):
+		m.status = "Loading source..."
+		return m, m.fetchSource(m.selectedMail.ID)
+	case key.Matches(msg, m.keys.Expand) && m.source == "":
+		return m.toggleQuotes(), nil
+	case key.Matches(msg, m.keys.LoadAll) && m.selectedMail.loaded && m.selectedMail.cut > 0:
+		return m.loadWholeBody()
+	case key.Matches(msg, m.keys.SaveText):
+		return m.startExport(exportText, "")
+	case key.Matches(msg, m.keys.RSVP):
+		return m.openRSVP(), nil
+	case key.Matches(msg, m.keys.Images):
+		m.status = "Loading images..."
+		return m, m.fetchImages(m.selectedMail.ID)
+	case key.Matches(msg, m.keys.Files):
+		m = m.openAttachPicker()
+	case key.Matches(msg, m.keys.SaveAll):
+		return m.saveAllAttachments()
+	case key.Matches(msg, m.keys.Search):
+		m.searching = true
+		m.searchInput.Reset()
+		return m, m.searchInput.Focus()
+	case key.Matches(msg, m.keys.NextMatch) && len(m.matches) > 0:
+		m.match = (m.match + 1) % len(m.matches)
+		m = m.showMatch()
+	case key.Matches(msg, m.keys.PrevMatch) && len(m.matches) > 0:
+		m.match = (m.match - 1 + len(m.matches)) % len(m.matches)
+		m = m.showMatch()
+	case key.Matches(msg, m.keys.Command):
+		return m.openPalette()
+	case m.plugins.bound(msg.String()):
+		return m, m.runBinding(msg.String(), *m.selectedMail)
+	}
+	return m, nil
+}
+
+// openSelected shows the message under the list cursor in the reading view,
+// fetching its body first if it has not been loaded yet.
+func (m Model) openSelected() (Model, tea.Cmd) {
+	i, ok := m.list.SelectedItem().(Email)
+	if !ok {
+		return m, nil
+	}
+	m.selectedMail = &i
+	m.state = messageView
+	m.source = ""
+	m.resumeScroll = 0
+	m.expandQuotes = false
+	m = m.clearSearch()
+	m.viewport.Width = m.width - 4
+	m.viewport.Height = m.height - 7
+	m.viewport.SetContent(m.messageContent())
+	m.viewport.GotoTop()
+	m, markRead := m.scheduleMarkRead(i)
+	sessionMetrics.cache("bodies", i.loaded)
+	if !i.loaded && !m.prefetch.running(i.ID) {
+		return m, tea.Batch(m.fetchBody(i.ID, false), m.prefetchBodies(), markRead)
+	}
+	if i.loaded {
+		m = m.cacheBody(i.ID)
+	}
+	return m, tea.Batch(m.prefetchBodies(), markRead)
+}
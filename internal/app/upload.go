@@ -0,0 +1,56 @@
+package app
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/charmbracelet/bubbles/progress"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"gmail-tui/internal/ui/compose"
+	"gmail-tui/internal/ui/view"
+)
+
+// uploadPollInterval is how often the progress of a draft upload is
+// redrawn.
+const uploadPollInterval = 250 * time.Millisecond
+
+// uploadCancelledStatus is shown when a draft's upload was cancelled.
+const uploadCancelledStatus = "Upload cancelled; the draft is as last saved"
+
+type uploadTickMsg struct {
+	session *compose.Session
+}
+
+func uploadTick(c *compose.Session) tea.Cmd {
+	return tea.Tick(uploadPollInterval, func(time.Time) tea.Msg {
+		return uploadTickMsg{session: c}
+	})
+}
+
+// updateUpload redraws the progress of saving the draft, until it is
+// saved.
+func (m Model) updateUpload(msg uploadTickMsg) (Model, tea.Cmd) {
+	if msg.session != m.compose || !m.loading {
+		return m, nil
+	}
+	return m, uploadTick(msg.session)
+}
+
+// uploadStatus describes the upload of the draft being saved, or is ""
+// when none is under way.
+func (m Model) uploadStatus() string {
+	if m.compose == nil {
+		return ""
+	}
+	sent, total, ok := m.compose.Progress()
+	if !ok {
+		return ""
+	}
+	status := fmt.Sprintf("Uploading draft: %s of %s", view.Size(sent), view.Size(total))
+	if !view.Accessible {
+		bar := progress.New(progress.WithDefaultGradient(), progress.WithWidth(30))
+		status += " " + bar.ViewAs(float64(sent)/float64(total))
+	}
+	return status
+}
@@ -0,0 +1,252 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textarea"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"google.golang.org/api/gmail/v1"
+
+	"gmail-tui/internal/mimepart"
+	"gmail-tui/internal/ui/view"
+)
+
+// vacationDateLayout is how start and end dates are entered. Dates are
+// whole days in local time; the end date is inclusive.
+const vacationDateLayout = "2006-01-02"
+
+type vacationMsg struct {
+	settings *gmail.VacationSettings
+}
+
+type vacationSavedMsg string
+
+const (
+	vacationEnabled = iota
+	vacationSubject
+	vacationStart
+	vacationEnd
+	vacationContacts
+	vacationBody
+	vacationFields
+)
+
+// vacationForm edits the out-of-office auto-reply. Settings the form does
+// not show, such as the HTML body, are kept from the fetched settings.
+type vacationForm struct {
+	original *gmail.VacationSettings
+	enabled  bool
+	contacts bool
+	subject  textinput.Model
+	start    textinput.Model
+	end      textinput.Model
+	body     textarea.Model
+	focus    int
+}
+
+func newVacationForm(v *gmail.VacationSettings, width, height int) *vacationForm {
+	form := &vacationForm{
+		original: v,
+		enabled:  v.EnableAutoReply,
+		contacts: v.RestrictToContacts,
+	}
+
+	form.subject = textinput.New()
+	form.subject.Prompt = "Subject:    "
+	form.subject.SetValue(v.ResponseSubject)
+
+	form.start = textinput.New()
+	form.start.Prompt = "First day:  "
+	form.start.Placeholder = vacationDateLayout
+	if v.StartTime != 0 {
+		form.start.SetValue(time.UnixMilli(v.StartTime).Format(vacationDateLayout))
+	}
+
+	form.end = textinput.New()
+	form.end.Prompt = "Last day:   "
+	form.end.Placeholder = vacationDateLayout
+	if v.EndTime != 0 {
+		// Gmail stores the end as an exclusive instant.
+		form.end.SetValue(time.UnixMilli(v.EndTime - 1).Format(vacationDateLayout))
+	}
+
+	form.body = textarea.New()
+	form.body.ShowLineNumbers = false
+	form.body.Placeholder = "Message"
+	form.body.CharLimit = 0
+	form.body.SetWidth(max(width-4, 20))
+	form.body.SetHeight(max(height-16, 3))
+	body := v.ResponseBodyPlainText
+	if body == "" && v.ResponseBodyHtml != "" {
+		body = mimepart.HTMLToText(v.ResponseBodyHtml)
+	}
+	form.body.SetValue(body)
+
+	return form
+}
+
+func (m Model) fetchVacation() tea.Msg {
+	v, err := m.gmailSvc.Users.Settings.GetVacation("me").Context(m.ctx).Do()
+	if err != nil {
+		return errMsg(fmt.Errorf("unable to get vacation responder: %w", err))
+	}
+	return vacationMsg{settings: v}
+}
+
+func (m Model) openVacation() (Model, tea.Cmd) {
+	m.state = vacationView
+	m.vacation = nil
+	m.loading = true
+	return m, m.fetchVacation
+}
+
+func (f *vacationForm) setFocus(i int) tea.Cmd {
+	f.focus = (i + vacationFields) % vacationFields
+	f.subject.Blur()
+	f.start.Blur()
+	f.end.Blur()
+	f.body.Blur()
+	switch f.focus {
+	case vacationSubject:
+		return f.subject.Focus()
+	case vacationStart:
+		return f.start.Focus()
+	case vacationEnd:
+		return f.end.Focus()
+	case vacationBody:
+		return f.body.Focus()
+	}
+	return nil
+}
+
+// settings builds the settings to save from the form, validating dates.
+func (f *vacationForm) settings() (*gmail.VacationSettings, error) {
+	v := *f.original
+	v.EnableAutoReply = f.enabled
+	v.RestrictToContacts = f.contacts
+	v.ResponseSubject = strings.TrimSpace(f.subject.Value())
+	v.ResponseBodyPlainText = f.body.Value()
+	v.ResponseBodyHtml = ""
+	v.StartTime, v.EndTime = 0, 0
+	v.ForceSendFields = []string{"EnableAutoReply", "RestrictToContacts", "ResponseSubject", "ResponseBodyPlainText"}
+
+	if s := strings.TrimSpace(f.start.Value()); s != "" {
+		d, err := time.ParseInLocation(vacationDateLayout, s, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("first day must be YYYY-MM-DD")
+		}
+		v.StartTime = d.UnixMilli()
+	}
+	if s := strings.TrimSpace(f.end.Value()); s != "" {
+		d, err := time.ParseInLocation(vacationDateLayout, s, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("last day must be YYYY-MM-DD")
+		}
+		v.EndTime = d.AddDate(0, 0, 1).UnixMilli()
+	}
+	if v.StartTime != 0 && v.EndTime != 0 && v.EndTime <= v.StartTime {
+		return nil, fmt.Errorf("last day is before first day")
+	}
+	return &v, nil
+}
+
+func (m Model) saveVacation(v *gmail.VacationSettings) tea.Cmd {
+	return func() tea.Msg {
+		if _, err := m.gmailSvc.Users.Settings.UpdateVacation("me", v).Context(m.ctx).Do(); err != nil {
+			return errMsg(fmt.Errorf("unable to update vacation responder: %w", err))
+		}
+		if v.EnableAutoReply {
+			return vacationSavedMsg("Vacation responder on")
+		}
+		return vacationSavedMsg("Vacation responder off")
+	}
+}
+
+func (m Model) updateVacation(msg tea.KeyMsg) (Model, tea.Cmd) {
+	form := m.vacation
+	if form == nil {
+		if key.Matches(msg, m.keys.Back) {
+			m.state = listView
+		}
+		return m, nil
+	}
+
+	switch {
+	case key.Matches(msg, m.keys.Back):
+		m.vacation = nil
+		m.state = listView
+		return m, nil
+	case key.Matches(msg, m.keys.NextField):
+		return m, form.setFocus(form.focus + 1)
+	case key.Matches(msg, m.keys.PrevField):
+		return m, form.setFocus(form.focus - 1)
+	case key.Matches(msg, m.keys.Save):
+		v, err := form.settings()
+		if err != nil {
+			m.status = err.Error()
+			return m, nil
+		}
+		m.loading = true
+		return m, m.saveVacation(v)
+	case key.Matches(msg, m.keys.Toggle) && form.focus == vacationEnabled:
+		form.enabled = !form.enabled
+		return m, nil
+	case key.Matches(msg, m.keys.Toggle) && form.focus == vacationContacts:
+		form.contacts = !form.contacts
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	switch form.focus {
+	case vacationSubject:
+		form.subject, cmd = form.subject.Update(msg)
+	case vacationStart:
+		form.start, cmd = form.start.Update(msg)
+	case vacationEnd:
+		form.end, cmd = form.end.Update(msg)
+	case vacationBody:
+		form.body, cmd = form.body.Update(msg)
+	}
+	return m, cmd
+}
+
+func (m Model) vacationView() string {
+	form := m.vacation
+	if form == nil {
+		return view.HelpStyle.Render(m.statusLine() + "esc: back")
+	}
+
+	toggle := func(field int, label string, on bool) string {
+		marker := "  "
+		if form.focus == field {
+			marker = "> "
+		}
+		box := "[ ]"
+		if on {
+			box = "[x]"
+		}
+		return fmt.Sprintf("%s%s %s", marker, box, label)
+	}
+
+	lines := []string{
+		view.TitleStyle.Render("Vacation responder"),
+		"",
+		toggle(vacationEnabled, "Auto-reply on", form.enabled),
+		"  " + form.subject.View(),
+		"  " + form.start.View(),
+		"  " + form.end.View(),
+		toggle(vacationContacts, "Only reply to my contacts", form.contacts),
+		"",
+		form.body.View(),
+	}
+
+	return fmt.Sprintf(
+		"\n%s\n\n%s",
+		strings.Join(lines, "\n"),
+		view.HelpStyle.Render(m.statusLine()+"tab/shift+tab: move • space: toggle • ctrl+s: save • esc: back"),
+	)
+}
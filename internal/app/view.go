@@ -0,0 +1,192 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"gmail-tui/internal/backend"
+	"gmail-tui/internal/ui/view"
+)
+
+func (m Model) View() string {
+	if m.failure != nil && m.failure.details != nil {
+		return m.failureView()
+	}
+	if m.reauth != nil {
+		return m.reauthView()
+	}
+	if m.profile != nil {
+		return m.profileView()
+	}
+	if m.helpScreen != nil {
+		return m.helpView()
+	}
+
+	if m.loading && !m.skeleton() && m.editor == nil {
+		text := "Loading emails..."
+		switch {
+		case m.compose != nil && m.uploadStatus() != "":
+			text = m.uploadStatus() + "\n\n   esc: cancel"
+		case m.compose != nil:
+			text = "Saving draft..."
+		case m.state == draftsView:
+			text = "Loading drafts..."
+		case m.state == filtersView:
+			text = "Loading filters..."
+		case m.state == vacationView:
+			text = "Loading vacation responder..."
+		case m.state == signaturesView:
+			text = "Loading signatures..."
+		case m.state == contactsView:
+			text = "Loading contacts..."
+		}
+		return fmt.Sprintf("\n\n   %s %s\n\n", m.spinner.View(), text)
+	}
+
+	switch m.state {
+	case composeView:
+		if m.confirming {
+			return m.confirmSendView()
+		}
+		if m.editor != nil {
+			return m.editorView()
+		}
+		return m.composeView()
+	case filtersView:
+		return m.filtersView()
+	case vacationView:
+		return m.vacationView()
+	case signaturesView:
+		return m.signaturesView()
+	case scheduledView:
+		return m.scheduledView()
+	case contactsView:
+		return m.contactsView()
+	case draftsView:
+		return fmt.Sprintf(
+			"%s\n\n%s",
+			m.drafts.View(),
+			view.HelpStyle.Render(m.statusLine()+"enter: edit • x: delete • r: refresh • esc: back"),
+		)
+	case messageView:
+		header := m.messageHeader()
+		body := m.viewport.View()
+		if !m.selectedMail.loaded && m.source == "" {
+			body = fmt.Sprintf("\n  %s Loading message...", m.spinner.View())
+		}
+		footer := "↑/↓: scroll • J/K: next/prev message • /: search • E: quotes • o: links • w: web • esc: back • ?: help"
+		if n := len(m.selectedMail.files); n > 0 {
+			footer = fmt.Sprintf("A: %d attachment(s) • ", n) + footer
+		}
+		if m.source != "" {
+			footer = "↑/↓: scroll • /: search • esc: back to message"
+		}
+		if s := m.searchStatus(); s != "" {
+			footer = s
+		}
+		if m.pickingLink {
+			body = m.linkPickerView()
+			footer = "↑/↓: move • enter/1-9: open • esc: close"
+		}
+		if m.pickingAttach {
+			body = m.attachPickerView()
+			footer = "↑/↓: move • enter/1-9: preview • o: open in app • S: save all • esc: close"
+		}
+		if m.saving != nil {
+			footer = m.saveProgress()
+		}
+
+		return fmt.Sprintf(
+			"%s\n%s\n\n%s",
+			header,
+			body,
+			view.HelpStyle.Render(m.statusLine()+footer),
+		)
+	}
+
+	body := m.list.View()
+	if tabs := m.tabBar(); tabs != "" {
+		body = tabs + "\n" + body
+	}
+	return fmt.Sprintf(
+		"%s\n\n%s",
+		body,
+		view.HelpStyle.Render(m.statusLine()+m.help.View(m.keys)),
+	)
+}
+
+// messageHeader is the subject, sender and date shown above an open message.
+func (m Model) messageHeader() string {
+	return fmt.Sprintf(
+		"%s\n%s\n%s\n%s\n",
+		view.TitleStyle.Render(m.selectedMail.Subject)+m.labels.headerChips(*m.selectedMail),
+		view.InfoStyle.Render(fmt.Sprintf("From: %s", m.selectedMail.From)),
+		view.InfoStyle.Render(fmt.Sprintf("Date: %s", view.FullDate(m.selectedMail.Date))+
+			signatureNote("PGP", m.selectedMail.pgp)+signatureNote("S/MIME", m.selectedMail.smime)+
+			remoteNote(m.selectedMail.remote)+m.cutNote(m.selectedMail.cut)),
+		view.Rule(m.viewport.Width),
+	)
+}
+
+func (m Model) statusLine() string {
+	// The HUD sits above whatever else the status line shows, in the lines
+	// the views were shortened by.
+	if m.hud {
+		m.hud = false
+		return m.hudView() + "\n" + m.statusLine()
+	}
+	if m.failure != nil {
+		return m.failurePrompt() + "\n"
+	}
+	if m.palette != nil {
+		return m.paletteView() + "\n"
+	}
+	if m.rsvp != nil {
+		return m.rsvpPrompt() + "\n"
+	}
+	if m.unsubscribe != nil {
+		return m.unsubscribePrompt() + "\n"
+	}
+	if m.confirmation != nil {
+		return m.confirmation.prompt + "\n"
+	}
+	if m.quitting {
+		return m.quitPrompt() + "\n"
+	}
+	if m.snooze != nil {
+		return m.snoozePrompt() + "\n"
+	}
+	if m.followUp != nil {
+		return m.followUpPrompt() + "\n"
+	}
+	if m.purge != nil {
+		return m.purgePrompt() + "\n"
+	}
+
+	var parts []string
+	if s := m.offlineBanner(); s != "" {
+		parts = append(parts, s)
+	}
+	if s := m.loadStatus(); s != "" {
+		parts = append(parts, s)
+	}
+	if m.status != "" {
+		parts = append(parts, m.status)
+	}
+	if s := m.motion.String(); s != "" {
+		parts = append(parts, s)
+	}
+	if s := m.pendingStatus(); s != "" {
+		parts = append(parts, s)
+	}
+	if s := m.outboxStatus(); s != "" {
+		parts = append(parts, s)
+	}
+	if s := backend.ThrottleStatus(); s != "" {
+		parts = append(parts, s)
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return strings.Join(parts, " • ") + "\n"
+}
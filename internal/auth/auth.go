@@ -0,0 +1,487 @@
+// Package auth signs in to Google with OAuth and keeps the token, in the OS
+// credential store or in token.json in the config directory.
+package auth
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/zalando/go-keyring"
+	"golang.org/x/oauth2"
+
+	"gmail-tui/internal/browser"
+	"gmail-tui/internal/config"
+	"gmail-tui/internal/network"
+	"gmail-tui/internal/safefile"
+	"gmail-tui/internal/vault"
+)
+
+const (
+	tokenFile = "token.json"
+
+	// pasteRedirectPort is the redirect port used in --no-browser mode when
+	// none is configured. Nothing listens on it; the user copies the
+	// address instead.
+	pasteRedirectPort = 8080
+
+	keyringService = "gmail-tui"
+	keyringUser    = "oauth-token"
+)
+
+// tokenStore persists the OAuth token, in the OS credential store unless the
+// user opted out or none is available, in which case token.json in the
+// config directory is used, encrypted with vault if it is set.
+type tokenStore struct {
+	keyring bool
+	vault   *vault.Vault
+}
+
+// load returns the saved token. A token.json left over from before the
+// keyring was used is moved into it.
+func (s tokenStore) load() (*oauth2.Token, error) {
+	if !s.keyring {
+		return s.fromFile()
+	}
+
+	data, err := keyring.Get(keyringService, keyringUser)
+	if err == nil {
+		tok := &oauth2.Token{}
+		return tok, json.Unmarshal([]byte(data), tok)
+	}
+
+	tok, ferr := s.fromFile()
+	if ferr != nil || !errors.Is(err, keyring.ErrNotFound) {
+		return tok, ferr
+	}
+	if data, err := json.Marshal(tok); err == nil && keyring.Set(keyringService, keyringUser, string(data)) == nil {
+		if path, err := tokenPath(); err == nil {
+			os.Remove(path)
+		}
+	}
+	return tok, nil
+}
+
+// fromFile reads token.json. One that earlier versions left in the
+// working directory is moved to the config directory first.
+func (s tokenStore) fromFile() (*oauth2.Token, error) {
+	path, err := tokenPath()
+	if err != nil {
+		return nil, err
+	}
+	if err := migrateTokenFile(tokenFile, path); err != nil {
+		return nil, err
+	}
+	return tokenFromFile(path, s.vault)
+}
+
+func tokenPath() (string, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return "", fmt.Errorf("unable to find the config directory for %s: %v", tokenFile, err)
+	}
+	return filepath.Join(dir, tokenFile), nil
+}
+
+// migrateTokenFile moves the token file at legacy to path, unless there is
+// already one at path. It is copied as is, encrypted or not, and only
+// readable by the user.
+func migrateTokenFile(legacy, path string) error {
+	data, err := os.ReadFile(legacy)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("unable to read %s: %v", legacy, err)
+	}
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("unable to move %s: %v", legacy, err)
+	}
+	if err := safefile.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("unable to move %s: %v", legacy, err)
+	}
+	os.Remove(legacy)
+	return nil
+}
+
+// lock keeps other gmail-tui processes from loading or saving the token
+// until the returned function is called, so a token one of them has just
+// refreshed isn't overwritten with an older one. Without a config
+// directory to lock in, nothing is locked.
+func (s tokenStore) lock() func() {
+	dir, err := config.Dir()
+	if err != nil {
+		return func() {}
+	}
+	unlock, err := safefile.Lock(filepath.Join(dir, "token"))
+	if err != nil {
+		return func() {}
+	}
+	return unlock
+}
+
+// save stores tok, falling back to token.json if the credential store
+// cannot be used, e.g. on a server without a Secret Service.
+func (s tokenStore) save(tok *oauth2.Token) error {
+	if s.keyring {
+		data, err := json.Marshal(tok)
+		if err != nil {
+			return err
+		}
+		if err := keyring.Set(keyringService, keyringUser, string(data)); err == nil {
+			return nil
+		}
+	}
+	path, err := tokenPath()
+	if err != nil {
+		return err
+	}
+	return saveToken(path, tok, s.vault)
+}
+
+// Source supplies the OAuth token for every API request. When Google
+// rejects the refresh token it remembers that the user has to sign in
+// again, and Save swaps in a new token without rebuilding the clients that
+// use it.
+type Source struct {
+	config *oauth2.Config
+	store  tokenStore
+	// client reaches Google's sign-in endpoints, through the configured
+	// proxy.
+	client *http.Client
+
+	// noBrowser makes sign-in print the consent URL and take the code
+	// pasted back by the user, for sessions without a local browser.
+	noBrowser bool
+	// port is the loopback port for the redirect; zero picks a free one.
+	port int
+
+	mu      sync.Mutex
+	tok     *oauth2.Token
+	revoked bool
+}
+
+func (a *Source) Token() (*oauth2.Token, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	tok, err := a.refresh()
+	if err != nil && needsSignIn(err) {
+		a.revoked = true
+	}
+	return tok, err
+}
+
+// refresh returns a valid token, refreshing the one in hand once it has
+// expired and saving the new one. Another gmail-tui may have refreshed it
+// first, in which case the token it saved is used instead.
+func (a *Source) refresh() (*oauth2.Token, error) {
+	if a.tok.Valid() {
+		return a.tok, nil
+	}
+	unlock := a.store.lock()
+	defer unlock()
+
+	if saved, err := a.store.load(); err == nil && saved.Valid() {
+		a.tok = saved
+		return saved, nil
+	}
+	tok, err := a.config.TokenSource(oauthContext(context.Background(), a.client), a.tok).Token()
+	if err != nil {
+		return nil, err
+	}
+	a.tok = tok
+	// Saving only spares the next start a refresh, so a failure is let go.
+	a.store.save(tok)
+	return tok, nil
+}
+
+// Expired reports whether the stored token can no longer be refreshed.
+func (a *Source) Expired() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.revoked
+}
+
+// NoBrowser reports whether sign-in takes a pasted code instead of
+// opening a local browser.
+func (a *Source) NoBrowser() bool {
+	return a.noBrowser
+}
+
+// Save stores a new token and starts using it.
+func (a *Source) Save(tok *oauth2.Token) error {
+	unlock := a.store.lock()
+	err := a.store.save(tok)
+	unlock()
+	if err != nil {
+		return err
+	}
+	a.reset(tok)
+	return nil
+}
+
+func (a *Source) reset(tok *oauth2.Token) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.tok = tok
+	a.revoked = false
+}
+
+// needsSignIn reports whether a token refresh failed because the grant was
+// revoked or has expired, rather than because Google could not be reached.
+func needsSignIn(err error) bool {
+	var rerr *oauth2.RetrieveError
+	if errors.As(err, &rerr) {
+		return rerr.ErrorCode == "invalid_grant"
+	}
+	return strings.Contains(err.Error(), "refresh token is not set")
+}
+
+// SignIn is one run of the consent flow. Google sends the browser back to
+// the loopback redirect with the code and the random state given in the
+// consent URL; a redirect without the right state is rejected.
+type SignIn struct {
+	config *oauth2.Config
+	client *http.Client
+	state  string
+	ln     net.Listener
+}
+
+// NewSignIn prepares a consent flow. Unless the code is to be pasted, it
+// listens for the redirect on the configured port, or on any free one.
+func (a *Source) NewSignIn() (*SignIn, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("unable to generate state: %v", err)
+	}
+	s := &SignIn{client: a.client, state: hex.EncodeToString(b)}
+
+	port := a.port
+	if !a.noBrowser {
+		ln, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", a.port))
+		if err != nil {
+			return nil, fmt.Errorf("unable to listen for the authorization redirect: %v", err)
+		}
+		s.ln = ln
+		port = ln.Addr().(*net.TCPAddr).Port
+	} else if port == 0 {
+		port = pasteRedirectPort
+	}
+
+	c := *a.config
+	c.RedirectURL = fmt.Sprintf("http://127.0.0.1:%d", port)
+	s.config = &c
+	return s, nil
+}
+
+// AuthURL is the consent page to open in a browser.
+func (s *SignIn) AuthURL() string {
+	return s.config.AuthCodeURL(s.state, oauth2.AccessTypeOffline)
+}
+
+// Close stops listening for the redirect.
+func (s *SignIn) Close() {
+	if s.ln != nil {
+		s.ln.Close()
+	}
+}
+
+// code returns the authorization code from the query of a redirect.
+func (s *SignIn) code(q url.Values) (string, error) {
+	if q.Get("state") != s.state {
+		return "", errors.New("the authorization response is not from this sign-in")
+	}
+	if e := q.Get("error"); e != "" {
+		return "", fmt.Errorf("authorization failed: %s", e)
+	}
+	if q.Get("code") == "" {
+		return "", errors.New("the authorization response has no code")
+	}
+	return q.Get("code"), nil
+}
+
+// PastedCode takes what the user pasted after approving access without a
+// local browser: either the address the browser was sent to, or just its
+// code parameter.
+func (s *SignIn) PastedCode(pasted string) (string, error) {
+	pasted = strings.TrimSpace(pasted)
+	if u, err := url.Parse(pasted); err == nil && u.RawQuery != "" {
+		return s.code(u.Query())
+	}
+	if pasted == "" {
+		return "", errors.New("no authorization code given")
+	}
+	return pasted, nil
+}
+
+// FromBrowser opens the consent page in the browser and waits for Google
+// to redirect back to the local server with the authorization code.
+func (s *SignIn) FromBrowser(ctx context.Context) (*oauth2.Token, error) {
+	type result struct {
+		code string
+		err  error
+	}
+	results := make(chan result, 1)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if !q.Has("code") && !q.Has("error") {
+			http.NotFound(w, r)
+			return
+		}
+		code, err := s.code(q)
+		if q.Get("state") != s.state {
+			// Keep waiting for the real redirect.
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			fmt.Fprintf(w, "Authorization failed. You can close this window.")
+		} else {
+			fmt.Fprintf(w, "Authorization successful! You can close this window.")
+		}
+		select {
+		case results <- result{code, err}:
+		default:
+		}
+	})
+	server := &http.Server{Handler: mux}
+	go server.Serve(s.ln)
+	defer server.Shutdown(context.Background())
+
+	browser.Open(s.AuthURL())
+
+	select {
+	case r := <-results:
+		if r.err != nil {
+			return nil, r.err
+		}
+		return s.Exchange(ctx, r.code)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Exchange trades an authorization code for a token.
+func (s *SignIn) Exchange(ctx context.Context, code string) (*oauth2.Token, error) {
+	tok, err := s.config.Exchange(oauthContext(ctx, s.client), code)
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve token from web: %v", err)
+	}
+	return tok, nil
+}
+
+// PasteInstructions tell the user what to paste after approving access
+// without a local browser.
+const PasteInstructions = "After approving access, your browser is sent to a 127.0.0.1 address that will not load. Copy that address from the address bar and paste it here."
+
+// fromTerminal prints the consent URL and reads the pasted redirect from
+// stdin, for use before the TUI starts.
+func (s *SignIn) fromTerminal(ctx context.Context) (*oauth2.Token, error) {
+	fmt.Printf("Visit this URL to authorize gmail-tui:\n%v\n\n%s\n> ", s.AuthURL(), PasteInstructions)
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return nil, fmt.Errorf("unable to read authorization code: %v", err)
+	}
+	code, err := s.PastedCode(line)
+	if err != nil {
+		return nil, err
+	}
+	return s.Exchange(ctx, code)
+}
+
+// firstSignIn runs the consent flow before the TUI starts.
+func (a *Source) firstSignIn() (*oauth2.Token, error) {
+	s, err := a.NewSignIn()
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+
+	if a.noBrowser {
+		return s.fromTerminal(context.Background())
+	}
+	fmt.Printf("Opening this URL in your browser: \n%v\n", s.AuthURL())
+	return s.FromBrowser(context.Background())
+}
+
+// NewClient returns a client authorized with the saved token, signing in
+// first if there is none.
+func NewClient(oauthConfig *oauth2.Config, cfg config.Config, v *vault.Vault, noBrowser bool) (*http.Client, *Source, error) {
+	n, err := network.New(cfg.Network)
+	if err != nil {
+		return nil, nil, err
+	}
+	a := &Source{
+		config:    oauthConfig,
+		store:     tokenStore{keyring: cfg.UseKeyring, vault: v},
+		client:    n.Client(cfg.RequestTimeout()),
+		noBrowser: noBrowser,
+		port:      cfg.OAuthRedirectPort,
+	}
+
+	unlock := a.store.lock()
+	tok, err := a.store.load()
+	unlock()
+	if err != nil {
+		tok, err = a.firstSignIn()
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := a.Save(tok); err != nil {
+			return nil, nil, err
+		}
+	} else if v.Enabled() {
+		// A token.json written before encryption was turned on is
+		// encrypted now rather than at the next refresh.
+		a.Save(tok)
+	}
+	a.reset(tok)
+	return &http.Client{Transport: &oauth2.Transport{Source: a, Base: n.Transport()}}, a, nil
+}
+
+// oauthContext has the oauth2 package reach Google over client.
+func oauthContext(ctx context.Context, client *http.Client) context.Context {
+	if client == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, oauth2.HTTPClient, client)
+}
+
+func tokenFromFile(file string, v *vault.Vault) (*oauth2.Token, error) {
+	data, err := v.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	tok := &oauth2.Token{}
+	return tok, json.Unmarshal(data, tok)
+}
+
+func saveToken(path string, token *oauth2.Token, v *vault.Vault) error {
+	data, err := json.Marshal(token)
+	if err != nil {
+		return fmt.Errorf("unable to cache oauth token: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("unable to cache oauth token: %v", err)
+	}
+	if err := v.WriteFile(path, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("unable to cache oauth token: %v", err)
+	}
+	return nil
+}
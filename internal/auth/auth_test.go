@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+func TestTokenFileMovedToConfigDir(t *testing.T) {
+	home, work := t.TempDir(), t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", home)
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(work); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	if err := saveToken(tokenFile, &oauth2.Token{AccessToken: "old"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	s := tokenStore{}
+	tok, err := s.load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.AccessToken != "old" {
+		t.Errorf("loaded token %q, want the one in the working directory", tok.AccessToken)
+	}
+	if _, err := os.Stat(filepath.Join(work, tokenFile)); !os.IsNotExist(err) {
+		t.Errorf("%s is still in the working directory", tokenFile)
+	}
+
+	if err := s.save(&oauth2.Token{AccessToken: "new"}); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(filepath.Join(home, "gmail-tui", tokenFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("%s has mode %v, want 0600", tokenFile, info.Mode().Perm())
+	}
+	if _, err := os.Stat(filepath.Join(work, tokenFile)); !os.IsNotExist(err) {
+		t.Errorf("saving wrote %s to the working directory", tokenFile)
+	}
+}
@@ -0,0 +1,131 @@
+package backend
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strconv"
+
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/googleapi"
+)
+
+const (
+	batchURL = "https://gmail.googleapis.com/batch/gmail/v1"
+
+	// maxBatchSize is the largest batch Gmail recommends; bigger batches
+	// are more likely to be rate limited.
+	maxBatchSize = 50
+)
+
+// getMetadata fetches the given headers of several messages, sending up to
+// maxBatchSize requests per HTTP round trip through Gmail's batch endpoint.
+// Messages a batch could not return are fetched one by one, which also
+// retries rate-limited ones; failed counts those that still could not be
+// fetched. The result keeps the order of ids.
+func (p gmailProvider) getMetadata(ctx context.Context, ids []string, headers ...string) (msgs []*gmail.Message, failed int) {
+	query := url.Values{"format": {"metadata"}, "metadataHeaders": headers}
+	progress := listProgress(ctx)
+
+	byID := map[string]*gmail.Message{}
+	for start := 0; start < len(ids); start += maxBatchSize {
+		chunk := ids[start:min(start+maxBatchSize, len(ids))]
+		got, err := batchGetMessages(ctx, p.client, chunk, query, progress.fetched)
+		if err != nil {
+			continue
+		}
+		for id, msg := range got {
+			byID[id] = msg
+		}
+	}
+
+	for _, id := range ids {
+		msg, ok := byID[id]
+		if !ok {
+			var err error
+			msg, err = p.svc.Users.Messages.Get("me", id).Format("metadata").MetadataHeaders(headers...).Context(ctx).Do()
+			if err != nil {
+				failed++
+				continue
+			}
+			progress.fetched(msg)
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, failed
+}
+
+// batchGetMessages sends one batch request getting each message in ids. It
+// returns the messages that came back successfully, keyed by ID, passing
+// each to fetched as soon as it has been read from the response.
+func batchGetMessages(ctx context.Context, client *http.Client, ids []string, query url.Values, fetched func(*gmail.Message)) (map[string]*gmail.Message, error) {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for i, id := range ids {
+		part, err := w.CreatePart(textproto.MIMEHeader{
+			"Content-Type": {"application/http"},
+			"Content-Id":   {"<" + strconv.Itoa(i) + ">"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(part, "GET /gmail/v1/users/me/messages/%s?%s\r\n\r\n", url.PathEscape(id), query.Encode())
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, batchURL, bytes.NewReader(body.Bytes()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "multipart/mixed; boundary="+w.Boundary())
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := googleapi.CheckResponse(resp); err != nil {
+		return nil, err
+	}
+
+	_, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse batch response: %v", err)
+	}
+
+	msgs := map[string]*gmail.Message{}
+	r := multipart.NewReader(resp.Body, params["boundary"])
+	for {
+		part, err := r.NextPart()
+		if err != nil {
+			// io.EOF ends the batch; anything else leaves the remaining
+			// messages to be fetched individually.
+			break
+		}
+		inner, err := http.ReadResponse(bufio.NewReader(part), req)
+		if err != nil {
+			continue
+		}
+		if inner.StatusCode != http.StatusOK {
+			inner.Body.Close()
+			continue
+		}
+		var msg gmail.Message
+		err = json.NewDecoder(inner.Body).Decode(&msg)
+		inner.Body.Close()
+		if err == nil && msg.Id != "" {
+			msgs[msg.Id] = &msg
+			fetched(&msg)
+		}
+	}
+	return msgs, nil
+}
@@ -0,0 +1,196 @@
+package backend
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// cacheKeepLists is how long the daemon keeps fetching a list nobody has
+// asked for.
+const cacheKeepLists = 10 * time.Minute
+
+// Cache keeps what a provider returns in memory, for the daemon. Lists and
+// messages are answered from it while younger than its ttl; Refresh fetches
+// the lists asked for lately again, so they stay fresh; and changes made
+// through it drop what they put out of date.
+type Cache struct {
+	Provider
+	ttl time.Duration
+
+	mu       sync.Mutex
+	lists    map[string]*cachedList
+	messages map[string]cachedMessage
+}
+
+type cachedList struct {
+	query    string
+	max      int64
+	headers  []string
+	messages []*gmail.Message
+	failed   int
+	fetched  time.Time
+	asked    time.Time
+}
+
+type cachedMessage struct {
+	msg     *gmail.Message
+	fetched time.Time
+}
+
+// NewCache caches what p returns for ttl.
+func NewCache(p Provider, ttl time.Duration) *Cache {
+	return &Cache{
+		Provider: p,
+		ttl:      ttl,
+		lists:    map[string]*cachedList{},
+		messages: map[string]cachedMessage{},
+	}
+}
+
+func listKey(query string, max int64, headers []string) string {
+	return query + "\x00" + strconv.FormatInt(max, 10) + "\x00" + strings.Join(headers, "\x00")
+}
+
+func (c *Cache) List(ctx context.Context, query string, max int64, headers ...string) ([]*gmail.Message, int, error) {
+	key := listKey(query, max, headers)
+	c.mu.Lock()
+	if l, ok := c.lists[key]; ok && time.Since(l.fetched) < c.ttl {
+		l.asked = time.Now()
+		c.mu.Unlock()
+		return l.messages, l.failed, nil
+	}
+	c.mu.Unlock()
+
+	msgs, failed, err := c.Provider.List(ctx, query, max, headers...)
+	if err != nil {
+		return nil, 0, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	c.lists[key] = &cachedList{query: query, max: max, headers: headers, messages: msgs, failed: failed, fetched: now, asked: now}
+	return msgs, failed, nil
+}
+
+func (c *Cache) Get(ctx context.Context, id, format string) (*gmail.Message, error) {
+	key := id + "\x00" + format
+	c.mu.Lock()
+	if m, ok := c.messages[key]; ok && time.Since(m.fetched) < c.ttl {
+		c.mu.Unlock()
+		return m.msg, nil
+	}
+	c.mu.Unlock()
+
+	msg, err := c.Provider.Get(ctx, id, format)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.messages[key] = cachedMessage{msg: msg, fetched: time.Now()}
+	return msg, nil
+}
+
+// changed drops what a change to message id, if any, puts out of date: the
+// message and every list, which are fetched again when next asked for.
+func (c *Cache) changed(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.messages {
+		if strings.HasPrefix(key, id+"\x00") {
+			delete(c.messages, key)
+		}
+	}
+	for _, l := range c.lists {
+		l.fetched = time.Time{}
+	}
+}
+
+func (c *Cache) Modify(ctx context.Context, id string, add, remove []string) (*gmail.Message, error) {
+	msg, err := c.Provider.Modify(ctx, id, add, remove)
+	if err == nil {
+		c.changed(id)
+	}
+	return msg, err
+}
+
+func (c *Cache) Trash(ctx context.Context, id string) (*gmail.Message, error) {
+	msg, err := c.Provider.Trash(ctx, id)
+	if err == nil {
+		c.changed(id)
+	}
+	return msg, err
+}
+
+func (c *Cache) Untrash(ctx context.Context, id string) (*gmail.Message, error) {
+	msg, err := c.Provider.Untrash(ctx, id)
+	if err == nil {
+		c.changed(id)
+	}
+	return msg, err
+}
+
+func (c *Cache) SaveDraft(ctx context.Context, id, raw string) (string, error) {
+	newID, err := c.Provider.SaveDraft(ctx, id, raw)
+	if err == nil {
+		c.changed(id)
+	}
+	return newID, err
+}
+
+func (c *Cache) SendDraft(ctx context.Context, id string) (*gmail.Message, error) {
+	msg, err := c.Provider.SendDraft(ctx, id)
+	if err == nil {
+		c.changed(id)
+	}
+	return msg, err
+}
+
+func (c *Cache) Send(ctx context.Context, raw string) (*gmail.Message, error) {
+	msg, err := c.Provider.Send(ctx, raw)
+	if err == nil {
+		c.changed("")
+	}
+	return msg, err
+}
+
+// Refresh fetches the lists asked for lately again, so the next ask for
+// them is answered at once, and forgets the other lists and the messages
+// gone stale. Lists that fail to load are kept as they were.
+func (c *Cache) Refresh(ctx context.Context) {
+	c.mu.Lock()
+	var lists []cachedList
+	for key, l := range c.lists {
+		if time.Since(l.asked) > cacheKeepLists {
+			delete(c.lists, key)
+			continue
+		}
+		lists = append(lists, *l)
+	}
+	for key, m := range c.messages {
+		if time.Since(m.fetched) > c.ttl {
+			delete(c.messages, key)
+		}
+	}
+	c.mu.Unlock()
+
+	for _, l := range lists {
+		msgs, failed, err := c.Provider.List(ctx, l.query, l.max, l.headers...)
+		if err != nil {
+			continue
+		}
+		c.mu.Lock()
+		key := listKey(l.query, l.max, l.headers)
+		asked := l.asked
+		if cur, ok := c.lists[key]; ok {
+			asked = cur.asked
+		}
+		c.lists[key] = &cachedList{query: l.query, max: l.max, headers: l.headers, messages: msgs, failed: failed, fetched: time.Now(), asked: asked}
+		c.mu.Unlock()
+	}
+}
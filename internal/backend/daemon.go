@@ -0,0 +1,281 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/googleapi"
+)
+
+// The daemon serves a provider on a Unix socket, so the app and the
+// commands share one provider and its cache instead of each fetching on
+// their own. A connection carries one request and its response, each as
+// JSON, and closing it cancels the request.
+
+// daemonDialTimeout is how long DialDaemon waits for a daemon to answer.
+const daemonDialTimeout = 200 * time.Millisecond
+
+// DaemonSocket is where the daemon listens: gmail-tui.sock in
+// $XDG_RUNTIME_DIR, or else daemon.sock in the gmail-tui cache directory.
+func DaemonSocket() (string, error) {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "gmail-tui.sock"), nil
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gmail-tui", "daemon.sock"), nil
+}
+
+// daemonRequest is a call of a Provider method, named by Method, with its
+// arguments.
+type daemonRequest struct {
+	Method       string   `json:"method"`
+	Query        string   `json:"query,omitempty"`
+	Max          int64    `json:"max,omitempty"`
+	Headers      []string `json:"headers,omitempty"`
+	ID           string   `json:"id,omitempty"`
+	Format       string   `json:"format,omitempty"`
+	AttachmentID string   `json:"attachment_id,omitempty"`
+	Add          []string `json:"add,omitempty"`
+	Remove       []string `json:"remove,omitempty"`
+	Raw          string   `json:"raw,omitempty"`
+}
+
+// daemonResponse is what a call returned. Code is the HTTP status of a
+// Gmail API error, and Network is set for a network error, so clients can
+// tell them apart as they would without the daemon.
+type daemonResponse struct {
+	Messages []*gmail.Message `json:"messages,omitempty"`
+	Message  *gmail.Message   `json:"message,omitempty"`
+	Failed   int              `json:"failed,omitempty"`
+	ID       string           `json:"id,omitempty"`
+	Data     []byte           `json:"data,omitempty"`
+	Error    string           `json:"error,omitempty"`
+	Code     int              `json:"code,omitempty"`
+	Network  bool             `json:"network,omitempty"`
+}
+
+func (r *daemonResponse) setError(err error) {
+	r.Error = err.Error()
+	var gerr *googleapi.Error
+	var nerr net.Error
+	switch {
+	case errors.As(err, &gerr):
+		r.Error, r.Code = gerr.Message, gerr.Code
+	case errors.As(err, &nerr):
+		r.Network = true
+	}
+}
+
+func (r daemonResponse) err() error {
+	switch {
+	case r.Error == "":
+		return nil
+	case r.Code != 0:
+		return &googleapi.Error{Code: r.Code, Message: r.Error}
+	case r.Network:
+		return daemonNetError(r.Error)
+	}
+	return errors.New(r.Error)
+}
+
+// daemonNetError is a network error the daemon ran into, passed on as one
+// so that clients still treat it as a connection problem.
+type daemonNetError string
+
+func (e daemonNetError) Error() string   { return string(e) }
+func (e daemonNetError) Timeout() bool   { return false }
+func (e daemonNetError) Temporary() bool { return true }
+
+// ListenDaemon opens the daemon's socket, replacing one left behind by a
+// daemon that is gone. It fails if a daemon is listening on it. The socket
+// is only accessible by the user from the moment it is created.
+func ListenDaemon(socket string) (net.Listener, error) {
+	if _, ok := DialDaemon(socket); ok {
+		return nil, fmt.Errorf("a daemon is already listening on %s", socket)
+	}
+	os.Remove(socket)
+	if err := os.MkdirAll(filepath.Dir(socket), 0700); err != nil {
+		return nil, fmt.Errorf("unable to create %s: %v", filepath.Dir(socket), err)
+	}
+	l, err := listenPrivate(socket)
+	if err != nil {
+		return nil, fmt.Errorf("unable to listen on %s: %v", socket, err)
+	}
+	if err := os.Chmod(socket, 0600); err != nil {
+		l.Close()
+		return nil, fmt.Errorf("unable to listen on %s: %v", socket, err)
+	}
+	return l, nil
+}
+
+// Serve answers the requests made on l with p until ctx is done.
+// Connections from other users are closed unanswered where the peer's
+// user can be checked.
+func Serve(ctx context.Context, l net.Listener, p Provider) error {
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("unable to accept daemon connection: %v", err)
+		}
+		if !peerIsSelf(conn) {
+			conn.Close()
+			continue
+		}
+		go serveConn(ctx, conn, p)
+	}
+}
+
+func serveConn(ctx context.Context, conn net.Conn, p Provider) {
+	defer conn.Close()
+	dec := json.NewDecoder(conn)
+	var req daemonRequest
+	if err := dec.Decode(&req); err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// The client hangs up to cancel.
+	go func() {
+		io.Copy(io.Discard, io.MultiReader(dec.Buffered(), conn))
+		cancel()
+	}()
+	json.NewEncoder(conn).Encode(handleDaemonRequest(ctx, p, req))
+}
+
+func handleDaemonRequest(ctx context.Context, p Provider, req daemonRequest) daemonResponse {
+	var resp daemonResponse
+	var err error
+	switch req.Method {
+	case "list":
+		resp.Messages, resp.Failed, err = p.List(ctx, req.Query, req.Max, req.Headers...)
+	case "get":
+		resp.Message, err = p.Get(ctx, req.ID, req.Format)
+	case "attachment":
+		resp.Data, err = p.Attachment(ctx, req.ID, req.AttachmentID)
+	case "modify":
+		resp.Message, err = p.Modify(ctx, req.ID, req.Add, req.Remove)
+	case "trash":
+		resp.Message, err = p.Trash(ctx, req.ID)
+	case "untrash":
+		resp.Message, err = p.Untrash(ctx, req.ID)
+	case "save_draft":
+		resp.ID, err = p.SaveDraft(ctx, req.ID, req.Raw)
+	case "send_draft":
+		resp.Message, err = p.SendDraft(ctx, req.ID)
+	case "send":
+		resp.Message, err = p.Send(ctx, req.Raw)
+	default:
+		err = fmt.Errorf("unknown daemon method %q", req.Method)
+	}
+	if err != nil {
+		resp.setError(err)
+	}
+	return resp
+}
+
+// daemonProvider passes every call on to the daemon. Upload progress isn't
+// reported through it.
+type daemonProvider struct {
+	socket string
+}
+
+// DialDaemon returns a provider that uses the daemon listening on socket,
+// if one is.
+func DialDaemon(socket string) (Provider, bool) {
+	conn, err := net.DialTimeout("unix", socket, daemonDialTimeout)
+	if err != nil {
+		return nil, false
+	}
+	conn.Close()
+	return daemonProvider{socket: socket}, true
+}
+
+func (p daemonProvider) call(ctx context.Context, req daemonRequest) (daemonResponse, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", p.socket)
+	if err != nil {
+		return daemonResponse{}, fmt.Errorf("unable to reach the daemon: %w", err)
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		if ctx.Err() != nil {
+			return daemonResponse{}, ctx.Err()
+		}
+		return daemonResponse{}, fmt.Errorf("unable to reach the daemon: %w", err)
+	}
+	var resp daemonResponse
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		if ctx.Err() != nil {
+			return daemonResponse{}, ctx.Err()
+		}
+		return daemonResponse{}, fmt.Errorf("unable to read the daemon's answer: %w", err)
+	}
+	return resp, resp.err()
+}
+
+func (p daemonProvider) List(ctx context.Context, query string, max int64, headers ...string) ([]*gmail.Message, int, error) {
+	resp, err := p.call(ctx, daemonRequest{Method: "list", Query: query, Max: max, Headers: headers})
+	return resp.Messages, resp.Failed, err
+}
+
+func (p daemonProvider) Get(ctx context.Context, id, format string) (*gmail.Message, error) {
+	resp, err := p.call(ctx, daemonRequest{Method: "get", ID: id, Format: format})
+	return resp.Message, err
+}
+
+func (p daemonProvider) Attachment(ctx context.Context, messageID, attachmentID string) ([]byte, error) {
+	resp, err := p.call(ctx, daemonRequest{Method: "attachment", ID: messageID, AttachmentID: attachmentID})
+	return resp.Data, err
+}
+
+func (p daemonProvider) Modify(ctx context.Context, id string, add, remove []string) (*gmail.Message, error) {
+	resp, err := p.call(ctx, daemonRequest{Method: "modify", ID: id, Add: add, Remove: remove})
+	return resp.Message, err
+}
+
+func (p daemonProvider) Trash(ctx context.Context, id string) (*gmail.Message, error) {
+	resp, err := p.call(ctx, daemonRequest{Method: "trash", ID: id})
+	return resp.Message, err
+}
+
+func (p daemonProvider) Untrash(ctx context.Context, id string) (*gmail.Message, error) {
+	resp, err := p.call(ctx, daemonRequest{Method: "untrash", ID: id})
+	return resp.Message, err
+}
+
+func (p daemonProvider) SaveDraft(ctx context.Context, id, raw string) (string, error) {
+	resp, err := p.call(ctx, daemonRequest{Method: "save_draft", ID: id, Raw: raw})
+	return resp.ID, err
+}
+
+func (p daemonProvider) SendDraft(ctx context.Context, id string) (*gmail.Message, error) {
+	resp, err := p.call(ctx, daemonRequest{Method: "send_draft", ID: id})
+	return resp.Message, err
+}
+
+func (p daemonProvider) Send(ctx context.Context, raw string) (*gmail.Message, error) {
+	resp, err := p.call(ctx, daemonRequest{Method: "send", Raw: raw})
+	return resp.Message, err
+}
@@ -0,0 +1,35 @@
+package backend
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDaemonSocketIsPrivate(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "gmail-tui", "daemon.sock")
+	l, err := ListenDaemon(socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm&0077 != 0 {
+		t.Errorf("socket has mode %v, want no access for others", perm)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go Serve(ctx, l, newMemoryProvider())
+
+	p, ok := DialDaemon(socket)
+	if !ok {
+		t.Fatal("no daemon answered")
+	}
+	if _, _, err := p.List(ctx, "in:inbox", 10); err != nil {
+		t.Errorf("the daemon didn't serve its own user: %v", err)
+	}
+}
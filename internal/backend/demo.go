@@ -0,0 +1,346 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/googleapi"
+
+	"gmail-tui/internal/mimepart"
+)
+
+// memoryProvider keeps a few sample messages in memory, for trying
+// gmail-tui, or working on it, without a mail account. Changes last until
+// it quits, and nothing sent leaves the machine.
+type memoryProvider struct {
+	mu sync.Mutex
+	// msgs are newest first.
+	msgs   []*memoryMessage
+	nextID int
+}
+
+type memoryMessage struct {
+	id     string
+	labels []string
+	raw    []byte
+	date   time.Time
+}
+
+func newMemoryProvider() *memoryProvider {
+	p := &memoryProvider{}
+	now := time.Now()
+	for _, s := range demoMessages {
+		raw := fmt.Sprintf("Date: %s\r\n%s", now.Add(-s.age).Format(time.RFC1123Z), strings.ReplaceAll(s.raw, "\n", "\r\n"))
+		p.add([]byte(raw), s.labels)
+	}
+	return p
+}
+
+// add stores a message as the newest, returning it.
+func (p *memoryProvider) add(raw []byte, labels []string) *memoryMessage {
+	p.nextID++
+	m := &memoryMessage{id: "demo" + strconv.Itoa(p.nextID), labels: labels, raw: raw, date: time.Now()}
+	if msg, err := mail.ReadMessage(bytes.NewReader(raw)); err == nil {
+		if date, err := msg.Header.Date(); err == nil {
+			m.date = date
+		}
+	}
+	i, _ := slices.BinarySearchFunc(p.msgs, m.date, func(m *memoryMessage, t time.Time) int {
+		return t.Compare(m.date)
+	})
+	p.msgs = slices.Insert(p.msgs, i, m)
+	return m
+}
+
+// find returns the message with the ID, failing as the Gmail API does if
+// there is none.
+func (p *memoryProvider) find(id string) (*memoryMessage, error) {
+	for _, m := range p.msgs {
+		if m.id == id {
+			return m, nil
+		}
+	}
+	return nil, &googleapi.Error{Code: http.StatusNotFound, Message: "no message " + id}
+}
+
+// message is m in one of the Gmail API's formats.
+func (m *memoryMessage) message(format string) *gmail.Message {
+	msg := &gmail.Message{
+		Id:           m.id,
+		ThreadId:     m.id,
+		LabelIds:     slices.Clone(m.labels),
+		InternalDate: m.date.UnixMilli(),
+		SizeEstimate: int64(len(m.raw)),
+	}
+	full := mimepart.Parse(m.raw, "", false)
+	if part := mimepart.Find(full, "text/plain"); part != nil {
+		if data, ok := mimepart.Data(part); ok {
+			snippet := []rune(strings.Join(strings.Fields(string(data)), " "))
+			msg.Snippet = string(snippet[:min(len(snippet), 140)])
+		}
+	}
+	switch format {
+	case "raw":
+		msg.Raw = base64.URLEncoding.EncodeToString(m.raw)
+	case "metadata":
+		head, _ := mimepart.SplitHeader(m.raw)
+		msg.Payload = mimepart.HeaderPart(head)
+	default:
+		msg.Payload = full
+	}
+	return msg
+}
+
+// matches reports whether m is found by a Gmail search. The common
+// operators are understood; others are ignored.
+func (m *memoryMessage) matches(query string) bool {
+	head, _ := mimepart.SplitHeader(m.raw)
+	header := &gmail.MessagePart{Headers: mimepart.ParseHeaders(head)}
+	placed := false
+	for _, tok := range queryTokens(query) {
+		negate := len(tok) > 1 && tok[0] == '-'
+		if negate {
+			tok = tok[1:]
+		}
+		op, arg, ok := strings.Cut(tok, ":")
+		if !ok {
+			op, arg = "", tok
+		}
+		arg = strings.ToLower(strings.Trim(arg, `"`))
+
+		var match bool
+		switch op = strings.ToLower(op); op {
+		case "in", "label", "is":
+			if arg == "all" {
+				continue
+			}
+			placed = placed || op != "is"
+			switch arg {
+			case "anywhere":
+				continue
+			case "read":
+				match = !slices.Contains(m.labels, "UNREAD")
+			default:
+				if arg == "drafts" {
+					arg = "draft"
+				}
+				match = slices.ContainsFunc(m.labels, func(l string) bool { return strings.EqualFold(l, arg) })
+			}
+		case "from", "to", "cc", "subject":
+			match = strings.Contains(strings.ToLower(mimepart.DecodeHeader(mimepart.Header(header, op))), arg)
+		case "has":
+			match = arg != "attachment" || strings.Contains(strings.ToLower(mimepart.Header(header, "Content-Type")), "multipart/mixed")
+		case "":
+			match = arg == "or" || arg == "and" || strings.Contains(strings.ToLower(string(m.raw)), arg)
+		default:
+			continue
+		}
+		if match == negate {
+			return false
+		}
+	}
+	// As on Gmail, Spam and Trash are only searched when asked for.
+	return placed || !slices.Contains(m.labels, "SPAM") && !slices.Contains(m.labels, "TRASH")
+}
+
+func (p *memoryProvider) List(ctx context.Context, query string, max int64, headers ...string) ([]*gmail.Message, int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var msgs []*gmail.Message
+	for _, m := range p.msgs {
+		if max > 0 && int64(len(msgs)) >= max {
+			break
+		}
+		if m.matches(query) {
+			msgs = append(msgs, m.message("metadata"))
+		}
+	}
+	progress := listProgress(ctx)
+	ids := make([]string, len(msgs))
+	for i, msg := range msgs {
+		ids[i] = msg.Id
+	}
+	progress.found(ids)
+	for _, msg := range msgs {
+		progress.fetched(msg)
+	}
+	return msgs, 0, nil
+}
+
+func (p *memoryProvider) Get(ctx context.Context, id, format string) (*gmail.Message, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	m, err := p.find(id)
+	if err != nil {
+		return nil, err
+	}
+	return m.message(format), nil
+}
+
+func (p *memoryProvider) Attachment(ctx context.Context, messageID, attachmentID string) ([]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	m, err := p.find(messageID)
+	if err != nil {
+		return nil, err
+	}
+	return mimepart.AttachmentData(m.raw, attachmentID)
+}
+
+func (p *memoryProvider) Modify(ctx context.Context, id string, add, remove []string) (*gmail.Message, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	m, err := p.find(id)
+	if err != nil {
+		return nil, err
+	}
+	labels := slices.DeleteFunc(slices.Clone(m.labels), func(l string) bool { return slices.Contains(remove, l) })
+	for _, l := range add {
+		if !slices.Contains(labels, l) {
+			labels = append(labels, l)
+		}
+	}
+	m.labels = labels
+	return &gmail.Message{Id: m.id, ThreadId: m.id, LabelIds: slices.Clone(labels)}, nil
+}
+
+func (p *memoryProvider) Trash(ctx context.Context, id string) (*gmail.Message, error) {
+	return p.Modify(ctx, id, []string{"TRASH"}, nil)
+}
+
+func (p *memoryProvider) Untrash(ctx context.Context, id string) (*gmail.Message, error) {
+	return p.Modify(ctx, id, nil, []string{"TRASH"})
+}
+
+// SaveDraft keeps drafts as messages with the DRAFT label, so a draft's ID
+// is its message's.
+func (p *memoryProvider) SaveDraft(ctx context.Context, id, raw string) (string, error) {
+	data, err := mimepart.DecodeRaw(raw)
+	if err != nil {
+		return "", fmt.Errorf("unable to save draft: %v", err)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if id != "" {
+		m, err := p.find(id)
+		if err != nil {
+			return "", fmt.Errorf("unable to save draft: %v", err)
+		}
+		m.raw = data
+		return id, nil
+	}
+	return p.add(data, []string{"DRAFT"}).id, nil
+}
+
+func (p *memoryProvider) SendDraft(ctx context.Context, id string) (*gmail.Message, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	m, err := p.find(id)
+	if err != nil {
+		return nil, err
+	}
+	m.raw = mimepart.WithSendHeaders(m.raw)
+	m.labels = []string{"SENT"}
+	return m.message("metadata"), nil
+}
+
+func (p *memoryProvider) Send(ctx context.Context, raw string) (*gmail.Message, error) {
+	data, err := mimepart.DecodeRaw(raw)
+	if err != nil {
+		return nil, fmt.Errorf("unable to send message: %v", err)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.add(mimepart.WithSendHeaders(data), []string{"SENT"}).message("metadata"), nil
+}
+
+// demoMessages fill the demo mailbox, each dated age before the start.
+var demoMessages = []struct {
+	age    time.Duration
+	labels []string
+	raw    string
+}{
+	{10 * time.Minute, []string{"INBOX", "UNREAD", "IMPORTANT"}, `From: gmail-tui <demo@example.com>
+To: You <you@example.com>
+Subject: Welcome to the demo mailbox
+Content-Type: text/plain; charset=utf-8
+
+This mailbox lives in memory. Archive, star, trash, reply and send as much
+as you like: nothing reaches a real server, and it is all back as it was
+the next time gmail-tui starts.
+
+Press ? for every key binding. Features that need a Gmail account, such as
+filters, contacts and snoozing, say that they are unavailable.
+`},
+	{2 * time.Hour, []string{"INBOX", "UNREAD"}, `From: Dana Lee <dana@example.com>
+To: You <you@example.com>
+Subject: Quarterly numbers
+Content-Type: multipart/mixed; boundary="demo-boundary"
+
+--demo-boundary
+Content-Type: text/plain; charset=utf-8
+
+Hi,
+
+The numbers for the quarter are attached. Sales are up in every region
+but the north, which we should talk about on Thursday.
+
+Dana
+--demo-boundary
+Content-Type: text/csv; name="quarter.csv"
+Content-Disposition: attachment; filename="quarter.csv"
+
+region,q1,q2
+north,120,98
+south,80,112
+east,64,90
+west,101,140
+--demo-boundary--
+`},
+	{26 * time.Hour, []string{"INBOX", "CATEGORY_PROMOTIONS"}, `From: Example Weekly <news@example.org>
+To: you@example.com
+Subject: This week: five terminal tools worth a look
+List-Unsubscribe: <mailto:unsubscribe@example.org?subject=unsubscribe>
+Content-Type: multipart/alternative; boundary="alt"
+
+--alt
+Content-Type: text/plain; charset=utf-8
+
+This week: five terminal tools worth a look.
+Read it online: https://example.org/weekly/42
+--alt
+Content-Type: text/html; charset=utf-8
+
+<h1>This week</h1>
+<p>Five <b>terminal tools</b> worth a look.</p>
+<p><a href="https://example.org/weekly/42">Read it online</a></p>
+--alt--
+`},
+	{3 * 24 * time.Hour, []string{"INBOX", "STARRED"}, `From: Sam Ortiz <sam@example.net>
+To: You <you@example.com>
+Subject: Lunch on Friday?
+Content-Type: text/plain; charset=utf-8
+
+Are you free for lunch on Friday? The new place by the station opens
+this week.
+
+Sam
+`},
+	{4 * 24 * time.Hour, []string{"SENT"}, `From: You <you@example.com>
+To: Dana Lee <dana@example.com>
+Subject: Re: Project kickoff
+Content-Type: text/plain; charset=utf-8
+
+Thanks, Dana. Tuesday at ten works for me.
+`},
+}
@@ -0,0 +1,1229 @@
+package backend
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/googleapi"
+
+	"gmail-tui/internal/config"
+	"gmail-tui/internal/mimepart"
+	"gmail-tui/internal/network"
+)
+
+// imapProvider reads mail from an IMAP server and sends it through an SMTP
+// server. Folders stand in for Gmail's INBOX, SENT, DRAFT, TRASH and SPAM
+// labels and flags for UNREAD and STARRED; other folders show up as labels
+// named after them. There are no threads: each message is its own.
+type imapProvider struct {
+	cfg     config.IMAPConfig
+	timeout time.Duration
+	net     *network.Network
+
+	// mu guards the connection, which runs one command at a time.
+	mu       sync.Mutex
+	conn     *imapConn
+	password string
+}
+
+func newIMAPProvider(cfg config.IMAPConfig, timeout time.Duration, n *network.Network) (*imapProvider, error) {
+	if cfg.Host == "" || cfg.SMTPHost == "" || cfg.Username == "" {
+		return nil, errors.New("the imap backend needs imap.host, imap.smtp_host and imap.username in the config")
+	}
+	return &imapProvider{cfg: cfg, timeout: timeout, net: n}, nil
+}
+
+// secret returns the password printed by the password command, running it
+// only the first time. p.mu must be held.
+func (p *imapProvider) secret() (string, error) {
+	if p.password != "" {
+		return p.password, nil
+	}
+	if p.cfg.PasswordCommand == "" {
+		return "", errors.New("no imap.password_command in the config")
+	}
+	out, err := exec.Command("sh", "-c", p.cfg.PasswordCommand).Output()
+	if err != nil {
+		return "", fmt.Errorf("unable to run the imap password command: %v", err)
+	}
+	p.password = strings.TrimRight(string(out), "\r\n")
+	return p.password, nil
+}
+
+// do runs f on the connection, connecting first if need be. A connection
+// that breaks is dropped and f is tried once more on a new one; commands
+// the server refuses are not retried.
+func (p *imapProvider) do(ctx context.Context, f func(c *imapConn) error) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if p.conn == nil {
+			password, err := p.secret()
+			if err != nil {
+				return err
+			}
+			if p.conn, err = dialIMAP(ctx, p.net, p.cfg, password, p.timeout); err != nil {
+				return err
+			}
+		}
+		c := p.conn
+		deadline := time.Now().Add(p.timeout)
+		if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+			deadline = d
+		}
+		c.conn.SetDeadline(deadline)
+		stop := context.AfterFunc(ctx, func() { c.conn.SetDeadline(time.Now()) })
+		err = f(c)
+		stop()
+		if err == nil || !brokenConn(err) {
+			return err
+		}
+		c.conn.Close()
+		p.conn = nil
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// brokenConn reports whether err means the connection can't be used again.
+func brokenConn(err error) bool {
+	var nerr net.Error
+	return errors.As(err, &nerr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// imapID is the ID of a message on the IMAP server: its folder, hex
+// encoded, and its UID there. Moving a message gives it a new ID.
+func imapID(folder string, uid uint32) string {
+	return hex.EncodeToString([]byte(folder)) + "x" + strconv.FormatUint(uint64(uid), 10)
+}
+
+func parseIMAPID(id string) (string, uint32, error) {
+	folder, uid, ok := strings.Cut(id, "x")
+	name, err := hex.DecodeString(folder)
+	n, nerr := strconv.ParseUint(uid, 10, 32)
+	if !ok || err != nil || nerr != nil || n == 0 {
+		return "", 0, &googleapi.Error{Code: http.StatusNotFound, Message: "no message " + id}
+	}
+	return string(name), uint32(n), nil
+}
+
+// labels are the Gmail label IDs standing for a message's folder and flags.
+func (p *imapProvider) labels(folder string, flags []string) []string {
+	var labels []string
+	if l := p.folderLabel(folder); l != "" {
+		labels = append(labels, l)
+	}
+	seen := false
+	for _, f := range flags {
+		switch strings.ToLower(f) {
+		case `\seen`:
+			seen = true
+		case `\flagged`:
+			labels = append(labels, "STARRED")
+		case `\draft`:
+			if !slices.Contains(labels, "DRAFT") {
+				labels = append(labels, "DRAFT")
+			}
+		}
+	}
+	if !seen {
+		labels = append(labels, "UNREAD")
+	}
+	return labels
+}
+
+// folderLabel is the label a folder stands for: a system label, the
+// folder's own name, or none for the archive.
+func (p *imapProvider) folderLabel(folder string) string {
+	switch {
+	case strings.EqualFold(folder, "INBOX"):
+		return "INBOX"
+	case folder == p.cfg.SentFolder:
+		return "SENT"
+	case folder == p.cfg.DraftsFolder:
+		return "DRAFT"
+	case folder == p.cfg.TrashFolder:
+		return "TRASH"
+	case folder == p.cfg.SpamFolder:
+		return "SPAM"
+	case folder == p.cfg.ArchiveFolder:
+		return ""
+	}
+	return folder
+}
+
+// folder is the folder a Gmail in: or label: search names.
+func (p *imapProvider) folder(name string) string {
+	switch strings.ToLower(name) {
+	case "inbox":
+		return "INBOX"
+	case "sent":
+		return p.cfg.SentFolder
+	case "draft", "drafts":
+		return p.cfg.DraftsFolder
+	case "trash":
+		return p.cfg.TrashFolder
+	case "spam":
+		return p.cfg.SpamFolder
+	case "all", "anywhere", "archive":
+		return p.cfg.ArchiveFolder
+	}
+	return name
+}
+
+// message is a message as the Gmail API would describe it, without its
+// content.
+func (p *imapProvider) message(folder string, f imapFetched) *gmail.Message {
+	id := imapID(folder, f.uid)
+	return &gmail.Message{
+		Id:           id,
+		ThreadId:     id,
+		LabelIds:     p.labels(folder, f.flags),
+		InternalDate: f.date.UnixMilli(),
+		SizeEstimate: f.size,
+	}
+}
+
+func (p *imapProvider) List(ctx context.Context, query string, max int64, headers ...string) ([]*gmail.Message, int, error) {
+	folder, criteria := p.searchCriteria(query)
+	item := "BODY.PEEK[HEADER]"
+	if len(headers) > 0 {
+		item = "BODY.PEEK[HEADER.FIELDS (" + strings.Join(slices.Concat(headers, []string{"Content-Type"}), " ") + ")]"
+	}
+
+	progress := listProgress(ctx)
+	var msgs []*gmail.Message
+	var failed int
+	err := p.do(ctx, func(c *imapConn) error {
+		msgs, failed = nil, 0
+		if err := c.selectFolder(folder); err != nil {
+			return err
+		}
+		uids, err := c.search(criteria)
+		if err != nil {
+			return err
+		}
+		slices.Sort(uids)
+		slices.Reverse(uids)
+		if max > 0 && int64(len(uids)) > max {
+			uids = uids[:max]
+		}
+		ids := make([]string, len(uids))
+		for i, uid := range uids {
+			ids[i] = imapID(folder, uid)
+		}
+		progress.found(ids)
+		if len(uids) == 0 {
+			return nil
+		}
+		fetched, err := c.fetch(uids, "UID FLAGS INTERNALDATE RFC822.SIZE "+item)
+		if err != nil {
+			return err
+		}
+		for _, uid := range uids {
+			f, ok := fetched[uid]
+			if !ok {
+				failed++
+				continue
+			}
+			msg := p.message(folder, f)
+			msg.Payload = mimepart.HeaderPart(f.header)
+			progress.fetched(msg)
+			msgs = append(msgs, msg)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return msgs, failed, nil
+}
+
+func (p *imapProvider) Get(ctx context.Context, id, format string) (*gmail.Message, error) {
+	folder, uid, err := parseIMAPID(id)
+	if err != nil {
+		return nil, err
+	}
+	item := "BODY.PEEK[]"
+	if format == "metadata" {
+		item = "BODY.PEEK[HEADER]"
+	}
+
+	var msg *gmail.Message
+	err = p.do(ctx, func(c *imapConn) error {
+		f, err := c.fetchOne(folder, uid, "UID FLAGS INTERNALDATE RFC822.SIZE "+item)
+		if err != nil {
+			return err
+		}
+		msg = p.message(folder, f)
+		switch format {
+		case "raw":
+			msg.Raw = base64.URLEncoding.EncodeToString(f.body)
+		case "metadata":
+			msg.Payload = mimepart.HeaderPart(f.header)
+		default:
+			msg.Payload = mimepart.Parse(f.body, "", false)
+		}
+		return nil
+	})
+	return msg, err
+}
+
+func (p *imapProvider) Attachment(ctx context.Context, messageID, attachmentID string) ([]byte, error) {
+	folder, uid, err := parseIMAPID(messageID)
+	if err != nil {
+		return nil, err
+	}
+	var raw []byte
+	err = p.do(ctx, func(c *imapConn) error {
+		f, err := c.fetchOne(folder, uid, "UID BODY.PEEK[]")
+		raw = f.body
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return mimepart.AttachmentData(raw, attachmentID)
+}
+
+// Modify maps UNREAD and STARRED to flags, and INBOX, TRASH and SPAM to
+// moves between folders: taking a message out of the inbox archives it,
+// and out of Trash or Spam puts it back in the inbox.
+func (p *imapProvider) Modify(ctx context.Context, id string, add, remove []string) (*gmail.Message, error) {
+	folder, uid, err := parseIMAPID(id)
+	if err != nil {
+		return nil, err
+	}
+	var plus, minus []string
+	target := folder
+	for _, l := range add {
+		switch l {
+		case "UNREAD":
+			minus = append(minus, `\Seen`)
+		case "STARRED":
+			plus = append(plus, `\Flagged`)
+		case "INBOX":
+			target = "INBOX"
+		case "TRASH":
+			target = p.cfg.TrashFolder
+		case "SPAM":
+			target = p.cfg.SpamFolder
+		default:
+			return nil, fmt.Errorf("the %s label is %v", l, ErrNoGmailAPI)
+		}
+	}
+	for _, l := range remove {
+		switch l {
+		case "UNREAD":
+			plus = append(plus, `\Seen`)
+		case "STARRED":
+			minus = append(minus, `\Flagged`)
+		case "INBOX", "TRASH", "SPAM":
+			if target != folder || p.folderLabel(folder) != l {
+				continue
+			}
+			target = "INBOX"
+			if l == "INBOX" {
+				target = p.cfg.ArchiveFolder
+			}
+		default:
+			return nil, fmt.Errorf("the %s label is %v", l, ErrNoGmailAPI)
+		}
+	}
+
+	var msg *gmail.Message
+	err = p.do(ctx, func(c *imapConn) error {
+		if err := c.selectFolder(folder); err != nil {
+			return err
+		}
+		if len(plus) > 0 {
+			if err := c.store(uid, "+FLAGS.SILENT", plus); err != nil {
+				return err
+			}
+		}
+		if len(minus) > 0 {
+			if err := c.store(uid, "-FLAGS.SILENT", minus); err != nil {
+				return err
+			}
+		}
+		f, err := c.fetchOne(folder, uid, "UID FLAGS")
+		if err != nil {
+			return err
+		}
+		msg = p.message(folder, f)
+		if target == folder {
+			return nil
+		}
+		moved, err := c.move(uid, target)
+		if err != nil {
+			return err
+		}
+		// Without a new UID from the server the message can't be found
+		// again, so it is left without an ID.
+		msg.Id, msg.ThreadId = "", ""
+		if moved != 0 {
+			msg.Id = imapID(target, moved)
+			msg.ThreadId = msg.Id
+		}
+		msg.LabelIds = p.labels(target, f.flags)
+		return nil
+	})
+	return msg, err
+}
+
+func (p *imapProvider) Trash(ctx context.Context, id string) (*gmail.Message, error) {
+	return p.Modify(ctx, id, []string{"TRASH"}, nil)
+}
+
+func (p *imapProvider) Untrash(ctx context.Context, id string) (*gmail.Message, error) {
+	return p.Modify(ctx, id, nil, []string{"TRASH"})
+}
+
+// SaveDraft appends the draft to the drafts folder and deletes the copy it
+// replaces, as IMAP messages can't be changed in place.
+func (p *imapProvider) SaveDraft(ctx context.Context, id, raw string) (string, error) {
+	data, err := mimepart.DecodeRaw(raw)
+	if err != nil {
+		return "", fmt.Errorf("unable to save draft: %v", err)
+	}
+	var saved string
+	err = p.do(ctx, func(c *imapConn) error {
+		uid, err := c.appendMessage(p.cfg.DraftsFolder, []string{`\Draft`, `\Seen`}, data)
+		if err != nil {
+			return err
+		}
+		if uid == 0 {
+			return errors.New("the saved draft can't be found")
+		}
+		saved = imapID(p.cfg.DraftsFolder, uid)
+		if id == "" || id == saved {
+			return nil
+		}
+		if folder, old, err := parseIMAPID(id); err == nil {
+			return c.remove(folder, old)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("unable to save draft: %v", err)
+	}
+	return saved, nil
+}
+
+// SendDraft sends a draft through the SMTP server and deletes it. A draft
+// that can't be deleted once sent is left in place rather than reported as
+// a failed send, which would send it again.
+func (p *imapProvider) SendDraft(ctx context.Context, id string) (*gmail.Message, error) {
+	folder, uid, err := parseIMAPID(id)
+	if err != nil {
+		return nil, err
+	}
+	var data []byte
+	err = p.do(ctx, func(c *imapConn) error {
+		f, err := c.fetchOne(folder, uid, "UID BODY.PEEK[]")
+		data = f.body
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	msg, err := p.send(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+	p.do(ctx, func(c *imapConn) error { return c.remove(folder, uid) })
+	return msg, nil
+}
+
+func (p *imapProvider) Send(ctx context.Context, raw string) (*gmail.Message, error) {
+	data, err := mimepart.DecodeRaw(raw)
+	if err != nil {
+		return nil, fmt.Errorf("unable to send message: %v", err)
+	}
+	return p.send(ctx, data)
+}
+
+// send delivers a message through the SMTP server and files a copy in the
+// sent folder. The message has gone by then, so a copy that can't be filed
+// doesn't fail the send.
+func (p *imapProvider) send(ctx context.Context, data []byte) (*gmail.Message, error) {
+	data = mimepart.WithSendHeaders(data)
+	if err := p.smtpSend(ctx, data); err != nil {
+		return nil, err
+	}
+	msg := &gmail.Message{LabelIds: []string{"SENT"}}
+	if !p.cfg.SaveSent {
+		return msg, nil
+	}
+	p.do(ctx, func(c *imapConn) error {
+		uid, err := c.appendMessage(p.cfg.SentFolder, []string{`\Seen`}, data)
+		if err == nil && uid != 0 {
+			msg.Id = imapID(p.cfg.SentFolder, uid)
+			msg.ThreadId = msg.Id
+		}
+		return err
+	})
+	return msg, nil
+}
+
+// smtpSend hands a message to the SMTP server for everyone in its To, Cc
+// and Bcc headers, leaving the Bcc header out. The connection is always
+// encrypted: with TLS from the start on port 465, or with STARTTLS.
+func (p *imapProvider) smtpSend(ctx context.Context, data []byte) error {
+	p.mu.Lock()
+	password, err := p.secret()
+	p.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("unable to send message: %v", err)
+	}
+	from, err := mimepart.AddressParser.Parse(msg.Header.Get("From"))
+	if err != nil {
+		return fmt.Errorf("unable to send message: invalid From address: %v", err)
+	}
+	var rcpts []string
+	for _, name := range []string{"To", "Cc", "Bcc"} {
+		if msg.Header.Get(name) == "" {
+			continue
+		}
+		addrs, err := mimepart.AddressParser.ParseList(msg.Header.Get(name))
+		if err != nil {
+			return fmt.Errorf("unable to send message: invalid %s address: %v", name, err)
+		}
+		for _, a := range addrs {
+			rcpts = append(rcpts, a.Address)
+		}
+	}
+	if len(rcpts) == 0 {
+		return errors.New("unable to send message: no recipients")
+	}
+
+	host := p.cfg.SMTPHost
+	addr := net.JoinHostPort(host, strconv.Itoa(p.cfg.SMTPPort))
+	var conn net.Conn
+	if p.cfg.SMTPPort == 465 {
+		conn, err = p.net.DialTLS(ctx, addr, host)
+	} else {
+		conn, err = p.net.Dial(ctx, addr)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to connect to %s: %w", addr, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(p.timeout))
+	defer context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })()
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return fmt.Errorf("unable to connect to %s: %w", addr, err)
+	}
+	defer c.Close()
+	if p.cfg.SMTPPort != 465 {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("unable to send message: %s doesn't offer STARTTLS, so the password would be sent in the clear", addr)
+		}
+		if err := c.StartTLS(p.net.TLSConfig(host)); err != nil {
+			return fmt.Errorf("unable to connect to %s: %w", addr, err)
+		}
+	}
+	if err := c.Auth(smtp.PlainAuth("", p.cfg.Username, password, host)); err != nil {
+		return fmt.Errorf("unable to sign in to %s: %v", host, err)
+	}
+	if err := c.Mail(from.Address); err != nil {
+		return fmt.Errorf("unable to send message: %v", err)
+	}
+	for _, rcpt := range rcpts {
+		if err := c.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("unable to send message to %s: %v", rcpt, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("unable to send message: %v", err)
+	}
+	if _, err := w.Write(mimepart.WithoutHeader(data, "Bcc")); err != nil {
+		return fmt.Errorf("unable to send message: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("unable to send message: %v", err)
+	}
+	return c.Quit()
+}
+
+// searchCriteria turns a Gmail search query into the folder to search and
+// IMAP SEARCH criteria. Operators with no IMAP equivalent are left out, so
+// a search can match more than it would on Gmail.
+func (p *imapProvider) searchCriteria(query string) (folder, criteria string) {
+	folder = "INBOX"
+	var terms []string
+	for _, tok := range queryTokens(query) {
+		negate := len(tok) > 1 && tok[0] == '-'
+		if negate {
+			tok = tok[1:]
+		}
+		op, arg, ok := strings.Cut(tok, ":")
+		if !ok {
+			op, arg = "", tok
+		}
+		arg = strings.Trim(arg, `"`)
+
+		var term string
+		switch op = strings.ToLower(op); op {
+		case "in", "label":
+			if !negate {
+				folder = p.folder(arg)
+			}
+		case "is":
+			term = map[string]string{"unread": "UNSEEN", "read": "SEEN", "starred": "FLAGGED"}[strings.ToLower(arg)]
+		case "from", "to", "cc", "bcc", "subject":
+			term = strings.ToUpper(op) + " " + imapString(arg)
+		case "larger", "smaller":
+			if n := querySize(arg); n > 0 {
+				term = fmt.Sprintf("%s %d", strings.ToUpper(op), n)
+			}
+		case "after", "before", "newer_than", "older_than":
+			if t, ok := queryDate(op, arg); ok {
+				term = "SINCE " + t.Format("2-Jan-2006")
+				if op == "before" || op == "older_than" {
+					term = "BEFORE " + t.Format("2-Jan-2006")
+				}
+			}
+		case "":
+			if arg != "" && arg != "OR" && arg != "AND" {
+				term = "TEXT " + imapString(arg)
+			}
+		}
+		if term == "" {
+			continue
+		}
+		if negate {
+			term = "NOT " + term
+		}
+		terms = append(terms, term)
+	}
+	if len(terms) == 0 {
+		return folder, "ALL"
+	}
+	return folder, strings.Join(terms, " ")
+}
+
+// queryTokens splits a search query at spaces outside quotes.
+func queryTokens(query string) []string {
+	var tokens []string
+	var b strings.Builder
+	quoted := false
+	for _, r := range query {
+		if r == '"' {
+			quoted = !quoted
+		}
+		if r == ' ' && !quoted {
+			if b.Len() > 0 {
+				tokens = append(tokens, b.String())
+				b.Reset()
+			}
+			continue
+		}
+		b.WriteRune(r)
+	}
+	if b.Len() > 0 {
+		tokens = append(tokens, b.String())
+	}
+	return tokens
+}
+
+// querySize reads a size such as 5M or 200K, in bytes.
+func querySize(s string) int64 {
+	mult := int64(1)
+	switch {
+	case strings.HasSuffix(strings.ToUpper(s), "M"):
+		mult, s = 1<<20, s[:len(s)-1]
+	case strings.HasSuffix(strings.ToUpper(s), "K"):
+		mult, s = 1<<10, s[:len(s)-1]
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return n * mult
+}
+
+// queryDate reads the date of an after: or before: search, such as
+// 2024/03/31, or the time ago of a newer_than: or older_than: one, such as
+// 3d, 2m or 1y.
+func queryDate(op, arg string) (time.Time, bool) {
+	if op == "newer_than" || op == "older_than" {
+		if len(arg) < 2 {
+			return time.Time{}, false
+		}
+		n, err := strconv.Atoi(arg[:len(arg)-1])
+		if err != nil {
+			return time.Time{}, false
+		}
+		now := time.Now()
+		switch arg[len(arg)-1] {
+		case 'd':
+			return now.AddDate(0, 0, -n), true
+		case 'm':
+			return now.AddDate(0, -n, 0), true
+		case 'y':
+			return now.AddDate(-n, 0, 0), true
+		}
+		return time.Time{}, false
+	}
+	for _, layout := range []string{"2006/1/2", "2006-1-2", "1/2/2006"} {
+		if t, err := time.Parse(layout, arg); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// imapConn is a signed-in connection to an IMAP server.
+type imapConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	tag  int
+	// selected is the open folder.
+	selected string
+}
+
+// imapResponse is one response from the server, with any literals it
+// carried. Status responses (OK, NO, BAD, BYE) and continuations keep the
+// rest of their line as text; other responses are split into fields, with
+// lists as []any, strings as string and NIL as nil.
+type imapResponse struct {
+	tag    string
+	fields []any
+	text   string
+}
+
+// status is the status of a status response, such as OK or NO.
+func (r imapResponse) status() string {
+	if len(r.fields) == 0 {
+		return ""
+	}
+	s, _ := r.fields[0].(string)
+	return strings.ToUpper(s)
+}
+
+// imapError is a command the server refused.
+type imapError struct {
+	command string
+	status  string
+	text    string
+}
+
+func (e *imapError) Error() string {
+	return fmt.Sprintf("IMAP %s failed: %s", e.command, e.text)
+}
+
+// imapFetched is what a FETCH returned for one message.
+type imapFetched struct {
+	uid    uint32
+	flags  []string
+	date   time.Time
+	size   int64
+	header []byte
+	body   []byte
+}
+
+func dialIMAP(ctx context.Context, n *network.Network, cfg config.IMAPConfig, password string, timeout time.Duration) (*imapConn, error) {
+	addr := net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+	conn, err := n.DialTLS(ctx, addr, cfg.Host)
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to %s: %w", addr, err)
+	}
+	conn.SetDeadline(time.Now().Add(timeout))
+	c := &imapConn{conn: conn, r: bufio.NewReader(conn)}
+	greeting, err := c.readResponse()
+	if err == nil && greeting.status() != "OK" {
+		err = errors.New(greeting.text)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("unable to connect to %s: %w", addr, err)
+	}
+	if _, err := c.run("LOGIN "+imapString(cfg.Username)+" "+imapString(password), nil); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("unable to sign in to %s: %v", cfg.Host, err)
+	}
+	return c, nil
+}
+
+// run sends a command, followed by literal if it isn't nil, and returns
+// the responses to it, ending with the tagged one.
+func (c *imapConn) run(command string, literal []byte) ([]imapResponse, error) {
+	c.tag++
+	tag := "g" + strconv.Itoa(c.tag)
+	if literal != nil {
+		command += fmt.Sprintf(" {%d}\r\n", len(literal)) + string(literal)
+	}
+	// Each literal is sent once the server is ready for it.
+	chunks := literalChunks(tag + " " + command)
+	if _, err := io.WriteString(c.conn, chunks[0]); err != nil {
+		return nil, err
+	}
+	chunks = chunks[1:]
+
+	name := strings.Fields(command)[0]
+	if name == "UID" {
+		name += " " + strings.Fields(command)[1]
+	}
+	var resps []imapResponse
+	for {
+		resp, err := c.readResponse()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case resp.tag == "+" && len(chunks) > 0:
+			if _, err := io.WriteString(c.conn, chunks[0]); err != nil {
+				return nil, err
+			}
+			chunks = chunks[1:]
+		case resp.tag == tag:
+			resps = append(resps, resp)
+			if resp.status() != "OK" {
+				return resps, &imapError{command: name, status: resp.status(), text: resp.text}
+			}
+			return resps, nil
+		case resp.status() == "BYE":
+			return nil, fmt.Errorf("IMAP server closed the connection: %s: %w", resp.text, io.EOF)
+		default:
+			resps = append(resps, resp)
+		}
+	}
+}
+
+// readResponse reads a response, with the literals in it.
+func (c *imapConn) readResponse() (imapResponse, error) {
+	var raw []byte
+	for {
+		line, err := c.r.ReadBytes('\n')
+		if err != nil {
+			return imapResponse{}, err
+		}
+		raw = append(raw, line...)
+		n, ok := literalSize(line)
+		if !ok {
+			break
+		}
+		lit := make([]byte, n)
+		if _, err := io.ReadFull(c.r, lit); err != nil {
+			return imapResponse{}, err
+		}
+		raw = append(raw, lit...)
+	}
+
+	p := &imapParser{b: raw}
+	tag, err := p.value()
+	if err != nil {
+		return imapResponse{}, fmt.Errorf("unable to read IMAP response: %v", err)
+	}
+	resp := imapResponse{}
+	resp.tag, _ = tag.(string)
+	if resp.tag == "+" {
+		resp.text = p.rest()
+		return resp, nil
+	}
+	for !p.atEnd() {
+		// A response the parser can't make sense of is kept as far as
+		// it was read.
+		v, err := p.value()
+		if err != nil {
+			break
+		}
+		resp.fields = append(resp.fields, v)
+		if len(resp.fields) == 1 {
+			switch resp.status() {
+			case "OK", "NO", "BAD", "BYE", "PREAUTH":
+				resp.text = p.rest()
+				return resp, nil
+			}
+		}
+	}
+	return resp, nil
+}
+
+// literalSize reads the size of the literal a line ends in, as {n}.
+func literalSize(line []byte) (int, bool) {
+	line = bytes.TrimRight(line, "\r\n")
+	i := bytes.LastIndexByte(line, '{')
+	if i < 0 || !bytes.HasSuffix(line, []byte("}")) {
+		return 0, false
+	}
+	n, err := strconv.Atoi(strings.TrimSuffix(string(line[i+1:len(line)-1]), "+"))
+	return n, err == nil && n >= 0 && n < 1<<30
+}
+
+// imapParser reads the fields of a response.
+type imapParser struct {
+	b []byte
+	i int
+}
+
+func (p *imapParser) skipSpaces() {
+	for p.i < len(p.b) && p.b[p.i] == ' ' {
+		p.i++
+	}
+}
+
+func (p *imapParser) atEnd() bool {
+	p.skipSpaces()
+	return p.i >= len(p.b) || p.b[p.i] == '\r' || p.b[p.i] == '\n'
+}
+
+// rest returns the rest of the line as it is.
+func (p *imapParser) rest() string {
+	p.skipSpaces()
+	return strings.TrimRight(string(p.b[p.i:]), "\r\n")
+}
+
+func (p *imapParser) value() (any, error) {
+	if p.atEnd() {
+		return nil, errors.New("unexpected end of line")
+	}
+	switch p.b[p.i] {
+	case '(':
+		p.i++
+		list := []any{}
+		for {
+			if p.atEnd() {
+				return nil, errors.New("unterminated list")
+			}
+			if p.b[p.i] == ')' {
+				p.i++
+				return list, nil
+			}
+			v, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+	case '"':
+		var s []byte
+		for p.i++; p.i < len(p.b); p.i++ {
+			switch ch := p.b[p.i]; ch {
+			case '\\':
+				if p.i+1 < len(p.b) {
+					p.i++
+					s = append(s, p.b[p.i])
+				}
+			case '"':
+				p.i++
+				return string(s), nil
+			default:
+				s = append(s, ch)
+			}
+		}
+		return nil, errors.New("unterminated string")
+	case '{':
+		end := bytes.IndexByte(p.b[p.i:], '\n')
+		if end < 0 {
+			return nil, errors.New("malformed literal")
+		}
+		start := p.i + end + 1
+		n, ok := literalSize(p.b[p.i:start])
+		if !ok || start+n > len(p.b) {
+			return nil, errors.New("malformed literal")
+		}
+		p.i = start + n
+		return string(p.b[start:p.i]), nil
+	}
+
+	// An atom, which may have a bracketed section with spaces in it, as
+	// in BODY[HEADER.FIELDS (FROM TO)].
+	start, depth := p.i, 0
+	for ; p.i < len(p.b); p.i++ {
+		ch := p.b[p.i]
+		if ch == '[' {
+			depth++
+		} else if ch == ']' {
+			depth--
+		} else if depth <= 0 && (ch == ' ' || ch == '(' || ch == ')' || ch == '\r' || ch == '\n') {
+			break
+		}
+	}
+	if p.i == start {
+		return nil, fmt.Errorf("unexpected %q", p.b[p.i])
+	}
+	atom := string(p.b[start:p.i])
+	if strings.EqualFold(atom, "NIL") {
+		return nil, nil
+	}
+	return atom, nil
+}
+
+// imapQuote quotes s as an IMAP string.
+func imapQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\r", "", "\n", "").Replace(s) + `"`
+}
+
+// imapString writes s as a quoted string or, if it isn't ASCII, which
+// quoted strings can't hold, as a literal for run to send.
+func imapString(s string) string {
+	for _, r := range s {
+		if r > 127 {
+			return fmt.Sprintf("{%d}\r\n%s", len(s), s)
+		}
+	}
+	return imapQuote(s)
+}
+
+// literalChunks splits a command line at the literals in it, written as
+// {n} and CRLF followed by n bytes, into the first line and what is sent
+// after each of the server's continuation requests.
+func literalChunks(line string) []string {
+	var chunks []string
+	start, from := 0, 0
+	for {
+		i := strings.Index(line[from:], "}\r\n")
+		if i < 0 {
+			break
+		}
+		i += from
+		from = i + 3
+		open := strings.LastIndexByte(line[:i], '{')
+		if open < 0 {
+			continue
+		}
+		n, err := strconv.Atoi(line[open+1 : i])
+		if err != nil || from+n > len(line) {
+			continue
+		}
+		chunks = append(chunks, line[start:from])
+		start = from
+		from += n
+	}
+	return append(chunks, line[start:]+"\r\n")
+}
+
+// responseCode returns the arguments of a response code such as
+// [APPENDUID 38505 3955] in the responses.
+func responseCode(resps []imapResponse, code string) string {
+	for _, r := range resps {
+		if rest, ok := strings.CutPrefix(r.text, "["+code+" "); ok {
+			args, _, _ := strings.Cut(rest, "]")
+			return args
+		}
+	}
+	return ""
+}
+
+// codeUID reads the UID that APPENDUID and COPYUID give last.
+func codeUID(args string) uint32 {
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		return 0
+	}
+	n, _ := strconv.ParseUint(fields[len(fields)-1], 10, 32)
+	return uint32(n)
+}
+
+func (c *imapConn) selectFolder(folder string) error {
+	if c.selected == folder {
+		return nil
+	}
+	c.selected = ""
+	if _, err := c.run("SELECT "+imapQuote(folder), nil); err != nil {
+		return err
+	}
+	c.selected = folder
+	return nil
+}
+
+// search returns the UIDs of the messages in the open folder matching the
+// criteria.
+func (c *imapConn) search(criteria string) ([]uint32, error) {
+	command := "UID SEARCH " + criteria
+	for _, r := range criteria {
+		if r > 127 {
+			command = "UID SEARCH CHARSET UTF-8 " + criteria
+			break
+		}
+	}
+	resps, err := c.run(command, nil)
+	if err != nil {
+		return nil, err
+	}
+	var uids []uint32
+	for _, r := range resps {
+		if r.tag != "*" || len(r.fields) == 0 || r.status() != "SEARCH" {
+			continue
+		}
+		for _, f := range r.fields[1:] {
+			s, _ := f.(string)
+			if n, err := strconv.ParseUint(s, 10, 32); err == nil {
+				uids = append(uids, uint32(n))
+			}
+		}
+	}
+	return uids, nil
+}
+
+// fetch fetches items of messages in the open folder, by UID.
+func (c *imapConn) fetch(uids []uint32, items string) (map[uint32]imapFetched, error) {
+	set := make([]string, len(uids))
+	for i, uid := range uids {
+		set[i] = strconv.FormatUint(uint64(uid), 10)
+	}
+	resps, err := c.run("UID FETCH "+strings.Join(set, ",")+" ("+items+")", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	fetched := map[uint32]imapFetched{}
+	for _, r := range resps {
+		if r.tag != "*" || len(r.fields) < 3 {
+			continue
+		}
+		if name, _ := r.fields[1].(string); !strings.EqualFold(name, "FETCH") {
+			continue
+		}
+		list, _ := r.fields[2].([]any)
+		var f imapFetched
+		for i := 0; i+1 < len(list); i += 2 {
+			name, _ := list[i].(string)
+			s, _ := list[i+1].(string)
+			switch name = strings.ToUpper(name); {
+			case name == "UID":
+				n, _ := strconv.ParseUint(s, 10, 32)
+				f.uid = uint32(n)
+			case name == "FLAGS":
+				flags, _ := list[i+1].([]any)
+				for _, flag := range flags {
+					if s, ok := flag.(string); ok {
+						f.flags = append(f.flags, s)
+					}
+				}
+			case name == "INTERNALDATE":
+				f.date, _ = time.Parse("2-Jan-2006 15:04:05 -0700", strings.TrimSpace(s))
+			case name == "RFC822.SIZE":
+				f.size, _ = strconv.ParseInt(s, 10, 64)
+			case name == "BODY[]":
+				f.body = []byte(s)
+			case strings.HasPrefix(name, "BODY[HEADER"):
+				f.header = []byte(s)
+			}
+		}
+		// Flag changes the server reports on its own are not what was
+		// asked for.
+		if _, ok := fetched[f.uid]; f.uid != 0 && (!ok || f.header != nil || f.body != nil) {
+			fetched[f.uid] = f
+		}
+	}
+	return fetched, nil
+}
+
+// fetchOne fetches items of one message, failing as the Gmail API does if
+// it is gone.
+func (c *imapConn) fetchOne(folder string, uid uint32, items string) (imapFetched, error) {
+	if err := c.selectFolder(folder); err != nil {
+		return imapFetched{}, err
+	}
+	fetched, err := c.fetch([]uint32{uid}, items)
+	if err != nil {
+		return imapFetched{}, err
+	}
+	f, ok := fetched[uid]
+	if !ok {
+		return imapFetched{}, &googleapi.Error{Code: http.StatusNotFound, Message: "no message " + imapID(folder, uid)}
+	}
+	return f, nil
+}
+
+func (c *imapConn) store(uid uint32, op string, flags []string) error {
+	_, err := c.run(fmt.Sprintf("UID STORE %d %s (%s)", uid, op, strings.Join(flags, " ")), nil)
+	return err
+}
+
+// move moves a message from the open folder to another, returning its UID
+// there, or zero if the server doesn't say. Servers without the MOVE
+// extension copy it and delete the original.
+func (c *imapConn) move(uid uint32, folder string) (uint32, error) {
+	resps, err := c.run(fmt.Sprintf("UID MOVE %d %s", uid, imapQuote(folder)), nil)
+	var refused *imapError
+	if errors.As(err, &refused) && refused.status == "BAD" {
+		if resps, err = c.run(fmt.Sprintf("UID COPY %d %s", uid, imapQuote(folder)), nil); err != nil {
+			return 0, err
+		}
+		if err := c.expunge(uid); err != nil {
+			return 0, err
+		}
+	} else if err != nil {
+		return 0, err
+	}
+	return codeUID(responseCode(resps, "COPYUID")), nil
+}
+
+// expunge deletes a message from the open folder for good.
+func (c *imapConn) expunge(uid uint32) error {
+	if err := c.store(uid, "+FLAGS.SILENT", []string{`\Deleted`}); err != nil {
+		return err
+	}
+	if _, err := c.run(fmt.Sprintf("UID EXPUNGE %d", uid), nil); err != nil {
+		// Without the UIDPLUS extension, everything marked deleted in
+		// the folder goes.
+		_, err = c.run("EXPUNGE", nil)
+		return err
+	}
+	return nil
+}
+
+// remove deletes a message for good, if it is still there.
+func (c *imapConn) remove(folder string, uid uint32) error {
+	if err := c.selectFolder(folder); err != nil {
+		return err
+	}
+	return c.expunge(uid)
+}
+
+// appendMessage adds a message to a folder, returning its UID there. Without
+// the UIDPLUS extension to say, the newest message in the folder is taken
+// to be it.
+func (c *imapConn) appendMessage(folder string, flags []string, msg []byte) (uint32, error) {
+	resps, err := c.run(fmt.Sprintf("APPEND %s (%s)", imapQuote(folder), strings.Join(flags, " ")), msg)
+	if err != nil {
+		return 0, err
+	}
+	if uid := codeUID(responseCode(resps, "APPENDUID")); uid != 0 {
+		return uid, nil
+	}
+	if err := c.selectFolder(folder); err != nil {
+		return 0, err
+	}
+	uids, err := c.search("ALL")
+	if err != nil || len(uids) == 0 {
+		return 0, err
+	}
+	return slices.Max(uids), nil
+}
@@ -0,0 +1,364 @@
+package backend
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"gmail-tui/internal/config"
+	"gmail-tui/internal/network"
+)
+
+func TestReadResponse(t *testing.T) {
+	header := "From: ada@example.com\r\n\r\n"
+	tests := []struct {
+		name   string
+		raw    string
+		tag    string
+		fields []any
+		text   string
+	}{
+		{
+			name:   "status",
+			raw:    "* OK [CAPABILITY IMAP4rev1] ready\r\n",
+			tag:    "*",
+			fields: []any{"OK"},
+			text:   "[CAPABILITY IMAP4rev1] ready",
+		},
+		{
+			name:   "tagged refusal",
+			raw:    "g3 NO [TRYCREATE] no such folder\r\n",
+			tag:    "g3",
+			fields: []any{"NO"},
+			text:   "[TRYCREATE] no such folder",
+		},
+		{
+			name: "continuation",
+			raw:  "+ go ahead\r\n",
+			tag:  "+",
+			text: "go ahead",
+		},
+		{
+			name:   "search",
+			raw:    "* SEARCH 4 8 15\r\n",
+			tag:    "*",
+			fields: []any{"SEARCH", "4", "8", "15"},
+		},
+		{
+			name:   "quoted strings and NIL",
+			raw:    `* LIST (\HasNoChildren) "/" "My \"Box\"" NIL` + "\r\n",
+			tag:    "*",
+			fields: []any{"LIST", []any{`\HasNoChildren`}, "/", `My "Box"`, nil},
+		},
+		{
+			name: "fetch with a literal",
+			raw:  "* 3 FETCH (UID 7 FLAGS (\\Seen) BODY[HEADER.FIELDS (FROM)] {" + strconv.Itoa(len(header)) + "}\r\n" + header + ")\r\n",
+			tag:  "*",
+			fields: []any{"3", "FETCH", []any{
+				"UID", "7", "FLAGS", []any{`\Seen`}, "BODY[HEADER.FIELDS (FROM)]", header,
+			}},
+		},
+	}
+	for _, tt := range tests {
+		c := &imapConn{r: bufio.NewReader(strings.NewReader(tt.raw))}
+		resp, err := c.readResponse()
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if resp.tag != tt.tag || resp.text != tt.text || !reflect.DeepEqual(resp.fields, tt.fields) {
+			t.Errorf("%s: got tag %q, fields %#v, text %q; want %q, %#v, %q", tt.name, resp.tag, resp.fields, resp.text, tt.tag, tt.fields, tt.text)
+		}
+	}
+}
+
+func TestReadResponseTruncatedLiteral(t *testing.T) {
+	c := &imapConn{r: bufio.NewReader(strings.NewReader("* 1 FETCH (BODY[] {100}\r\nshort"))}
+	if _, err := c.readResponse(); err == nil {
+		t.Error("read a literal shorter than its size")
+	}
+}
+
+func TestLiteralSize(t *testing.T) {
+	tests := []struct {
+		line string
+		n    int
+		ok   bool
+	}{
+		{"* 1 FETCH (BODY[] {12}\r\n", 12, true},
+		{"a1 APPEND Drafts {5+}\r\n", 5, true},
+		{"* OK done\r\n", 0, false},
+		{"* 1 FETCH (BODY[] {x}\r\n", 0, false},
+		{"* 1 FETCH (BODY[] {-1}\r\n", 0, false},
+		{"* 1 FETCH (BODY[] {12} trailing\r\n", 0, false},
+	}
+	for _, tt := range tests {
+		n, ok := literalSize([]byte(tt.line))
+		if ok != tt.ok || ok && n != tt.n {
+			t.Errorf("literalSize(%q) = %d, %v; want %d, %v", tt.line, n, ok, tt.n, tt.ok)
+		}
+	}
+}
+
+func TestLiteralChunks(t *testing.T) {
+	got := literalChunks("g1 LOGIN {5}\r\nnaïve \"pw\"")
+	want := []string{"g1 LOGIN {5}\r\n", "naïve \"pw\"\r\n"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("literalChunks = %q, want %q", got, want)
+	}
+	if got := literalChunks("g2 NOOP"); !reflect.DeepEqual(got, []string{"g2 NOOP\r\n"}) {
+		t.Errorf("literalChunks without a literal = %q", got)
+	}
+}
+
+func TestIMAPString(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"plain", `"plain"`},
+		{`say "hi" \o/`, `"say \"hi\" \\o/"`},
+		{"line\r\nbreak", `"linebreak"`},
+		{"naïve", "{6}\r\nnaïve"},
+		{"", `""`},
+	}
+	for _, tt := range tests {
+		if got := imapString(tt.in); got != tt.want {
+			t.Errorf("imapString(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSearchCriteria(t *testing.T) {
+	p := &imapProvider{cfg: config.IMAPConfig{
+		ArchiveFolder: "Archive",
+		SentFolder:    "Sent",
+		DraftsFolder:  "Drafts",
+		TrashFolder:   "Trash",
+		SpamFolder:    "Junk",
+	}}
+	tests := []struct {
+		query, folder, criteria string
+	}{
+		{"", "INBOX", "ALL"},
+		{"is:unread from:ada", "INBOX", `UNSEEN FROM "ada"`},
+		{`subject:"weekly report"`, "INBOX", `SUBJECT "weekly report"`},
+		{"-is:starred", "INBOX", "NOT FLAGGED"},
+		{"in:sent larger:5M", "Sent", "LARGER 5242880"},
+		{"label:Receipts smaller:200K", "Receipts", "SMALLER 204800"},
+		{"-in:trash to:bob", "INBOX", `TO "bob"`},
+		{"after:2024/03/31", "INBOX", "SINCE 31-Mar-2024"},
+		{"before:2024-3-1", "INBOX", "BEFORE 1-Mar-2024"},
+		{"café", "INBOX", "TEXT {5}\r\ncafé"},
+		{"has:attachment", "INBOX", "ALL"},
+		{"ada OR bob", "INBOX", `TEXT "ada" TEXT "bob"`},
+		{"in:spam", "Junk", "ALL"},
+	}
+	for _, tt := range tests {
+		folder, criteria := p.searchCriteria(tt.query)
+		if folder != tt.folder || criteria != tt.criteria {
+			t.Errorf("searchCriteria(%q) = %q, %q; want %q, %q", tt.query, folder, criteria, tt.folder, tt.criteria)
+		}
+	}
+}
+
+// fakeSMTP is an SMTP server for one session. It offers STARTTLS when tls
+// is set, and records the commands and the message it gets.
+type fakeSMTP struct {
+	tls *tls.Config
+
+	commands []string
+	data     string
+	done     chan struct{}
+}
+
+func (s *fakeSMTP) serve(t *testing.T, l net.Listener) {
+	defer close(s.done)
+	conn, err := l.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	r, w := bufio.NewReader(conn), conn
+	reply := func(lines ...string) {
+		for _, l := range lines {
+			w.Write([]byte(l + "\r\n"))
+		}
+	}
+
+	reply("220 fake ESMTP")
+	secure := false
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.TrimRight(line, "\r\n")
+		s.commands = append(s.commands, cmd)
+		switch verb := strings.ToUpper(strings.Fields(cmd + " x")[0]); verb {
+		case "EHLO":
+			if s.tls != nil && !secure {
+				reply("250-fake", "250 STARTTLS")
+			} else {
+				reply("250-fake", "250 AUTH PLAIN")
+			}
+		case "STARTTLS":
+			reply("220 go ahead")
+			tc := tls.Server(conn, s.tls)
+			if err := tc.Handshake(); err != nil {
+				t.Errorf("TLS handshake: %v", err)
+				return
+			}
+			r, w, secure = bufio.NewReader(tc), tc, true
+		case "AUTH":
+			reply("235 ok")
+		case "MAIL", "RCPT":
+			reply("250 ok")
+		case "DATA":
+			reply("354 go ahead")
+			var b strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				b.WriteString(l)
+			}
+			s.data = b.String()
+			reply("250 queued")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 unknown")
+		}
+	}
+}
+
+// startSMTP runs s on a local port and returns an imapProvider that sends
+// through it, trusting the certificate s presents.
+func startSMTP(t *testing.T, s *fakeSMTP) *imapProvider {
+	t.Helper()
+	for _, v := range []string{"ALL_PROXY", "all_proxy", "HTTPS_PROXY", "https_proxy", "HTTP_PROXY", "http_proxy"} {
+		t.Setenv(v, "")
+	}
+	var netCfg config.NetworkConfig
+	if s.tls != nil {
+		netCfg.CABundle = filepath.Join(t.TempDir(), "ca.pem")
+		cert := s.tls.Certificates[0].Certificate[0]
+		if err := os.WriteFile(netCfg.CABundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert}), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	n, err := network.New(netCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	s.done = make(chan struct{})
+	go s.serve(t, l)
+
+	port := l.Addr().(*net.TCPAddr).Port
+	return &imapProvider{
+		cfg:      config.IMAPConfig{SMTPHost: "127.0.0.1", SMTPPort: port, Username: "ada"},
+		timeout:  5 * time.Second,
+		net:      n,
+		password: "secret",
+	}
+}
+
+// testTLS returns a server config with a self-signed certificate for
+// 127.0.0.1.
+func testTLS(t *testing.T) *tls.Config {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+}
+
+const testMessage = "From: Ada <ada@example.com>\r\n" +
+	"To: bob@example.com\r\n" +
+	"Cc: Carol <carol@example.com>\r\n" +
+	"Bcc: dave@example.com\r\n" +
+	"Subject: Minutes\r\n" +
+	"\r\n" +
+	"Attached.\r\n"
+
+func TestSMTPSendStripsBcc(t *testing.T) {
+	s := &fakeSMTP{tls: testTLS(t)}
+	p := startSMTP(t, s)
+	if err := p.smtpSend(context.Background(), []byte(testMessage)); err != nil {
+		t.Fatal(err)
+	}
+	<-s.done
+
+	var rcpts []string
+	for _, c := range s.commands {
+		if strings.HasPrefix(c, "RCPT TO:") {
+			rcpts = append(rcpts, c)
+		}
+	}
+	want := []string{"RCPT TO:<bob@example.com>", "RCPT TO:<carol@example.com>", "RCPT TO:<dave@example.com>"}
+	if !reflect.DeepEqual(rcpts, want) {
+		t.Errorf("recipients %q, want %q", rcpts, want)
+	}
+	if strings.Contains(s.data, "Bcc") || strings.Contains(s.data, "dave@example.com") {
+		t.Errorf("the message sent names the Bcc recipient:\n%s", s.data)
+	}
+	if !strings.Contains(s.data, "Subject: Minutes") {
+		t.Errorf("the message sent lost its header:\n%s", s.data)
+	}
+}
+
+func TestSMTPRequiresSTARTTLS(t *testing.T) {
+	s := &fakeSMTP{}
+	p := startSMTP(t, s)
+	err := p.smtpSend(context.Background(), []byte(testMessage))
+	if err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+		t.Fatalf("smtpSend without STARTTLS returned %v", err)
+	}
+	<-s.done
+	for _, c := range s.commands {
+		if strings.HasPrefix(strings.ToUpper(c), "AUTH") || strings.HasPrefix(strings.ToUpper(c), "MAIL") {
+			t.Errorf("sent %q over an unencrypted connection", c)
+		}
+	}
+}
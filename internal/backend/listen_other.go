@@ -0,0 +1,9 @@
+//go:build !unix
+
+package backend
+
+import "net"
+
+func listenPrivate(socket string) (net.Listener, error) {
+	return net.Listen("unix", socket)
+}
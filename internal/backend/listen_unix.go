@@ -0,0 +1,17 @@
+//go:build unix
+
+package backend
+
+import (
+	"net"
+	"syscall"
+)
+
+// listenPrivate listens on socket with the umask cleared of group and
+// other bits, so the socket is never reachable by other users, not even
+// between being created and having its mode set.
+func listenPrivate(socket string) (net.Listener, error) {
+	old := syscall.Umask(0177)
+	defer syscall.Umask(old)
+	return net.Listen("unix", socket)
+}
@@ -0,0 +1,28 @@
+//go:build darwin || freebsd
+
+package backend
+
+import (
+	"net"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// peerIsSelf reports whether the process at the other end of conn runs as
+// the same user as the daemon.
+func peerIsSelf(conn net.Conn) bool {
+	uc, ok := conn.(*net.UnixConn)
+	if !ok {
+		return false
+	}
+	raw, err := uc.SyscallConn()
+	if err != nil {
+		return false
+	}
+	var cred *unix.Xucred
+	err = raw.Control(func(fd uintptr) {
+		cred, err = unix.GetsockoptXucred(int(fd), unix.SOL_LOCAL, unix.LOCAL_PEERCRED)
+	})
+	return err == nil && int(cred.Uid) == os.Getuid()
+}
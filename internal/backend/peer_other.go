@@ -0,0 +1,11 @@
+//go:build !linux && !darwin && !freebsd
+
+package backend
+
+import "net"
+
+// peerIsSelf can't ask for the peer's credentials here, so the socket's
+// mode is all that keeps other users out.
+func peerIsSelf(net.Conn) bool {
+	return true
+}
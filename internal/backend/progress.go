@@ -0,0 +1,77 @@
+package backend
+
+import (
+	"context"
+
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/googleapi"
+)
+
+// ListProgress follows a List as it runs, so a list can be shown filling
+// in rather than all at once. Either function may be nil. Both are called
+// from the goroutine running List.
+type ListProgress struct {
+	// Found is called with the IDs of the messages listed, newest first,
+	// before their headers are fetched.
+	Found func(ids []string)
+	// Fetched is called with each message as its headers arrive, in no
+	// particular order.
+	Fetched func(msg *gmail.Message)
+}
+
+type listProgressKey struct{}
+
+// WithListProgress returns a context that makes List report its progress
+// to p.
+func WithListProgress(ctx context.Context, p ListProgress) context.Context {
+	return context.WithValue(ctx, listProgressKey{}, p)
+}
+
+func listProgress(ctx context.Context) ListProgress {
+	p, _ := ctx.Value(listProgressKey{}).(ListProgress)
+	return p
+}
+
+func (p ListProgress) found(ids []string) {
+	if p.Found != nil {
+		p.Found(ids)
+	}
+}
+
+func (p ListProgress) fetched(msg *gmail.Message) {
+	if p.Fetched != nil {
+		p.Fetched(msg)
+	}
+}
+
+// UploadProgress follows the upload of a large message by SaveDraft or
+// Send, with how many of its bytes have been sent out of total. It is
+// called from the goroutine saving or sending the message.
+type UploadProgress func(sent, total int64)
+
+type uploadProgressKey struct{}
+
+// WithUploadProgress returns a context that makes SaveDraft and Send report
+// the progress of uploading a large message to p.
+func WithUploadProgress(ctx context.Context, p UploadProgress) context.Context {
+	return context.WithValue(ctx, uploadProgressKey{}, p)
+}
+
+func uploadProgress(ctx context.Context) UploadProgress {
+	p, _ := ctx.Value(uploadProgressKey{}).(UploadProgress)
+	return p
+}
+
+// updater reports progress through p, which knows the total the API's
+// updater leaves out.
+func (p UploadProgress) updater(total int64) googleapi.ProgressUpdater {
+	return func(sent, _ int64) {
+		p.sent(sent, total)
+	}
+}
+
+func (p UploadProgress) sent(sent, total int64) {
+	if p != nil {
+		p(sent, total)
+	}
+}
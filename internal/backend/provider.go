@@ -0,0 +1,167 @@
+// Package backend fetches and sends mail, through the Gmail API or another
+// provider, presenting messages the same way whatever their source.
+package backend
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/option"
+	"google.golang.org/api/people/v1"
+
+	"gmail-tui/internal/config"
+	"gmail-tui/internal/network"
+)
+
+// Provider is where mail is read from and sent through: the Gmail API,
+// or an IMAP and SMTP server. Messages are passed around as Gmail API
+// messages whatever their source, with Gmail's system label IDs (INBOX,
+// UNREAD, STARRED, TRASH, ...) standing for folders and flags. Features
+// with no equivalent elsewhere, such as filters or snoozing, still use the
+// Gmail API directly.
+type Provider interface {
+	// List returns the newest max messages matching a Gmail search query,
+	// with the given headers, and how many could not be fetched.
+	List(ctx context.Context, query string, max int64, headers ...string) ([]*gmail.Message, int, error)
+	// Get returns a message in the "full", "raw" or "metadata" format.
+	Get(ctx context.Context, id, format string) (*gmail.Message, error)
+	// Attachment returns the content of an attachment of a message.
+	Attachment(ctx context.Context, messageID, attachmentID string) ([]byte, error)
+	// Modify adds and removes labels, returning the message with its
+	// labels afterwards.
+	Modify(ctx context.Context, id string, add, remove []string) (*gmail.Message, error)
+	Trash(ctx context.Context, id string) (*gmail.Message, error)
+	Untrash(ctx context.Context, id string) (*gmail.Message, error)
+	// SaveDraft saves a raw message as draft id, or as a new draft if id
+	// is empty, returning the draft's ID. The ID may change on every save.
+	// Large messages report the progress of their upload to the
+	// UploadProgress of ctx, as do those passed to Send.
+	SaveDraft(ctx context.Context, id, raw string) (string, error)
+	// SendDraft sends a draft and removes it from the drafts.
+	SendDraft(ctx context.Context, id string) (*gmail.Message, error)
+	// Send sends a raw message straight away.
+	Send(ctx context.Context, raw string) (*gmail.Message, error)
+}
+
+// ErrNoGmailAPI is what Gmail-only features fail with when mail comes from
+// another provider.
+var ErrNoGmailAPI = errors.New("not available without the Gmail API")
+
+// noGmailTransport fails every Gmail API request. It stands in for the
+// Gmail API client when another provider is used, so Gmail-only features
+// report that they are unavailable.
+type noGmailTransport struct{}
+
+func (noGmailTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, ErrNoGmailAPI
+}
+
+// New returns the provider the config chooses.
+func New(cfg config.Config, svc *gmail.Service, client *http.Client) (Provider, error) {
+	switch cfg.Backend {
+	case "imap":
+		n, err := network.New(cfg.Network)
+		if err != nil {
+			return nil, err
+		}
+		return newIMAPProvider(cfg.IMAP, cfg.RequestTimeout(), n)
+	case "demo":
+		return newMemoryProvider(), nil
+	case "", "gmail":
+		return gmailProvider{svc: svc, client: client}, nil
+	}
+	return nil, fmt.Errorf("unknown backend %q in the config", cfg.Backend)
+}
+
+// NoGmailServices stand in for the Google API clients when mail comes from
+// elsewhere, without signing in to Google.
+func NoGmailServices() (*gmail.Service, *people.Service, *http.Client, error) {
+	client := &http.Client{Transport: noGmailTransport{}}
+	srv, err := gmail.NewService(context.Background(), option.WithHTTPClient(client))
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("unable to retrieve Gmail client: %v", err)
+	}
+	psrv, err := people.NewService(context.Background(), option.WithHTTPClient(client))
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("unable to retrieve People client: %v", err)
+	}
+	return srv, psrv, client, nil
+}
+
+// gmailProvider uses the Gmail API.
+type gmailProvider struct {
+	svc    *gmail.Service
+	client *http.Client
+}
+
+func (p gmailProvider) List(ctx context.Context, query string, max int64, headers ...string) ([]*gmail.Message, int, error) {
+	r, err := p.svc.Users.Messages.List("me").Q(query).MaxResults(max).Context(ctx).Do()
+	if err != nil {
+		return nil, 0, err
+	}
+	var ids []string
+	for _, msg := range r.Messages {
+		ids = append(ids, msg.Id)
+	}
+	listProgress(ctx).found(ids)
+	msgs, failed := p.getMetadata(ctx, ids, headers...)
+	return msgs, failed, nil
+}
+
+func (p gmailProvider) Get(ctx context.Context, id, format string) (*gmail.Message, error) {
+	return p.svc.Users.Messages.Get("me", id).Format(format).Context(ctx).Do()
+}
+
+func (p gmailProvider) Attachment(ctx context.Context, messageID, attachmentID string) ([]byte, error) {
+	body, err := p.svc.Users.Messages.Attachments.Get("me", messageID, attachmentID).Context(ctx).Do()
+	if err != nil {
+		return nil, err
+	}
+	return base64.URLEncoding.DecodeString(body.Data)
+}
+
+func (p gmailProvider) Modify(ctx context.Context, id string, add, remove []string) (*gmail.Message, error) {
+	return p.svc.Users.Messages.Modify("me", id, &gmail.ModifyMessageRequest{
+		AddLabelIds:    add,
+		RemoveLabelIds: remove,
+	}).Context(ctx).Do()
+}
+
+func (p gmailProvider) Trash(ctx context.Context, id string) (*gmail.Message, error) {
+	return p.svc.Users.Messages.Trash("me", id).Context(ctx).Do()
+}
+
+func (p gmailProvider) Untrash(ctx context.Context, id string) (*gmail.Message, error) {
+	return p.svc.Users.Messages.Untrash("me", id).Context(ctx).Do()
+}
+
+func (p gmailProvider) SaveDraft(ctx context.Context, id, raw string) (string, error) {
+	draft := &gmail.Draft{Message: &gmail.Message{Raw: raw}}
+	var err error
+	if data, ok := largeUpload(raw); ok {
+		draft, err = p.saveLargeDraft(ctx, id, data)
+	} else if id == "" {
+		draft, err = p.svc.Users.Drafts.Create("me", draft).Context(ctx).Do()
+	} else {
+		draft, err = p.svc.Users.Drafts.Update("me", id, draft).Context(ctx).Do()
+	}
+	if err != nil {
+		return "", fmt.Errorf("unable to save draft: %w", err)
+	}
+	return draft.Id, nil
+}
+
+func (p gmailProvider) SendDraft(ctx context.Context, id string) (*gmail.Message, error) {
+	return p.svc.Users.Drafts.Send("me", &gmail.Draft{Id: id}).Context(ctx).Do()
+}
+
+func (p gmailProvider) Send(ctx context.Context, raw string) (*gmail.Message, error) {
+	if data, ok := largeUpload(raw); ok {
+		return p.sendLarge(ctx, data)
+	}
+	return p.svc.Users.Messages.Send("me", &gmail.Message{Raw: raw}).Context(ctx).Do()
+}
@@ -0,0 +1,139 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	retryMaxAttempts = 6
+	retryBaseDelay   = time.Second
+	retryMaxDelay    = 32 * time.Second
+)
+
+// throttledRequests counts requests currently waiting to be retried after
+// Gmail rate limited them, so the status bar can say why things are slow.
+var throttledRequests atomic.Int32
+
+// retryTransport retries requests that Gmail rejects for rate limiting
+// (429, or 403 with a rateLimitExceeded reason) using jittered exponential
+// backoff, honoring Retry-After when the server sends it.
+type retryTransport struct {
+	base http.RoundTripper
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := t.base.RoundTrip(req)
+		if err != nil || attempt == retryMaxAttempts-1 || !rateLimited(resp) {
+			return resp, err
+		}
+		// A request whose body cannot be replayed is returned as is.
+		if req.Body != nil && req.GetBody == nil {
+			return resp, nil
+		}
+
+		delay := retryDelay(attempt, resp.Header.Get("Retry-After"))
+		resp.Body.Close()
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+
+		throttledRequests.Add(1)
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			throttledRequests.Add(-1)
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+		throttledRequests.Add(-1)
+	}
+}
+
+// rateLimited reports whether resp is a rate-limit rejection. The body of a
+// 403 is read to tell rate limiting from a permission error, then restored.
+func rateLimited(resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusForbidden:
+		data, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		resp.Body = io.NopCloser(bytes.NewReader(data))
+		if err != nil {
+			return false
+		}
+		return bytes.Contains(data, []byte("rateLimitExceeded")) ||
+			bytes.Contains(data, []byte("userRateLimitExceeded"))
+	}
+	return false
+}
+
+// retryDelay is the wait before retry number attempt+1: the server's
+// Retry-After if given in seconds, otherwise an exponential delay with full
+// jitter.
+func retryDelay(attempt int, retryAfter string) time.Duration {
+	if s, err := strconv.Atoi(retryAfter); err == nil && s > 0 {
+		return min(time.Duration(s)*time.Second, retryMaxDelay)
+	}
+	d := min(retryBaseDelay<<attempt, retryMaxDelay)
+	return d/2 + rand.N(d/2+1)
+}
+
+// ThrottleStatus is shown in the status bar while requests are backing off.
+func ThrottleStatus() string {
+	if throttledRequests.Load() == 0 {
+		return ""
+	}
+	return "Throttled by Gmail, retrying..."
+}
+
+// NewTransport wraps a transport for the Gmail API, giving each request
+// attempt the timeout and retrying requests that are rate limited.
+func NewTransport(base http.RoundTripper, timeout time.Duration) http.RoundTripper {
+	return &retryTransport{base: &timeoutTransport{base: base, timeout: timeout}}
+}
+
+// timeoutTransport gives each request attempt its own deadline. The
+// deadline covers reading the response body, so it is only released when
+// the body is closed.
+type timeoutTransport struct {
+	base    http.RoundTripper
+	timeout time.Duration
+}
+
+func (t *timeoutTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(req.Context(), t.timeout)
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
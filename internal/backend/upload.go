@@ -0,0 +1,70 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/googleapi"
+)
+
+const (
+	// largeMessage is the size above which the Gmail API is sent a message
+	// with a resumable upload, in chunks that are each a request with its
+	// own timeout, rather than as base64 in one request that a slow
+	// connection might not finish in time.
+	largeMessage = 4 << 20
+	// uploadChunkSize is the size of each chunk, a multiple of the 256 KiB
+	// resumable uploads require.
+	uploadChunkSize = 1 << 20
+)
+
+// largeUpload returns the message raw encodes if it is large enough to be
+// uploaded in chunks.
+func largeUpload(raw string) ([]byte, bool) {
+	if base64.URLEncoding.DecodedLen(len(raw)) <= largeMessage {
+		return nil, false
+	}
+	data, err := base64.URLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+func uploadOptions() []googleapi.MediaOption {
+	return []googleapi.MediaOption{
+		googleapi.ContentType("message/rfc822"),
+		googleapi.ChunkSize(uploadChunkSize),
+	}
+}
+
+// saveLargeDraft uploads data as draft id, or as a new draft if id is
+// empty.
+func (p gmailProvider) saveLargeDraft(ctx context.Context, id string, data []byte) (*gmail.Draft, error) {
+	progress := uploadProgress(ctx)
+	total := int64(len(data))
+	progress.sent(0, total)
+	if id == "" {
+		return p.svc.Users.Drafts.Create("me", &gmail.Draft{}).
+			Media(bytes.NewReader(data), uploadOptions()...).
+			ProgressUpdater(progress.updater(total)).
+			Context(ctx).Do()
+	}
+	return p.svc.Users.Drafts.Update("me", id, &gmail.Draft{Id: id}).
+		Media(bytes.NewReader(data), uploadOptions()...).
+		ProgressUpdater(progress.updater(total)).
+		Context(ctx).Do()
+}
+
+// sendLarge uploads data and sends it.
+func (p gmailProvider) sendLarge(ctx context.Context, data []byte) (*gmail.Message, error) {
+	progress := uploadProgress(ctx)
+	total := int64(len(data))
+	progress.sent(0, total)
+	return p.svc.Users.Messages.Send("me", &gmail.Message{}).
+		Media(bytes.NewReader(data), uploadOptions()...).
+		ProgressUpdater(progress.updater(total)).
+		Context(ctx).Do()
+}
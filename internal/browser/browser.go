@@ -0,0 +1,22 @@
+// Package browser opens links and files with the platform's default
+// handler.
+package browser
+
+import (
+	"os/exec"
+	"runtime"
+)
+
+// Open opens url with the platform's default handler.
+func Open(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	case "darwin":
+		cmd = exec.Command("open", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	return cmd.Start()
+}
@@ -0,0 +1,437 @@
+// Package config reads and writes the settings in config.json.
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"gmail-tui/internal/safefile"
+)
+
+// Config holds user settings read from config.json in the gmail-tui config
+// directory. Missing fields keep their defaults.
+type Config struct {
+	// DraftAutosaveSeconds is how often an open compose session is saved
+	// back to Gmail as a draft. Zero or less disables autosave.
+	DraftAutosaveSeconds int `json:"draft_autosave_seconds"`
+
+	// UndoSendSeconds is how long a confirmed message is held before it is
+	// actually sent, giving a chance to undo. Zero sends immediately.
+	UndoSendSeconds int `json:"undo_send_seconds"`
+
+	// ContactsRefreshMinutes is how often the address autocomplete index
+	// is rebuilt from Google Contacts and sent mail.
+	ContactsRefreshMinutes int `json:"contacts_refresh_minutes"`
+
+	// DateFormat is the Go time layout used for dates in list rows, or
+	// "relative" for "5m ago" / "Yesterday" / "Mar 3" style dates.
+	DateFormat string `json:"date_format"`
+
+	// AccountIndex is the /u/N index of this account in the browser, used
+	// when opening messages in Gmail web.
+	AccountIndex int `json:"account_index"`
+
+	// AutoAdvance opens the next message after the open one is moved out
+	// of the list (e.g. reported as spam) instead of returning to the list.
+	AutoAdvance bool `json:"auto_advance"`
+
+	// MarkReadSeconds is how long an unread message stays open before it
+	// is marked read. Zero marks it read on opening; -1 never does, leaving
+	// it to be marked by hand.
+	MarkReadSeconds int `json:"mark_read_seconds"`
+
+	// PrefetchCount is how many messages below the cursor have their
+	// bodies fetched in the background so they open instantly. Zero
+	// disables prefetching.
+	PrefetchCount int `json:"prefetch_count"`
+
+	// MaxBodyKB is how much of a message's text is decoded and shown
+	// before the rest is left to be loaded on request. Zero shows it all.
+	MaxBodyKB int `json:"max_body_kb"`
+
+	// RequestTimeoutSeconds bounds each API request, including reading
+	// its response, so a stalled connection cannot hang the app.
+	RequestTimeoutSeconds int `json:"request_timeout_seconds"`
+
+	// Network is how connections are made: through which proxy, trusting
+	// which certificate authorities, and how long connecting may take.
+	Network NetworkConfig `json:"network"`
+
+	// UseKeyring keeps the OAuth token in the OS credential store rather
+	// than in token.json.
+	UseKeyring bool `json:"use_keyring"`
+
+	// EncryptCache encrypts the cached lists, contacts and senders, and
+	// token.json, with a key kept in the OS credential store ("keyring")
+	// or derived from a passphrase ("passphrase"). Empty leaves them
+	// unencrypted.
+	EncryptCache string `json:"encrypt_cache"`
+
+	// PassphraseCommand prints the passphrase when EncryptCache is
+	// "passphrase". It is run with sh -c; empty asks on the terminal.
+	PassphraseCommand string `json:"passphrase_command"`
+
+	// OAuthRedirectPort is the loopback port the browser is sent back to
+	// after signing in. Zero picks a free port each time.
+	OAuthRedirectPort int `json:"oauth_redirect_port"`
+
+	// Hooks are commands run on new mail, sending and archiving.
+	Hooks Hooks `json:"hooks"`
+
+	// FormatBody reflows message bodies to the window and styles quotes,
+	// signatures and tables. When off, bodies are shown as sent.
+	FormatBody bool `json:"format_body"`
+
+	// ComposeMarkdown treats message bodies as Markdown, sending an HTML
+	// rendering along with the text.
+	ComposeMarkdown bool `json:"compose_markdown"`
+
+	// ComposeEditor is where messages are written: "external" for $VISUAL
+	// or $EDITOR, "builtin" for the editor inside gmail-tui, or "auto" for
+	// the built-in one when neither variable is set.
+	ComposeEditor string `json:"compose_editor"`
+
+	// Signatures are plain-text signatures keyed by send-as address, used
+	// instead of the ones in Gmail. The one under "default" is for
+	// addresses with neither, and for backends without send-as addresses.
+	Signatures map[string]string `json:"signatures"`
+
+	// SignaturePlacement is where the signature goes in a message that
+	// quotes another: "below" the quoted text, at the end, or "above" it.
+	SignaturePlacement string `json:"signature_placement"`
+
+	// InternalDomains are the domains of your organization. Recipients at
+	// other domains are listed for a second look before a message is sent.
+	// Empty turns the check off.
+	InternalDomains []string `json:"internal_domains"`
+
+	// AttachmentKeywords are words that mean a message should have an
+	// attachment, such as "attached". A message mentioning one but without
+	// an attachment is pointed out before it is sent. Empty turns the
+	// check off.
+	AttachmentKeywords []string `json:"attachment_keywords"`
+
+	// ImageProtocol is how images are drawn: "kitty", "iterm", "sixel",
+	// "none" for a text placeholder, or "auto" to detect the terminal.
+	ImageProtocol string `json:"image_protocol"`
+
+	// Mouse enables clicking and scrolling. The terminal's own text
+	// selection then needs a modifier, usually shift.
+	Mouse bool `json:"mouse"`
+
+	// PGPSign signs new messages with gpg unless turned off while
+	// composing.
+	PGPSign bool `json:"pgp_sign"`
+
+	// SMIMECAFile is a PEM file of extra certificate authorities trusted
+	// for S/MIME signatures, such as a company's internal CA.
+	SMIMECAFile string `json:"smime_ca_file"`
+
+	// DownloadDir is where attachments and invitations are saved. Empty
+	// means ~/Downloads.
+	DownloadDir string `json:"download_dir"`
+
+	// LocalIndex keeps a full-text index of opened and prefetched messages
+	// on disk, so searches also match their bodies and work offline.
+	LocalIndex bool `json:"local_index"`
+
+	// RestoreSession starts on the list, message and scroll position
+	// gmail-tui was on when it last quit.
+	RestoreSession bool `json:"restore_session"`
+
+	// Tabs are the inbox categories shown as tabs above the list, from
+	// primary, social, promotions, updates and forums. The list starts on
+	// the first one. Empty hides the tabs.
+	Tabs []string `json:"tabs"`
+
+	// ListViews holds the sort order and grouping of each list, keyed by
+	// place: "inbox", "all", a category or a label name. Changing them in
+	// the app saves them here.
+	ListViews map[string]ListView `json:"list_views"`
+
+	// RefreshSeconds is how often the list is refreshed in the background.
+	// Zero turns auto-refresh off.
+	RefreshSeconds int `json:"refresh_seconds"`
+
+	// FollowUpNotify shows a desktop notification when messages land in
+	// Follow-ups.
+	FollowUpNotify bool `json:"follow_up_notify"`
+
+	// MailRules set, per label or search, how often it is checked for new
+	// mail and whether new mail shows a desktop notification. Mail is
+	// handled by the first rule whose search finds it, so a rule that
+	// doesn't notify silences the rules after it.
+	MailRules []MailRule `json:"mail_rules"`
+
+	// ListColumns are the fields shown in each list row, in order, from
+	// star, unread, sender, subject, snippet, labels, size and date. Empty
+	// keeps the subject above the sender, date and snippet.
+	ListColumns []ListColumn `json:"list_columns"`
+
+	// ListDensity is "compact" for one line per message, or "comfortable"
+	// for two.
+	ListDensity string `json:"list_density"`
+
+	// Maildir is a Maildir kept in sync with all mail but Spam and Trash,
+	// on each auto-refresh and with the sync command, for other mail tools
+	// to read. Empty turns syncing off.
+	Maildir string `json:"maildir"`
+
+	// Notmuch has notmuch index the synced Maildir and tag each message
+	// with its labels.
+	Notmuch bool `json:"notmuch"`
+
+	// Backend is where mail comes from: "gmail" for the Gmail API, "imap"
+	// for the IMAP and SMTP servers in IMAP, or "demo" for sample messages
+	// kept in memory.
+	Backend string `json:"backend"`
+
+	// IMAP is the mail server used by the imap backend.
+	IMAP IMAPConfig `json:"imap"`
+
+	// Accessible suits terminal screen readers: changes are announced as
+	// plain lines that stay in the scrollback, and nothing is shown by
+	// color or box drawing alone. It implies --inline and
+	// --reduced-motion.
+	Accessible bool `json:"accessible"`
+
+	// UseDaemon has the app and the commands get mail through the daemon
+	// (gmail-tui daemon) when one is running.
+	UseDaemon bool `json:"use_daemon"`
+}
+
+// ListColumn is a column of the message list. Width is how many cells it
+// takes, cutting longer text short with …; zero uses the column's default.
+type ListColumn struct {
+	Name  string `json:"name"`
+	Width int    `json:"width,omitempty"`
+}
+
+// Hooks are shell commands run when something happens, with details of the
+// message involved in GMAIL_TUI_* environment variables. They run in the
+// background with their output discarded.
+type Hooks struct {
+	OnNewMail string `json:"on_new_mail"`
+	OnSend    string `json:"on_send"`
+	OnArchive string `json:"on_archive"`
+}
+
+// MailRule is how the mail found by a Gmail search, such as "label:urgent"
+// or "category:promotions", is watched. RefreshSeconds is how often the
+// search is checked for new mail, and its list reloaded while shown; zero
+// keeps refresh_seconds. Notify shows a desktop notification for new mail.
+type MailRule struct {
+	Query          string `json:"query"`
+	RefreshSeconds int    `json:"refresh_seconds"`
+	Notify         bool   `json:"notify"`
+}
+
+// IMAPConfig is an IMAP server to read mail from and an SMTP server to send
+// it through, signing in to both as Username. The password is what
+// PasswordCommand prints, run with sh -c, so it needn't be written here.
+// The folders are the server's names for Gmail's system labels; messages
+// archived from the inbox go to ArchiveFolder.
+type IMAPConfig struct {
+	Host            string `json:"host"`
+	Port            int    `json:"port"`
+	Username        string `json:"username"`
+	PasswordCommand string `json:"password_command"`
+	SMTPHost        string `json:"smtp_host"`
+	SMTPPort        int    `json:"smtp_port"`
+	ArchiveFolder   string `json:"archive_folder"`
+	SentFolder      string `json:"sent_folder"`
+	DraftsFolder    string `json:"drafts_folder"`
+	TrashFolder     string `json:"trash_folder"`
+	SpamFolder      string `json:"spam_folder"`
+	// SaveSent files a copy of sent mail in SentFolder. Servers that do
+	// it themselves, such as Gmail's, want it off.
+	SaveSent bool `json:"save_sent"`
+}
+
+// NetworkConfig sets how gmail-tui connects to Google and mail servers.
+type NetworkConfig struct {
+	// Proxy is the proxy all connections go through, as an http://,
+	// https:// or socks5:// URL. Empty uses HTTPS_PROXY, HTTP_PROXY and
+	// NO_PROXY for web requests, and ALL_PROXY for IMAP and SMTP.
+	Proxy string `json:"proxy"`
+	// CABundle is a PEM file of certificate authorities to trust besides
+	// the system's, such as a corporate proxy's.
+	CABundle string `json:"ca_bundle"`
+	// ConnectTimeoutSeconds bounds connecting, including the TLS
+	// handshake.
+	ConnectTimeoutSeconds int `json:"connect_timeout_seconds"`
+}
+
+// ConnectTimeout is how long connecting may take.
+func (c NetworkConfig) ConnectTimeout() time.Duration {
+	if c.ConnectTimeoutSeconds <= 0 {
+		return 30 * time.Second
+	}
+	return time.Duration(c.ConnectTimeoutSeconds) * time.Second
+}
+
+// ListView is how a list is ordered. Sort is "date" (newest first, the
+// default), "oldest", "sender" or "subject"; Group "day" adds day headers
+// when sorted by date, and "priority" splits the list into the Priority
+// Inbox sections.
+type ListView struct {
+	Sort  string `json:"sort,omitempty"`
+	Group string `json:"group,omitempty"`
+}
+
+// ByDate reports whether the list is sorted by date.
+func (v ListView) ByDate() bool {
+	return v.Sort == "date" || v.Sort == "oldest"
+}
+
+// defaultAttachmentKeywords mention an attachment in English, German,
+// Spanish, French, Italian, Portuguese, Dutch, the Nordic languages,
+// Polish, Russian, Japanese and Chinese. Most are stems, matching the
+// start of a word.
+var defaultAttachmentKeywords = []string{
+	"attach", "enclosed", "anbei", "angehängt", "anhang", "adjunt", "ci-joint",
+	"pièce jointe", "pièces jointes", "allegat", "anexo", "anexad", "bijlage",
+	"bifoga", "vedhæft", "vedlagt", "liite", "załącz", "приложен", "添付", "附件",
+}
+
+// Default returns the settings used when config.json leaves them out.
+func Default() Config {
+	return Config{
+		DraftAutosaveSeconds:   15,
+		UndoSendSeconds:        10,
+		ContactsRefreshMinutes: 60,
+		DateFormat:             "relative",
+		PrefetchCount:          5,
+		MaxBodyKB:              512,
+		RequestTimeoutSeconds:  60,
+		Network:                NetworkConfig{ConnectTimeoutSeconds: 30},
+		UseKeyring:             true,
+		FormatBody:             true,
+		ImageProtocol:          "auto",
+		ComposeEditor:          "auto",
+		SignaturePlacement:     "below",
+		AttachmentKeywords:     slices.Clone(defaultAttachmentKeywords),
+		RefreshSeconds:         60,
+		RestoreSession:         true,
+		UseDaemon:              true,
+		IMAP: IMAPConfig{
+			Port:          993,
+			SMTPPort:      587,
+			ArchiveFolder: "Archive",
+			SentFolder:    "Sent",
+			DraftsFolder:  "Drafts",
+			TrashFolder:   "Trash",
+			SpamFolder:    "Junk",
+			SaveSent:      true,
+		},
+	}
+}
+
+// Dir is the gmail-tui directory in the user config directory, where the
+// config, credentials and local state are kept.
+func Dir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gmail-tui"), nil
+}
+
+// Load reads config.json. Missing fields, or a missing file, keep their
+// defaults.
+func Load() (Config, error) {
+	cfg := Default()
+
+	dir, err := Dir()
+	if err != nil {
+		return cfg, nil
+	}
+
+	f, err := os.Open(filepath.Join(dir, "config.json"))
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, fmt.Errorf("unable to read config file: %v", err)
+	}
+	defer f.Close()
+
+	if err := json.NewDecoder(f).Decode(&cfg); err != nil {
+		return cfg, fmt.Errorf("unable to parse config file: %v", err)
+	}
+	return cfg, nil
+}
+
+// SaveValue sets one top-level field of the config file, keeping the
+// others. The file is rewritten with its fields in alphabetical order,
+// under a lock so that two instances saving at once both keep their field.
+func SaveValue(name string, value any) error {
+	dir, err := Dir()
+	if err != nil {
+		return fmt.Errorf("unable to save config: %v", err)
+	}
+	path := filepath.Join(dir, "config.json")
+	unlock, err := safefile.Lock(path)
+	if err != nil {
+		return fmt.Errorf("unable to save config: %v", err)
+	}
+	defer unlock()
+
+	fields := map[string]json.RawMessage{}
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("unable to read config file: %v", err)
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return fmt.Errorf("unable to parse config file: %v", err)
+		}
+	}
+	if fields[name], err = json.Marshal(value); err != nil {
+		return fmt.Errorf("unable to save config: %v", err)
+	}
+	if data, err = json.MarshalIndent(fields, "", "  "); err != nil {
+		return fmt.Errorf("unable to save config: %v", err)
+	}
+	if err := safefile.WriteFile(path, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("unable to save config: %v", err)
+	}
+	return nil
+}
+
+func (c Config) DraftAutosaveInterval() time.Duration {
+	return time.Duration(c.DraftAutosaveSeconds) * time.Second
+}
+
+func (c Config) ContactsRefreshInterval() time.Duration {
+	if c.ContactsRefreshMinutes <= 0 {
+		return time.Hour
+	}
+	return time.Duration(c.ContactsRefreshMinutes) * time.Minute
+}
+
+func (c Config) UndoSendDelay() time.Duration {
+	return time.Duration(c.UndoSendSeconds) * time.Second
+}
+
+// GmailAPI reports whether mail comes from the Gmail API rather than an
+// IMAP server or the demo mailbox.
+func (c Config) GmailAPI() bool {
+	return c.Backend == "" || c.Backend == "gmail"
+}
+
+// MaxBodyBytes is MaxBodyKB in bytes, or 0 for no limit.
+func (c Config) MaxBodyBytes() int {
+	return max(c.MaxBodyKB, 0) << 10
+}
+
+func (c Config) RequestTimeout() time.Duration {
+	if c.RequestTimeoutSeconds <= 0 {
+		return time.Minute
+	}
+	return time.Duration(c.RequestTimeoutSeconds) * time.Second
+}
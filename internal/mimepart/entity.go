@@ -0,0 +1,154 @@
+package mimepart
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"strings"
+)
+
+// ReadEntity splits a raw MIME entity into its header and body.
+func ReadEntity(raw []byte) (textproto.MIMEHeader, []byte, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to parse message: %v", err)
+	}
+	body, err := io.ReadAll(msg.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to parse message: %v", err)
+	}
+	return textproto.MIMEHeader(msg.Header), body, nil
+}
+
+// RawParts splits a multipart body into its parts byte for byte, headers
+// included, as signature checks need. The line break before a delimiter
+// belongs to the delimiter.
+func RawParts(body []byte, boundary string) [][]byte {
+	delim := []byte("--" + boundary)
+	var parts [][]byte
+	var cur []byte
+	in := false
+	for _, l := range bytes.SplitAfter(body, []byte("\n")) {
+		t := bytes.TrimRight(l, " \t\r\n")
+		if !bytes.HasPrefix(t, delim) || len(t) != len(delim) && string(t[len(delim):]) != "--" {
+			if in {
+				cur = append(cur, l...)
+			}
+			continue
+		}
+		if in {
+			cur = bytes.TrimSuffix(cur, []byte("\n"))
+			parts = append(parts, bytes.TrimSuffix(cur, []byte("\r")))
+		}
+		if len(t) != len(delim) {
+			break
+		}
+		cur, in = nil, true
+	}
+	return parts
+}
+
+// CRLF converts line endings to CRLF, the canonical form signatures are
+// made over.
+func CRLF(b []byte) []byte {
+	b = bytes.ReplaceAll(b, []byte("\r\n"), []byte("\n"))
+	return bytes.ReplaceAll(b, []byte("\n"), []byte("\r\n"))
+}
+
+// EntityText returns the readable text of a raw MIME entity, preferring
+// text/plain as Text does.
+func EntityText(raw []byte) string {
+	header, body, err := ReadEntity(raw)
+	if err != nil {
+		return string(raw)
+	}
+	for _, want := range []string{"text/plain", "text/html"} {
+		if text, ok := findText(header, body, want); ok {
+			return text
+		}
+	}
+	return ""
+}
+
+func findText(header textproto.MIMEHeader, body []byte, want string) (string, bool) {
+	mt, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mt = "text/plain"
+	}
+	if strings.HasPrefix(mt, "multipart/") {
+		r := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+		for {
+			p, err := r.NextRawPart()
+			if err != nil {
+				return "", false
+			}
+			data, err := io.ReadAll(p)
+			if err != nil {
+				return "", false
+			}
+			if text, ok := findText(p.Header, data, want); ok {
+				return text, true
+			}
+		}
+	}
+	if mt != want || strings.HasPrefix(strings.ToLower(header.Get("Content-Disposition")), "attachment") {
+		return "", false
+	}
+
+	body = decodeTransfer(body, header.Get("Content-Transfer-Encoding"))
+	text := ToUTF8(body, params["charset"])
+	if mt == "text/html" {
+		text = HTMLToText(text)
+	}
+	return text, true
+}
+
+// File is an attachment of a raw MIME entity, with its content.
+type File struct {
+	Name     string
+	MimeType string
+	Data     []byte
+}
+
+// EntityFiles returns the attachments of a raw MIME entity with their
+// content, for a message that only exists once decrypted.
+func EntityFiles(raw []byte) []File {
+	header, body, err := ReadEntity(raw)
+	if err != nil {
+		return nil
+	}
+	return partFiles(header, body)
+}
+
+func partFiles(header textproto.MIMEHeader, body []byte) []File {
+	mt, params, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	if strings.HasPrefix(mt, "multipart/") {
+		var files []File
+		r := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+		for {
+			p, err := r.NextRawPart()
+			if err != nil {
+				return files
+			}
+			data, err := io.ReadAll(p)
+			if err != nil {
+				return files
+			}
+			files = append(files, partFiles(p.Header, data)...)
+		}
+	}
+	disposition, dparams, _ := mime.ParseMediaType(header.Get("Content-Disposition"))
+	if disposition != "attachment" {
+		return nil
+	}
+	name := dparams["filename"]
+	if name == "" {
+		name = params["name"]
+	}
+	data := decodeTransfer(body, header.Get("Content-Transfer-Encoding"))
+	return []File{{Name: name, MimeType: mt, Data: data}}
+}
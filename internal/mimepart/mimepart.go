@@ -0,0 +1,390 @@
+// Package mimepart reads messages as Gmail API message parts: decoding
+// headers, charsets and transfer encodings, and parsing raw MIME messages
+// into parts.
+package mimepart
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net/http"
+	"net/mail"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/text/encoding/htmlindex"
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/googleapi"
+)
+
+// WordDecoder decodes RFC 2047 encoded words in any charset known to the
+// WHATWG encoding index, not just UTF-8 and ISO-8859-1.
+var WordDecoder = &mime.WordDecoder{CharsetReader: charsetReader}
+
+// AddressParser parses address lists, decoding encoded display names.
+var AddressParser = &mail.AddressParser{WordDecoder: WordDecoder}
+
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		return nil, err
+	}
+	return enc.NewDecoder().Reader(input), nil
+}
+
+// DecodeHeader decodes encoded words such as =?UTF-8?B?...?= in a header
+// value, returning the value unchanged if it cannot be decoded.
+func DecodeHeader(value string) string {
+	decoded, err := WordDecoder.DecodeHeader(value)
+	if err != nil {
+		return value
+	}
+	return decoded
+}
+
+// Header returns the value of the named header of a message part.
+func Header(part *gmail.MessagePart, name string) string {
+	for _, h := range part.Headers {
+		if strings.EqualFold(h.Name, name) {
+			return h.Value
+		}
+	}
+	return ""
+}
+
+// Charset returns the charset declared in a part's Content-Type.
+func Charset(part *gmail.MessagePart) string {
+	_, params, err := mime.ParseMediaType(Header(part, "Content-Type"))
+	if err != nil {
+		return ""
+	}
+	return params["charset"]
+}
+
+// ToUTF8 converts text in the given charset to UTF-8. Unknown charsets are
+// passed through as-is.
+func ToUTF8(data []byte, charset string) string {
+	switch strings.ToLower(charset) {
+	case "", "utf-8", "utf8", "us-ascii":
+		return string(data)
+	}
+
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		return string(data)
+	}
+	out, err := enc.NewDecoder().Bytes(data)
+	if err != nil {
+		return string(data)
+	}
+	return string(out)
+}
+
+// Find returns the first inline part of the given type, searching the
+// MIME tree depth first. Attachments are skipped.
+func Find(part *gmail.MessagePart, mimeType string) *gmail.MessagePart {
+	if part.MimeType == mimeType && part.Filename == "" && part.Body != nil && part.Body.Data != "" {
+		return part
+	}
+	for _, p := range part.Parts {
+		if found := Find(p, mimeType); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+var qpEscape = regexp.MustCompile(`=(\r?\n|[0-9A-F]{2})`)
+
+// Data decodes a part's body. The API normally returns base64url data
+// with the transfer encoding already removed, but some parts come back
+// in standard base64 or still quoted-printable encoded.
+func Data(part *gmail.MessagePart) ([]byte, bool) {
+	if part.Body == nil || part.Body.Data == "" {
+		return nil, false
+	}
+
+	var data []byte
+	var err error
+	for _, enc := range []*base64.Encoding{
+		base64.URLEncoding,
+		base64.RawURLEncoding,
+		base64.StdEncoding,
+		base64.RawStdEncoding,
+	} {
+		if data, err = enc.DecodeString(part.Body.Data); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, false
+	}
+
+	return unquote(part, data, false), true
+}
+
+// unquote removes quoted-printable encoding the API left on data. When
+// data is only the start of the part, an escape cut in two at its end is
+// dropped.
+func unquote(part *gmail.MessagePart, data []byte, cut bool) []byte {
+	cte := strings.ToLower(strings.TrimSpace(Header(part, "Content-Transfer-Encoding")))
+	if cte == "quoted-printable" && qpEscape.Match(data) {
+		decoded, err := io.ReadAll(quotedprintable.NewReader(bytes.NewReader(data)))
+		if err == nil || cut {
+			data = decoded
+		}
+	}
+	return data
+}
+
+// DataLimit decodes no more than the first limit bytes of a part's body,
+// as Data does, leaving the rest of it encoded. full is the size of the
+// whole body when it was cut short, and 0 when it wasn't. A limit of 0
+// decodes all of it.
+func DataLimit(part *gmail.MessagePart, limit int) (data []byte, full int64, ok bool) {
+	if part.Body == nil || part.Body.Data == "" {
+		return nil, 0, false
+	}
+	encoded := part.Body.Data
+	if limit <= 0 || base64.RawStdEncoding.DecodedLen(len(encoded)) <= limit {
+		data, ok := Data(part)
+		return data, 0, ok
+	}
+
+	enc := base64.URLEncoding
+	if strings.ContainsAny(encoded, "+/") {
+		enc = base64.StdEncoding
+	}
+	if len(encoded)%4 != 0 && !strings.HasSuffix(encoded, "=") {
+		enc = enc.WithPadding(base64.NoPadding)
+	}
+	data, err := io.ReadAll(io.LimitReader(base64.NewDecoder(enc, strings.NewReader(encoded)), int64(limit)+1))
+	if err != nil {
+		// Not in the encoding guessed; decode it all as Data does.
+		if data, ok = Data(part); !ok || len(data) <= limit {
+			return data, 0, ok
+		}
+		return data[:limit], int64(len(data)), true
+	}
+	if len(data) <= limit {
+		return unquote(part, data, false), 0, true
+	}
+	data = data[:limit]
+	full = part.Body.Size
+	if full <= int64(limit) {
+		full = int64(enc.DecodedLen(len(encoded)))
+	}
+	return unquote(part, data, true), full, true
+}
+
+// HeaderPart is a message part holding only a header, as in the Gmail
+// API's metadata format.
+func HeaderPart(head []byte) *gmail.MessagePart {
+	part := &gmail.MessagePart{Headers: ParseHeaders(head), MimeType: "text/plain"}
+	if mediaType, _, err := mime.ParseMediaType(Header(part, "Content-Type")); err == nil {
+		part.MimeType = mediaType
+	}
+	return part
+}
+
+// Parse converts a MIME entity into a Gmail API message part, with its
+// content decoded. Parts with a file name get an attachment ID in place of
+// their content unless withFiles is set.
+func Parse(raw []byte, partID string, withFiles bool) *gmail.MessagePart {
+	head, body := SplitHeader(raw)
+	part := HeaderPart(head)
+	part.PartId = partID
+	part.Body = &gmail.MessagePartBody{}
+
+	_, params, _ := mime.ParseMediaType(Header(part, "Content-Type"))
+	if strings.HasPrefix(part.MimeType, "multipart/") && params["boundary"] != "" {
+		for i, sub := range splitMultipart(body, params["boundary"]) {
+			id := strconv.Itoa(i)
+			if partID != "" {
+				id = partID + "." + id
+			}
+			part.Parts = append(part.Parts, Parse(sub, id, withFiles))
+		}
+		return part
+	}
+
+	if _, disp, err := mime.ParseMediaType(Header(part, "Content-Disposition")); err == nil && disp["filename"] != "" {
+		part.Filename = DecodeHeader(disp["filename"])
+	} else if params["name"] != "" {
+		part.Filename = DecodeHeader(params["name"])
+	}
+	data := decodeTransfer(body, Header(part, "Content-Transfer-Encoding"))
+	part.Body.Size = int64(len(data))
+	if part.Filename != "" && !withFiles {
+		part.Body.AttachmentId = "p" + partID
+	} else {
+		part.Body.Data = base64.URLEncoding.EncodeToString(data)
+	}
+	return part
+}
+
+// AttachmentData returns the content of an attachment of a raw message,
+// parsing it again, this time keeping the files.
+func AttachmentData(raw []byte, attachmentID string) ([]byte, error) {
+	var find func(part *gmail.MessagePart) *gmail.MessagePart
+	find = func(part *gmail.MessagePart) *gmail.MessagePart {
+		if "p"+part.PartId == attachmentID {
+			return part
+		}
+		for _, sub := range part.Parts {
+			if found := find(sub); found != nil {
+				return found
+			}
+		}
+		return nil
+	}
+	part := find(Parse(raw, "", true))
+	if part == nil || part.Body == nil {
+		return nil, &googleapi.Error{Code: http.StatusNotFound, Message: "no attachment " + attachmentID}
+	}
+	return base64.URLEncoding.DecodeString(part.Body.Data)
+}
+
+// decodeTransfer undoes a part's Content-Transfer-Encoding, leaving the
+// content as it is if it can't be decoded.
+func decodeTransfer(body []byte, encoding string) []byte {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		clean := bytes.Map(func(r rune) rune {
+			if r == ' ' || r == '\t' || r == '\r' || r == '\n' {
+				return -1
+			}
+			return r
+		}, body)
+		if data, err := base64.RawStdEncoding.DecodeString(strings.TrimRight(string(clean), "=")); err == nil {
+			return data
+		}
+	case "quoted-printable":
+		if data, err := io.ReadAll(quotedprintable.NewReader(bytes.NewReader(body))); err == nil {
+			return data
+		}
+	}
+	return body
+}
+
+// SplitHeader splits a message at the blank line ending its header.
+func SplitHeader(raw []byte) (head, body []byte) {
+	for i := 0; i < len(raw); {
+		next := len(raw)
+		if j := bytes.IndexByte(raw[i:], '\n'); j >= 0 {
+			next = i + j + 1
+		}
+		if len(bytes.TrimRight(raw[i:next], "\r\n")) == 0 {
+			return raw[:i], raw[next:]
+		}
+		i = next
+	}
+	return raw, nil
+}
+
+// ParseHeaders reads the fields of a header in order, unfolding long ones.
+// Values are left encoded, as the Gmail API leaves them.
+func ParseHeaders(head []byte) []*gmail.MessagePartHeader {
+	var headers []*gmail.MessagePartHeader
+	for _, line := range strings.SplitAfter(string(head), "\n") {
+		text := strings.TrimRight(line, "\r\n")
+		if text == "" {
+			continue
+		}
+		if (text[0] == ' ' || text[0] == '\t') && len(headers) > 0 {
+			headers[len(headers)-1].Value += " " + strings.TrimSpace(text)
+			continue
+		}
+		if name, value, ok := strings.Cut(text, ":"); ok {
+			headers = append(headers, &gmail.MessagePartHeader{Name: strings.TrimSpace(name), Value: strings.TrimSpace(value)})
+		}
+	}
+	return headers
+}
+
+// splitMultipart returns the parts of a multipart body, each with its own
+// header.
+func splitMultipart(body []byte, boundary string) [][]byte {
+	delim := []byte("--" + boundary)
+	var parts [][]byte
+	start := -1
+	for i := 0; i < len(body); {
+		next := len(body)
+		if j := bytes.IndexByte(body[i:], '\n'); j >= 0 {
+			next = i + j + 1
+		}
+		line := bytes.TrimRight(body[i:next], " \t\r\n")
+		if rest, ok := bytes.CutPrefix(line, delim); ok && (len(rest) == 0 || string(rest) == "--") {
+			// The line break before a delimiter belongs to it.
+			if start >= 0 && start <= i {
+				part := bytes.TrimSuffix(body[start:i], []byte("\n"))
+				parts = append(parts, bytes.TrimSuffix(part, []byte("\r")))
+			}
+			if len(rest) > 0 {
+				return parts
+			}
+			start = next
+		}
+		i = next
+	}
+	if start >= 0 && start < len(body) {
+		parts = append(parts, body[start:])
+	}
+	return parts
+}
+
+// WithSendHeaders adds the Date and Message-ID a message needs before it
+// is sent, if it doesn't have them.
+func WithSendHeaders(data []byte) []byte {
+	head, _ := SplitHeader(data)
+	part := &gmail.MessagePart{Headers: ParseHeaders(head)}
+	var extra string
+	if Header(part, "Date") == "" {
+		extra += "Date: " + time.Now().Format(time.RFC1123Z) + "\r\n"
+	}
+	if Header(part, "Message-ID") == "" {
+		domain := "localhost"
+		if from, err := AddressParser.Parse(Header(part, "From")); err == nil {
+			if _, d, ok := strings.Cut(from.Address, "@"); ok {
+				domain = d
+			}
+		}
+		id := make([]byte, 16)
+		rand.Read(id)
+		extra += "Message-ID: <" + hex.EncodeToString(id) + "@" + domain + ">\r\n"
+	}
+	return append([]byte(extra), data...)
+}
+
+// WithoutHeader removes a header field from a message.
+func WithoutHeader(data []byte, name string) []byte {
+	head, body := SplitHeader(data)
+	var out []byte
+	skip := false
+	for _, line := range bytes.SplitAfter(head, []byte("\n")) {
+		if len(line) > 0 && line[0] != ' ' && line[0] != '\t' {
+			field, _, _ := bytes.Cut(line, []byte(":"))
+			skip = strings.EqualFold(strings.TrimSpace(string(field)), name)
+		}
+		if !skip {
+			out = append(out, line...)
+		}
+	}
+	out = append(out, "\r\n"...)
+	return append(out, body...)
+}
+
+// DecodeRaw decodes a message in the Gmail API's raw format.
+func DecodeRaw(raw string) ([]byte, error) {
+	data, err := base64.URLEncoding.DecodeString(raw)
+	if err != nil {
+		data, err = base64.RawURLEncoding.DecodeString(raw)
+	}
+	return data, err
+}
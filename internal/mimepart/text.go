@@ -0,0 +1,130 @@
+package mimepart
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"google.golang.org/api/gmail/v1"
+)
+
+// Text returns the readable text of a message, preferring a text/plain
+// part anywhere in the MIME tree and falling back to a text/html part
+// converted to plain text.
+func Text(payload *gmail.MessagePart) string {
+	body, _ := TextLimit(payload, 0)
+	return body
+}
+
+// TextLimit is Text decoding no more than limit bytes of the
+// part the text is in, so that megabytes of inlined logs are neither held
+// nor laid out. cut is the size of the whole part when it was cut short.
+func TextLimit(payload *gmail.MessagePart, limit int) (body string, cut int64) {
+	text := func(part *gmail.MessagePart) (string, bool) {
+		var data []byte
+		var ok bool
+		if data, cut, ok = DataLimit(part, limit); !ok {
+			return "", false
+		}
+		s := ToUTF8(data, Charset(part))
+		if cut > 0 {
+			// The last character may have been cut in two.
+			s = strings.ToValidUTF8(s, "")
+		}
+		return s, true
+	}
+
+	if part := Find(payload, "text/plain"); part != nil {
+		if s, ok := text(part); ok {
+			return s, cut
+		}
+	}
+
+	if part := Find(payload, "text/html"); part != nil {
+		if s, ok := text(part); ok {
+			return HTMLToText(s), cut
+		}
+	}
+
+	if s, ok := text(payload); ok {
+		return s, cut
+	}
+	return "", 0
+}
+
+// HTMLToText renders HTML as plain text: scripts and styles are dropped,
+// block elements become line breaks, and links keep their target.
+func HTMLToText(s string) string {
+	var b strings.Builder
+	z := html.NewTokenizer(strings.NewReader(s))
+	skip := 0
+	var href string
+
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return tidyText(b.String())
+		case html.TextToken:
+			if skip == 0 {
+				b.WriteString(collapseSpace(string(z.Text())))
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			switch string(name) {
+			case "script", "style", "head", "title":
+				skip++
+			case "br":
+				b.WriteString("\n")
+			case "p", "div", "tr", "table", "h1", "h2", "h3", "h4", "h5", "h6", "blockquote":
+				b.WriteString("\n\n")
+			case "li":
+				b.WriteString("\n• ")
+			case "td", "th":
+				b.WriteString(" ")
+			case "a":
+				href = ""
+				for hasAttr {
+					var k, v []byte
+					k, v, hasAttr = z.TagAttr()
+					if string(k) == "href" {
+						href = string(v)
+					}
+				}
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			switch string(name) {
+			case "script", "style", "head", "title":
+				if skip > 0 {
+					skip--
+				}
+			case "p", "div", "table", "h1", "h2", "h3", "h4", "h5", "h6", "blockquote":
+				b.WriteString("\n\n")
+			case "a":
+				if strings.HasPrefix(href, "http") {
+					b.WriteString(" <" + href + ">")
+				}
+				href = ""
+			}
+		}
+	}
+}
+
+var spaceRun = regexp.MustCompile(`[ \t\r\n\f]+`)
+
+func collapseSpace(s string) string {
+	return spaceRun.ReplaceAllString(s, " ")
+}
+
+var blankLines = regexp.MustCompile(`\n[ \t]*(\n[ \t]*)+`)
+
+// tidyText trims trailing spaces and collapses runs of blank lines.
+func tidyText(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimSpace(l)
+	}
+	s = strings.Join(lines, "\n")
+	s = blankLines.ReplaceAllString(s, "\n\n")
+	return strings.TrimSpace(s)
+}
@@ -0,0 +1,124 @@
+// Package network makes the connections gmail-tui opens to Google and to
+// mail servers, through the proxy and trusting the certificate authorities
+// set in the config.
+package network
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"golang.org/x/net/proxy"
+
+	"gmail-tui/internal/config"
+)
+
+// Network opens connections as the config's network settings say.
+type Network struct {
+	proxy   *url.URL
+	roots   *x509.CertPool
+	timeout time.Duration
+}
+
+// New checks cfg and reads its CA bundle.
+func New(cfg config.NetworkConfig) (*Network, error) {
+	n := &Network{timeout: cfg.ConnectTimeout()}
+	if cfg.Proxy != "" {
+		u, err := url.Parse(cfg.Proxy)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid network.proxy %q: use a URL such as http://proxy:3128 or socks5://127.0.0.1:1080", cfg.Proxy)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return nil, fmt.Errorf("unsupported network.proxy scheme %q: use http, https or socks5", u.Scheme)
+		}
+		n.proxy = u
+	}
+	if cfg.CABundle != "" {
+		pem, err := os.ReadFile(cfg.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read network.ca_bundle: %v", err)
+		}
+		roots, err := x509.SystemCertPool()
+		if err != nil {
+			roots = x509.NewCertPool()
+		}
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.CABundle)
+		}
+		n.roots = roots
+	}
+	return n, nil
+}
+
+// Transport returns an HTTP transport for Google's APIs and sign-in.
+func (n *Network) Transport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = http.ProxyFromEnvironment
+	if n.proxy != nil {
+		t.Proxy = http.ProxyURL(n.proxy)
+	}
+	t.DialContext = (&net.Dialer{Timeout: n.timeout, KeepAlive: 30 * time.Second}).DialContext
+	t.TLSHandshakeTimeout = n.timeout
+	t.TLSClientConfig = &tls.Config{RootCAs: n.roots}
+	return t
+}
+
+// Client returns an HTTP client on Transport whose requests give up after
+// timeout.
+func (n *Network) Client(timeout time.Duration) *http.Client {
+	return &http.Client{Transport: n.Transport(), Timeout: timeout}
+}
+
+// TLSConfig is the TLS config for a connection to serverName.
+func (n *Network) TLSConfig(serverName string) *tls.Config {
+	return &tls.Config{ServerName: serverName, RootCAs: n.roots}
+}
+
+// Dial opens a TCP connection to addr, for IMAP and SMTP. It goes through
+// the proxy if that is a SOCKS5 one, or else the one in ALL_PROXY; HTTP
+// proxies only carry web requests, so mail servers are reached directly.
+func (n *Network) Dial(ctx context.Context, addr string) (net.Conn, error) {
+	direct := &net.Dialer{Timeout: n.timeout}
+	var d proxy.Dialer = proxy.FromEnvironmentUsing(direct)
+	if n.proxy != nil {
+		d = direct
+		if n.proxy.Scheme == "socks5" {
+			var err error
+			if d, err = proxy.FromURL(n.proxy, direct); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, n.timeout)
+	defer cancel()
+	if cd, ok := d.(proxy.ContextDialer); ok {
+		return cd.DialContext(ctx, "tcp", addr)
+	}
+	return d.Dial("tcp", addr)
+}
+
+// DialTLS opens a TLS connection to addr as Dial does, checking that its
+// certificate is serverName's.
+func (n *Network) DialTLS(ctx context.Context, addr, serverName string) (net.Conn, error) {
+	conn, err := n.Dial(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, n.timeout)
+	defer cancel()
+	tc := tls.Client(conn, n.TLSConfig(serverName))
+	if err := tc.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tc, nil
+}
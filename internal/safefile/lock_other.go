@@ -0,0 +1,11 @@
+//go:build !unix && !windows
+
+package safefile
+
+import "os"
+
+// Elsewhere files aren't locked; writes are still atomic.
+
+func lockFile(*os.File, bool) error { return nil }
+
+func unlockFile(*os.File) error { return nil }
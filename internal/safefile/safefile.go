@@ -0,0 +1,93 @@
+// Package safefile writes the files gmail-tui keeps its state in so that
+// instances running at once, such as the app and a command, can't leave
+// one half written or undo each other's changes.
+package safefile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// errLocked is what lockFile fails with, when not waiting, if another
+// process holds the lock.
+var errLocked = errors.New("locked by another process")
+
+// WriteFile writes data to path through a temporary file in the same
+// directory, renamed over path once complete, so readers see either the
+// old contents or the new and never part of them.
+func WriteFile(path string, data []byte, perm os.FileMode) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp, perm)
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+// Lock takes the lock on path for a read, change and write of it, waiting
+// while another process holds it, and returns the function that releases
+// it. The lock is held on path+".lock", so WriteFile can replace path
+// meanwhile.
+func Lock(path string) (unlock func(), err error) {
+	f, err := openLock(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(f, true); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("unable to lock %s: %v", path, err)
+	}
+	return func() {
+		unlockFile(f)
+		f.Close()
+	}, nil
+}
+
+// TryLock is Lock without the wait: ok is false if another process holds
+// the lock.
+func TryLock(path string) (unlock func(), ok bool, err error) {
+	f, err := openLock(path)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := lockFile(f, false); err != nil {
+		f.Close()
+		if errors.Is(err, errLocked) {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("unable to lock %s: %v", path, err)
+	}
+	return func() {
+		unlockFile(f)
+		f.Close()
+	}, true, nil
+}
+
+func openLock(path string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("unable to lock %s: %v", path, err)
+	}
+	f, err := os.OpenFile(path+".lock", os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("unable to lock %s: %v", path, err)
+	}
+	return f, nil
+}
@@ -0,0 +1,87 @@
+package compose
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"gmail-tui/internal/backend"
+	"gmail-tui/internal/ui/view"
+)
+
+// Draft is an unsent message, either stored in Gmail or being composed.
+// ID is the Gmail draft ID, not the ID of the underlying message.
+type Draft struct {
+	ID      string
+	From    string
+	To      string
+	Cc      string
+	Bcc     string
+	Subject string
+	Body    string
+	Date    time.Time
+
+	Attachments []Attachment
+
+	// Markdown sends the body with an HTML rendering alongside it.
+	Markdown bool
+
+	// Sign and Encrypt wrap the message in PGP/MIME when it is sent. The
+	// draft saved in Gmail is encrypted if Encrypt is set but never signed,
+	// so autosaving never asks for a passphrase.
+	Sign    bool
+	Encrypt bool
+
+	// FollowUp, if set, is when to be reminded if the sent message has had
+	// no reply.
+	FollowUp time.Time
+}
+
+func (d Draft) Title() string {
+	if d.Subject == "" {
+		return "(no subject)"
+	}
+	return d.Subject
+}
+func (d Draft) Description() string {
+	return fmt.Sprintf("To: %s | %s", d.To, view.ListDate(d.Date))
+}
+func (d Draft) FilterValue() string { return d.Subject }
+
+// Attachment is a file attached to a draft. Local attachments are read from
+// Path; attachments of drafts loaded from Gmail are fetched by MessageID and
+// AttachmentID. Data caches the content once loaded.
+type Attachment struct {
+	Name         string
+	MimeType     string
+	Size         int64
+	Path         string
+	MessageID    string
+	AttachmentID string
+	Data         []byte
+}
+
+// Load returns the attachment content, reading or downloading it on first
+// use.
+func (a *Attachment) Load(ctx context.Context, mp backend.Provider) ([]byte, error) {
+	if a.Data != nil {
+		return a.Data, nil
+	}
+
+	if a.Path != "" {
+		data, err := os.ReadFile(a.Path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read attachment %s: %v", a.Name, err)
+		}
+		a.Data = data
+		return data, nil
+	}
+
+	data, err := mp.Attachment(ctx, a.MessageID, a.AttachmentID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to download attachment %s: %v", a.Name, err)
+	}
+	a.Data = data
+	return data, nil
+}
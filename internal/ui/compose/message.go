@@ -0,0 +1,282 @@
+// Package compose builds the messages gmail-tui sends: drafts, the text
+// they are edited as, and the sessions that save them to Gmail while they
+// are written.
+package compose
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"path/filepath"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer/html"
+
+	"gmail-tui/internal/backend"
+)
+
+// Text renders a draft in the header block + body layout that is
+// handed to the editor.
+func Text(d Draft) string {
+	return fmt.Sprintf("From: %s\nTo: %s\nCc: %s\nBcc: %s\nSubject: %s\n\n%s", d.From, d.To, d.Cc, d.Bcc, d.Subject, d.Body)
+}
+
+// ParseText is the inverse of Text. Unknown header lines are
+// ignored and everything after the first blank line is the body.
+func ParseText(text string) Draft {
+	var d Draft
+	sc := bufio.NewScanner(strings.NewReader(text))
+	var body []string
+	inBody := false
+	for sc.Scan() {
+		line := sc.Text()
+		if inBody {
+			body = append(body, line)
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			inBody = true
+			continue
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "from":
+			d.From = value
+		case "to":
+			d.To = value
+		case "cc":
+			d.Cc = value
+		case "bcc":
+			d.Bcc = value
+		case "subject":
+			d.Subject = value
+		}
+	}
+	d.Body = strings.Join(body, "\n")
+	return d
+}
+
+// Protect wraps the content of a draft marked for signing or encryption.
+type Protect func(entity []byte, d Draft) ([]byte, error)
+
+// errNoProtect is returned for a draft marked for signing or encryption
+// when no Protect is given.
+var errNoProtect = errors.New("unable to sign or encrypt message: PGP is not available")
+
+// Raw builds the base64url-encoded RFC 822 message expected by the Gmail
+// API's Raw field. Messages with attachments are sent as multipart/mixed
+// with base64-encoded attachment parts. Markdown bodies are sent as
+// multipart/alternative with an HTML rendering. Drafts marked for signing
+// or encryption are wrapped by protect.
+func Raw(ctx context.Context, mp backend.Provider, d Draft, protect Protect) (string, error) {
+	var b bytes.Buffer
+	for _, h := range []struct{ name, value string }{
+		{"From", d.From},
+		{"To", d.To},
+		{"Cc", d.Cc},
+		{"Bcc", d.Bcc},
+	} {
+		if h.value != "" {
+			fmt.Fprintf(&b, "%s: %s\r\n", h.name, h.value)
+		}
+	}
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", d.Subject))
+	b.WriteString("MIME-Version: 1.0\r\n")
+
+	content, err := entity(ctx, mp, d)
+	if err != nil {
+		return "", err
+	}
+	if d.Sign || d.Encrypt {
+		if protect == nil {
+			return "", errNoProtect
+		}
+		if content, err = protect(content, d); err != nil {
+			return "", err
+		}
+	}
+	b.Write(content)
+	return base64.URLEncoding.EncodeToString(b.Bytes()), nil
+}
+
+// entity builds the content of a message, starting with its
+// Content-Type header.
+func entity(ctx context.Context, mp backend.Provider, d Draft) ([]byte, error) {
+	var b bytes.Buffer
+	body := strings.ReplaceAll(d.Body, "\n", "\r\n")
+	// A signature breaks if a relay re-encodes 8bit text, so signed text
+	// is sent quoted-printable.
+	cte := "8bit"
+	if d.Sign {
+		cte = "quoted-printable"
+	}
+
+	var altType string
+	var alt []byte
+	if d.Markdown {
+		var err error
+		if altType, alt, err = alternativeBody(body, d.Body, cte); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(d.Attachments) == 0 && d.Markdown {
+		fmt.Fprintf(&b, "Content-Type: %s\r\n\r\n", altType)
+		b.Write(alt)
+		return b.Bytes(), nil
+	}
+	if len(d.Attachments) == 0 {
+		b.WriteString("Content-Type: text/plain; charset=\"UTF-8\"\r\n")
+		fmt.Fprintf(&b, "Content-Transfer-Encoding: %s\r\n", cte)
+		b.WriteString("\r\n")
+		if err := writeText(&b, body, cte); err != nil {
+			return nil, err
+		}
+		return b.Bytes(), nil
+	}
+
+	w := multipart.NewWriter(&b)
+	fmt.Fprintf(&b, "Content-Type: multipart/mixed; boundary=%q\r\n\r\n", w.Boundary())
+
+	if d.Markdown {
+		text, err := w.CreatePart(textproto.MIMEHeader{"Content-Type": {altType}})
+		if err != nil {
+			return nil, err
+		}
+		text.Write(alt)
+	} else {
+		text, err := w.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {`text/plain; charset="UTF-8"`},
+			"Content-Transfer-Encoding": {cte},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeText(text, body, cte); err != nil {
+			return nil, err
+		}
+	}
+
+	for i := range d.Attachments {
+		a := &d.Attachments[i]
+		data, err := a.Load(ctx, mp)
+		if err != nil {
+			return nil, err
+		}
+
+		ctype := a.MimeType
+		if ctype == "" {
+			ctype = mime.TypeByExtension(filepath.Ext(a.Name))
+		}
+		if mt, _, err := mime.ParseMediaType(ctype); err == nil {
+			ctype = mt
+		} else {
+			ctype = "application/octet-stream"
+		}
+
+		h := textproto.MIMEHeader{}
+		h.Set("Content-Type", mime.FormatMediaType(ctype, map[string]string{"name": a.Name}))
+		h.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": a.Name}))
+		h.Set("Content-Transfer-Encoding", "base64")
+		part, err := w.CreatePart(h)
+		if err != nil {
+			return nil, err
+		}
+
+		enc := base64.StdEncoding.EncodeToString(data)
+		for len(enc) > 76 {
+			fmt.Fprintf(part, "%s\r\n", enc[:76])
+			enc = enc[76:]
+		}
+		fmt.Fprintf(part, "%s\r\n", enc)
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+// writeText writes text with the given transfer encoding, 8bit or
+// quoted-printable.
+func writeText(w io.Writer, text, cte string) error {
+	if cte != "quoted-printable" {
+		_, err := io.WriteString(w, text)
+		return err
+	}
+	qp := quotedprintable.NewWriter(w)
+	if _, err := io.WriteString(qp, text); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// MarkdownHTML renders a Markdown message body as an HTML document. Single
+// line breaks are kept, as they would be in a plain text email.
+func MarkdownHTML(src string) (string, error) {
+	md := goldmark.New(
+		goldmark.WithExtensions(extension.GFM),
+		goldmark.WithRendererOptions(html.WithHardWraps()),
+	)
+	var b bytes.Buffer
+	b.WriteString("<html><body>\n")
+	if err := md.Convert([]byte(src), &b); err != nil {
+		return "", fmt.Errorf("unable to render markdown: %v", err)
+	}
+	b.WriteString("</body></html>\n")
+	return b.String(), nil
+}
+
+// alternativeBody builds a multipart/alternative body holding text, sent
+// with transfer encoding cte, and its HTML rendering, returning its
+// Content-Type and content.
+func alternativeBody(text, markdown, cte string) (string, []byte, error) {
+	htmlBody, err := MarkdownHTML(markdown)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var b bytes.Buffer
+	w := multipart.NewWriter(&b)
+	plain, err := w.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {`text/plain; charset="UTF-8"`},
+		"Content-Transfer-Encoding": {cte},
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	if err := writeText(plain, text, cte); err != nil {
+		return "", nil, err
+	}
+
+	part, err := w.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {`text/html; charset="UTF-8"`},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	qp := quotedprintable.NewWriter(part)
+	qp.Write([]byte(htmlBody))
+	if err := qp.Close(); err != nil {
+		return "", nil, err
+	}
+	if err := w.Close(); err != nil {
+		return "", nil, err
+	}
+	return fmt.Sprintf("multipart/alternative; boundary=%q", w.Boundary()), b.Bytes(), nil
+}
@@ -0,0 +1,285 @@
+package compose
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"gmail-tui/internal/backend"
+)
+
+// Session is a message being written in the user's editor. The text
+// lives in a temp file while editing and is saved to Gmail as a draft, both
+// periodically while the editor is open and when it exits.
+type Session struct {
+	path string
+
+	mu    sync.Mutex
+	draft Draft
+	saved string
+
+	// unsaved is set when the last save could not store a change, so the
+	// draft as shown is not yet in Gmail.
+	unsaved bool
+
+	stop chan struct{}
+	done chan struct{}
+
+	// file is held while the compose file is read or written, so the
+	// built-in editor can write it while a save holding mu uploads.
+	file   sync.Mutex
+	upload upload
+
+	// protect encrypts the draft as it is saved, once it is marked Encrypt.
+	protect Protect
+}
+
+// NewSession starts composing d. Saves of a draft marked Encrypt are
+// encrypted with protect, so Gmail never holds its text.
+func NewSession(d Draft, protect Protect) (*Session, error) {
+	f, err := os.CreateTemp("", "gmail-tui-*.eml")
+	if err != nil {
+		return nil, fmt.Errorf("unable to create compose file: %v", err)
+	}
+	defer f.Close()
+
+	text := Text(d)
+	if _, err := f.WriteString(text); err != nil {
+		return nil, fmt.Errorf("unable to write compose file: %v", err)
+	}
+
+	return &Session{path: f.Name(), draft: d, saved: text, protect: protect}, nil
+}
+
+// Snapshot returns the draft as of the last save, including changes a
+// save could not store.
+func (c *Session) Snapshot() Draft {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.draft
+}
+
+// Save reads the compose file and stores it as a Gmail draft, creating the
+// draft on first save. It is a no-op when the file has not changed, so an
+// untouched new message never creates an empty draft.
+func (c *Session) Save(ctx context.Context, mp backend.Provider) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.file.Lock()
+	data, err := os.ReadFile(c.path)
+	c.file.Unlock()
+	if err != nil {
+		return fmt.Errorf("unable to read compose file: %v", err)
+	}
+	text := string(data)
+	if text == c.saved {
+		return nil
+	}
+
+	d := ParseText(text)
+	d.ID = c.draft.ID
+	d.Attachments = c.draft.Attachments
+	d.Markdown = c.draft.Markdown
+	d.Sign, d.Encrypt = c.draft.Sign, c.draft.Encrypt
+	d.FollowUp = c.draft.FollowUp
+	d.Date = time.Now()
+
+	c.draft = d
+	c.unsaved = true
+
+	ctx, done := c.upload.start(ctx)
+	defer done()
+
+	// Signing can need a passphrase, so it waits until the draft is sent.
+	stored := d
+	stored.Sign = false
+	raw, err := Raw(ctx, mp, stored, c.protect)
+	if err != nil {
+		return err
+	}
+	id, err := mp.SaveDraft(ctx, d.ID, raw)
+	if err != nil {
+		if context.Cause(ctx) == ErrUploadCancelled {
+			return ErrUploadCancelled
+		}
+		return err
+	}
+	c.draft.ID = id
+	c.saved = text
+	c.unsaved = false
+	return nil
+}
+
+// Unsaved reports whether the draft has changes that a save could not
+// store in Gmail.
+func (c *Session) Unsaved() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.unsaved
+}
+
+// StartAutosave saves the draft every interval until StopAutosave is
+// called. Failures are retried on the next tick; the save made when the
+// editor exits reports errors to the user.
+func (c *Session) StartAutosave(ctx context.Context, mp backend.Provider, interval time.Duration) {
+	c.stop = make(chan struct{})
+	c.done = make(chan struct{})
+
+	if interval <= 0 {
+		close(c.done)
+		return
+	}
+
+	go func() {
+		defer close(c.done)
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-c.stop:
+				return
+			case <-t.C:
+				c.Save(ctx, mp)
+			}
+		}
+	}()
+}
+
+// StopAutosave is called again when a failed final save is retried, so
+// it only stops autosaving once.
+func (c *Session) StopAutosave() {
+	select {
+	case <-c.stop:
+	default:
+		close(c.stop)
+	}
+	<-c.done
+}
+
+// Path is the compose file the editor writes.
+func (c *Session) Path() string {
+	return c.path
+}
+
+// Progress returns how much of the draft being saved has been uploaded. ok
+// is false when no upload is under way.
+func (c *Session) Progress() (sent, total int64, ok bool) {
+	return c.upload.progress()
+}
+
+// StopUpload cancels the upload under way, reporting whether there was
+// one. The save fails with ErrUploadCancelled.
+func (c *Session) StopUpload() bool {
+	return c.upload.stop()
+}
+
+func (c *Session) Remove() {
+	os.Remove(c.path)
+}
+
+func EditorCommand(path string) *exec.Cmd {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		if runtime.GOOS == "windows" {
+			editor = "notepad"
+		} else {
+			editor = "vi"
+		}
+	}
+
+	args := strings.Fields(editor)
+	return exec.Command(args[0], append(args[1:], path)...)
+}
+
+// Write replaces the compose file with text, for the next save to pick up.
+// It doesn't wait for a save that is uploading.
+func (c *Session) Write(text string) error {
+	c.file.Lock()
+	defer c.file.Unlock()
+
+	if err := os.WriteFile(c.path, []byte(text), 0600); err != nil {
+		return fmt.Errorf("unable to write compose file: %v", err)
+	}
+	return nil
+}
+
+// AddAttachment adds a to the session and marks the draft for saving.
+func (c *Session) AddAttachment(a Attachment) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.draft.Attachments = append(c.draft.Attachments, a)
+	c.saved = ""
+}
+
+func (c *Session) RemoveLastAttachment() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.draft.Attachments) == 0 {
+		return false
+	}
+	c.draft.Attachments = c.draft.Attachments[:len(c.draft.Attachments)-1]
+	c.saved = ""
+	return true
+}
+
+func (c *Session) SetFollowUp(by time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.draft.FollowUp = by
+}
+
+// SetPGP sets how the draft is protected when it is sent. Changing whether
+// it is encrypted marks the draft for saving, so the copy in Gmail follows.
+func (c *Session) SetPGP(sign, encrypt bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.draft.Encrypt != encrypt {
+		c.saved = ""
+	}
+	c.draft.Sign, c.draft.Encrypt = sign, encrypt
+}
+
+// SetField replaces one of the address fields and rewrites the compose
+// file to match, marking the draft for saving.
+func (c *Session) SetField(field, value string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch field {
+	case "To":
+		c.draft.To = value
+	case "Cc":
+		c.draft.Cc = value
+	case "Bcc":
+		c.draft.Bcc = value
+	}
+
+	if err := os.WriteFile(c.path, []byte(Text(c.draft)), 0600); err != nil {
+		return fmt.Errorf("unable to write compose file: %v", err)
+	}
+	c.saved = ""
+	return nil
+}
+
+// SetDraft replaces the draft being composed and rewrites the compose file
+// to match, marking the draft for saving.
+func (c *Session) SetDraft(d Draft) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.draft = d
+	if err := os.WriteFile(c.path, []byte(Text(d)), 0600); err != nil {
+		return fmt.Errorf("unable to write compose file: %v", err)
+	}
+	c.saved = ""
+	return nil
+}
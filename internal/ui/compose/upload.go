@@ -0,0 +1,63 @@
+package compose
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"gmail-tui/internal/backend"
+)
+
+// ErrUploadCancelled is what a save fails with when its upload is
+// cancelled.
+var ErrUploadCancelled = errors.New("upload cancelled")
+
+// upload is the progress of a save uploading a large draft. total is zero
+// unless one is under way.
+type upload struct {
+	mu     sync.Mutex
+	sent   int64
+	total  int64
+	cancel context.CancelFunc
+}
+
+// start follows a save run with the returned context, until done is
+// called.
+func (u *upload) start(ctx context.Context) (_ context.Context, done func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	u.mu.Lock()
+	u.sent, u.total = 0, 0
+	u.cancel = func() { cancel(ErrUploadCancelled) }
+	u.mu.Unlock()
+
+	ctx = backend.WithUploadProgress(ctx, func(sent, total int64) {
+		u.mu.Lock()
+		defer u.mu.Unlock()
+		u.sent, u.total = sent, total
+	})
+	return ctx, func() {
+		cancel(nil)
+		u.mu.Lock()
+		defer u.mu.Unlock()
+		u.sent, u.total, u.cancel = 0, 0, nil
+	}
+}
+
+// progress returns how much of the upload has been sent. ok is false when
+// no upload is under way.
+func (u *upload) progress() (sent, total int64, ok bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.sent, u.total, u.total > 0
+}
+
+// stop cancels the upload under way, reporting whether there was one.
+func (u *upload) stop() bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.total == 0 || u.cancel == nil {
+		return false
+	}
+	u.cancel()
+	return true
+}
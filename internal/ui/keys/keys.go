@@ -0,0 +1,291 @@
+// Package keys holds the key bindings of the interactive client and how
+// the help screen groups them.
+package keys
+
+import (
+	"reflect"
+
+	"github.com/charmbracelet/bubbles/key"
+)
+
+// Map is every key binding, by what it does. The same key can be bound in
+// more than one place, as each view only matches the ones it uses.
+type Map struct {
+	Up       key.Binding
+	Down     key.Binding
+	Select   key.Binding
+	Back     key.Binding
+	Quit     key.Binding
+	Help     key.Binding
+	Fetch    key.Binding
+	PageUp   key.Binding
+	PageDown key.Binding
+	Top      key.Binding
+	Bottom   key.Binding
+	HalfDown key.Binding
+	HalfUp   key.Binding
+	GoTo     key.Binding
+	NextMsg  key.Binding
+	PrevMsg  key.Binding
+	Search   key.Binding
+	Links    key.Binding
+	OpenWeb  key.Binding
+	Yank     key.Binding
+	Headers  key.Binding
+	Source   key.Binding
+	Expand   key.Binding
+	LoadAll  key.Binding
+	SaveText key.Binding
+	Images   key.Binding
+	Files    key.Binding
+	SaveAll  key.Binding
+	NextTab  key.Binding
+	PrevTab  key.Binding
+	Sort     key.Binding
+	Group    key.Binding
+	Unread   key.Binding
+	Starred  key.Binding
+	HasFiles key.Binding
+	Trash    key.Binding
+	Empty    key.Binding
+	Read     key.Binding
+	Priority key.Binding
+
+	Unsubscribe  key.Binding
+	UnsubArchive key.Binding
+	UnsubFilter  key.Binding
+	Spam         key.Binding
+	Block        key.Binding
+	Snooze       key.Binding
+	Mute         key.Binding
+	Profile      key.Binding
+
+	Filters       key.Binding
+	NewFilter     key.Binding
+	FilterFromMsg key.Binding
+	Toggle        key.Binding
+	Vacation      key.Binding
+	NextField     key.Binding
+	PrevField     key.Binding
+	Save          key.Binding
+
+	YankBody    key.Binding
+	YankSender  key.Binding
+	YankSubject key.Binding
+	YankLink    key.Binding
+
+	NextMatch key.Binding
+	PrevMatch key.Binding
+
+	Compose  key.Binding
+	Drafts   key.Binding
+	Edit     key.Binding
+	Discard  key.Binding
+	Send     key.Binding
+	Confirm  key.Binding
+	Cancel   key.Binding
+	Undo     key.Binding
+	Attach   key.Binding
+	Detach   key.Binding
+	Preview  key.Binding
+	Complete key.Binding
+	EditTo   key.Binding
+	EditCc   key.Binding
+	EditBcc  key.Binding
+	From     key.Binding
+
+	Signatures key.Binding
+	Contacts   key.Binding
+	MailFrom   key.Binding
+	Scheduled  key.Binding
+	SendLater  key.Binding
+	Command    key.Binding
+	PGP        key.Binding
+	FollowUp   key.Binding
+	HUD        key.Binding
+
+	SuggestNext key.Binding
+	SuggestPrev key.Binding
+	NextUnread  key.Binding
+	PrevUnread  key.Binding
+
+	RSVP      key.Binding
+	Accept    key.Binding
+	Tentative key.Binding
+	Decline   key.Binding
+	ExportICS key.Binding
+
+	// Plugins are the key bindings registered by Lua plugins.
+	Plugins []key.Binding
+}
+
+func (k Map) ShortHelp() []key.Binding {
+	return []key.Binding{k.Command, k.Help, k.Quit}
+}
+
+func (k Map) FullHelp() [][]key.Binding {
+	var groups [][]key.Binding
+	for _, s := range k.Sections() {
+		groups = append(groups, s.Bindings)
+	}
+	return groups
+}
+
+// New returns the default key bindings.
+func New() Map {
+	return Map{
+		Up:       key.NewBinding(key.WithKeys("up", "k"), key.WithHelp("↑/k", "up")),
+		Down:     key.NewBinding(key.WithKeys("down", "j"), key.WithHelp("↓/j", "down")),
+		Select:   key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", "select")),
+		Back:     key.NewBinding(key.WithKeys("esc"), key.WithHelp("esc", "back")),
+		Help:     key.NewBinding(key.WithKeys("?"), key.WithHelp("?", "key bindings")),
+		Quit:     key.NewBinding(key.WithKeys("Q", "ctrl+c"), key.WithHelp("Q", "quit")),
+		Fetch:    key.NewBinding(key.WithKeys("r"), key.WithHelp("r", "refresh")),
+		PageUp:   key.NewBinding(key.WithKeys("pgup"), key.WithHelp("pgup", "page up")),
+		PageDown: key.NewBinding(key.WithKeys("pgdown"), key.WithHelp("pgdown", "page down")),
+		Top:      key.NewBinding(key.WithKeys("g"), key.WithHelp("gg", "go to top / line N")),
+		Bottom:   key.NewBinding(key.WithKeys("G"), key.WithHelp("G", "go to bottom / line N")),
+		HalfDown: key.NewBinding(key.WithKeys("ctrl+d"), key.WithHelp("ctrl+d", "half page down")),
+		HalfUp:   key.NewBinding(key.WithKeys("ctrl+u"), key.WithHelp("ctrl+u", "half page up")),
+		GoTo:     key.NewBinding(key.WithKeys("g"), key.WithHelp("g i/s/t/d/l/u", "go to inbox/starred/sent/drafts/label/first unread")),
+		NextMsg:  key.NewBinding(key.WithKeys("J"), key.WithHelp("J", "next message")),
+		PrevMsg:  key.NewBinding(key.WithKeys("K"), key.WithHelp("K", "previous message")),
+		Search:   key.NewBinding(key.WithKeys("/"), key.WithHelp("/", "search message")),
+		Links:    key.NewBinding(key.WithKeys("o"), key.WithHelp("o", "open link")),
+		OpenWeb:  key.NewBinding(key.WithKeys("w"), key.WithHelp("w", "open in gmail web")),
+		Yank:     key.NewBinding(key.WithKeys("y"), key.WithHelp("y", "copy…")),
+		Headers:  key.NewBinding(key.WithKeys("H"), key.WithHelp("H", "show all headers")),
+		Source:   key.NewBinding(key.WithKeys("V"), key.WithHelp("V", "view source")),
+		Expand:   key.NewBinding(key.WithKeys("E"), key.WithHelp("E", "expand quoted text")),
+		LoadAll:  key.NewBinding(key.WithKeys("X"), key.WithHelp("X", "load all of a cut message")),
+		SaveText: key.NewBinding(key.WithKeys("ctrl+s"), key.WithHelp("ctrl+s", "save as a text file")),
+		Images:   key.NewBinding(key.WithKeys("I"), key.WithHelp("I", "show images, loading remote ones")),
+		Files:    key.NewBinding(key.WithKeys("A"), key.WithHelp("A", "attachments")),
+		SaveAll:  key.NewBinding(key.WithKeys("S"), key.WithHelp("S", "save all attachments")),
+		NextTab:  key.NewBinding(key.WithKeys("tab"), key.WithHelp("tab", "next tab")),
+		PrevTab:  key.NewBinding(key.WithKeys("shift+tab"), key.WithHelp("shift+tab", "previous tab")),
+		Sort:     key.NewBinding(key.WithKeys("s"), key.WithHelp("s", "sort by date/sender/subject/size")),
+		Group:    key.NewBinding(key.WithKeys("v"), key.WithHelp("v", "group by day / priority")),
+		Unread:   key.NewBinding(key.WithKeys("N"), key.WithHelp("N", "unread only")),
+		Starred:  key.NewBinding(key.WithKeys("*"), key.WithHelp("*", "starred only")),
+		HasFiles: key.NewBinding(key.WithKeys("a"), key.WithHelp("a", "with attachments only")),
+		Trash:    key.NewBinding(key.WithKeys("#"), key.WithHelp("#", "trash / restore")),
+		Empty:    key.NewBinding(key.WithKeys("X"), key.WithHelp("X", "empty trash / spam")),
+		Read:     key.NewBinding(key.WithKeys("m"), key.WithHelp("m", "mark read / unread")),
+		Priority: key.NewBinding(key.WithKeys("+"), key.WithHelp("+", "mark important / not")),
+
+		Unsubscribe:  key.NewBinding(key.WithKeys("U"), key.WithHelp("U", "unsubscribe")),
+		UnsubArchive: key.NewBinding(key.WithKeys("a"), key.WithHelp("a", "unsubscribe and archive")),
+		UnsubFilter:  key.NewBinding(key.WithKeys("f"), key.WithHelp("f", "unsubscribe, archive and filter")),
+		Spam:         key.NewBinding(key.WithKeys("!"), key.WithHelp("!", "spam / not spam")),
+		Block:        key.NewBinding(key.WithKeys("B"), key.WithHelp("B", "block sender")),
+		Snooze:       key.NewBinding(key.WithKeys("z"), key.WithHelp("z", "snooze")),
+		Mute:         key.NewBinding(key.WithKeys("M"), key.WithHelp("M", "mute / unmute conversation")),
+		Profile:      key.NewBinding(key.WithKeys("i"), key.WithHelp("i", "sender profile")),
+
+		Filters:       key.NewBinding(key.WithKeys("F"), key.WithHelp("F", "filters")),
+		NewFilter:     key.NewBinding(key.WithKeys("n"), key.WithHelp("n", "new filter")),
+		FilterFromMsg: key.NewBinding(key.WithKeys("c"), key.WithHelp("c", "filter from message")),
+		Toggle:        key.NewBinding(key.WithKeys(" "), key.WithHelp("space", "toggle")),
+		Vacation:      key.NewBinding(key.WithKeys("O"), key.WithHelp("O", "vacation responder")),
+		NextField:     key.NewBinding(key.WithKeys("tab"), key.WithHelp("tab", "next field")),
+		PrevField:     key.NewBinding(key.WithKeys("shift+tab"), key.WithHelp("shift+tab", "previous field")),
+		Save:          key.NewBinding(key.WithKeys("ctrl+s"), key.WithHelp("ctrl+s", "save")),
+
+		YankBody:    key.NewBinding(key.WithKeys("b"), key.WithHelp("y b", "copy body")),
+		YankSender:  key.NewBinding(key.WithKeys("a"), key.WithHelp("y a", "copy sender address")),
+		YankSubject: key.NewBinding(key.WithKeys("s"), key.WithHelp("y s", "copy subject")),
+		YankLink:    key.NewBinding(key.WithKeys("l"), key.WithHelp("y l", "copy gmail link")),
+
+		NextMatch: key.NewBinding(key.WithKeys("n"), key.WithHelp("n", "next match")),
+		PrevMatch: key.NewBinding(key.WithKeys("N"), key.WithHelp("N", "previous match")),
+
+		Compose:  key.NewBinding(key.WithKeys("c"), key.WithHelp("c", "compose")),
+		Drafts:   key.NewBinding(key.WithKeys("D"), key.WithHelp("D", "drafts")),
+		Edit:     key.NewBinding(key.WithKeys("e"), key.WithHelp("e", "edit draft")),
+		Discard:  key.NewBinding(key.WithKeys("x"), key.WithHelp("x", "discard draft")),
+		Send:     key.NewBinding(key.WithKeys("s"), key.WithHelp("s", "send")),
+		Confirm:  key.NewBinding(key.WithKeys("y"), key.WithHelp("y", "confirm")),
+		Cancel:   key.NewBinding(key.WithKeys("n", "esc"), key.WithHelp("n", "cancel")),
+		Undo:     key.NewBinding(key.WithKeys("u", "ctrl+z"), key.WithHelp("u/ctrl+z", "undo send or last change")),
+		Attach:   key.NewBinding(key.WithKeys("a"), key.WithHelp("a", "attach file")),
+		Detach:   key.NewBinding(key.WithKeys("A"), key.WithHelp("A", "remove attachment")),
+		Preview:  key.NewBinding(key.WithKeys("p"), key.WithHelp("p", "preview markdown")),
+		Complete: key.NewBinding(key.WithKeys("tab"), key.WithHelp("tab", "complete path")),
+		EditTo:   key.NewBinding(key.WithKeys("t"), key.WithHelp("t", "edit to")),
+		EditCc:   key.NewBinding(key.WithKeys("c"), key.WithHelp("c", "edit cc")),
+		EditBcc:  key.NewBinding(key.WithKeys("b"), key.WithHelp("b", "edit bcc")),
+		From:     key.NewBinding(key.WithKeys("f"), key.WithHelp("f", "send as")),
+
+		Signatures: key.NewBinding(key.WithKeys("S"), key.WithHelp("S", "signatures")),
+		Contacts:   key.NewBinding(key.WithKeys("C"), key.WithHelp("C", "contacts")),
+		MailFrom:   key.NewBinding(key.WithKeys("s"), key.WithHelp("s", "mail from contact")),
+		Scheduled:  key.NewBinding(key.WithKeys("L"), key.WithHelp("L", "outbox")),
+		SendLater:  key.NewBinding(key.WithKeys("L"), key.WithHelp("L", "send later")),
+		Command:    key.NewBinding(key.WithKeys(":"), key.WithHelp(":", "command")),
+		PGP:        key.NewBinding(key.WithKeys("P"), key.WithHelp("P", "pgp sign / encrypt")),
+		FollowUp:   key.NewBinding(key.WithKeys("W"), key.WithHelp("W", "remind me if no reply")),
+		HUD:        key.NewBinding(key.WithKeys("ctrl+g"), key.WithHelp("ctrl+g", "performance HUD")),
+
+		SuggestNext: key.NewBinding(key.WithKeys("tab", "down"), key.WithHelp("tab", "next suggestion")),
+		SuggestPrev: key.NewBinding(key.WithKeys("shift+tab", "up"), key.WithHelp("shift+tab", "previous suggestion")),
+		NextUnread:  key.NewBinding(key.WithKeys("]"), key.WithHelp("]", "next unread")),
+		PrevUnread:  key.NewBinding(key.WithKeys("["), key.WithHelp("[", "previous unread")),
+
+		RSVP:      key.NewBinding(key.WithKeys("R"), key.WithHelp("R", "reply to invitation")),
+		Accept:    key.NewBinding(key.WithKeys("a"), key.WithHelp("a", "accept")),
+		Tentative: key.NewBinding(key.WithKeys("t"), key.WithHelp("t", "maybe")),
+		Decline:   key.NewBinding(key.WithKeys("d"), key.WithHelp("d", "decline")),
+		ExportICS: key.NewBinding(key.WithKeys("x"), key.WithHelp("x", "export to calendar")),
+	}
+}
+
+// Section is a group of key bindings used in the same context.
+type Section struct {
+	Title    string
+	Bindings []key.Binding
+}
+
+// sections groups the key bindings by where they are used. Bindings that
+// are not placed in a section are listed under Other, so a new binding is
+// never missing from the help screen.
+func (k Map) Sections() []Section {
+	sections := []Section{
+		{"List", []key.Binding{k.Up, k.Down, k.PageUp, k.PageDown, k.Top, k.Bottom, k.HalfDown, k.HalfUp, k.GoTo,
+			k.Select, k.Fetch, k.NextTab, k.PrevTab, k.Sort, k.Group, k.Unread, k.Starred, k.HasFiles, k.NextUnread, k.PrevUnread}},
+		{"Messages", []key.Binding{k.Trash, k.Empty, k.Read, k.Priority, k.Undo, k.Spam, k.Block, k.Snooze, k.Mute,
+			k.Profile, k.FollowUp, k.Unsubscribe, k.UnsubArchive, k.UnsubFilter}},
+		{"Copying", []key.Binding{k.Yank, k.YankBody, k.YankSender, k.YankSubject, k.YankLink}},
+		{"Reading", []key.Binding{k.NextMsg, k.PrevMsg, k.Search, k.NextMatch, k.PrevMatch, k.Links, k.OpenWeb,
+			k.Headers, k.Source, k.Expand, k.LoadAll, k.SaveText, k.Images, k.Files, k.SaveAll}},
+		{"Invitations", []key.Binding{k.RSVP, k.Accept, k.Tentative, k.Decline, k.ExportICS}},
+		{"Compose", []key.Binding{k.Compose, k.Drafts, k.Edit, k.Discard, k.Send, k.SendLater, k.Scheduled,
+			k.Confirm, k.Cancel, k.Attach, k.Detach, k.Complete, k.Preview, k.EditTo, k.EditCc, k.EditBcc, k.From,
+			k.SuggestNext, k.SuggestPrev, k.Signatures, k.PGP}},
+		{"Filters and settings", []key.Binding{k.Filters, k.NewFilter, k.FilterFromMsg, k.Toggle, k.Vacation,
+			k.NextField, k.PrevField, k.Save}},
+		{"Contacts", []key.Binding{k.Contacts, k.MailFrom}},
+		{"General", []key.Binding{k.Command, k.Back, k.Help, k.HUD, k.Quit}},
+	}
+	if len(k.Plugins) > 0 {
+		sections = append(sections, Section{"Plugins", k.Plugins})
+	}
+
+	listed := map[string]bool{}
+	for _, s := range sections {
+		for _, b := range s.Bindings {
+			listed[b.Help().Key+"\x00"+b.Help().Desc] = true
+		}
+	}
+	var other []key.Binding
+	v := reflect.ValueOf(k)
+	for i := 0; i < v.NumField(); i++ {
+		if b, ok := v.Field(i).Interface().(key.Binding); ok && !listed[b.Help().Key+"\x00"+b.Help().Desc] {
+			other = append(other, b)
+		}
+	}
+	if len(other) > 0 {
+		sections = append(sections, Section{"Other", other})
+	}
+	return sections
+}
@@ -0,0 +1,112 @@
+// Package maillist holds how gmail-tui's message lists are arranged: the
+// sort orders and groupings, the columns a row can show, and the delegate
+// that draws the headers of grouped rows.
+package maillist
+
+import (
+	"fmt"
+	"io"
+	"net/mail"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/lipgloss"
+
+	"gmail-tui/internal/config"
+)
+
+var groupStyle = lipgloss.NewStyle().
+	MarginLeft(2).
+	Bold(true).
+	Foreground(lipgloss.Color("#9B9B9B"))
+
+// Sorts are the list sort orders, in the order the sort key steps through
+// them.
+var Sorts = []string{"date", "oldest", "sender", "subject", "size"}
+
+// SortNames describe the sort orders in the status line.
+var SortNames = map[string]string{
+	"date":    "newest first",
+	"oldest":  "oldest first",
+	"sender":  "sender",
+	"subject": "subject",
+	"size":    "size, largest first",
+}
+
+// Groups are the list groupings, in the order the group key steps through
+// them.
+var Groups = []string{"", "day", "priority"}
+
+// PrioritySections are the Priority Inbox sections, in the order they are
+// listed.
+var PrioritySections = []string{"Important and unread", "Starred", "Everything else"}
+
+// Columns are the columns a list row can show, with their default widths.
+// Zero means the column takes what is left of the row.
+var Columns = map[string]int{
+	"star":    1,
+	"unread":  1,
+	"files":   2,
+	"sender":  20,
+	"subject": 0,
+	"snippet": 0,
+	"labels":  20,
+	"size":    8,
+	"date":    12,
+}
+
+// CompactColumns are shown in compact rows when no columns are configured.
+var CompactColumns = []config.ListColumn{{Name: "star"}, {Name: "unread"}, {Name: "files"}, {Name: "sender"}, {Name: "subject"}, {Name: "date"}}
+
+// SenderName is the name a message is sorted under: the sender's display
+// name, or their address if there is none.
+func SenderName(from string) string {
+	if a, err := mail.ParseAddress(from); err == nil {
+		if a.Name != "" {
+			return strings.ToLower(a.Name)
+		}
+		return strings.ToLower(a.Address)
+	}
+	return strings.ToLower(strings.Trim(from, `" `))
+}
+
+// SortSubject drops reply and forward prefixes so a thread's messages sort
+// together.
+func SortSubject(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for {
+		trimmed := strings.TrimSpace(strings.TrimPrefix(strings.TrimPrefix(strings.TrimPrefix(s, "re:"), "fwd:"), "fw:"))
+		if trimmed == s {
+			return s
+		}
+		s = trimmed
+	}
+}
+
+// Grouped draws the rows of its ItemDelegate with Header, the group a row
+// is listed under, in the line above the first row of each group and of
+// each page. The header takes the place of the blank line between rows, so
+// grouping does not change how many rows fit, except in compact lists,
+// which have no blank lines.
+type Grouped struct {
+	list.ItemDelegate
+	Header func(list.Item) string
+}
+
+func (d Grouped) Height() int {
+	return d.ItemDelegate.Height() + max(d.ItemDelegate.Spacing(), 1)
+}
+
+func (d Grouped) Spacing() int {
+	return 0
+}
+
+func (d Grouped) Render(w io.Writer, m list.Model, index int, item list.Item) {
+	header := d.Header(item)
+	first := index == m.Paginator.Page*m.Paginator.PerPage
+	if !first && d.Header(m.VisibleItems()[max(index-1, 0)]) == header {
+		header = ""
+	}
+	fmt.Fprintln(w, groupStyle.Render(header))
+	d.ItemDelegate.Render(w, m, index, item)
+}
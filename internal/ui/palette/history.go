@@ -0,0 +1,67 @@
+package palette
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gmail-tui/internal/config"
+	"gmail-tui/internal/vault"
+)
+
+const maxHistory = 200
+
+// AppendHistory adds line to the end of history, unless it repeats the
+// last line, keeping the newest lines.
+func AppendHistory(history []string, line string) []string {
+	if n := len(history); n > 0 && history[n-1] == line {
+		return history
+	}
+	history = append(history, line)
+	if len(history) > maxHistory {
+		history = history[len(history)-maxHistory:]
+	}
+	return history
+}
+
+func historyPath() (string, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "command_history"), nil
+}
+
+// LoadHistory reads the command lines run in earlier sessions, decrypting
+// them with v.
+func LoadHistory(v *vault.Vault) []string {
+	path, err := historyPath()
+	if err != nil {
+		return nil
+	}
+	data, err := v.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+
+	var history []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line != "" {
+			history = AppendHistory(history, line)
+		}
+	}
+	return history
+}
+
+// SaveHistory writes the history, encrypted with v, so it survives
+// restarts. Failures only lose history, so they are ignored.
+func SaveHistory(v *vault.Vault, history []string) {
+	path, err := historyPath()
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return
+	}
+	v.WriteFile(path, []byte(strings.Join(history, "\n")+"\n"), 0600)
+}
@@ -0,0 +1,99 @@
+// Package palette is the : command prompt: a table of named commands that
+// are matched fuzzily as they are typed, and the history of the command
+// lines run from it.
+package palette
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/sahilm/fuzzy"
+)
+
+// MaxMatches is how many suggestions are listed under the prompt.
+const MaxMatches = 5
+
+// Keys is the help line shown under the prompt.
+const Keys = "tab: complete • ↑/↓: history • enter: run • esc: cancel"
+
+// Command is a command that can be run from the prompt on a model M.
+type Command[M any] struct {
+	Name string
+	Args string
+	Help string
+	Run  func(m M, arg string) (M, tea.Cmd)
+}
+
+// Line describes c in the list of suggestions.
+func (c Command[M]) Line() string {
+	return fmt.Sprintf("  %-12s %-10s %s", c.Name, c.Args, c.Help)
+}
+
+// Match returns the commands whose names fuzzily match name, best first,
+// or all of them if name is empty.
+func Match[M any](cmds []Command[M], name string) []Command[M] {
+	if name == "" {
+		return cmds
+	}
+	names := make([]string, len(cmds))
+	for i, c := range cmds {
+		names[i] = c.Name
+	}
+	var out []Command[M]
+	for _, match := range fuzzy.Find(name, names) {
+		out = append(out, cmds[match.Index])
+	}
+	return out
+}
+
+// Resolve finds the command called name, or the best fuzzy match.
+func Resolve[M any](cmds []Command[M], name string) (Command[M], bool) {
+	matches := Match(cmds, name)
+	for _, c := range matches {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	if len(matches) == 0 {
+		return Command[M]{}, false
+	}
+	return matches[0], true
+}
+
+// Prompt is the prompt while it is open. Pos indexes the command history
+// while browsing it with up and down; it equals the history's length for
+// a new command. Query does the same for the search history, which up and
+// down browse instead once :search has been typed.
+type Prompt struct {
+	Input textinput.Model
+	Pos   int
+	Query int
+}
+
+// New opens a prompt at the end of a command history of length history
+// and a search history of length searches.
+func New(history, searches int) *Prompt {
+	ti := textinput.New()
+	ti.Prompt = ":"
+	return &Prompt{Input: ti, Pos: history, Query: searches}
+}
+
+// Browse puts the previous command line from history at the prompt, or
+// the next one when older is false. Past the newest the prompt is empty.
+func (p *Prompt) Browse(history []string, older bool) {
+	switch {
+	case older && p.Pos > 0:
+		p.Pos--
+	case !older && p.Pos < len(history):
+		p.Pos++
+	default:
+		return
+	}
+	value := ""
+	if p.Pos < len(history) {
+		value = history[p.Pos]
+	}
+	p.Input.SetValue(value)
+	p.Input.CursorEnd()
+}
@@ -0,0 +1,275 @@
+package view
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+	"github.com/charmbracelet/x/ansi"
+)
+
+var (
+	quoteStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#7FA6C9"))
+
+	signatureStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#626262"))
+
+	inviteStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#FFB86C"))
+)
+
+// LineKind says how a line of a formatted body is styled.
+type LineKind int
+
+const (
+	PlainLine LineKind = iota
+	QuoteLine
+	SignatureLine
+	InviteLine
+)
+
+// Line is a line of a message body laid out for the reading view.
+type Line struct {
+	Text string
+	Kind LineKind
+}
+
+var (
+	quotePrefix  = regexp.MustCompile(`^(>[ \t]?)+`)
+	listItem     = regexp.MustCompile(`^\s*([-*•+]|\d+[.)])\s`)
+	tableDivider = regexp.MustCompile(`^\|?\s*:?-{3,}:?\s*(\|\s*:?-{3,}:?\s*)*\|?$`)
+	attribution  = regexp.MustCompile(`^On\s.*\swrote:\s*$`)
+	replyDivider = regexp.MustCompile(`^\s*(-{2,}\s*Original Message\s*-{2,}|_{20,})\s*$`)
+)
+
+// maxInlineQuote is the longest run of quoted lines without an "On ...
+// wrote:" line that is left expanded, so short quotes answered inline stay
+// readable.
+const maxInlineQuote = 3
+
+// FormatBody lays out a plain text body for a viewport width columns wide.
+// Paragraphs that overflow are reflowed and other long lines wrapped,
+// quoted lines keep their > prefix, Markdown-style tables are drawn with
+// borders, and the lines after a "-- " delimiter, up to any quoted text,
+// are the signature. With collapse set, quoted replies and the signature
+// are each replaced by a "[+ N quoted lines]" marker.
+func FormatBody(body string, width int, collapse bool) []Line {
+	src := strings.Split(strings.ReplaceAll(body, "\r\n", "\n"), "\n")
+	var out []Line
+	inSignature := false
+
+	for i := 0; i < len(src); {
+		line := src[i]
+		quoted := QuoteLen(src[i:])
+		if quoted > 0 {
+			inSignature = false
+		}
+		if strings.TrimRight(line, " ") == "--" {
+			inSignature = true
+			if n := signatureLen(src[i:]); collapse {
+				out = append(out, Line{Text: collapsedMarker(n, "signature"), Kind: SignatureLine})
+				i += n
+				continue
+			}
+		}
+
+		switch {
+		case collapse && (quoted > maxInlineQuote || quoted > 0 && !quotePrefix.MatchString(line)):
+			out = append(out, Line{Text: collapsedMarker(quoted, "quoted"), Kind: QuoteLine})
+			i += quoted
+		case inSignature:
+			out = AppendWrapped(out, "", line, width, SignatureLine)
+			i++
+		case quotePrefix.MatchString(line):
+			prefix := quotePrefix.FindString(line)
+			out = AppendWrapped(out, prefix, line[len(prefix):], width, QuoteLine)
+			i++
+		case isTableStart(src[i:]):
+			n := tableLen(src[i:])
+			for _, l := range strings.Split(renderTable(src[i:i+n]), "\n") {
+				out = append(out, Line{Text: l})
+			}
+			i += n
+		default:
+			n := paragraphLen(src[i:])
+			out = appendParagraph(out, src[i:i+n], width)
+			i += n
+		}
+	}
+	return out
+}
+
+// QuoteLen returns the length of the quoted reply starting lines: an "On
+// ... wrote:" line, which mail clients may wrap over two lines, followed by
+// > lines, or a bare run of > lines. Everything after an Outlook-style
+// divider is quoted. It returns 0 if lines do not start a quote.
+func QuoteLen(lines []string) int {
+	if replyDivider.MatchString(lines[0]) {
+		return len(lines)
+	}
+
+	n := 0
+	switch {
+	case attribution.MatchString(lines[0]):
+		n = 1
+	case len(lines) > 1 && strings.HasPrefix(lines[0], "On ") && attribution.MatchString(lines[0]+" "+lines[1]):
+		n = 2
+	}
+	if n == 0 && !quotePrefix.MatchString(lines[0]) {
+		return 0
+	}
+	start := n
+	for n < len(lines) && (quotePrefix.MatchString(lines[n]) ||
+		strings.TrimSpace(lines[n]) == "" && n+1 < len(lines) && quotePrefix.MatchString(lines[n+1])) {
+		n++
+	}
+	if n == start {
+		return 0
+	}
+	return n
+}
+
+// signatureLen counts the lines of the signature starting lines, which
+// runs to the end of the message or the start of quoted text.
+func signatureLen(lines []string) int {
+	n := 1
+	for n < len(lines) && QuoteLen(lines[n:]) == 0 {
+		n++
+	}
+	for n > 1 && strings.TrimSpace(lines[n-1]) == "" {
+		n--
+	}
+	return n
+}
+
+func collapsedMarker(n int, what string) string {
+	if n == 1 {
+		return fmt.Sprintf("[+ 1 %s line]", what)
+	}
+	return fmt.Sprintf("[+ %d %s lines]", n, what)
+}
+
+// IsCollapsedMarker reports whether a displayed line is a collapsed block.
+func IsCollapsedMarker(line string) bool {
+	line = strings.TrimSpace(ansi.Strip(line))
+	return strings.HasPrefix(line, "[+ ") && strings.HasSuffix(line, "]")
+}
+
+// paragraphLen counts the leading lines that can be reflowed together:
+// unindented text up to a blank, quoted or list line.
+func paragraphLen(lines []string) int {
+	n := 1
+	if !reflowable(lines[0]) {
+		return n
+	}
+	for n < len(lines) && reflowable(lines[n]) && !listItem.MatchString(lines[n]) &&
+		strings.TrimRight(lines[n], " ") != "--" && !isTableStart(lines[n:]) {
+		n++
+	}
+	return n
+}
+
+func reflowable(line string) bool {
+	return strings.TrimSpace(line) != "" && !strings.HasPrefix(line, " ") &&
+		!strings.HasPrefix(line, "\t") && !quotePrefix.MatchString(line)
+}
+
+// appendParagraph adds a paragraph, joining and rewrapping its lines if any
+// of them is too wide, so a message hard-wrapped wider than the viewport
+// does not come out ragged. Paragraphs that fit keep their line breaks.
+func appendParagraph(out []Line, lines []string, width int) []Line {
+	overflows := false
+	for _, l := range lines {
+		if ansi.StringWidth(l) > width {
+			overflows = true
+		}
+	}
+	if !overflows || len(lines) == 1 {
+		for _, l := range lines {
+			out = AppendWrapped(out, "", l, width, PlainLine)
+		}
+		return out
+	}
+
+	words := make([]string, len(lines))
+	for i, l := range lines {
+		words[i] = strings.TrimSpace(l)
+	}
+	return AppendWrapped(out, "", strings.Join(words, " "), width, PlainLine)
+}
+
+// AppendWrapped wraps text to width less the prefix, which starts every
+// resulting line. Words longer than a line, such as URLs, are broken.
+func AppendWrapped(out []Line, prefix, text string, width int, kind LineKind) []Line {
+	w := width - ansi.StringWidth(prefix)
+	if w < 20 {
+		return append(out, Line{Text: prefix + text, Kind: kind})
+	}
+	for _, l := range strings.Split(ansi.Wrap(text, w, ""), "\n") {
+		out = append(out, Line{Text: prefix + strings.TrimRight(l, " "), Kind: kind})
+	}
+	return out
+}
+
+// isTableStart reports whether lines begin with a Markdown-style table: a
+// row of |-separated cells followed by a --- divider row.
+func isTableStart(lines []string) bool {
+	return len(lines) >= 2 && strings.Contains(lines[0], "|") &&
+		tableDivider.MatchString(strings.TrimSpace(lines[1]))
+}
+
+func tableLen(lines []string) int {
+	n := 2
+	for n < len(lines) && strings.Contains(lines[n], "|") && strings.TrimSpace(lines[n]) != "" {
+		n++
+	}
+	return n
+}
+
+// renderTable draws a Markdown-style table with aligned columns.
+func renderTable(lines []string) string {
+	cell := lipgloss.NewStyle().Padding(0, 1)
+	t := table.New().
+		Border(TableBorder()).
+		StyleFunc(func(row, col int) lipgloss.Style { return cell }).
+		Headers(tableCells(lines[0])...)
+	for _, l := range lines[2:] {
+		t.Row(tableCells(l)...)
+	}
+	return t.String()
+}
+
+func tableCells(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(strings.TrimSuffix(line, "|"), "|")
+	cells := strings.Split(line, "|")
+	for i, c := range cells {
+		cells[i] = strings.TrimSpace(c)
+	}
+	return cells
+}
+
+// Styled colours a formatted line for display.
+func (l Line) Styled() string {
+	switch l.Kind {
+	case QuoteLine:
+		return quoteStyle.Render(l.Text)
+	case SignatureLine:
+		return signatureStyle.Render(l.Text)
+	case InviteLine:
+		return inviteStyle.Render(l.Text)
+	}
+	return l.Text
+}
+
+// PlainLines splits text into lines that are shown as they are.
+func PlainLines(text string) []Line {
+	var lines []Line
+	for _, l := range strings.Split(text, "\n") {
+		lines = append(lines, Line{Text: l})
+	}
+	return lines
+}
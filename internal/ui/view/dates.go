@@ -0,0 +1,74 @@
+package view
+
+import (
+	"fmt"
+	"time"
+)
+
+// ListDateFormat is the layout used for dates in list rows. An empty value
+// or "relative" shows relative dates such as "5m ago" or "Yesterday".
+var ListDateFormat = "relative"
+
+// ListDate renders t for a list row using ListDateFormat.
+func ListDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	if ListDateFormat != "" && ListDateFormat != "relative" {
+		return t.Local().Format(ListDateFormat)
+	}
+	return relativeDate(t, time.Now())
+}
+
+// DayGroup names the section of a list grouped by day that t falls in:
+// "Today", "Yesterday", "Last week", or the month for anything older.
+func DayGroup(t, now time.Time) string {
+	if t.IsZero() {
+		return "Undated"
+	}
+	t = t.Local()
+	now = now.Local()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	switch {
+	case !t.Before(today.AddDate(0, 0, 1)):
+		return "Upcoming"
+	case !t.Before(today):
+		return "Today"
+	case !t.Before(today.AddDate(0, 0, -1)):
+		return "Yesterday"
+	case !t.Before(today.AddDate(0, 0, -7)):
+		return "Last week"
+	}
+	return t.Format("January 2006")
+}
+
+func relativeDate(t, now time.Time) string {
+	t = t.Local()
+	now = now.Local()
+
+	d := now.Sub(t)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case !t.Before(today):
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	case !t.Before(today.AddDate(0, 0, -1)):
+		return "Yesterday"
+	case t.Year() == now.Year():
+		return t.Format("Jan 2")
+	}
+	return t.Format("Jan 2, 2006")
+}
+
+// FullDate renders the exact timestamp shown in the reading view.
+func FullDate(t time.Time) string {
+	if t.IsZero() {
+		return "unknown"
+	}
+	return t.Local().Format("Mon, 2 Jan 2006 15:04:05 MST")
+}
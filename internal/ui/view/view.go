@@ -0,0 +1,88 @@
+// Package view holds what gmail-tui's screens are drawn with: the shared
+// styles, message bodies laid out for the reading view, and dates and sizes
+// as the lists show them.
+package view
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Accessible turns on the mode for terminal screen readers. Like
+// ListDateFormat it is set once at start, for the rendering code that has
+// no Model at hand.
+var Accessible bool
+
+var (
+	TitleStyle = lipgloss.NewStyle().
+			MarginLeft(2).
+			Bold(true).
+			Foreground(lipgloss.Color("#FF75B7"))
+
+	InfoStyle = lipgloss.NewStyle().
+			MarginLeft(2).
+			Foreground(lipgloss.Color("#9B9B9B"))
+
+	HelpStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#626262")).
+			MarginTop(1).
+			MarginLeft(2)
+)
+
+// asciiBorder draws boxes and tables without box-drawing characters.
+var asciiBorder = lipgloss.Border{
+	Top: "-", Bottom: "-", Left: "|", Right: "|",
+	TopLeft: "+", TopRight: "+", BottomLeft: "+", BottomRight: "+",
+	MiddleLeft: "+", MiddleRight: "+", Middle: "+", MiddleTop: "+", MiddleBottom: "+",
+}
+
+// Rule is a horizontal line n wide. In accessible mode it is drawn with
+// hyphens, which screen readers skip or read briefly.
+func Rule(n int) string {
+	if Accessible {
+		return strings.Repeat("-", n)
+	}
+	return strings.Repeat("─", n)
+}
+
+// TableBorder is the border drawn around tables and boxes.
+func TableBorder() lipgloss.Border {
+	if Accessible {
+		return asciiBorder
+	}
+	return lipgloss.NormalBorder()
+}
+
+// ItemDelegate returns the delegate that draws the rows of every list.
+func ItemDelegate() list.DefaultDelegate {
+	delegate := list.NewDefaultDelegate()
+	delegate.Styles.SelectedTitle = delegate.Styles.SelectedTitle.
+		Foreground(lipgloss.Color("170")).
+		BorderForeground(lipgloss.Color("170"))
+	delegate.Styles.SelectedDesc = delegate.Styles.SelectedDesc.
+		Foreground(lipgloss.Color("241")).
+		BorderForeground(lipgloss.Color("170"))
+	return delegate
+}
+
+// PlainDelegate marks the selected row with > rather than a colored bar,
+// so the selection isn't shown by color alone.
+func PlainDelegate(d list.DefaultDelegate) list.DefaultDelegate {
+	d.Styles.SelectedTitle = d.Styles.SelectedTitle.BorderStyle(lipgloss.Border{Left: ">"})
+	d.Styles.SelectedDesc = d.Styles.SelectedDesc.BorderStyle(lipgloss.Border{Left: " "})
+	return d
+}
+
+// Size renders a size in bytes as B, KB or MB.
+func Size(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
@@ -0,0 +1,345 @@
+// Package vault encrypts the files gmail-tui caches mail and its token in,
+// so that they can't be read from the disk without the key.
+package vault
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/zalando/go-keyring"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/term"
+
+	"gmail-tui/internal/config"
+	"gmail-tui/internal/safefile"
+)
+
+// The ways the key can be kept, as named in encrypt_cache.
+const (
+	// Keyring keeps a random key in the OS credential store.
+	Keyring = "keyring"
+	// Passphrase derives the key from a passphrase, asked for at start or
+	// printed by passphrase_command.
+	Passphrase = "passphrase"
+)
+
+const (
+	keyringService = "gmail-tui"
+	keyringUser    = "cache-key"
+
+	keySize = 32
+)
+
+// magic starts every encrypted file. Files without it are only read while
+// encryption is off, or in the run that turns it on, which encrypts them.
+var magic = []byte("gmail-tui vault 1\n")
+
+// errEncrypted is what reading an encrypted file fails with when
+// encryption has been turned off since it was written.
+var errEncrypted = errors.New("the file is encrypted and encrypt_cache is off")
+
+// errPlain is what reading a file that isn't encrypted fails with once
+// encryption is on. It was written while encrypt_cache was off, or put in
+// place of the encrypted file.
+var errPlain = errors.New("the file is not encrypted but encrypt_cache is on; delete it to start over")
+
+// Vault encrypts and decrypts files with one key. A nil Vault leaves them
+// as they are.
+type Vault struct {
+	aead cipher.AEAD
+	// migrating is set in the run that made the key, when files written
+	// before encryption was turned on are read and encrypted in place.
+	migrating bool
+}
+
+// Open returns the vault for the encrypt_cache setting mode, getting its
+// key from the credential store or the passphrase. An empty mode returns
+// a nil Vault.
+func Open(mode, passphraseCommand string) (*Vault, error) {
+	var key []byte
+	var made bool
+	var err error
+	switch mode {
+	case "":
+		return nil, nil
+	case Keyring:
+		key, made, err = keyringKey()
+	case Passphrase:
+		key, made, err = passphraseKey(passphraseCommand)
+	default:
+		return nil, fmt.Errorf("unknown encrypt_cache %q; use %q or %q", mode, Keyring, Passphrase)
+	}
+	if err != nil {
+		return nil, err
+	}
+	v, err := newVault(key)
+	if err != nil {
+		return nil, err
+	}
+	v.migrating = made
+	return v, nil
+}
+
+func newVault(key []byte) (*Vault, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Vault{aead: aead}, nil
+}
+
+// lock keeps two instances from making different keys at once the first
+// time one is needed.
+func lock() (func(), error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return nil, err
+	}
+	return safefile.Lock(filepath.Join(dir, "vault"))
+}
+
+// keyringKey returns the key kept in the credential store, making one the
+// first time. made reports whether it did.
+func keyringKey() (key []byte, made bool, err error) {
+	unlock, err := lock()
+	if err != nil {
+		return nil, false, fmt.Errorf("unable to get the cache key: %v", err)
+	}
+	defer unlock()
+
+	saved, err := keyring.Get(keyringService, keyringUser)
+	if err == nil {
+		key, err := base64.StdEncoding.DecodeString(saved)
+		if err != nil || len(key) != keySize {
+			return nil, false, errors.New("the cache key in the credential store is damaged")
+		}
+		return key, false, nil
+	}
+	if !errors.Is(err, keyring.ErrNotFound) {
+		return nil, false, fmt.Errorf("unable to get the cache key from the credential store: %v", err)
+	}
+	key = make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, false, err
+	}
+	if err := keyring.Set(keyringService, keyringUser, base64.StdEncoding.EncodeToString(key)); err != nil {
+		return nil, false, fmt.Errorf("unable to save the cache key in the credential store: %v", err)
+	}
+	return key, true, nil
+}
+
+// passphraseParams is vault.json, which holds what is needed to derive the
+// key from the passphrase again and to tell a wrong passphrase.
+type passphraseParams struct {
+	Salt []byte `json:"salt"`
+	// Check is checkText encrypted with the key.
+	Check []byte `json:"check"`
+}
+
+const (
+	checkText = "gmail-tui"
+	checkName = "vault.json"
+)
+
+// passphraseKey derives the key from the passphrase, setting it the first
+// time. made reports whether it did.
+func passphraseKey(command string) (key []byte, made bool, err error) {
+	unlock, err := lock()
+	if err != nil {
+		return nil, false, fmt.Errorf("unable to get the cache key: %v", err)
+	}
+	defer unlock()
+
+	dir, err := config.Dir()
+	if err != nil {
+		return nil, false, err
+	}
+	path := filepath.Join(dir, "vault.json")
+	var params passphraseParams
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, &params); err != nil {
+			return nil, false, fmt.Errorf("unable to parse %s: %v", path, err)
+		}
+	case !os.IsNotExist(err):
+		return nil, false, fmt.Errorf("unable to read %s: %v", path, err)
+	}
+	first := params.Salt == nil
+
+	passphrase, err := readPassphrase(command, first)
+	if err != nil {
+		return nil, false, err
+	}
+	if first {
+		params.Salt = make([]byte, 16)
+		if _, err := rand.Read(params.Salt); err != nil {
+			return nil, false, err
+		}
+	}
+	key, err = scrypt.Key(passphrase, params.Salt, 1<<15, 8, 1, keySize)
+	if err != nil {
+		return nil, false, err
+	}
+	v, err := newVault(key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if !first {
+		if text, err := v.open(params.Check, checkName); err != nil || string(text) != checkText {
+			return nil, false, errors.New("wrong passphrase for the gmail-tui cache")
+		}
+		return key, false, nil
+	}
+	if params.Check, err = v.seal([]byte(checkText), checkName); err != nil {
+		return nil, false, err
+	}
+	if data, err = json.MarshalIndent(params, "", "  "); err != nil {
+		return nil, false, err
+	}
+	if err := safefile.WriteFile(path, data, 0600); err != nil {
+		return nil, false, fmt.Errorf("unable to save %s: %v", path, err)
+	}
+	return key, true, nil
+}
+
+// readPassphrase runs command for the passphrase or, without one, asks for
+// it on the terminal, twice if it is being set.
+func readPassphrase(command string, confirm bool) ([]byte, error) {
+	if command != "" {
+		out, err := exec.Command("sh", "-c", command).Output()
+		if err != nil {
+			return nil, fmt.Errorf("unable to run the passphrase command: %v", err)
+		}
+		out = bytes.TrimRight(out, "\r\n")
+		if len(out) == 0 {
+			return nil, errors.New("the passphrase command printed nothing")
+		}
+		return out, nil
+	}
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return nil, errors.New("no passphrase_command in the config and no terminal to ask for the cache passphrase on")
+	}
+	ask := func(prompt string) ([]byte, error) {
+		fmt.Fprint(os.Stderr, prompt)
+		p, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the passphrase: %v", err)
+		}
+		return p, nil
+	}
+	prompt := "Passphrase for the gmail-tui cache: "
+	if confirm {
+		prompt = "New passphrase for the gmail-tui cache: "
+	}
+	p, err := ask(prompt)
+	if err != nil {
+		return nil, err
+	}
+	if len(strings.TrimSpace(string(p))) == 0 {
+		return nil, errors.New("the passphrase is empty")
+	}
+	if confirm {
+		again, err := ask("Repeat it: ")
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(p, again) {
+			return nil, errors.New("the passphrases don't match")
+		}
+	}
+	return p, nil
+}
+
+// additionalData binds what is encrypted to the name of the file it is
+// for, so that one encrypted file can't be passed off as another.
+func additionalData(name string) []byte {
+	return append(bytes.Clone(magic), name...)
+}
+
+func (v *Vault) seal(data []byte, name string) ([]byte, error) {
+	nonce := make([]byte, v.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("unable to encrypt the file: %v", err)
+	}
+	out := append(bytes.Clone(magic), nonce...)
+	return v.aead.Seal(out, nonce, data, additionalData(name)), nil
+}
+
+func (v *Vault) open(data []byte, name string) ([]byte, error) {
+	if !bytes.HasPrefix(data, magic) {
+		if v != nil {
+			return nil, errPlain
+		}
+		return data, nil
+	}
+	if v == nil {
+		return nil, errEncrypted
+	}
+	data = data[len(magic):]
+	n := v.aead.NonceSize()
+	if len(data) < n {
+		return nil, errors.New("the encrypted file is cut short")
+	}
+	plain, err := v.aead.Open(nil, data[:n], data[n:], additionalData(name))
+	if err != nil {
+		return nil, errors.New("unable to decrypt the file: it was encrypted with another key, for another file, or is damaged")
+	}
+	return plain, nil
+}
+
+// Enabled reports whether files are encrypted.
+func (v *Vault) Enabled() bool {
+	return v != nil
+}
+
+// ReadFile reads path, decrypting it. With v nil, the file must not be
+// encrypted; otherwise it must be, except in the run that turned
+// encryption on, which encrypts it in place.
+func (v *Vault) ReadFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if v != nil && v.migrating && !bytes.HasPrefix(data, magic) {
+		if err := v.WriteFile(path, data, 0600); err != nil {
+			return nil, err
+		}
+		return data, nil
+	}
+	data, err = v.open(data, filepath.Base(path))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %v", path, err)
+	}
+	return data, nil
+}
+
+// WriteFile encrypts data, unless v is nil, and writes it to path as
+// safefile.WriteFile does.
+func (v *Vault) WriteFile(path string, data []byte, perm os.FileMode) error {
+	if v != nil {
+		var err error
+		if data, err = v.seal(data, filepath.Base(path)); err != nil {
+			return fmt.Errorf("unable to write %s: %v", path, err)
+		}
+	}
+	return safefile.WriteFile(path, data, perm)
+}
@@ -0,0 +1,117 @@
+package vault
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testVault(t *testing.T, fill byte) *Vault {
+	t.Helper()
+	v, err := newVault(bytes.Repeat([]byte{fill}, keySize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestRoundTrip(t *testing.T) {
+	v := testVault(t, 1)
+	path := filepath.Join(t.TempDir(), "cache.json")
+	want := []byte(`{"subject":"Minutes"}`)
+	if err := v.WriteFile(path, want, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(raw, magic) || bytes.Contains(raw, []byte("Minutes")) {
+		t.Errorf("the file on disk isn't encrypted: %q", raw)
+	}
+
+	got, err := v.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("read %q, want %q", got, want)
+	}
+}
+
+func TestOpenRefuses(t *testing.T) {
+	v := testVault(t, 1)
+	sealed, err := v.seal([]byte("secret"), "token.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		v    *Vault
+		data []byte
+		file string
+	}{
+		{"wrong key", testVault(t, 2), sealed, "token.json"},
+		{"wrong file name", v, sealed, "outbox.json"},
+		{"truncated", v, sealed[:len(sealed)-1], "token.json"},
+		{"cut before the nonce", v, sealed[:len(magic)+4], "token.json"},
+		{"flipped bit", v, append(bytes.Clone(sealed[:len(sealed)-1]), sealed[len(sealed)-1]^1), "token.json"},
+		{"encrypted while off", nil, sealed, "token.json"},
+	}
+	for _, tt := range tests {
+		if plain, err := tt.v.open(tt.data, tt.file); err == nil {
+			t.Errorf("%s: opened as %q", tt.name, plain)
+		}
+	}
+	if plain, err := v.open(sealed, "token.json"); err != nil || string(plain) != "secret" {
+		t.Errorf("open = %q, %v", plain, err)
+	}
+}
+
+func TestPlainFileRefused(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token.json")
+	if err := os.WriteFile(path, []byte(`{"access_token":"x"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	v := testVault(t, 1)
+	if _, err := v.open([]byte(`{"access_token":"x"}`), "token.json"); !errors.Is(err, errPlain) {
+		t.Errorf("open of a plain file = %v, want errPlain", err)
+	}
+	if _, err := v.ReadFile(path); err == nil {
+		t.Error("read an unencrypted file while encryption is on")
+	}
+
+	var off *Vault
+	if got, err := off.ReadFile(path); err != nil || string(got) != `{"access_token":"x"}` {
+		t.Errorf("read with encryption off = %q, %v", got, err)
+	}
+}
+
+func TestPlainFileMigrated(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token.json")
+	want := []byte(`{"access_token":"x"}`)
+	if err := os.WriteFile(path, want, 0600); err != nil {
+		t.Fatal(err)
+	}
+	v := testVault(t, 1)
+	v.migrating = true
+	got, err := v.ReadFile(path)
+	if err != nil || !bytes.Equal(got, want) {
+		t.Fatalf("read = %q, %v", got, err)
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(raw, magic) {
+		t.Errorf("the file wasn't encrypted in place: %q", raw)
+	}
+	v.migrating = false
+	if got, err := v.ReadFile(path); err != nil || !bytes.Equal(got, want) {
+		t.Errorf("read after migrating = %q, %v", got, err)
+	}
+}